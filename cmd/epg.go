@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/db/model"
+	"github.com/iamseth/tiny-headend/internal/epg"
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var epgOutput string
+
+var epgCmd = &cobra.Command{
+	Use:   "epg",
+	Short: "Work with the XMLTV programme guide",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// The guide may be written to stdout, so keep logs out of it.
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			Level: slog.LevelInfo,
+		})))
+		return rootCmd.PersistentPreRunE(cmd, args)
+	},
+}
+
+var epgExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the XMLTV guide for all channels",
+	Long: `Write the XMLTV guide for all channels, covering TINY_HEADEND_EPG_WINDOW from now.
+The guide is the same document served at /epg.xml.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g, err := openDatabase()
+		if err != nil {
+			return err
+		}
+		defer closeDatabase(g)
+
+		channelRepo := model.NewChannelRepo(g)
+		timeline := service.NewTimelineService(channelRepo, model.NewChannelItemRepo(g), appConfig.TimelineEpoch,
+			service.WithSchedule(model.NewScheduleBlockRepo(g)), service.WithFiller(model.NewFillerPoolRepo(g)),
+			service.WithCollections(model.NewCollectionRepo(g)), service.WithCursors(model.NewCursorRepo(g)))
+		gen := epg.New(
+			service.NewChannelService(channelRepo),
+			timeline,
+			appConfig.EPGWindow,
+		)
+		tv, err := gen.Build(cmd.Context(), time.Now(), appConfig.BaseURL)
+		if err != nil {
+			return fmt.Errorf("failed to build epg: %w", err)
+		}
+
+		if epgOutput == "" || epgOutput == "-" {
+			return tv.Write(cmd.OutOrStdout())
+		}
+		return writeEPGFile(epgOutput, tv)
+	},
+}
+
+func writeEPGFile(path string, tv *epg.TV) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create epg file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close epg file: %w", closeErr)
+		}
+	}()
+	return tv.Write(f)
+}
+
+func registerEPGCommands() {
+	epgExportCmd.Flags().StringVarP(&epgOutput, "output", "o", "-", "file to write the guide to (- for stdout)")
+	epgCmd.AddCommand(epgExportCmd)
+	rootCmd.AddCommand(epgCmd)
+}
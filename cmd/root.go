@@ -5,10 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	nethttp "net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -16,17 +20,31 @@ import (
 	"github.com/iamseth/tiny-headend/internal/config"
 	"github.com/iamseth/tiny-headend/internal/db"
 	"github.com/iamseth/tiny-headend/internal/db/model"
+	"github.com/iamseth/tiny-headend/internal/epg"
 	tinyhttp "github.com/iamseth/tiny-headend/internal/http"
+	"github.com/iamseth/tiny-headend/internal/http/handler"
+	"github.com/iamseth/tiny-headend/internal/multicast"
+	"github.com/iamseth/tiny-headend/internal/probe"
+	"github.com/iamseth/tiny-headend/internal/scanner"
 	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/ssdp"
+	"github.com/iamseth/tiny-headend/internal/stream"
+	"github.com/iamseth/tiny-headend/internal/transcode"
 	"github.com/spf13/cobra"
 	"gorm.io/gorm"
 )
 
 var (
 	cfgFile   string
+	httpAddr  string
+	dbPath    string
 	appConfig config.Config
 )
 
+// cursorSaveInterval is how often channels' cursors are moved on to the programme that last
+// finished airing.
+const cursorSaveInterval = time.Minute
+
 var registerFlagsOnce sync.Once
 
 var rootCmd = &cobra.Command{
@@ -38,14 +56,19 @@ It supports a variety of media formats and can be easily configured using a YAML
 Configuration precedence:
   1. CLI flags
   2. Environment variables
-  3. Built-in defaults
+  3. Config file (--config)
+  4. Built-in defaults
 
 Durations use Go duration format (examples: 500ms, 5s, 2m).
 
 Environment variables:
   TINY_HEADEND_DB_PATH
   TINY_HEADEND_HTTP_ADDR
+  TINY_HEADEND_BASE_URL
   TINY_HEADEND_CONFIG_PATH
+  TINY_HEADEND_SCAN_ENABLED
+  TINY_HEADEND_SCAN_PATH
+  TINY_HEADEND_SCAN_INTERVAL
   TINY_HEADEND_DB_PING_TIMEOUT
   TINY_HEADEND_HEALTH_PING_TIMEOUT
   TINY_HEADEND_SERVER_READ_HEADER_TIMEOUT
@@ -54,28 +77,42 @@ Environment variables:
   TINY_HEADEND_SERVER_IDLE_TIMEOUT
   TINY_HEADEND_SERVER_MAX_HEADER_BYTES
   TINY_HEADEND_HEALTH_LOG_INTERVAL
-  TINY_HEADEND_SERVER_SHUTDOWN_TIMEOUT`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		g, err := db.Open(appConfig.DBPath)
+  TINY_HEADEND_SERVER_SHUTDOWN_TIMEOUT
+  TINY_HEADEND_TIMELINE_EPOCH
+  TINY_HEADEND_EPG_WINDOW
+  TINY_HEADEND_DEVICE_ID
+  TINY_HEADEND_FRIENDLY_NAME
+  TINY_HEADEND_TUNER_COUNT
+  TINY_HEADEND_SSDP_ENABLED
+  TINY_HEADEND_SSDP_NOTIFY_INTERVAL
+  TINY_HEADEND_HLS_SEGMENT_DURATION
+  TINY_HEADEND_HLS_WINDOW
+  TINY_HEADEND_HLS_SESSION_IDLE
+  TINY_HEADEND_FFMPEG_PATH`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		loaded, err := config.Load(cfgFile)
 		if err != nil {
-			return fmt.Errorf("failed to open database: %w", err)
+			return fmt.Errorf("failed to load configuration: %w", err)
 		}
-		defer func() {
-			if closeErr := db.Close(g); closeErr != nil {
-				slog.Error("failed to close database", "error", closeErr)
-			}
-		}()
+		if err := applyFlagOverrides(cmd, &loaded); err != nil {
+			return err
+		}
+		appConfig = loaded
 
-		pingCtx, cancelPing := context.WithTimeout(context.Background(), appConfig.DBPingTimeout)
-		if err := db.Ping(pingCtx, g); err != nil {
-			cancelPing()
-			return fmt.Errorf("database ping failed: %w", err)
+		slog.Info("configuration loaded", "config_path", appConfig.ConfigPath)
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scanRoots := filepath.SplitList(appConfig.ScanPath)
+		if appConfig.ScanEnabled && len(scanRoots) == 0 {
+			return errors.New("library scanning is enabled but no scan path is configured")
 		}
-		cancelPing()
 
-		if err := db.Migrate(g); err != nil {
-			return fmt.Errorf("failed to migrate database: %w", err)
+		g, err := openDatabase()
+		if err != nil {
+			return err
 		}
+		defer closeDatabase(g)
 
 		healthCheck := func(ctx context.Context) error {
 			pingCtx, cancel := context.WithTimeout(ctx, appConfig.HealthPingTimeout)
@@ -83,10 +120,48 @@ Environment variables:
 			return db.Ping(pingCtx, g)
 		}
 
+		contentRepo := model.NewContentRepo(g)
+		channelRepo := model.NewChannelRepo(g)
+		itemRepo := model.NewChannelItemRepo(g)
+		blockRepo := model.NewScheduleBlockRepo(g)
+		poolRepo := model.NewFillerPoolRepo(g)
+		collectionRepo := model.NewCollectionRepo(g)
+		cursorRepo := model.NewCursorRepo(g)
+		contentSvc := service.NewContentService(contentRepo, service.WithProber(probe.Prober{}), service.WithSearch(contentRepo))
+		profiles := transcodeProfiles(appConfig.TranscodeProfiles)
+		channelSvc := service.NewChannelService(channelRepo, service.WithProfiles(slices.Collect(maps.Keys(profiles))...))
+		timelineSvc := service.NewTimelineService(channelRepo, itemRepo, appConfig.TimelineEpoch, service.WithSchedule(blockRepo), service.WithFiller(poolRepo), service.WithCollections(collectionRepo), service.WithCursors(cursorRepo))
+		streamOpts := []stream.Option{stream.WithChannels(channelSvc)}
+		if len(profiles) > 0 {
+			ffmpeg := transcode.NewFFmpeg(appConfig.FFmpegPath)
+			streamOpts = append(streamOpts, stream.WithTranscoder(channelSvc, ffmpeg, profiles))
+		}
+		broadcaster := stream.NewBroadcaster(stream.New(timelineSvc, streamOpts...))
+		var sender *multicast.Sender
+		if len(appConfig.MulticastOutputs) > 0 {
+			sender = multicast.New(broadcaster, multicastOutputs(appConfig.MulticastOutputs))
+		}
 		deps := tinyhttp.Deps{
-			Content:     service.NewContentService(model.NewContentRepo(g)),
+			Content:     contentSvc,
+			Channel:     channelSvc,
+			Playlist:    service.NewPlaylistService(channelRepo, contentRepo, itemRepo, service.WithPlaylistCollections(collectionRepo), service.WithPlaylistTimeline(timelineSvc)),
+			Schedule:    service.NewScheduleService(channelRepo, contentRepo, blockRepo, service.WithScheduleCollections(collectionRepo)),
+			Filler:      service.NewFillerService(channelRepo, contentRepo, poolRepo),
+			Collections: service.NewCollectionService(contentRepo, collectionRepo, contentRepo),
+			Shows:       service.NewShowService(model.NewShowRepo(g)),
+			Logos:       service.NewLogoService(model.NewLogoRepo(g)),
+			Timeline:    timelineSvc,
+			EPG:         epg.New(channelSvc, timelineSvc, appConfig.EPGWindow),
+			Stream:      broadcaster,
+			HLS:         stream.NewSegmenter(timelineSvc, appConfig.HLSSegmentDuration, appConfig.HLSWindow, streamOpts...),
+			Sessions:    service.NewSessionRegistry(appConfig.TunerCount),
 			HealthCheck: healthCheck,
 		}
+		if sender != nil {
+			deps.HealthDetails = func() map[string]any {
+				return map[string]any{"multicast": sender.Status()}
+			}
+		}
 
 		srv := tinyhttp.New(tinyhttp.Config{
 			Addr:              appConfig.HTTPAddr,
@@ -95,6 +170,14 @@ Environment variables:
 			WriteTimeout:      appConfig.WriteTimeout,
 			IdleTimeout:       appConfig.IdleTimeout,
 			MaxHeaderBytes:    appConfig.MaxHeaderBytes,
+			BaseURL:           appConfig.BaseURL,
+			LibraryRoots:      scanRoots,
+			HLSSessionIdle:    appConfig.HLSSessionIdle,
+			Device: handler.Device{
+				DeviceID:     appConfig.DeviceID,
+				FriendlyName: appConfig.FriendlyName,
+				TunerCount:   appConfig.TunerCount,
+			},
 		}, deps)
 
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -102,6 +185,42 @@ Environment variables:
 
 		errCh := make(chan error, 1)
 		startPeriodicHealthLog(ctx, g, appConfig.HealthLogInterval)
+		startCursorSaver(ctx, timelineSvc, cursorSaveInterval)
+
+		if appConfig.ScanEnabled {
+			slog.Info("starting library scanner", "roots", scanRoots, "interval", appConfig.ScanInterval)
+			go scanner.New(contentSvc, scanRoots, appConfig.ScanInterval).Run(ctx)
+		}
+
+		ssdpDone := make(chan struct{})
+		if appConfig.SSDPEnabled {
+			slog.Info("starting ssdp responder", "interval", appConfig.SSDPNotifyInterval)
+			responder := ssdp.New(ssdp.Config{
+				DeviceID:       appConfig.DeviceID,
+				BaseURL:        appConfig.BaseURL,
+				HTTPAddr:       appConfig.HTTPAddr,
+				NotifyInterval: appConfig.SSDPNotifyInterval,
+			})
+			go func() {
+				defer close(ssdpDone)
+				if err := responder.Run(ctx); err != nil {
+					slog.Error("ssdp responder stopped", "error", err)
+				}
+			}()
+		} else {
+			close(ssdpDone)
+		}
+
+		multicastDone := make(chan struct{})
+		if sender != nil {
+			slog.Info("starting multicast outputs", "outputs", len(appConfig.MulticastOutputs))
+			go func() {
+				defer close(multicastDone)
+				sender.Run(ctx)
+			}()
+		} else {
+			close(multicastDone)
+		}
 
 		go func() {
 			errCh <- srv.ListenAndServe()
@@ -122,6 +241,8 @@ Environment variables:
 				return fmt.Errorf("server shutdown error: %w", err)
 			}
 			slog.Info("server shutdown complete")
+			<-ssdpDone
+			<-multicastDone
 
 			if err := <-errCh; err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
 				return fmt.Errorf("server error: %w", err)
@@ -132,6 +253,80 @@ Environment variables:
 	},
 }
 
+// transcodeProfiles converts the configured transcode profiles, keyed by name.
+func transcodeProfiles(cfg map[string]config.TranscodeProfile) map[string]transcode.Profile {
+	profiles := make(map[string]transcode.Profile, len(cfg))
+	for name, p := range cfg {
+		profiles[name] = transcode.Profile{
+			Name:         name,
+			Width:        p.Width,
+			Height:       p.Height,
+			VideoCodec:   p.VideoCodec,
+			AudioCodec:   p.AudioCodec,
+			VideoBitrate: p.VideoBitrate,
+			AudioBitrate: p.AudioBitrate,
+		}
+	}
+	return profiles
+}
+
+// multicastOutputs converts the configured multicast outputs.
+func multicastOutputs(cfg []config.MulticastOutput) []multicast.Output {
+	outputs := make([]multicast.Output, len(cfg))
+	for i, o := range cfg {
+		outputs[i] = multicast.Output{ChannelID: o.ChannelID, Group: o.Group, TTL: o.TTL, Interface: o.Interface}
+	}
+	return outputs
+}
+
+// openDatabase opens, pings and migrates the configured database.
+func openDatabase() (*gorm.DB, error) {
+	g, err := db.Open(appConfig.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), appConfig.DBPingTimeout)
+	defer cancelPing()
+	if err := db.Ping(pingCtx, g); err != nil {
+		closeDatabase(g)
+		return nil, fmt.Errorf("database ping failed: %w", err)
+	}
+
+	if err := db.Migrate(g); err != nil {
+		closeDatabase(g)
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return g, nil
+}
+
+func closeDatabase(g *gorm.DB) {
+	if err := db.Close(g); err != nil {
+		slog.Error("failed to close database", "error", err)
+	}
+}
+
+// startCursorSaver moves channels' cursors on as their programmes finish airing, so a restart picks
+// up where programming was even if the library changed in the meantime.
+func startCursorSaver(ctx context.Context, timeline *service.TimelineService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := timeline.SaveCursors(ctx); err != nil {
+					slog.Error("failed to save channel cursors", "error", err)
+				}
+			}
+		}
+	}()
+}
+
 func startPeriodicHealthLog(ctx context.Context, g *gorm.DB, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 
@@ -165,34 +360,50 @@ func startPeriodicHealthLog(ctx context.Context, g *gorm.DB, interval time.Durat
 }
 
 func Execute() {
-	loadedConfig, err := config.LoadFromEnv()
-	if err != nil {
-		slog.Error("failed to load configuration", "error", err)
-		os.Exit(1)
-	}
-	appConfig = loadedConfig
-
-	registerFlagsOnce.Do(registerRootFlags)
+	registerFlagsOnce.Do(func() {
+		registerRootFlags()
+		registerEPGCommands()
+	})
 
 	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	})))
 
-	err = rootCmd.Execute()
-	if err != nil {
+	if err := rootCmd.Execute(); err != nil {
 		slog.Error("command execution failed", "error", err)
 		os.Exit(1)
 	}
-
-	slog.Info("config file", "path", cfgFile)
 }
 
 func registerRootFlags() {
 	rootCmd.PersistentFlags().StringVar(
 		&cfgFile,
 		"config",
-		appConfig.ConfigPath,
+		"",
 		"config file (default is $HOME/.tiny-headend.yaml)",
 	)
+	rootCmd.PersistentFlags().StringVar(&httpAddr, "http-addr", "", "HTTP bind address (overrides TINY_HEADEND_HTTP_ADDR)")
+	rootCmd.PersistentFlags().StringVar(&dbPath, "db-path", "", "SQLite database file path (overrides TINY_HEADEND_DB_PATH)")
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
+
+func applyFlagOverrides(cmd *cobra.Command, cfg *config.Config) error {
+	overrides := []struct {
+		name  string
+		value string
+		dst   *string
+	}{
+		{name: "http-addr", value: httpAddr, dst: &cfg.HTTPAddr},
+		{name: "db-path", value: dbPath, dst: &cfg.DBPath},
+	}
+	for _, o := range overrides {
+		if !cmd.Flags().Changed(o.name) {
+			continue
+		}
+		if strings.TrimSpace(o.value) == "" {
+			return fmt.Errorf("flag --%s must not be empty", o.name)
+		}
+		*o.dst = strings.TrimSpace(o.value)
+	}
+	return nil
+}
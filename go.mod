@@ -5,6 +5,7 @@ go 1.25.6
 require (
 	github.com/go-chi/chi/v5 v5.2.3
 	github.com/spf13/cobra v1.10.2
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/sqlite v1.6.0
 	gorm.io/gorm v1.31.1
 )
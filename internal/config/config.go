@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -11,6 +14,7 @@ import (
 const (
 	envDBPath              = "TINY_HEADEND_DB_PATH"
 	envHTTPAddr            = "TINY_HEADEND_HTTP_ADDR"
+	envBaseURL             = "TINY_HEADEND_BASE_URL"
 	envConfigPath          = "TINY_HEADEND_CONFIG_PATH"
 	envScanEnabled         = "TINY_HEADEND_SCAN_ENABLED"
 	envScanPath            = "TINY_HEADEND_SCAN_PATH"
@@ -24,6 +28,17 @@ const (
 	envMaxHeaderBytes      = "TINY_HEADEND_SERVER_MAX_HEADER_BYTES"
 	envHealthLogInterval   = "TINY_HEADEND_HEALTH_LOG_INTERVAL"
 	envServerShutdownTimer = "TINY_HEADEND_SERVER_SHUTDOWN_TIMEOUT"
+	envTimelineEpoch       = "TINY_HEADEND_TIMELINE_EPOCH"
+	envEPGWindow           = "TINY_HEADEND_EPG_WINDOW"
+	envDeviceID            = "TINY_HEADEND_DEVICE_ID"
+	envFriendlyName        = "TINY_HEADEND_FRIENDLY_NAME"
+	envTunerCount          = "TINY_HEADEND_TUNER_COUNT"
+	envSSDPEnabled         = "TINY_HEADEND_SSDP_ENABLED"
+	envSSDPNotifyInterval  = "TINY_HEADEND_SSDP_NOTIFY_INTERVAL"
+	envHLSSegmentDuration  = "TINY_HEADEND_HLS_SEGMENT_DURATION"
+	envHLSWindow           = "TINY_HEADEND_HLS_WINDOW"
+	envHLSSessionIdle      = "TINY_HEADEND_HLS_SESSION_IDLE"
+	envFFmpegPath          = "TINY_HEADEND_FFMPEG_PATH"
 
 	defaultDBPath            = "tiny-headend.db"
 	defaultHTTPAddr          = ":8080"
@@ -40,65 +55,172 @@ const (
 	defaultMaxHeaderBytes    = 1 << 20
 	defaultHealthLogInterval = 60 * time.Second
 	defaultShutdownTimeout   = 10 * time.Second
+	defaultEPGWindow         = 48 * time.Hour
+	defaultDeviceID          = "10A1B2C3"
+	defaultFriendlyName      = "tiny-headend"
+	defaultTunerCount        = 2
+	defaultSSDPEnabled       = true
+	defaultSSDPInterval      = 5 * time.Minute
+	defaultHLSSegmentLength  = 6 * time.Second
+	defaultHLSWindow         = 6
+	defaultHLSSessionIdle    = 30 * time.Second
+	defaultFFmpegPath        = "ffmpeg"
+	defaultMulticastTTL      = 1
 )
 
+// defaultTimelineEpoch is the instant every channel is treated as having started playing.
+var defaultTimelineEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
 type Config struct {
-	DBPath            string
-	HTTPAddr          string
-	ConfigPath        string
-	ScanEnabled       bool
-	ScanPath          string
-	ScanInterval      time.Duration
-	DBPingTimeout     time.Duration
-	HealthPingTimeout time.Duration
-	ReadHeaderTimeout time.Duration
-	ReadTimeout       time.Duration
-	WriteTimeout      time.Duration
-	IdleTimeout       time.Duration
-	MaxHeaderBytes    int
-	HealthLogInterval time.Duration
-	ShutdownTimeout   time.Duration
+	DBPath             string
+	HTTPAddr           string
+	BaseURL            string
+	ConfigPath         string
+	ScanEnabled        bool
+	ScanPath           string
+	ScanInterval       time.Duration
+	DBPingTimeout      time.Duration
+	HealthPingTimeout  time.Duration
+	ReadHeaderTimeout  time.Duration
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	IdleTimeout        time.Duration
+	MaxHeaderBytes     int
+	HealthLogInterval  time.Duration
+	ShutdownTimeout    time.Duration
+	TimelineEpoch      time.Time
+	EPGWindow          time.Duration
+	DeviceID           string
+	FriendlyName       string
+	TunerCount         int
+	SSDPEnabled        bool
+	SSDPNotifyInterval time.Duration
+	HLSSegmentDuration time.Duration
+	HLSWindow          int
+	HLSSessionIdle     time.Duration
+	FFmpegPath         string
+	TranscodeProfiles  map[string]TranscodeProfile
+	MulticastOutputs   []MulticastOutput
+}
+
+// TranscodeProfile holds the output settings channels can be transcoded with.
+type TranscodeProfile struct {
+	// Width and Height scale the video. Zero keeps the source resolution.
+	Width  int
+	Height int
+	// VideoCodec and AudioCodec name ffmpeg encoders, or copy.
+	VideoCodec string
+	AudioCodec string
+	// VideoBitrate and AudioBitrate are in kbit/s. Zero leaves the encoder's default.
+	VideoBitrate int
+	AudioBitrate int
+}
+
+// MulticastOutput pushes a channel's live stream to a UDP multicast group.
+type MulticastOutput struct {
+	ChannelID uint
+	// Group is the IPv4 multicast address and port, such as 239.0.0.1:1234.
+	Group string
+	TTL   int
+	// Interface names the network interface to send from. Empty leaves the choice to the system.
+	Interface string
 }
 
 func Default() Config {
 	return Config{
-		DBPath:            defaultDBPath,
-		HTTPAddr:          defaultHTTPAddr,
-		ConfigPath:        defaultConfigPath,
-		ScanEnabled:       defaultScanEnabled,
-		ScanPath:          defaultScanPath,
-		ScanInterval:      defaultScanInterval,
-		DBPingTimeout:     defaultDBPingTimeout,
-		HealthPingTimeout: defaultHealthPingTimeout,
-		ReadHeaderTimeout: defaultReadHeaderTimeout,
-		ReadTimeout:       defaultReadTimeout,
-		WriteTimeout:      defaultWriteTimeout,
-		IdleTimeout:       defaultIdleTimeout,
-		MaxHeaderBytes:    defaultMaxHeaderBytes,
-		HealthLogInterval: defaultHealthLogInterval,
-		ShutdownTimeout:   defaultShutdownTimeout,
+		DBPath:             defaultDBPath,
+		HTTPAddr:           defaultHTTPAddr,
+		ConfigPath:         defaultConfigPath,
+		ScanEnabled:        defaultScanEnabled,
+		ScanPath:           defaultScanPath,
+		ScanInterval:       defaultScanInterval,
+		DBPingTimeout:      defaultDBPingTimeout,
+		HealthPingTimeout:  defaultHealthPingTimeout,
+		ReadHeaderTimeout:  defaultReadHeaderTimeout,
+		ReadTimeout:        defaultReadTimeout,
+		WriteTimeout:       defaultWriteTimeout,
+		IdleTimeout:        defaultIdleTimeout,
+		MaxHeaderBytes:     defaultMaxHeaderBytes,
+		HealthLogInterval:  defaultHealthLogInterval,
+		ShutdownTimeout:    defaultShutdownTimeout,
+		TimelineEpoch:      defaultTimelineEpoch,
+		EPGWindow:          defaultEPGWindow,
+		DeviceID:           defaultDeviceID,
+		FriendlyName:       defaultFriendlyName,
+		TunerCount:         defaultTunerCount,
+		SSDPEnabled:        defaultSSDPEnabled,
+		SSDPNotifyInterval: defaultSSDPInterval,
+		HLSSegmentDuration: defaultHLSSegmentLength,
+		HLSWindow:          defaultHLSWindow,
+		HLSSessionIdle:     defaultHLSSessionIdle,
+		FFmpegPath:         defaultFFmpegPath,
 	}
 }
 
 func LoadFromEnv() (Config, error) {
 	cfg := Default()
-
-	if err := loadStringConfig(&cfg); err != nil {
+	if err := loadEnvConfig(&cfg); err != nil {
 		return Config{}, err
 	}
-	if err := loadDurationConfig(&cfg); err != nil {
+	return cfg, nil
+}
+
+// Load resolves the configuration from built-in defaults, the YAML config file and environment
+// variables, each overriding the previous one. flagPath is the value of --config; when it is empty
+// the file named by TINY_HEADEND_CONFIG_PATH is used, falling back to the default location. Only a
+// missing file at the default location is ignored.
+func Load(flagPath string) (Config, error) {
+	cfg := Default()
+
+	path, required, err := resolveConfigPath(flagPath)
+	if err != nil {
 		return Config{}, err
 	}
-	if err := loadBoolConfig(&cfg); err != nil {
-		return Config{}, err
+	expanded := os.ExpandEnv(path)
+
+	if err := LoadFile(&cfg, expanded); err != nil {
+		if required || !errors.Is(err, fs.ErrNotExist) {
+			return Config{}, err
+		}
 	}
-	if err := loadNumericConfig(&cfg); err != nil {
+	if err := loadEnvConfig(&cfg); err != nil {
 		return Config{}, err
 	}
+	cfg.ConfigPath = expanded
 
 	return cfg, nil
 }
 
+func resolveConfigPath(flagPath string) (string, bool, error) {
+	if flagPath = strings.TrimSpace(flagPath); flagPath != "" {
+		return flagPath, true, nil
+	}
+	if _, ok := os.LookupEnv(envConfigPath); ok {
+		path, err := loadString(envConfigPath, defaultConfigPath, true)
+		return path, true, err
+	}
+	return defaultConfigPath, false, nil
+}
+
+func loadEnvConfig(cfg *Config) error {
+	if err := loadStringConfig(cfg); err != nil {
+		return err
+	}
+	if err := loadDurationConfig(cfg); err != nil {
+		return err
+	}
+	if err := loadBoolConfig(cfg); err != nil {
+		return err
+	}
+	if err := loadNumericConfig(cfg); err != nil {
+		return err
+	}
+	if err := loadTimeConfig(cfg); err != nil {
+		return err
+	}
+	return nil
+}
+
 func loadStringConfig(cfg *Config) error {
 	var err error
 
@@ -122,6 +244,33 @@ func loadStringConfig(cfg *Config) error {
 		return err
 	}
 
+	cfg.BaseURL, err = loadString(envBaseURL, cfg.BaseURL, false)
+	if err != nil {
+		return err
+	}
+	cfg.BaseURL, err = normalizeBaseURL(cfg.BaseURL)
+	if err != nil {
+		return fmt.Errorf("environment variable %s %w", envBaseURL, err)
+	}
+
+	cfg.DeviceID, err = loadString(envDeviceID, cfg.DeviceID, true)
+	if err != nil {
+		return err
+	}
+	if err := validateDeviceID(cfg.DeviceID); err != nil {
+		return fmt.Errorf("environment variable %s %w", envDeviceID, err)
+	}
+
+	cfg.FriendlyName, err = loadString(envFriendlyName, cfg.FriendlyName, true)
+	if err != nil {
+		return err
+	}
+
+	cfg.FFmpegPath, err = loadString(envFFmpegPath, cfg.FFmpegPath, true)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -173,6 +322,26 @@ func loadDurationConfig(cfg *Config) error {
 		return err
 	}
 
+	cfg.EPGWindow, err = loadDuration(envEPGWindow, cfg.EPGWindow)
+	if err != nil {
+		return err
+	}
+
+	cfg.SSDPNotifyInterval, err = loadDuration(envSSDPNotifyInterval, cfg.SSDPNotifyInterval)
+	if err != nil {
+		return err
+	}
+
+	cfg.HLSSegmentDuration, err = loadDuration(envHLSSegmentDuration, cfg.HLSSegmentDuration)
+	if err != nil {
+		return err
+	}
+
+	cfg.HLSSessionIdle, err = loadDuration(envHLSSessionIdle, cfg.HLSSessionIdle)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -184,6 +353,11 @@ func loadBoolConfig(cfg *Config) error {
 		return err
 	}
 
+	cfg.SSDPEnabled, err = loadBool(envSSDPEnabled, cfg.SSDPEnabled)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -195,6 +369,27 @@ func loadNumericConfig(cfg *Config) error {
 		return err
 	}
 
+	cfg.TunerCount, err = loadInt(envTunerCount, cfg.TunerCount)
+	if err != nil {
+		return err
+	}
+
+	cfg.HLSWindow, err = loadInt(envHLSWindow, cfg.HLSWindow)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func loadTimeConfig(cfg *Config) error {
+	var err error
+
+	cfg.TimelineEpoch, err = loadTime(envTimelineEpoch, cfg.TimelineEpoch)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -274,3 +469,46 @@ func loadInt(key string, defaultValue int) (int, error) {
 
 	return parsed, nil
 }
+
+func loadTime(key string, defaultValue time.Time) (time.Time, error) {
+	raw, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue, nil
+	}
+
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("environment variable %s must not be empty", key)
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse environment variable %s as RFC 3339 time: %w", key, err)
+	}
+
+	return parsed, nil
+}
+
+// normalizeBaseURL checks that raw is an absolute http(s) URL and strips any trailing slash. An
+// empty value is allowed and means "derive from the request".
+func normalizeBaseURL(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("must be an absolute http or https URL")
+	}
+	return strings.TrimRight(raw, "/"), nil
+}
+
+// validateDeviceID checks for the eight hex digits HDHomeRun clients expect.
+func validateDeviceID(id string) error {
+	if len(id) != 8 {
+		return errors.New("must be 8 hexadecimal digits")
+	}
+	if _, err := strconv.ParseUint(id, 16, 32); err != nil {
+		return errors.New("must be 8 hexadecimal digits")
+	}
+	return nil
+}
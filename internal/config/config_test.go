@@ -1,6 +1,7 @@
 package config
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -9,7 +10,11 @@ import (
 func TestLoadFromEnvOverridesDefaults(t *testing.T) {
 	t.Setenv(envDBPath, "db.sqlite")
 	t.Setenv(envHTTPAddr, ":9090")
+	t.Setenv(envBaseURL, "http://headend.lan:9090/")
 	t.Setenv(envConfigPath, "/tmp/tiny-headend.yaml")
+	t.Setenv(envScanEnabled, "true")
+	t.Setenv(envScanPath, "/srv/media")
+	t.Setenv(envScanInterval, "1m")
 	t.Setenv(envDBPingTimeout, "4s")
 	t.Setenv(envHealthPingTimeout, "3s")
 	t.Setenv(envReadHeaderTimeout, "1s")
@@ -19,6 +24,17 @@ func TestLoadFromEnvOverridesDefaults(t *testing.T) {
 	t.Setenv(envMaxHeaderBytes, "65536")
 	t.Setenv(envHealthLogInterval, "2m")
 	t.Setenv(envServerShutdownTimer, "15s")
+	t.Setenv(envTimelineEpoch, "2025-06-01T12:00:00Z")
+	t.Setenv(envEPGWindow, "24h")
+	t.Setenv(envDeviceID, "ABCDEF01")
+	t.Setenv(envFriendlyName, "Den Tuner")
+	t.Setenv(envTunerCount, "4")
+	t.Setenv(envSSDPEnabled, "false")
+	t.Setenv(envSSDPNotifyInterval, "10m")
+	t.Setenv(envHLSSegmentDuration, "4s")
+	t.Setenv(envHLSWindow, "10")
+	t.Setenv(envHLSSessionIdle, "45s")
+	t.Setenv(envFFmpegPath, "/usr/local/bin/ffmpeg")
 
 	cfg, err := LoadFromEnv()
 	if err != nil {
@@ -26,20 +42,35 @@ func TestLoadFromEnvOverridesDefaults(t *testing.T) {
 	}
 
 	want := Config{
-		DBPath:            "db.sqlite",
-		HTTPAddr:          ":9090",
-		ConfigPath:        "/tmp/tiny-headend.yaml",
-		DBPingTimeout:     4 * time.Second,
-		HealthPingTimeout: 3 * time.Second,
-		ReadHeaderTimeout: time.Second,
-		ReadTimeout:       6 * time.Second,
-		WriteTimeout:      7 * time.Second,
-		IdleTimeout:       5 * time.Minute,
-		MaxHeaderBytes:    65536,
-		HealthLogInterval: 2 * time.Minute,
-		ShutdownTimeout:   15 * time.Second,
+		DBPath:             "db.sqlite",
+		HTTPAddr:           ":9090",
+		BaseURL:            "http://headend.lan:9090",
+		ConfigPath:         "/tmp/tiny-headend.yaml",
+		ScanEnabled:        true,
+		ScanPath:           "/srv/media",
+		ScanInterval:       time.Minute,
+		DBPingTimeout:      4 * time.Second,
+		HealthPingTimeout:  3 * time.Second,
+		ReadHeaderTimeout:  time.Second,
+		ReadTimeout:        6 * time.Second,
+		WriteTimeout:       7 * time.Second,
+		IdleTimeout:        5 * time.Minute,
+		MaxHeaderBytes:     65536,
+		HealthLogInterval:  2 * time.Minute,
+		ShutdownTimeout:    15 * time.Second,
+		TimelineEpoch:      time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC),
+		EPGWindow:          24 * time.Hour,
+		DeviceID:           "ABCDEF01",
+		FriendlyName:       "Den Tuner",
+		TunerCount:         4,
+		SSDPEnabled:        false,
+		SSDPNotifyInterval: 10 * time.Minute,
+		HLSSegmentDuration: 4 * time.Second,
+		HLSWindow:          10,
+		HLSSessionIdle:     45 * time.Second,
+		FFmpegPath:         "/usr/local/bin/ffmpeg",
 	}
-	if cfg != want {
+	if !reflect.DeepEqual(cfg, want) {
 		t.Fatalf("unexpected config: got %+v want %+v", cfg, want)
 	}
 }
@@ -69,6 +100,7 @@ func TestLoadFromEnvReturnsErrorOnEmptyRequiredValue(t *testing.T) {
 }
 
 func TestLoadFromEnvReturnsErrorOnInvalidBool(t *testing.T) {
+	t.Setenv(envScanEnabled, "maybe")
 
 	_, err := LoadFromEnv()
 	if err == nil {
@@ -84,6 +116,8 @@ func TestLoadStringConfigReturnsErrorForRequiredVariables(t *testing.T) {
 		{name: "db path", key: envDBPath},
 		{name: "http addr", key: envHTTPAddr},
 		{name: "config path", key: envConfigPath},
+		{name: "device id", key: envDeviceID},
+		{name: "friendly name", key: envFriendlyName},
 	}
 
 	for _, tt := range tests {
@@ -105,6 +139,7 @@ func TestLoadDurationConfigReturnsErrorForEachDurationVariable(t *testing.T) {
 		name string
 		key  string
 	}{
+		{name: "scan interval", key: envScanInterval},
 		{name: "db ping timeout", key: envDBPingTimeout},
 		{name: "health ping timeout", key: envHealthPingTimeout},
 		{name: "read header timeout", key: envReadHeaderTimeout},
@@ -113,6 +148,10 @@ func TestLoadDurationConfigReturnsErrorForEachDurationVariable(t *testing.T) {
 		{name: "idle timeout", key: envIdleTimeout},
 		{name: "health log interval", key: envHealthLogInterval},
 		{name: "shutdown timeout", key: envServerShutdownTimer},
+		{name: "epg window", key: envEPGWindow},
+		{name: "ssdp notify interval", key: envSSDPNotifyInterval},
+		{name: "hls segment duration", key: envHLSSegmentDuration},
+		{name: "hls session idle", key: envHLSSessionIdle},
 	}
 
 	for _, tt := range tests {
@@ -131,15 +170,19 @@ func TestLoadDurationConfigReturnsErrorForEachDurationVariable(t *testing.T) {
 }
 
 func TestLoadNumericConfigReturnsErrorOnInvalidValue(t *testing.T) {
-	t.Setenv(envMaxHeaderBytes, "0")
-	cfg := Default()
+	for _, key := range []string{envMaxHeaderBytes, envTunerCount, envHLSWindow} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, "0")
+			cfg := Default()
 
-	err := loadNumericConfig(&cfg)
-	if err == nil {
-		t.Fatal("expected error for max header bytes")
-	}
-	if !strings.Contains(err.Error(), envMaxHeaderBytes) {
-		t.Fatalf("expected error mentioning %s, got %v", envMaxHeaderBytes, err)
+			err := loadNumericConfig(&cfg)
+			if err == nil {
+				t.Fatalf("expected error for %s", key)
+			}
+			if !strings.Contains(err.Error(), key) {
+				t.Fatalf("expected error mentioning %s, got %v", key, err)
+			}
+		})
 	}
 }
 
@@ -282,3 +325,62 @@ func TestLoadInt(t *testing.T) {
 		}
 	})
 }
+
+func TestLoadTime(t *testing.T) {
+	const key = "TINY_HEADEND_TEST_TIME"
+	fallback := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		set     bool
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "unset uses default", want: fallback},
+		{name: "valid", value: "2024-03-04T05:06:07+02:00", set: true, want: time.Date(2024, time.March, 4, 3, 6, 7, 0, time.UTC)},
+		{name: "empty", value: " ", set: true, wantErr: true},
+		{name: "not rfc3339", value: "2024-03-04", set: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.set {
+				t.Setenv(key, tt.value)
+			}
+			got, err := loadTime(key, fallback)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadFromEnvRejectsInvalidBaseURL(t *testing.T) {
+	for _, value := range []string{"headend.lan:8080", "ftp://headend.lan", "http://"} {
+		t.Setenv(envBaseURL, value)
+		_, err := LoadFromEnv()
+		if err == nil || !strings.Contains(err.Error(), envBaseURL) {
+			t.Fatalf("%q: expected error mentioning %s, got %v", value, envBaseURL, err)
+		}
+	}
+}
+
+func TestLoadFromEnvRejectsInvalidDeviceID(t *testing.T) {
+	for _, value := range []string{"1234567", "123456789", "GHIJKLMN"} {
+		t.Setenv(envDeviceID, value)
+		_, err := LoadFromEnv()
+		if err == nil || !strings.Contains(err.Error(), envDeviceID) {
+			t.Fatalf("%q: expected error mentioning %s, got %v", value, envDeviceID, err)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig mirrors the YAML config file layout. Pointer fields distinguish keys that were
+// omitted from keys explicitly set to their zero value.
+type fileConfig struct {
+	DB        fileDBConfig        `yaml:"db"`
+	Server    fileServerConfig    `yaml:"server"`
+	Health    fileHealthConfig    `yaml:"health"`
+	Scan      fileScanConfig      `yaml:"scan"`
+	Timeline  fileTimelineConfig  `yaml:"timeline"`
+	EPG       fileEPGConfig       `yaml:"epg"`
+	HDHomeRun fileHDHomeRunConfig `yaml:"hdhomerun"`
+	SSDP      fileSSDPConfig      `yaml:"ssdp"`
+	HLS       fileHLSConfig       `yaml:"hls"`
+	Transcode fileTranscodeConfig `yaml:"transcode"`
+	Multicast fileMulticastConfig `yaml:"multicast"`
+}
+
+type fileDBConfig struct {
+	Path        *string       `yaml:"path"`
+	PingTimeout *fileDuration `yaml:"ping_timeout"`
+}
+
+type fileServerConfig struct {
+	Addr              *string       `yaml:"addr"`
+	BaseURL           *string       `yaml:"base_url"`
+	ReadHeaderTimeout *fileDuration `yaml:"read_header_timeout"`
+	ReadTimeout       *fileDuration `yaml:"read_timeout"`
+	WriteTimeout      *fileDuration `yaml:"write_timeout"`
+	IdleTimeout       *fileDuration `yaml:"idle_timeout"`
+	MaxHeaderBytes    *int          `yaml:"max_header_bytes"`
+	ShutdownTimeout   *fileDuration `yaml:"shutdown_timeout"`
+}
+
+type fileHealthConfig struct {
+	PingTimeout *fileDuration `yaml:"ping_timeout"`
+	LogInterval *fileDuration `yaml:"log_interval"`
+}
+
+type fileScanConfig struct {
+	Enabled  *bool         `yaml:"enabled"`
+	Path     *string       `yaml:"path"`
+	Interval *fileDuration `yaml:"interval"`
+}
+
+type fileTimelineConfig struct {
+	Epoch *string `yaml:"epoch"`
+}
+
+type fileEPGConfig struct {
+	Window *fileDuration `yaml:"window"`
+}
+
+type fileHDHomeRunConfig struct {
+	DeviceID     *string `yaml:"device_id"`
+	FriendlyName *string `yaml:"friendly_name"`
+	TunerCount   *int    `yaml:"tuner_count"`
+}
+
+type fileSSDPConfig struct {
+	Enabled        *bool         `yaml:"enabled"`
+	NotifyInterval *fileDuration `yaml:"notify_interval"`
+}
+
+type fileHLSConfig struct {
+	SegmentDuration *fileDuration `yaml:"segment_duration"`
+	Window          *int          `yaml:"window"`
+	SessionIdle     *fileDuration `yaml:"session_idle"`
+}
+
+type fileTranscodeConfig struct {
+	FFmpegPath *string                         `yaml:"ffmpeg_path"`
+	Profiles   map[string]fileTranscodeProfile `yaml:"profiles"`
+}
+
+type fileTranscodeProfile struct {
+	Width        int    `yaml:"width"`
+	Height       int    `yaml:"height"`
+	VideoCodec   string `yaml:"video_codec"`
+	VideoBitrate int    `yaml:"video_bitrate"`
+	AudioCodec   string `yaml:"audio_codec"`
+	AudioBitrate int    `yaml:"audio_bitrate"`
+}
+
+type fileMulticastConfig struct {
+	Outputs []fileMulticastOutput `yaml:"outputs"`
+}
+
+type fileMulticastOutput struct {
+	ChannelID uint   `yaml:"channel_id"`
+	Group     string `yaml:"group"`
+	TTL       *int   `yaml:"ttl"`
+	Interface string `yaml:"interface"`
+}
+
+// maxProfileNameLength matches the column channels store their profile name in.
+const maxProfileNameLength = 64
+
+var unknownFieldPattern = regexp.MustCompile(`^(line \d+): field (\S+) not found in type \S+$`)
+
+type fileDuration time.Duration
+
+func (d *fileDuration) UnmarshalYAML(value *yaml.Node) error {
+	var raw string
+	if err := value.Decode(&raw); err != nil {
+		return fmt.Errorf("line %d: duration must be a string such as 5s: %w", value.Line, err)
+	}
+	parsed, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = fileDuration(parsed)
+	return nil
+}
+
+// LoadFile merges the YAML file at path into cfg. Keys missing from the file leave cfg untouched,
+// and keys the file format does not define are rejected.
+func LoadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file %s: %w", path, err)
+	}
+
+	var fc fileConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("parse config file %s: %s", path, describeTypeErrors(typeErr.Errors))
+		}
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	if err := fc.apply(cfg); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (fc fileConfig) apply(cfg *Config) error {
+	if err := applyString(&cfg.DBPath, fc.DB.Path, "db.path", true); err != nil {
+		return err
+	}
+	if err := applyString(&cfg.HTTPAddr, fc.Server.Addr, "server.addr", true); err != nil {
+		return err
+	}
+	if err := applyString(&cfg.ScanPath, fc.Scan.Path, "scan.path", false); err != nil {
+		return err
+	}
+	if err := applyString(&cfg.BaseURL, fc.Server.BaseURL, "server.base_url", false); err != nil {
+		return err
+	}
+	baseURL, err := normalizeBaseURL(cfg.BaseURL)
+	if err != nil {
+		return fmt.Errorf("server.base_url %w", err)
+	}
+	cfg.BaseURL = baseURL
+	if err := applyString(&cfg.DeviceID, fc.HDHomeRun.DeviceID, "hdhomerun.device_id", true); err != nil {
+		return err
+	}
+	if err := validateDeviceID(cfg.DeviceID); err != nil {
+		return fmt.Errorf("hdhomerun.device_id %w", err)
+	}
+	if err := applyString(&cfg.FriendlyName, fc.HDHomeRun.FriendlyName, "hdhomerun.friendly_name", true); err != nil {
+		return err
+	}
+	if err := applyString(&cfg.FFmpegPath, fc.Transcode.FFmpegPath, "transcode.ffmpeg_path", true); err != nil {
+		return err
+	}
+	if fc.Transcode.Profiles != nil {
+		profiles, err := fc.Transcode.profiles()
+		if err != nil {
+			return err
+		}
+		cfg.TranscodeProfiles = profiles
+	}
+	if fc.Multicast.Outputs != nil {
+		outputs, err := fc.Multicast.outputs()
+		if err != nil {
+			return err
+		}
+		cfg.MulticastOutputs = outputs
+	}
+	if fc.Scan.Enabled != nil {
+		cfg.ScanEnabled = *fc.Scan.Enabled
+	}
+	if fc.SSDP.Enabled != nil {
+		cfg.SSDPEnabled = *fc.SSDP.Enabled
+	}
+	if fc.Timeline.Epoch != nil {
+		epoch, err := time.Parse(time.RFC3339, strings.TrimSpace(*fc.Timeline.Epoch))
+		if err != nil {
+			return fmt.Errorf("timeline.epoch must be an RFC 3339 time: %w", err)
+		}
+		cfg.TimelineEpoch = epoch
+	}
+	if fc.Server.MaxHeaderBytes != nil {
+		if *fc.Server.MaxHeaderBytes <= 0 {
+			return errors.New("server.max_header_bytes must be greater than zero")
+		}
+		cfg.MaxHeaderBytes = *fc.Server.MaxHeaderBytes
+	}
+	if fc.HDHomeRun.TunerCount != nil {
+		if *fc.HDHomeRun.TunerCount <= 0 {
+			return errors.New("hdhomerun.tuner_count must be greater than zero")
+		}
+		cfg.TunerCount = *fc.HDHomeRun.TunerCount
+	}
+	if fc.HLS.Window != nil {
+		if *fc.HLS.Window <= 0 {
+			return errors.New("hls.window must be greater than zero")
+		}
+		cfg.HLSWindow = *fc.HLS.Window
+	}
+
+	durations := []struct {
+		key string
+		src *fileDuration
+		dst *time.Duration
+	}{
+		{key: "db.ping_timeout", src: fc.DB.PingTimeout, dst: &cfg.DBPingTimeout},
+		{key: "server.read_header_timeout", src: fc.Server.ReadHeaderTimeout, dst: &cfg.ReadHeaderTimeout},
+		{key: "server.read_timeout", src: fc.Server.ReadTimeout, dst: &cfg.ReadTimeout},
+		{key: "server.write_timeout", src: fc.Server.WriteTimeout, dst: &cfg.WriteTimeout},
+		{key: "server.idle_timeout", src: fc.Server.IdleTimeout, dst: &cfg.IdleTimeout},
+		{key: "server.shutdown_timeout", src: fc.Server.ShutdownTimeout, dst: &cfg.ShutdownTimeout},
+		{key: "health.ping_timeout", src: fc.Health.PingTimeout, dst: &cfg.HealthPingTimeout},
+		{key: "health.log_interval", src: fc.Health.LogInterval, dst: &cfg.HealthLogInterval},
+		{key: "scan.interval", src: fc.Scan.Interval, dst: &cfg.ScanInterval},
+		{key: "epg.window", src: fc.EPG.Window, dst: &cfg.EPGWindow},
+		{key: "ssdp.notify_interval", src: fc.SSDP.NotifyInterval, dst: &cfg.SSDPNotifyInterval},
+		{key: "hls.segment_duration", src: fc.HLS.SegmentDuration, dst: &cfg.HLSSegmentDuration},
+		{key: "hls.session_idle", src: fc.HLS.SessionIdle, dst: &cfg.HLSSessionIdle},
+	}
+	for _, d := range durations {
+		if d.src == nil {
+			continue
+		}
+		if *d.src <= 0 {
+			return fmt.Errorf("%s must be greater than zero", d.key)
+		}
+		*d.dst = time.Duration(*d.src)
+	}
+
+	return nil
+}
+
+// profiles validates the configured transcode profiles.
+func (tc fileTranscodeConfig) profiles() (map[string]TranscodeProfile, error) {
+	profiles := make(map[string]TranscodeProfile, len(tc.Profiles))
+	for _, name := range slices.Sorted(maps.Keys(tc.Profiles)) {
+		p := tc.Profiles[name]
+		key := "transcode.profiles." + name
+		switch {
+		case strings.TrimSpace(name) != name || name == "" || len(name) > maxProfileNameLength:
+			return nil, fmt.Errorf("transcode profile name %q must be 1-%d characters without surrounding spaces",
+				name, maxProfileNameLength)
+		case p.Width < 0 || p.Height < 0 || (p.Width == 0) != (p.Height == 0):
+			return nil, fmt.Errorf("%s width and height must both be set and greater than zero, or both omitted", key)
+		case strings.TrimSpace(p.VideoCodec) == "":
+			return nil, fmt.Errorf("%s.video_codec must not be empty", key)
+		case strings.TrimSpace(p.AudioCodec) == "":
+			return nil, fmt.Errorf("%s.audio_codec must not be empty", key)
+		case p.VideoBitrate < 0 || p.AudioBitrate < 0:
+			return nil, fmt.Errorf("%s bitrates must not be negative", key)
+		}
+		profiles[name] = TranscodeProfile{
+			Width:        p.Width,
+			Height:       p.Height,
+			VideoCodec:   strings.TrimSpace(p.VideoCodec),
+			AudioCodec:   strings.TrimSpace(p.AudioCodec),
+			VideoBitrate: p.VideoBitrate,
+			AudioBitrate: p.AudioBitrate,
+		}
+	}
+	return profiles, nil
+}
+
+// outputs validates the configured multicast outputs.
+func (mc fileMulticastConfig) outputs() ([]MulticastOutput, error) {
+	outputs := make([]MulticastOutput, 0, len(mc.Outputs))
+	groups := make(map[string]bool, len(mc.Outputs))
+	for i, o := range mc.Outputs {
+		key := fmt.Sprintf("multicast.outputs[%d]", i)
+		group := strings.TrimSpace(o.Group)
+		ttl := defaultMulticastTTL
+		if o.TTL != nil {
+			ttl = *o.TTL
+		}
+		groupErr := validateMulticastGroup(group)
+		switch {
+		case o.ChannelID == 0:
+			return nil, fmt.Errorf("%s.channel_id must be greater than zero", key)
+		case groupErr != nil:
+			return nil, fmt.Errorf("%s.group %w", key, groupErr)
+		case groups[group]:
+			return nil, fmt.Errorf("%s.group %s is already used by another output", key, group)
+		case ttl < 1 || ttl > 255:
+			return nil, fmt.Errorf("%s.ttl must be between 1 and 255", key)
+		}
+		groups[group] = true
+		outputs = append(outputs, MulticastOutput{
+			ChannelID: o.ChannelID,
+			Group:     group,
+			TTL:       ttl,
+			Interface: strings.TrimSpace(o.Interface),
+		})
+	}
+	return outputs, nil
+}
+
+// validateMulticastGroup checks that group is an IPv4 multicast address with a port.
+func validateMulticastGroup(group string) error {
+	host, port, err := net.SplitHostPort(group)
+	if err != nil {
+		return errors.New("must be an address and port such as 239.0.0.1:1234")
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.To4() == nil || !ip.IsMulticast() {
+		return fmt.Errorf("address %q must be an IPv4 multicast address", host)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("port %q must be between 1 and 65535", port)
+	}
+	return nil
+}
+
+// describeTypeErrors rewrites yaml's unknown-field errors, which name internal Go types, into
+// messages that only mention the offending key.
+func describeTypeErrors(errs []string) string {
+	msgs := make([]string, len(errs))
+	for i, msg := range errs {
+		msgs[i] = unknownFieldPattern.ReplaceAllString(msg, `$1: unknown key "$2"`)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func applyString(dst *string, src *string, key string, required bool) error {
+	if src == nil {
+		return nil
+	}
+	value := strings.TrimSpace(*src)
+	if value == "" && required {
+		return fmt.Errorf("%s must not be empty", key)
+	}
+	*dst = value
+	return nil
+}
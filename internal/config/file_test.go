@@ -0,0 +1,311 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fullConfigYAML = `
+db:
+  path: /var/lib/tiny-headend.db
+  ping_timeout: 4s
+server:
+  addr: ":9090"
+  base_url: http://headend.lan:9090/
+  read_header_timeout: 1s
+  read_timeout: 6s
+  write_timeout: 7s
+  idle_timeout: 5m
+  max_header_bytes: 65536
+  shutdown_timeout: 15s
+health:
+  ping_timeout: 3s
+  log_interval: 2m
+scan:
+  enabled: true
+  path: /srv/media
+  interval: 1m
+timeline:
+  epoch: "2025-06-01T12:00:00Z"
+epg:
+  window: 24h
+hdhomerun:
+  device_id: ABCDEF01
+  friendly_name: Den Tuner
+  tuner_count: 4
+ssdp:
+  enabled: false
+  notify_interval: 10m
+hls:
+  segment_duration: 4s
+  window: 10
+  session_idle: 45s
+transcode:
+  ffmpeg_path: /opt/ffmpeg/bin/ffmpeg
+  profiles:
+    720p:
+      width: 1280
+      height: 720
+      video_codec: libx264
+      video_bitrate: 3000
+      audio_codec: aac
+      audio_bitrate: 128
+    passthrough:
+      video_codec: copy
+      audio_codec: copy
+multicast:
+  outputs:
+    - channel_id: 1
+      group: 239.0.0.1:1234
+      ttl: 4
+      interface: eth0
+    - channel_id: 2
+      group: 239.0.0.2:1234
+`
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tiny-headend.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFileReadsNestedSections(t *testing.T) {
+	path := writeConfigFile(t, fullConfigYAML)
+	cfg := Default()
+
+	if err := LoadFile(&cfg, path); err != nil {
+		t.Fatalf("load file: %v", err)
+	}
+
+	want := Config{
+		DBPath:             "/var/lib/tiny-headend.db",
+		HTTPAddr:           ":9090",
+		BaseURL:            "http://headend.lan:9090",
+		ConfigPath:         defaultConfigPath,
+		ScanEnabled:        true,
+		ScanPath:           "/srv/media",
+		ScanInterval:       time.Minute,
+		DBPingTimeout:      4 * time.Second,
+		HealthPingTimeout:  3 * time.Second,
+		ReadHeaderTimeout:  time.Second,
+		ReadTimeout:        6 * time.Second,
+		WriteTimeout:       7 * time.Second,
+		IdleTimeout:        5 * time.Minute,
+		MaxHeaderBytes:     65536,
+		HealthLogInterval:  2 * time.Minute,
+		ShutdownTimeout:    15 * time.Second,
+		TimelineEpoch:      time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC),
+		EPGWindow:          24 * time.Hour,
+		DeviceID:           "ABCDEF01",
+		FriendlyName:       "Den Tuner",
+		TunerCount:         4,
+		SSDPEnabled:        false,
+		SSDPNotifyInterval: 10 * time.Minute,
+		HLSSegmentDuration: 4 * time.Second,
+		HLSWindow:          10,
+		HLSSessionIdle:     45 * time.Second,
+		FFmpegPath:         "/opt/ffmpeg/bin/ffmpeg",
+		TranscodeProfiles: map[string]TranscodeProfile{
+			"720p": {
+				Width: 1280, Height: 720, VideoCodec: "libx264", VideoBitrate: 3000, AudioCodec: "aac", AudioBitrate: 128,
+			},
+			"passthrough": {VideoCodec: "copy", AudioCodec: "copy"},
+		},
+		MulticastOutputs: []MulticastOutput{
+			{ChannelID: 1, Group: "239.0.0.1:1234", TTL: 4, Interface: "eth0"},
+			{ChannelID: 2, Group: "239.0.0.2:1234", TTL: 1},
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("unexpected config: got %+v want %+v", cfg, want)
+	}
+}
+
+func TestLoadFileKeepsValuesForOmittedKeys(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  addr: \":9090\"\n")
+	cfg := Default()
+
+	if err := LoadFile(&cfg, path); err != nil {
+		t.Fatalf("load file: %v", err)
+	}
+
+	want := Default()
+	want.HTTPAddr = ":9090"
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("unexpected config: got %+v want %+v", cfg, want)
+	}
+}
+
+func TestLoadFileAcceptsEmptyFile(t *testing.T) {
+	path := writeConfigFile(t, "")
+	cfg := Default()
+
+	if err := LoadFile(&cfg, path); err != nil {
+		t.Fatalf("load file: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Fatalf("expected defaults, got %+v", cfg)
+	}
+}
+
+func TestLoadFileRejectsInvalidContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "unknown top-level key", content: "servr:\n  addr: \":1\"\n", want: "servr"},
+		{name: "unknown nested key", content: "scan:\n  intervall: 5s\n", want: "intervall"},
+		{name: "invalid duration", content: "server:\n  read_timeout: soon\n", want: "soon"},
+		{name: "non-positive duration", content: "scan:\n  interval: 0s\n", want: "scan.interval"},
+		{name: "empty required string", content: "db:\n  path: \" \"\n", want: "db.path"},
+		{name: "non-positive int", content: "server:\n  max_header_bytes: 0\n", want: "server.max_header_bytes"},
+		{name: "invalid bool", content: "scan:\n  enabled: maybe\n", want: "maybe"},
+		{name: "invalid epoch", content: "timeline:\n  epoch: yesterday\n", want: "timeline.epoch"},
+		{name: "relative base url", content: "server:\n  base_url: headend.lan\n", want: "server.base_url"},
+		{name: "bad device id", content: "hdhomerun:\n  device_id: xyz\n", want: "hdhomerun.device_id"},
+		{name: "non-positive notify interval", content: "ssdp:\n  notify_interval: 0s\n", want: "ssdp.notify_interval"},
+		{name: "zero tuners", content: "hdhomerun:\n  tuner_count: 0\n", want: "hdhomerun.tuner_count"},
+		{name: "empty hls window", content: "hls:\n  window: 0\n", want: "hls.window"},
+		{name: "empty ffmpeg path", content: "transcode:\n  ffmpeg_path: \"\"\n", want: "transcode.ffmpeg_path"},
+		{
+			name:    "profile without codec",
+			content: "transcode:\n  profiles:\n    hd:\n      audio_codec: aac\n",
+			want:    "transcode.profiles.hd.video_codec",
+		},
+		{
+			name:    "profile with only a width",
+			content: "transcode:\n  profiles:\n    hd:\n      width: 1280\n      video_codec: libx264\n      audio_codec: aac\n",
+			want:    "transcode.profiles.hd width and height",
+		},
+		{
+			name:    "multicast output without channel",
+			content: "multicast:\n  outputs:\n    - group: 239.0.0.1:1234\n",
+			want:    "multicast.outputs[0].channel_id",
+		},
+		{
+			name:    "unicast group",
+			content: "multicast:\n  outputs:\n    - channel_id: 1\n      group: 192.168.1.10:1234\n",
+			want:    "multicast.outputs[0].group",
+		},
+		{
+			name:    "group without port",
+			content: "multicast:\n  outputs:\n    - channel_id: 1\n      group: 239.0.0.1\n",
+			want:    "multicast.outputs[0].group",
+		},
+		{
+			name: "duplicate group",
+			content: "multicast:\n  outputs:\n    - channel_id: 1\n      group: 239.0.0.1:1234\n" +
+				"    - channel_id: 2\n      group: 239.0.0.1:1234\n",
+			want: "multicast.outputs[1].group",
+		},
+		{
+			name:    "ttl out of range",
+			content: "multicast:\n  outputs:\n    - channel_id: 1\n      group: 239.0.0.1:1234\n      ttl: 0\n",
+			want:    "multicast.outputs[0].ttl",
+		},
+		{
+			name:    "unknown profile key",
+			content: "transcode:\n  profiles:\n    hd:\n      fps: 30\n",
+			want:    "fps",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeConfigFile(t, tc.content)
+			cfg := Default()
+
+			err := LoadFile(&cfg, path)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.want) || !strings.Contains(err.Error(), path) {
+				t.Fatalf("expected error mentioning %q and the file path, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadFileDescribesUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  adr: \":1\"\n")
+	cfg := Default()
+
+	err := LoadFile(&cfg, path)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), `line 2: unknown key "adr"`) {
+		t.Fatalf("expected unknown key message, got %v", err)
+	}
+	if strings.Contains(err.Error(), "fileServerConfig") {
+		t.Fatalf("expected error to not mention internal types, got %v", err)
+	}
+}
+
+func TestLoadAppliesPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  addr: \":9090\"\n  read_timeout: 6s\n")
+	t.Setenv(envReadTimeout, "8s")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.HTTPAddr != ":9090" {
+		t.Fatalf("expected file value for addr, got %q", cfg.HTTPAddr)
+	}
+	if cfg.ReadTimeout != 8*time.Second {
+		t.Fatalf("expected env value to override file, got %s", cfg.ReadTimeout)
+	}
+	if cfg.WriteTimeout != defaultWriteTimeout {
+		t.Fatalf("expected default write timeout, got %s", cfg.WriteTimeout)
+	}
+	if cfg.ConfigPath != path {
+		t.Fatalf("expected config path %q, got %q", path, cfg.ConfigPath)
+	}
+}
+
+func TestLoadUsesConfigPathFromEnv(t *testing.T) {
+	path := writeConfigFile(t, "db:\n  path: from-file.db\n")
+	t.Setenv(envConfigPath, path)
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.DBPath != "from-file.db" {
+		t.Fatalf("expected db path from file, got %q", cfg.DBPath)
+	}
+}
+
+func TestLoadIgnoresMissingDefaultFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.HTTPAddr != defaultHTTPAddr {
+		t.Fatalf("expected default addr, got %q", cfg.HTTPAddr)
+	}
+}
+
+func TestLoadReturnsErrorForMissingExplicitFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+
+	if _, err := Load(missing); err == nil {
+		t.Fatal("expected error for missing --config file")
+	}
+
+	t.Setenv(envConfigPath, missing)
+	if _, err := Load(""); err == nil {
+		t.Fatal("expected error for missing TINY_HEADEND_CONFIG_PATH file")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -43,9 +44,23 @@ func Close(g *gorm.DB) error {
 }
 
 func Migrate(g *gorm.DB) error {
-	if err := g.AutoMigrate(&model.Content{}, &model.Channel{}); err != nil {
+	if err := g.AutoMigrate(
+		&model.Content{}, &model.Channel{}, &model.ChannelItem{},
+		&model.ScheduleBlock{}, &model.ScheduleBlockEntry{},
+		&model.FillerPool{}, &model.FillerPoolEntry{},
+		&model.Tag{}, &model.Collection{}, &model.CollectionEntry{},
+		&model.Show{}, &model.Season{}, &model.Episode{},
+		&model.ChannelCursor{}, &model.ContentTrack{}, &model.ChannelLogo{},
+	); err != nil {
 		return fmt.Errorf("auto-migrate db schema: %w", err)
 	}
+	indexed, err := model.MigrateSearch(g)
+	if err != nil {
+		return err
+	}
+	if !indexed {
+		slog.Warn("sqlite was built without FTS5; content search falls back to substring matching")
+	}
 	return nil
 }
 
@@ -89,3 +89,21 @@ func TestMigrateCreatesContentAndChannelTables(t *testing.T) {
 		t.Fatalf("expected channel table to exist after migration")
 	}
 }
+
+func TestMigrateIsRepeatable(t *testing.T) {
+	g, err := Open(filepath.Join(t.TempDir(), "tiny-headend-remigrate-test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer func() {
+		if closeErr := Close(g); closeErr != nil {
+			t.Fatalf("close db: %v", closeErr)
+		}
+	}()
+
+	for range 2 {
+		if err := Migrate(g); err != nil {
+			t.Fatalf("migrate db: %v", err)
+		}
+	}
+}
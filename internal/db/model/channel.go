@@ -13,6 +13,33 @@ type Channel struct {
 	Title         string `gorm:"type:varchar(255);not null" json:"title"`
 	ChannelNumber uint   `gorm:"not null" json:"channelNumber"`
 	Description   string `gorm:"type:text;not null" json:"description"`
+	// Loop is a pointer so an explicit false is written rather than replaced
+	// by the column default.
+	Loop             *bool        `gorm:"not null;default:true" json:"loop"`
+	Shuffle          bool         `gorm:"not null;default:false" json:"shuffle"`
+	Profile          string       `gorm:"type:varchar(64);not null;default:''" json:"profile"`
+	Timezone         string       `gorm:"type:varchar(64);not null;default:''" json:"timezone"`
+	PadTo            int          `gorm:"not null;default:0" json:"padTo"`
+	AudioLanguage    string       `gorm:"type:varchar(35);not null;default:''" json:"audioLanguage"`
+	SubtitleLanguage string       `gorm:"type:varchar(35);not null;default:''" json:"subtitleLanguage"`
+	Logo             *ChannelLogo `gorm:"foreignKey:ChannelID" json:"-"`
+}
+
+func (m *Channel) toService() service.Channel {
+	return service.Channel{
+		ID:               m.ID,
+		Title:            m.Title,
+		ChannelNumber:    m.ChannelNumber,
+		Description:      m.Description,
+		Loop:             m.Loop == nil || *m.Loop,
+		Shuffle:          m.Shuffle,
+		Profile:          m.Profile,
+		Timezone:         m.Timezone,
+		PadTo:            m.PadTo,
+		AudioLanguage:    m.AudioLanguage,
+		SubtitleLanguage: m.SubtitleLanguage,
+		HasLogo:          m.Logo != nil,
+	}
 }
 
 type ChannelRepo struct {
@@ -24,10 +51,18 @@ func NewChannelRepo(db *gorm.DB) *ChannelRepo {
 }
 
 func (r *ChannelRepo) Create(ctx context.Context, c *service.Channel) error {
+	loop := c.Loop
 	m := &Channel{
-		Title:         c.Title,
-		ChannelNumber: c.ChannelNumber,
-		Description:   c.Description,
+		Title:            c.Title,
+		ChannelNumber:    c.ChannelNumber,
+		Description:      c.Description,
+		Loop:             &loop,
+		Shuffle:          c.Shuffle,
+		Profile:          c.Profile,
+		Timezone:         c.Timezone,
+		PadTo:            c.PadTo,
+		AudioLanguage:    c.AudioLanguage,
+		SubtitleLanguage: c.SubtitleLanguage,
 	}
 	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
 		return err
@@ -38,42 +73,40 @@ func (r *ChannelRepo) Create(ctx context.Context, c *service.Channel) error {
 
 func (r *ChannelRepo) GetByID(ctx context.Context, id uint) (*service.Channel, error) {
 	var m Channel
-	if err := r.db.WithContext(ctx).First(&m, id).Error; err != nil {
+	if err := withLogo(r.db.WithContext(ctx)).First(&m, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, service.ErrNotFound
 		}
 		return nil, err
 	}
-	return &service.Channel{
-		ID:            m.ID,
-		Title:         m.Title,
-		ChannelNumber: m.ChannelNumber,
-		Description:   m.Description,
-	}, nil
+	c := m.toService()
+	return &c, nil
 }
 
 func (r *ChannelRepo) List(ctx context.Context, limit, offset int) ([]service.Channel, error) {
 	var ms []Channel
-	if err := r.db.WithContext(ctx).Order("id ASC").Limit(limit).Offset(offset).Find(&ms).Error; err != nil {
+	if err := withLogo(r.db.WithContext(ctx)).Order("id ASC").Limit(limit).Offset(offset).Find(&ms).Error; err != nil {
 		return nil, err
 	}
 	channels := make([]service.Channel, len(ms))
 	for i, m := range ms {
-		channels[i] = service.Channel{
-			ID:            m.ID,
-			Title:         m.Title,
-			ChannelNumber: m.ChannelNumber,
-			Description:   m.Description,
-		}
+		channels[i] = m.toService()
 	}
 	return channels, nil
 }
 
 func (r *ChannelRepo) Update(ctx context.Context, c *service.Channel) error {
 	res := r.db.WithContext(ctx).Model(&Channel{}).Where("id = ?", c.ID).Updates(map[string]any{
-		"title":          c.Title,
-		"channel_number": c.ChannelNumber,
-		"description":    c.Description,
+		"title":             c.Title,
+		"channel_number":    c.ChannelNumber,
+		"description":       c.Description,
+		"loop":              c.Loop,
+		"shuffle":           c.Shuffle,
+		"profile":           c.Profile,
+		"timezone":          c.Timezone,
+		"pad_to":            c.PadTo,
+		"audio_language":    c.AudioLanguage,
+		"subtitle_language": c.SubtitleLanguage,
 	})
 	if res.Error != nil {
 		return res.Error
@@ -81,16 +114,36 @@ func (r *ChannelRepo) Update(ctx context.Context, c *service.Channel) error {
 	if res.RowsAffected == 0 {
 		return service.ErrNotFound
 	}
+	// The logo is managed separately, so report whether the channel has one.
+	var logos int64
+	if err := r.db.WithContext(ctx).Model(&ChannelLogo{}).Where("channel_id = ?", c.ID).Count(&logos).Error; err != nil {
+		return err
+	}
+	c.HasLogo = logos > 0
 	return nil
 }
 
 func (r *ChannelRepo) Delete(ctx context.Context, id uint) error {
-	res := r.db.WithContext(ctx).Delete(&Channel{}, id)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return service.ErrNotFound
-	}
-	return nil
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&Channel{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return service.ErrNotFound
+		}
+		if err := tx.Where("channel_id = ?", id).Delete(&ChannelItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("channel_id = ?", id).Delete(&ChannelCursor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("channel_id = ?", id).Delete(&ChannelLogo{}).Error; err != nil {
+			return err
+		}
+		if err := deleteChannelBlocks(tx, id); err != nil {
+			return err
+		}
+		return deleteChannelPools(tx, id)
+	})
 }
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/gorm"
+)
+
+// ChannelItem joins a Content row or a Collection onto a Channel's playlist. ContentID is null
+// for a collection. Position is only a sort key; gaps left by removals are not compacted.
+type ChannelItem struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	ChannelID    uint  `gorm:"not null;index:idx_channel_items_order,priority:1"`
+	ContentID    *uint `gorm:"index"`
+	CollectionID uint  `gorm:"not null;default:0;index"`
+	Position     int   `gorm:"not null;index:idx_channel_items_order,priority:2"`
+	Content      Content
+}
+
+type ChannelItemRepo struct {
+	db *gorm.DB
+}
+
+func NewChannelItemRepo(db *gorm.DB) *ChannelItemRepo {
+	return &ChannelItemRepo{db: db}
+}
+
+// ListByChannel returns the channel's items in play order with their content
+// loaded. Items whose content was deleted are left out. Positions in the
+// result are renumbered from zero.
+func (r *ChannelItemRepo) ListByChannel(ctx context.Context, channelID uint) ([]service.ChannelItem, error) {
+	var ms []ChannelItem
+	err := withTracks(r.db.WithContext(ctx), "Content.Tracks").
+		Joins("Content").
+		Preload("Content.Episode.Season.Show").
+		Where("channel_items.channel_id = ?", channelID).
+		Where("`Content`.`id` IS NOT NULL OR channel_items.collection_id <> 0").
+		Order("channel_items.position ASC, channel_items.id ASC").
+		Find(&ms).Error
+	if err != nil {
+		return nil, err
+	}
+	items := make([]service.ChannelItem, len(ms))
+	for i, m := range ms {
+		items[i] = service.ChannelItem{
+			ID:           m.ID,
+			ChannelID:    m.ChannelID,
+			CollectionID: m.CollectionID,
+			Position:     i,
+		}
+		if m.ContentID != nil {
+			c := m.Content.toService()
+			items[i].ContentID = *m.ContentID
+			items[i].Content = &c
+		}
+	}
+	return items, nil
+}
+
+func (r *ChannelItemRepo) Append(ctx context.Context, item *service.ChannelItem) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var next int
+		if err := tx.Model(&ChannelItem{}).
+			Where("channel_id = ?", item.ChannelID).
+			Select("COALESCE(MAX(position) + 1, 0)").
+			Scan(&next).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&ChannelItem{}).Where("channel_id = ?", item.ChannelID).Count(&count).Error; err != nil {
+			return err
+		}
+		m := &ChannelItem{ChannelID: item.ChannelID, CollectionID: item.CollectionID, Position: next}
+		if item.ContentID != 0 {
+			m.ContentID = &item.ContentID
+		}
+		if err := tx.Omit("Content").Create(m).Error; err != nil {
+			return err
+		}
+		item.ID = m.ID
+		item.Position = int(count)
+		return nil
+	})
+}
+
+func (r *ChannelItemRepo) Delete(ctx context.Context, channelID, itemID uint) error {
+	res := r.db.WithContext(ctx).Where("channel_id = ?", channelID).Delete(&ChannelItem{}, itemID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return service.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ChannelItemRepo) Reorder(ctx context.Context, channelID uint, itemIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for pos, id := range itemIDs {
+			res := tx.Model(&ChannelItem{}).
+				Where("id = ? AND channel_id = ?", id, channelID).
+				Update("position", pos)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return service.ErrNotFound
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type playlistFixture struct {
+	content     *ContentRepo
+	channel     *ChannelRepo
+	items       *ChannelItemRepo
+	blocks      *ScheduleBlockRepo
+	pools       *FillerPoolRepo
+	collections *CollectionRepo
+	chID        uint
+	ids         []uint
+}
+
+func newPlaylistFixture(t *testing.T, titles ...string) *playlistFixture {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "test-playlist.db")
+	g, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := g.AutoMigrate(&Content{}, &Channel{}, &ChannelItem{}, &ScheduleBlock{}, &ScheduleBlockEntry{}, &FillerPool{}, &FillerPoolEntry{}, &Tag{}, &Collection{}, &CollectionEntry{}, &Show{}, &Season{}, &Episode{}, &ChannelCursor{}, &ContentTrack{}, &ChannelLogo{}); err != nil {
+		t.Fatalf("migrate db: %v", err)
+	}
+
+	f := &playlistFixture{content: NewContentRepo(g), channel: NewChannelRepo(g), items: NewChannelItemRepo(g), blocks: NewScheduleBlockRepo(g), pools: NewFillerPoolRepo(g), collections: NewCollectionRepo(g)}
+	ch := &service.Channel{Title: "News", ChannelNumber: 7, Loop: true}
+	if err := f.channel.Create(context.Background(), ch); err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	f.chID = ch.ID
+
+	for _, title := range titles {
+		c := &service.Content{Title: title, Path: "/media/" + title + ".ts", Size: 1, Length: 1}
+		if err := f.content.Create(context.Background(), c); err != nil {
+			t.Fatalf("create content: %v", err)
+		}
+		item := &service.ChannelItem{ChannelID: f.chID, ContentID: c.ID}
+		if err := f.items.Append(context.Background(), item); err != nil {
+			t.Fatalf("append item: %v", err)
+		}
+		f.ids = append(f.ids, item.ID)
+	}
+	return f
+}
+
+func titles(items []service.ChannelItem) []string {
+	out := make([]string, len(items))
+	for i, it := range items {
+		out[i] = it.Content.Title
+	}
+	return out
+}
+
+func TestChannelItemRepoAppendKeepsInsertionOrder(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b", "c")
+
+	got, err := f.items.ListByChannel(context.Background(), f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(titles(got), want) {
+		t.Fatalf("expected %v, got %v", want, titles(got))
+	}
+	for i, it := range got {
+		if it.Position != i {
+			t.Fatalf("expected position %d, got %d", i, it.Position)
+		}
+	}
+}
+
+func TestChannelItemRepoReorder(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b", "c")
+
+	ids := []uint{f.ids[2], f.ids[0], f.ids[1]}
+	if err := f.items.Reorder(context.Background(), f.chID, ids); err != nil {
+		t.Fatalf("reorder: %v", err)
+	}
+	got, err := f.items.ListByChannel(context.Background(), f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	if want := []string{"c", "a", "b"}; !slices.Equal(titles(got), want) {
+		t.Fatalf("expected %v, got %v", want, titles(got))
+	}
+}
+
+func TestChannelItemRepoDeleteScopesToChannel(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b")
+
+	err := f.items.Delete(context.Background(), f.chID+1, f.ids[0])
+	if !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for other channel, got %v", err)
+	}
+	if err := f.items.Delete(context.Background(), f.chID, f.ids[0]); err != nil {
+		t.Fatalf("delete item: %v", err)
+	}
+
+	got, err := f.items.ListByChannel(context.Background(), f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	if len(got) != 1 || got[0].Content.Title != "b" || got[0].Position != 0 {
+		t.Fatalf("unexpected items after delete: %+v", got)
+	}
+}
+
+func TestContentDeleteRemovesChannelItems(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b")
+
+	items, _ := f.items.ListByChannel(context.Background(), f.chID)
+	if err := f.content.Delete(context.Background(), items[0].ContentID); err != nil {
+		t.Fatalf("delete content: %v", err)
+	}
+
+	got, err := f.items.ListByChannel(context.Background(), f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	if want := []string{"b"}; !slices.Equal(titles(got), want) {
+		t.Fatalf("expected %v, got %v", want, titles(got))
+	}
+}
+
+func TestChannelDeleteRemovesChannelItems(t *testing.T) {
+	f := newPlaylistFixture(t, "a")
+
+	if err := f.channel.Delete(context.Background(), f.chID); err != nil {
+		t.Fatalf("delete channel: %v", err)
+	}
+	got, err := f.items.ListByChannel(context.Background(), f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("expected no items, got %+v", got)
+	}
+}
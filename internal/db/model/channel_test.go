@@ -20,7 +20,7 @@ func newTestChannelRepo(t *testing.T) *ChannelRepo {
 		t.Fatalf("open db: %v", err)
 	}
 
-	if err := g.AutoMigrate(&Channel{}); err != nil {
+	if err := g.AutoMigrate(&Content{}, &Channel{}, &ChannelItem{}, &ScheduleBlock{}, &ScheduleBlockEntry{}, &FillerPool{}, &FillerPoolEntry{}, &Tag{}, &Collection{}, &CollectionEntry{}, &Show{}, &Season{}, &Episode{}, &ChannelCursor{}, &ContentTrack{}, &ChannelLogo{}); err != nil {
 		t.Fatalf("migrate db: %v", err)
 	}
 
@@ -160,3 +160,87 @@ func TestChannelRepoDeleteNotFound(t *testing.T) {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
 }
+
+func TestChannelRepoPersistsPlaybackOptions(t *testing.T) {
+	repo := newTestChannelRepo(t)
+	c := &service.Channel{Title: "ABC", ChannelNumber: 7, Loop: false, Shuffle: true}
+	if err := repo.Create(context.Background(), c); err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+
+	got, err := repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	if got.Loop || !got.Shuffle {
+		t.Fatalf("expected loop=false shuffle=true, got %+v", got)
+	}
+
+	c.Loop, c.Shuffle = true, false
+	if err := repo.Update(context.Background(), c); err != nil {
+		t.Fatalf("update channel: %v", err)
+	}
+	got, err = repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	if !got.Loop || got.Shuffle {
+		t.Fatalf("expected loop=true shuffle=false, got %+v", got)
+	}
+}
+
+func TestChannelRepoPersistsProfile(t *testing.T) {
+	repo := newTestChannelRepo(t)
+	c := &service.Channel{Title: "ABC", ChannelNumber: 7, Profile: "720p"}
+	if err := repo.Create(context.Background(), c); err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+
+	got, err := repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	if got.Profile != "720p" {
+		t.Fatalf("expected profile 720p, got %q", got.Profile)
+	}
+
+	c.Profile = ""
+	if err := repo.Update(context.Background(), c); err != nil {
+		t.Fatalf("update channel: %v", err)
+	}
+	got, err = repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	if got.Profile != "" {
+		t.Fatalf("expected profile to be cleared, got %q", got.Profile)
+	}
+}
+
+func TestChannelRepoPersistsLanguages(t *testing.T) {
+	repo := newTestChannelRepo(t)
+	c := &service.Channel{Title: "ABC", ChannelNumber: 7, AudioLanguage: "en", SubtitleLanguage: "pt-BR"}
+	if err := repo.Create(context.Background(), c); err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+
+	got, err := repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	if got.AudioLanguage != "en" || got.SubtitleLanguage != "pt-BR" {
+		t.Fatalf("expected languages en and pt-BR, got %+v", got)
+	}
+
+	c.SubtitleLanguage = ""
+	if err := repo.Update(context.Background(), c); err != nil {
+		t.Fatalf("update channel: %v", err)
+	}
+	got, err = repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	if got.AudioLanguage != "en" || got.SubtitleLanguage != "" {
+		t.Fatalf("expected subtitle language to be cleared, got %+v", got)
+	}
+}
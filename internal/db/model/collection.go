@@ -0,0 +1,242 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/gorm"
+)
+
+// Collection is a named group of content. A smart collection selects content with its Query
+// columns; a manual one lists it in Entries.
+type Collection struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Name           string            `gorm:"type:varchar(255);not null"`
+	Smart          bool              `gorm:"not null;default:false"`
+	QueryTag       string            `gorm:"type:varchar(64);not null;default:''"`
+	QueryPath      string            `gorm:"type:varchar(255);not null;default:''"`
+	QueryMinLength float64           `gorm:"not null;default:0"`
+	QueryMaxLength float64           `gorm:"not null;default:0"`
+	QueryTitle     string            `gorm:"type:varchar(255);not null;default:''"`
+	QueryShowID    uint              `gorm:"not null;default:0"`
+	Entries        []CollectionEntry `gorm:"foreignKey:CollectionID"`
+}
+
+// CollectionEntry puts a Content row in a manual collection at Position.
+type CollectionEntry struct {
+	ID           uint `gorm:"primarykey"`
+	CollectionID uint `gorm:"not null;index:idx_collection_entries_order,priority:1"`
+	Position     int  `gorm:"not null;index:idx_collection_entries_order,priority:2"`
+	ContentID    uint `gorm:"not null;index"`
+	Content      Content
+}
+
+func collectionFromService(c *service.Collection) *Collection {
+	m := &Collection{ID: c.ID, Name: c.Name}
+	if q := c.Query; q != nil {
+		m.Smart = true
+		m.QueryTag = q.Tag
+		m.QueryPath = q.PathPrefix
+		m.QueryMinLength = q.MinLength
+		m.QueryMaxLength = q.MaxLength
+		m.QueryTitle = q.Title
+		m.QueryShowID = q.ShowID
+	}
+	return m
+}
+
+func (m *Collection) toService() service.Collection {
+	c := service.Collection{ID: m.ID, Name: m.Name, ContentIDs: []uint{}}
+	if m.Smart {
+		c.Query = &service.CollectionQuery{
+			Tag:        m.QueryTag,
+			PathPrefix: m.QueryPath,
+			MinLength:  m.QueryMinLength,
+			MaxLength:  m.QueryMaxLength,
+			Title:      m.QueryTitle,
+			ShowID:     m.QueryShowID,
+		}
+	}
+	for _, e := range m.Entries {
+		if e.Content.ID == 0 {
+			continue
+		}
+		c.ContentIDs = append(c.ContentIDs, e.ContentID)
+	}
+	return c
+}
+
+type CollectionRepo struct {
+	db *gorm.DB
+}
+
+func NewCollectionRepo(db *gorm.DB) *CollectionRepo {
+	return &CollectionRepo{db: db}
+}
+
+// withEntries loads collections with their entries in order and the entries' content.
+func (r *CollectionRepo) withEntries(ctx context.Context) *gorm.DB {
+	return withEntryContent(r.db.WithContext(ctx), "collection_entries")
+}
+
+func (r *CollectionRepo) List(ctx context.Context) ([]service.Collection, error) {
+	var ms []Collection
+	if err := r.withEntries(ctx).Order("name ASC, id ASC").Find(&ms).Error; err != nil {
+		return nil, err
+	}
+	collections := make([]service.Collection, len(ms))
+	for i, m := range ms {
+		collections[i] = m.toService()
+	}
+	return collections, nil
+}
+
+func (r *CollectionRepo) GetByID(ctx context.Context, id uint) (*service.Collection, error) {
+	var m Collection
+	if err := r.withEntries(ctx).First(&m, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrNotFound
+		}
+		return nil, err
+	}
+	c := m.toService()
+	return &c, nil
+}
+
+func (r *CollectionRepo) Create(ctx context.Context, c *service.Collection) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		m := collectionFromService(c)
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		c.ID = m.ID
+		return createCollectionEntries(tx, c)
+	})
+}
+
+// Update replaces the collection's name, query and content.
+func (r *CollectionRepo) Update(ctx context.Context, c *service.Collection) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		m := collectionFromService(c)
+		res := tx.Model(&Collection{}).Where("id = ?", c.ID).Updates(map[string]any{
+			"name":             m.Name,
+			"smart":            m.Smart,
+			"query_tag":        m.QueryTag,
+			"query_path":       m.QueryPath,
+			"query_min_length": m.QueryMinLength,
+			"query_max_length": m.QueryMaxLength,
+			"query_title":      m.QueryTitle,
+			"query_show_id":    m.QueryShowID,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return service.ErrNotFound
+		}
+		if err := tx.Where("collection_id = ?", c.ID).Delete(&CollectionEntry{}).Error; err != nil {
+			return err
+		}
+		return createCollectionEntries(tx, c)
+	})
+}
+
+// Delete removes the collection with its entries and the playlist items that play it. Schedule
+// blocks that play it are left with no content.
+func (r *CollectionRepo) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&Collection{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return service.ErrNotFound
+		}
+		if err := tx.Where("collection_id = ?", id).Delete(&CollectionEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("collection_id = ?", id).Delete(&ChannelItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ScheduleBlock{}).Where("collection_id = ?", id).Update("collection_id", 0).Error
+	})
+}
+
+// Members returns the content in c. A smart collection's query runs against the library as it is
+// now, so newly scanned files show up without editing the collection.
+func (r *CollectionRepo) Members(ctx context.Context, c *service.Collection) ([]service.Content, error) {
+	q := withTracks(withEpisode(r.db.WithContext(ctx).Model(&Content{})), "Tracks")
+	switch {
+	case c.Query == nil:
+		q = q.Joins("JOIN collection_entries ON collection_entries.content_id = content.id").
+			Where("collection_entries.collection_id = ?", c.ID).
+			Order("collection_entries.position ASC, collection_entries.id ASC")
+	case c.Query.ShowID != 0:
+		q = matchQuery(r.db, q, c.Query).Order(episodeOrder)
+	default:
+		q = matchQuery(r.db, q, c.Query).Order("content.path ASC, content.id ASC")
+	}
+	var ms []Content
+	if err := q.Find(&ms).Error; err != nil {
+		return nil, err
+	}
+	contents := make([]service.Content, len(ms))
+	for i, m := range ms {
+		contents[i] = m.toService()
+	}
+	return contents, nil
+}
+
+// matchQuery narrows q, a query over content, to the content matching cq. A query for a show joins
+// the episodes and seasons tables so the caller can sort in episode order.
+func matchQuery(db *gorm.DB, q *gorm.DB, cq *service.CollectionQuery) *gorm.DB {
+	if cq.ShowID != 0 {
+		q = q.Joins("JOIN episodes ON episodes.content_id = content.id").
+			Joins("JOIN seasons ON seasons.id = episodes.season_id").
+			Where("seasons.show_id = ?", cq.ShowID)
+	}
+	if cq.Tag != "" {
+		tagged := db.Table("content_tags").
+			Select("content_tags.content_id").
+			Joins("JOIN tags ON tags.id = content_tags.tag_id").
+			Where("tags.name = ?", cq.Tag)
+		q = q.Where("content.id IN (?)", tagged)
+	}
+	if cq.PathPrefix != "" {
+		q = q.Where(`content.path LIKE ? ESCAPE '\'`, escapeLike(cq.PathPrefix)+"%")
+	}
+	if cq.MinLength > 0 {
+		q = q.Where("content.length >= ?", cq.MinLength)
+	}
+	if cq.MaxLength > 0 {
+		q = q.Where("content.length <= ?", cq.MaxLength)
+	}
+	if cq.Title != "" {
+		// SQLite's LIKE ignores case for ASCII letters.
+		q = q.Where(`content.title LIKE ? ESCAPE '\'`, "%"+escapeLike(cq.Title)+"%")
+	}
+	return q
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match itself literally in a LIKE pattern escaped with a backslash.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func createCollectionEntries(tx *gorm.DB, c *service.Collection) error {
+	if len(c.ContentIDs) == 0 {
+		return nil
+	}
+	entries := make([]CollectionEntry, len(c.ContentIDs))
+	for i, id := range c.ContentIDs {
+		entries[i] = CollectionEntry{CollectionID: c.ID, ContentID: id, Position: i}
+	}
+	return tx.Omit("Content").Create(&entries).Error
+}
@@ -0,0 +1,217 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func TestCollectionRepoManualRoundTrip(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b", "c")
+	ids := f.contentIDs(t)
+	repo := f.collections
+	ctx := context.Background()
+
+	c := &service.Collection{Name: "Picks", ContentIDs: []uint{ids[2], ids[0]}}
+	if err := repo.Create(ctx, c); err != nil {
+		t.Fatalf("create collection: %v", err)
+	}
+	got, err := repo.GetByID(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("get collection: %v", err)
+	}
+	if got.Name != "Picks" || got.Query != nil || !slices.Equal(got.ContentIDs, []uint{ids[2], ids[0]}) {
+		t.Fatalf("unexpected collection %+v", got)
+	}
+	members, err := repo.Members(ctx, got)
+	if err != nil {
+		t.Fatalf("members: %v", err)
+	}
+	if want := []string{"c", "a"}; !slices.Equal(contentTitles(members), want) {
+		t.Fatalf("expected members %v, got %v", want, contentTitles(members))
+	}
+
+	got.Name = "Smart"
+	got.ContentIDs = []uint{}
+	got.Query = &service.CollectionQuery{Title: "b"}
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("update collection: %v", err)
+	}
+	list, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("list collections: %v", err)
+	}
+	if len(list) != 1 || list[0].Query == nil || list[0].Query.Title != "b" || len(list[0].ContentIDs) != 0 {
+		t.Fatalf("unexpected collections after update %+v", list)
+	}
+	if err := repo.Update(ctx, &service.Collection{ID: c.ID + 1, Name: "x"}); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCollectionRepoMembersMatchesQuery(t *testing.T) {
+	f := newPlaylistFixture(t)
+	repo := f.collections
+	ctx := context.Background()
+
+	library := []service.Content{
+		{Title: "Cartoon Hour", Path: "/media/kids/b.ts", Size: 1, Length: 1200},
+		{Title: "Old Cartoons", Path: "/media/kids/a.ts", Size: 1, Length: 300},
+		{Title: "News", Path: "/media/news/a.ts", Size: 1, Length: 1800},
+		{Title: "100% Cartoon", Path: "/media/kids_/c.ts", Size: 1, Length: 600},
+	}
+	for i := range library {
+		if err := f.content.Create(ctx, &library[i]); err != nil {
+			t.Fatalf("create content: %v", err)
+		}
+	}
+	if err := f.content.SetTags(ctx, library[0].ID, []string{"kids", "weekend"}); err != nil {
+		t.Fatalf("set tags: %v", err)
+	}
+	if err := f.content.SetTags(ctx, library[2].ID, []string{"weekend"}); err != nil {
+		t.Fatalf("set tags: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query service.CollectionQuery
+		want  []string
+	}{
+		{name: "tag", query: service.CollectionQuery{Tag: "weekend"}, want: []string{"Cartoon Hour", "News"}},
+		{name: "path prefix", query: service.CollectionQuery{PathPrefix: "/media/kids/"}, want: []string{"Old Cartoons", "Cartoon Hour"}},
+		{name: "path prefix is literal", query: service.CollectionQuery{PathPrefix: "/media/kids_"}, want: []string{"100% Cartoon"}},
+		{name: "length range", query: service.CollectionQuery{MinLength: 600, MaxLength: 1200}, want: []string{"Cartoon Hour", "100% Cartoon"}},
+		{name: "title ignores case", query: service.CollectionQuery{Title: "cartoon"}, want: []string{"Old Cartoons", "Cartoon Hour", "100% Cartoon"}},
+		{name: "title is literal", query: service.CollectionQuery{Title: "0%"}, want: []string{"100% Cartoon"}},
+		{name: "all conditions", query: service.CollectionQuery{Tag: "kids", PathPrefix: "/media/", Title: "hour", MinLength: 60}, want: []string{"Cartoon Hour"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			members, err := repo.Members(ctx, &service.Collection{Query: &tc.query})
+			if err != nil {
+				t.Fatalf("members: %v", err)
+			}
+			if got := contentTitles(members); !slices.Equal(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestContentRepoSetTags(t *testing.T) {
+	f := newPlaylistFixture(t, "a")
+	ids := f.contentIDs(t)
+	ctx := context.Background()
+
+	if err := f.content.SetTags(ctx, ids[0], []string{"news", "kids"}); err != nil {
+		t.Fatalf("set tags: %v", err)
+	}
+	if err := f.content.SetTags(ctx, ids[0], []string{"kids", "sport"}); err != nil {
+		t.Fatalf("replace tags: %v", err)
+	}
+	got, err := f.content.GetByID(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("get content: %v", err)
+	}
+	if want := []string{"kids", "sport"}; !slices.Equal(got.Tags, want) {
+		t.Fatalf("expected tags %v, got %v", want, got.Tags)
+	}
+
+	if err := f.content.SetTags(ctx, ids[0], nil); err != nil {
+		t.Fatalf("clear tags: %v", err)
+	}
+	if got, _ := f.content.GetByID(ctx, ids[0]); len(got.Tags) != 0 {
+		t.Fatalf("expected no tags, got %v", got.Tags)
+	}
+	if err := f.content.SetTags(ctx, ids[0]+1, []string{"kids"}); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestChannelItemRepoListsCollectionItems(t *testing.T) {
+	f := newPlaylistFixture(t, "a")
+	ctx := context.Background()
+
+	item := &service.ChannelItem{ChannelID: f.chID, CollectionID: 5}
+	if err := f.items.Append(ctx, item); err != nil {
+		t.Fatalf("append collection item: %v", err)
+	}
+	items, err := f.items.ListByChannel(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	if len(items) != 2 || items[1].ID != item.ID || items[1].CollectionID != 5 || items[1].ContentID != 0 || items[1].Content != nil {
+		t.Fatalf("unexpected items %+v", items)
+	}
+}
+
+func TestCollectionRepoDeleteDetachesReferences(t *testing.T) {
+	f := newPlaylistFixture(t, "a")
+	ids := f.contentIDs(t)
+	repo := f.collections
+	ctx := context.Background()
+
+	c := &service.Collection{Name: "Picks", ContentIDs: ids}
+	if err := repo.Create(ctx, c); err != nil {
+		t.Fatalf("create collection: %v", err)
+	}
+	if err := f.items.Append(ctx, &service.ChannelItem{ChannelID: f.chID, CollectionID: c.ID}); err != nil {
+		t.Fatalf("append collection item: %v", err)
+	}
+	b := &service.ScheduleBlock{ChannelID: f.chID, Start: "06:00", End: "07:00", CollectionID: c.ID}
+	if err := f.blocks.Create(ctx, b); err != nil {
+		t.Fatalf("create block: %v", err)
+	}
+
+	if err := repo.Delete(ctx, c.ID); err != nil {
+		t.Fatalf("delete collection: %v", err)
+	}
+	if err := repo.Delete(ctx, c.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if got := f.contentIDs(t); !slices.Equal(got, ids) {
+		t.Fatalf("expected only the content items to remain, got %v", got)
+	}
+	got, err := f.blocks.GetByID(ctx, f.chID, b.ID)
+	if err != nil {
+		t.Fatalf("get block: %v", err)
+	}
+	if got.CollectionID != 0 {
+		t.Fatalf("expected the block to be detached, got collection %d", got.CollectionID)
+	}
+}
+
+func TestContentDeleteRemovesCollectionEntriesAndTags(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b")
+	ids := f.contentIDs(t)
+	repo := f.collections
+	ctx := context.Background()
+
+	c := &service.Collection{Name: "Picks", ContentIDs: ids}
+	if err := repo.Create(ctx, c); err != nil {
+		t.Fatalf("create collection: %v", err)
+	}
+	if err := f.content.SetTags(ctx, ids[0], []string{"kids"}); err != nil {
+		t.Fatalf("set tags: %v", err)
+	}
+	if err := f.content.Delete(ctx, ids[0]); err != nil {
+		t.Fatalf("delete content: %v", err)
+	}
+	got, err := repo.GetByID(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("get collection: %v", err)
+	}
+	if !slices.Equal(got.ContentIDs, []uint{ids[1]}) {
+		t.Fatalf("expected only the remaining content, got %v", got.ContentIDs)
+	}
+	var tagged int64
+	if err := f.content.db.Table("content_tags").Count(&tagged).Error; err != nil {
+		t.Fatalf("count content tags: %v", err)
+	}
+	if tagged != 0 {
+		t.Fatalf("expected the content's tags to be removed, got %d", tagged)
+	}
+}
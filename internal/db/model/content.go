@@ -3,6 +3,8 @@ package model
 import (
 	"context"
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/iamseth/tiny-headend/internal/service"
 	"gorm.io/gorm"
@@ -10,96 +12,234 @@ import (
 
 type Content struct {
 	gorm.Model
-	Title  string  `gorm:"type:varchar(255);not null" json:"title"`
-	Path   string  `gorm:"type:varchar(255);not null" json:"path"`
-	Size   int64   `gorm:"not null" json:"size"`
-	Length float64 `gorm:"not null" json:"length"`
+	Title      string         `gorm:"type:varchar(255);not null" json:"title"`
+	Path       string         `gorm:"type:varchar(255);not null" json:"path"`
+	Size       int64          `gorm:"not null" json:"size"`
+	Length     float64        `gorm:"not null" json:"length"`
+	ModTime    time.Time      `json:"modTime"`
+	Container  string         `gorm:"type:varchar(32);not null;default:''" json:"container"`
+	VideoCodec string         `gorm:"type:varchar(32);not null;default:''" json:"videoCodec"`
+	AudioCodec string         `gorm:"type:varchar(32);not null;default:''" json:"audioCodec"`
+	Width      int            `gorm:"not null;default:0" json:"width"`
+	Height     int            `gorm:"not null;default:0" json:"height"`
+	Bitrate    int64          `gorm:"not null;default:0" json:"bitrate"`
+	Poster     string         `gorm:"type:varchar(255);not null;default:''" json:"poster"`
+	Tags       []Tag          `gorm:"many2many:content_tags" json:"-"`
+	Episode    *Episode       `gorm:"foreignKey:ContentID" json:"-"`
+	Tracks     []ContentTrack `gorm:"foreignKey:ContentID" json:"-"`
 }
 
 func (Content) TableName() string {
 	return "content"
 }
 
+// Tag is a label on content. Content and tags are joined through the content_tags table.
+type Tag struct {
+	ID   uint   `gorm:"primarykey"`
+	Name string `gorm:"type:varchar(64);not null;uniqueIndex"`
+}
+
+func contentFromService(c *service.Content) *Content {
+	return &Content{
+		Title:      c.Title,
+		Size:       c.Size,
+		Path:       c.Path,
+		Length:     c.Length,
+		ModTime:    c.ModTime,
+		Container:  c.Container,
+		VideoCodec: c.VideoCodec,
+		AudioCodec: c.AudioCodec,
+		Width:      c.Width,
+		Height:     c.Height,
+		Bitrate:    c.Bitrate,
+		Poster:     c.Poster,
+	}
+}
+
+func (m *Content) toService() service.Content {
+	c := service.Content{
+		ID:         m.ID,
+		Title:      m.Title,
+		Size:       m.Size,
+		Length:     m.Length,
+		Path:       m.Path,
+		ModTime:    m.ModTime,
+		Container:  m.Container,
+		VideoCodec: m.VideoCodec,
+		AudioCodec: m.AudioCodec,
+		Width:      m.Width,
+		Height:     m.Height,
+		Bitrate:    m.Bitrate,
+		Poster:     m.Poster,
+		Tags:       tagNames(m.Tags),
+		Tracks:     trackList(m.Tracks),
+	}
+	if m.Episode != nil {
+		c.Episode = m.Episode.toService()
+	}
+	return c
+}
+
+func tagNames(tags []Tag) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// withEntryContent preloads Entries, rows of table that place content in a playlist-like list,
+// in position order and with their content. Deleted content is not loaded, so an entry whose
+// content was deleted keeps a zero Content and callers skip entries with a zero Content.ID.
+func withEntryContent(db *gorm.DB, table string) *gorm.DB {
+	return db.
+		Preload("Entries", func(db *gorm.DB) *gorm.DB {
+			return db.Order(table + ".position ASC, " + table + ".id ASC")
+		}).
+		Preload("Entries.Content")
+}
+
 type ContentRepo struct {
 	db *gorm.DB
+	// searchIndexed reports whether Search can use the FTS5 index.
+	searchIndexed func() bool
 }
 
 func NewContentRepo(db *gorm.DB) *ContentRepo {
-	return &ContentRepo{db: db}
+	return &ContentRepo{db: db, searchIndexed: sync.OnceValue(func() bool { return searchIndexed(db) })}
+}
+
+// withMetadata loads content with its tags in name order, its episode and its tracks.
+func (r *ContentRepo) withMetadata(ctx context.Context) *gorm.DB {
+	return withTracks(withEpisode(r.db.WithContext(ctx)), "Tracks").Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.name ASC")
+	})
 }
 
 func (r *ContentRepo) Create(ctx context.Context, c *service.Content) error {
-	m := &Content{
-		Title:  c.Title,
-		Size:   c.Size,
-		Path:   c.Path,
-		Length: c.Length,
-	}
-	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
-		return err
-	}
-	c.ID = m.ID
-	return nil
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		m := contentFromService(c)
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		c.ID = m.ID
+		if err := saveTracks(tx, c.ID, c.Tracks); err != nil {
+			return err
+		}
+		if c.Episode == nil {
+			return nil
+		}
+		return saveEpisode(tx, c.ID, c.Episode)
+	})
 }
 
 func (r *ContentRepo) GetByID(ctx context.Context, id uint) (*service.Content, error) {
 	var m Content
-	if err := r.db.WithContext(ctx).First(&m, id).Error; err != nil {
+	if err := r.withMetadata(ctx).First(&m, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, service.ErrNotFound
 		}
 		return nil, err
 	}
-	return &service.Content{
-		ID:     m.ID,
-		Title:  m.Title,
-		Size:   m.Size,
-		Length: m.Length,
-		Path:   m.Path,
-	}, nil
+	c := m.toService()
+	return &c, nil
 }
 
 func (r *ContentRepo) List(ctx context.Context, limit, offset int) ([]service.Content, error) {
 	var ms []Content
-	if err := r.db.WithContext(ctx).Order("id ASC").Limit(limit).Offset(offset).Find(&ms).Error; err != nil {
+	if err := r.withMetadata(ctx).Order("id ASC").Limit(limit).Offset(offset).Find(&ms).Error; err != nil {
 		return nil, err
 	}
 	contents := make([]service.Content, len(ms))
 	for i, m := range ms {
-		contents[i] = service.Content{
-			ID:     m.ID,
-			Title:  m.Title,
-			Size:   m.Size,
-			Length: m.Length,
-			Path:   m.Path,
-		}
+		contents[i] = m.toService()
 	}
 	return contents, nil
 }
 
+// Update replaces the content's details, tracks and episode. Its tags are left alone.
 func (r *ContentRepo) Update(ctx context.Context, c *service.Content) error {
-	res := r.db.WithContext(ctx).Model(&Content{}).Where("id = ?", c.ID).Updates(map[string]any{
-		"title":  c.Title,
-		"size":   c.Size,
-		"length": c.Length,
-		"path":   c.Path,
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Content{}).Where("id = ?", c.ID).Updates(map[string]any{
+			"title":       c.Title,
+			"size":        c.Size,
+			"length":      c.Length,
+			"path":        c.Path,
+			"mod_time":    c.ModTime,
+			"container":   c.Container,
+			"video_codec": c.VideoCodec,
+			"audio_codec": c.AudioCodec,
+			"width":       c.Width,
+			"height":      c.Height,
+			"bitrate":     c.Bitrate,
+			"poster":      c.Poster,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return service.ErrNotFound
+		}
+		if err := saveTracks(tx, c.ID, c.Tracks); err != nil {
+			return err
+		}
+		return saveEpisode(tx, c.ID, c.Episode)
 	})
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return service.ErrNotFound
-	}
-	return nil
 }
 
 func (r *ContentRepo) Delete(ctx context.Context, id uint) error {
-	res := r.db.WithContext(ctx).Delete(&Content{}, id)
-	if res.Error != nil {
-		return res.Error
-	}
-	if res.RowsAffected == 0 {
-		return service.ErrNotFound
-	}
-	return nil
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&Content{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return service.ErrNotFound
+		}
+		if err := tx.Where("content_id = ?", id).Delete(&ChannelItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("content_id = ?", id).Delete(&ScheduleBlockEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("content_id = ?", id).Delete(&FillerPoolEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("content_id = ?", id).Delete(&CollectionEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("content_id = ?", id).Delete(&ContentTrack{}).Error; err != nil {
+			return err
+		}
+		if err := deleteEpisode(tx, id); err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM content_tags WHERE content_id = ?", id).Error
+	})
+}
+
+// SetTags replaces the tags on a content item, creating tags that do not exist yet.
+func (r *ContentRepo) SetTags(ctx context.Context, contentID uint, names []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var m Content
+		if err := tx.First(&m, contentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return service.ErrNotFound
+			}
+			return err
+		}
+		tags := make([]Tag, len(names))
+		for i, name := range names {
+			if err := tx.Where(Tag{Name: name}).FirstOrCreate(&tags[i]).Error; err != nil {
+				return err
+			}
+		}
+		if len(tags) == 0 {
+			return tx.Model(&m).Association("Tags").Clear()
+		}
+		return tx.Model(&m).Association("Tags").Replace(tags)
+	})
 }
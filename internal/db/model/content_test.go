@@ -5,6 +5,7 @@ import (
 	"errors"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/iamseth/tiny-headend/internal/service"
 	"gorm.io/driver/sqlite"
@@ -20,7 +21,7 @@ func newTestRepo(t *testing.T) *ContentRepo {
 		t.Fatalf("open db: %v", err)
 	}
 
-	if err := g.AutoMigrate(&Content{}); err != nil {
+	if err := g.AutoMigrate(&Content{}, &Channel{}, &ChannelItem{}, &ScheduleBlock{}, &ScheduleBlockEntry{}, &FillerPool{}, &FillerPoolEntry{}, &Tag{}, &Collection{}, &CollectionEntry{}, &Show{}, &Season{}, &Episode{}, &ChannelCursor{}, &ContentTrack{}, &ChannelLogo{}); err != nil {
 		t.Fatalf("migrate db: %v", err)
 	}
 
@@ -166,3 +167,75 @@ func TestContentRepoDeleteNotFound(t *testing.T) {
 		t.Fatalf("expected ErrNotFound, got: %v", err)
 	}
 }
+
+func TestContentRepoPreservesModTime(t *testing.T) {
+	repo := newTestRepo(t)
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	c := &service.Content{
+		Title:   "title",
+		Path:    "/tmp/file.ts",
+		ModTime: modTime,
+	}
+	if err := repo.Create(context.Background(), c); err != nil {
+		t.Fatalf("create content: %v", err)
+	}
+
+	got, err := repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get content: %v", err)
+	}
+	if !got.ModTime.Equal(modTime) {
+		t.Fatalf("expected mod time %v, got %v", modTime, got.ModTime)
+	}
+
+	c.ModTime = modTime.Add(time.Hour)
+	if err := repo.Update(context.Background(), c); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+	got, err = repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get content: %v", err)
+	}
+	if !got.ModTime.Equal(c.ModTime) {
+		t.Fatalf("expected mod time %v, got %v", c.ModTime, got.ModTime)
+	}
+}
+
+func TestContentRepoPersistsProbedFields(t *testing.T) {
+	repo := newTestRepo(t)
+	c := &service.Content{
+		Title:      "title",
+		Path:       "/tmp/file.mkv",
+		Container:  "matroska",
+		VideoCodec: "hevc",
+		AudioCodec: "opus",
+		Width:      3840,
+		Height:     2160,
+		Bitrate:    8000000,
+	}
+	if err := repo.Create(context.Background(), c); err != nil {
+		t.Fatalf("create content: %v", err)
+	}
+
+	got, err := repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get content: %v", err)
+	}
+	if got.Container != c.Container || got.VideoCodec != c.VideoCodec || got.AudioCodec != c.AudioCodec ||
+		got.Width != c.Width || got.Height != c.Height || got.Bitrate != c.Bitrate {
+		t.Fatalf("expected %+v, got %+v", c, got)
+	}
+
+	c.VideoCodec = ""
+	c.Width = 0
+	if err := repo.Update(context.Background(), c); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+	got, err = repo.GetByID(context.Background(), c.ID)
+	if err != nil {
+		t.Fatalf("get content: %v", err)
+	}
+	if got.VideoCodec != "" || got.Width != 0 {
+		t.Fatalf("expected zero values to be persisted, got %+v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChannelCursor is where a channel's playlist has got to. A channel has at most one.
+type ChannelCursor struct {
+	ChannelID uint      `gorm:"primarykey;autoIncrement:false"`
+	ItemID    uint      `gorm:"not null"`
+	ContentID uint      `gorm:"not null;default:0"`
+	Position  int       `gorm:"not null;default:0"`
+	StartedAt time.Time `gorm:"not null"`
+	EndedAt   time.Time `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+type CursorRepo struct {
+	db *gorm.DB
+}
+
+func NewCursorRepo(db *gorm.DB) *CursorRepo {
+	return &CursorRepo{db: db}
+}
+
+func (r *CursorRepo) Get(ctx context.Context, channelID uint) (*service.Cursor, error) {
+	var m ChannelCursor
+	if err := r.db.WithContext(ctx).First(&m, "channel_id = ?", channelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrNotFound
+		}
+		return nil, err
+	}
+	return &service.Cursor{
+		ChannelID: m.ChannelID,
+		ItemID:    m.ItemID,
+		ContentID: m.ContentID,
+		Position:  m.Position,
+		Start:     m.StartedAt,
+		End:       m.EndedAt,
+	}, nil
+}
+
+func (r *CursorRepo) Save(ctx context.Context, c *service.Cursor) error {
+	m := &ChannelCursor{
+		ChannelID: c.ChannelID,
+		ItemID:    c.ItemID,
+		ContentID: c.ContentID,
+		Position:  c.Position,
+		StartedAt: c.Start,
+		EndedAt:   c.End,
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(m).Error
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func TestCursorRepoSaveReplacesCursor(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b")
+	ids := f.contentIDs(t)
+	repo := NewCursorRepo(f.content.db)
+	ctx := context.Background()
+
+	if _, err := repo.Get(ctx, f.chID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound before a cursor is saved, got %v", err)
+	}
+	start := time.Date(2024, time.January, 1, 6, 0, 0, 0, time.UTC)
+	for i, id := range ids {
+		c := &service.Cursor{ChannelID: f.chID, ItemID: id, ContentID: id, Position: i, Start: start, End: start.Add(30 * time.Minute)}
+		if err := repo.Save(ctx, c); err != nil {
+			t.Fatalf("save cursor: %v", err)
+		}
+		start = c.End
+	}
+	got, err := repo.Get(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("get cursor: %v", err)
+	}
+	if got.ItemID != ids[1] || got.Position != 1 || !got.Start.Equal(start.Add(-30*time.Minute)) || !got.End.Equal(start) {
+		t.Fatalf("expected the last saved cursor, got %+v", got)
+	}
+
+	if err := f.channel.Delete(ctx, f.chID); err != nil {
+		t.Fatalf("delete channel: %v", err)
+	}
+	if _, err := repo.Get(ctx, f.chID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected the cursor to be deleted with its channel, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/gorm"
+)
+
+// FillerPool is filler content a channel plays in its breaks. Pools play in ID order.
+type FillerPool struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ChannelID uint              `gorm:"not null;index"`
+	Title     string            `gorm:"type:varchar(255);not null;default:''"`
+	MinBreak  float64           `gorm:"not null;default:0"`
+	MaxBreak  float64           `gorm:"not null;default:0"`
+	Entries   []FillerPoolEntry `gorm:"foreignKey:PoolID"`
+}
+
+// FillerPoolEntry puts a Content row in a pool at Position.
+type FillerPoolEntry struct {
+	ID        uint `gorm:"primarykey"`
+	PoolID    uint `gorm:"not null;index:idx_filler_pool_entries_order,priority:1"`
+	Position  int  `gorm:"not null;index:idx_filler_pool_entries_order,priority:2"`
+	ContentID uint `gorm:"not null;index"`
+	Content   Content
+}
+
+func (m *FillerPool) toService() service.FillerPool {
+	p := service.FillerPool{
+		ID:         m.ID,
+		ChannelID:  m.ChannelID,
+		Title:      m.Title,
+		MinBreak:   m.MinBreak,
+		MaxBreak:   m.MaxBreak,
+		ContentIDs: []uint{},
+	}
+	for _, e := range m.Entries {
+		if e.Content.ID == 0 {
+			continue
+		}
+		p.ContentIDs = append(p.ContentIDs, e.ContentID)
+		p.Content = append(p.Content, e.Content.toService())
+	}
+	return p
+}
+
+type FillerPoolRepo struct {
+	db *gorm.DB
+}
+
+func NewFillerPoolRepo(db *gorm.DB) *FillerPoolRepo {
+	return &FillerPoolRepo{db: db}
+}
+
+// withEntries loads pools with their entries in order and the entries' content.
+func (r *FillerPoolRepo) withEntries(ctx context.Context) *gorm.DB {
+	return withEntryContent(withTracks(r.db.WithContext(ctx), "Entries.Content.Tracks"), "filler_pool_entries")
+}
+
+func (r *FillerPoolRepo) ListByChannel(ctx context.Context, channelID uint) ([]service.FillerPool, error) {
+	var ms []FillerPool
+	if err := r.withEntries(ctx).Where("channel_id = ?", channelID).Order("id ASC").Find(&ms).Error; err != nil {
+		return nil, err
+	}
+	pools := make([]service.FillerPool, len(ms))
+	for i, m := range ms {
+		pools[i] = m.toService()
+	}
+	return pools, nil
+}
+
+func (r *FillerPoolRepo) GetByID(ctx context.Context, channelID, id uint) (*service.FillerPool, error) {
+	var m FillerPool
+	if err := r.withEntries(ctx).Where("channel_id = ?", channelID).First(&m, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrNotFound
+		}
+		return nil, err
+	}
+	p := m.toService()
+	return &p, nil
+}
+
+func (r *FillerPoolRepo) Create(ctx context.Context, p *service.FillerPool) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		m := &FillerPool{
+			ChannelID: p.ChannelID,
+			Title:     p.Title,
+			MinBreak:  p.MinBreak,
+			MaxBreak:  p.MaxBreak,
+		}
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		p.ID = m.ID
+		return createPoolEntries(tx, p)
+	})
+}
+
+// Update replaces the pool's settings and content.
+func (r *FillerPoolRepo) Update(ctx context.Context, p *service.FillerPool) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&FillerPool{}).Where("id = ? AND channel_id = ?", p.ID, p.ChannelID).Updates(map[string]any{
+			"title":     p.Title,
+			"min_break": p.MinBreak,
+			"max_break": p.MaxBreak,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return service.ErrNotFound
+		}
+		if err := tx.Where("pool_id = ?", p.ID).Delete(&FillerPoolEntry{}).Error; err != nil {
+			return err
+		}
+		return createPoolEntries(tx, p)
+	})
+}
+
+func (r *FillerPoolRepo) Delete(ctx context.Context, channelID, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("channel_id = ?", channelID).Delete(&FillerPool{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return service.ErrNotFound
+		}
+		return tx.Where("pool_id = ?", id).Delete(&FillerPoolEntry{}).Error
+	})
+}
+
+func createPoolEntries(tx *gorm.DB, p *service.FillerPool) error {
+	if len(p.ContentIDs) == 0 {
+		return nil
+	}
+	entries := make([]FillerPoolEntry, len(p.ContentIDs))
+	for i, id := range p.ContentIDs {
+		entries[i] = FillerPoolEntry{PoolID: p.ID, ContentID: id, Position: i}
+	}
+	return tx.Omit("Content").Create(&entries).Error
+}
+
+// deleteChannelPools removes every filler pool on a channel with its entries.
+func deleteChannelPools(tx *gorm.DB, channelID uint) error {
+	poolIDs := tx.Model(&FillerPool{}).Select("id").Where("channel_id = ?", channelID)
+	if err := tx.Where("pool_id IN (?)", poolIDs).Delete(&FillerPoolEntry{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("channel_id = ?", channelID).Delete(&FillerPool{}).Error
+}
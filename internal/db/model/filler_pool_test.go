@@ -0,0 +1,86 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func TestFillerPoolRepoRoundTrip(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b", "c")
+	ids := f.contentIDs(t)
+	ctx := context.Background()
+
+	p := &service.FillerPool{ChannelID: f.chID, Title: "Ads", MinBreak: 30, MaxBreak: 120, ContentIDs: []uint{ids[2], ids[0]}}
+	if err := f.pools.Create(ctx, p); err != nil {
+		t.Fatalf("create pool: %v", err)
+	}
+	got, err := f.pools.GetByID(ctx, f.chID, p.ID)
+	if err != nil {
+		t.Fatalf("get pool: %v", err)
+	}
+	if got.Title != "Ads" || got.MinBreak != 30 || got.MaxBreak != 120 || !slices.Equal(got.ContentIDs, []uint{ids[2], ids[0]}) {
+		t.Fatalf("unexpected pool %+v", got)
+	}
+	if want := []string{"c", "a"}; !slices.Equal(contentTitles(got.Content), want) {
+		t.Fatalf("expected content %v, got %v", want, contentTitles(got.Content))
+	}
+
+	got.MaxBreak = 0
+	got.ContentIDs = []uint{ids[1]}
+	if err := f.pools.Update(ctx, got); err != nil {
+		t.Fatalf("update pool: %v", err)
+	}
+	list, err := f.pools.ListByChannel(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("list pools: %v", err)
+	}
+	if len(list) != 1 || list[0].MaxBreak != 0 || !slices.Equal(list[0].ContentIDs, []uint{ids[1]}) {
+		t.Fatalf("unexpected pools after update %+v", list)
+	}
+
+	if err := f.pools.Delete(ctx, f.chID+1, p.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound from another channel, got %v", err)
+	}
+	if err := f.pools.Delete(ctx, f.chID, p.ID); err != nil {
+		t.Fatalf("delete pool: %v", err)
+	}
+	if _, err := f.pools.GetByID(ctx, f.chID, p.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestDeletesRemoveFillerPoolEntries(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b")
+	ids := f.contentIDs(t)
+	ctx := context.Background()
+
+	p := &service.FillerPool{ChannelID: f.chID, ContentIDs: ids}
+	if err := f.pools.Create(ctx, p); err != nil {
+		t.Fatalf("create pool: %v", err)
+	}
+	if err := f.content.Delete(ctx, ids[0]); err != nil {
+		t.Fatalf("delete content: %v", err)
+	}
+	got, err := f.pools.GetByID(ctx, f.chID, p.ID)
+	if err != nil {
+		t.Fatalf("get pool: %v", err)
+	}
+	if !slices.Equal(got.ContentIDs, []uint{ids[1]}) {
+		t.Fatalf("expected only the remaining content, got %v", got.ContentIDs)
+	}
+
+	if err := f.channel.Delete(ctx, f.chID); err != nil {
+		t.Fatalf("delete channel: %v", err)
+	}
+	list, err := f.pools.ListByChannel(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("list pools: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected no pools, got %+v", list)
+	}
+}
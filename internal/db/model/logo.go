@@ -0,0 +1,69 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChannelLogo is the logo uploaded for a channel. A channel has at most one.
+type ChannelLogo struct {
+	ChannelID   uint      `gorm:"primarykey;autoIncrement:false"`
+	ContentType string    `gorm:"type:varchar(64);not null"`
+	Data        []byte    `gorm:"not null"`
+	ModTime     time.Time `gorm:"not null"`
+}
+
+// withLogo loads whether channels have a logo, leaving the images themselves unread.
+func withLogo(db *gorm.DB) *gorm.DB {
+	return db.Preload("Logo", func(db *gorm.DB) *gorm.DB {
+		return db.Select("channel_id")
+	})
+}
+
+type LogoRepo struct {
+	db *gorm.DB
+}
+
+func NewLogoRepo(db *gorm.DB) *LogoRepo {
+	return &LogoRepo{db: db}
+}
+
+func (r *LogoRepo) SetLogo(ctx context.Context, channelID uint, img *service.Image) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&Channel{}, channelID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return service.ErrNotFound
+			}
+			return err
+		}
+		m := &ChannelLogo{ChannelID: channelID, ContentType: img.ContentType, Data: img.Data, ModTime: img.ModTime}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(m).Error
+	})
+}
+
+func (r *LogoRepo) GetLogo(ctx context.Context, channelID uint) (*service.Image, error) {
+	var m ChannelLogo
+	if err := r.db.WithContext(ctx).First(&m, "channel_id = ?", channelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrNotFound
+		}
+		return nil, err
+	}
+	return &service.Image{ContentType: m.ContentType, Data: m.Data, ModTime: m.ModTime}, nil
+}
+
+func (r *LogoRepo) DeleteLogo(ctx context.Context, channelID uint) error {
+	res := r.db.WithContext(ctx).Where("channel_id = ?", channelID).Delete(&ChannelLogo{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return service.ErrNotFound
+	}
+	return nil
+}
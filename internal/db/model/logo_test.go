@@ -0,0 +1,82 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func TestLogoRepoReplacesAndDeletesLogo(t *testing.T) {
+	f := newPlaylistFixture(t)
+	repo := NewLogoRepo(f.content.db)
+	ctx := context.Background()
+
+	if _, err := repo.GetLogo(ctx, f.chID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound before a logo is set, got %v", err)
+	}
+	if err := repo.SetLogo(ctx, f.chID+1, &service.Image{ContentType: "image/png", Data: []byte("x")}); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown channel, got %v", err)
+	}
+
+	modTime := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, img := range []*service.Image{
+		{ContentType: "image/png", Data: []byte("first"), ModTime: modTime},
+		{ContentType: "image/jpeg", Data: []byte("second"), ModTime: modTime.Add(time.Hour)},
+	} {
+		if err := repo.SetLogo(ctx, f.chID, img); err != nil {
+			t.Fatalf("set logo: %v", err)
+		}
+	}
+	got, err := repo.GetLogo(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("get logo: %v", err)
+	}
+	if got.ContentType != "image/jpeg" || string(got.Data) != "second" || !got.ModTime.Equal(modTime.Add(time.Hour)) {
+		t.Fatalf("expected the second logo, got %+v", got)
+	}
+
+	ch, err := f.channel.GetByID(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	channels, err := f.channel.List(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("list channels: %v", err)
+	}
+	if !ch.HasLogo || len(channels) != 1 || !channels[0].HasLogo {
+		t.Fatalf("expected the channel to have a logo, got %+v and %+v", ch, channels)
+	}
+	ch.HasLogo = false
+	if err := f.channel.Update(ctx, ch); err != nil || !ch.HasLogo {
+		t.Fatalf("expected an update to report the logo, got %+v (%v)", ch, err)
+	}
+
+	if err := repo.DeleteLogo(ctx, f.chID); err != nil {
+		t.Fatalf("delete logo: %v", err)
+	}
+	if err := repo.DeleteLogo(ctx, f.chID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound deleting a missing logo, got %v", err)
+	}
+	if ch, _ = f.channel.GetByID(ctx, f.chID); ch.HasLogo {
+		t.Fatalf("expected the channel to have no logo")
+	}
+}
+
+func TestChannelRepoDeleteRemovesLogo(t *testing.T) {
+	f := newPlaylistFixture(t)
+	repo := NewLogoRepo(f.content.db)
+	ctx := context.Background()
+
+	if err := repo.SetLogo(ctx, f.chID, &service.Image{ContentType: "image/png", Data: []byte("x")}); err != nil {
+		t.Fatalf("set logo: %v", err)
+	}
+	if err := f.channel.Delete(ctx, f.chID); err != nil {
+		t.Fatalf("delete channel: %v", err)
+	}
+	if _, err := repo.GetLogo(ctx, f.chID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected the logo to be deleted with its channel, got %v", err)
+	}
+}
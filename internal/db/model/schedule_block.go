@@ -0,0 +1,192 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/gorm"
+)
+
+// ScheduleBlock is a time-of-day rule on a channel. Days holds the weekday names joined with
+// commas. CollectionID is the collection the block plays instead of its entries, or zero.
+type ScheduleBlock struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ChannelID    uint                 `gorm:"not null;index"`
+	Title        string               `gorm:"type:varchar(255);not null;default:''"`
+	Days         string               `gorm:"type:varchar(32);not null;default:''"`
+	Start        string               `gorm:"column:start_time;type:varchar(5);not null"`
+	End          string               `gorm:"column:end_time;type:varchar(5);not null"`
+	Shuffle      bool                 `gorm:"not null;default:false"`
+	CollectionID uint                 `gorm:"not null;default:0;index"`
+	Entries      []ScheduleBlockEntry `gorm:"foreignKey:BlockID"`
+}
+
+// ScheduleBlockEntry puts a Content row in a block, either as a programme or as filler, at
+// Position among the block's entries of the same kind.
+type ScheduleBlockEntry struct {
+	ID        uint `gorm:"primarykey"`
+	BlockID   uint `gorm:"not null;index:idx_schedule_block_entries_order,priority:1"`
+	Filler    bool `gorm:"not null;index:idx_schedule_block_entries_order,priority:2"`
+	Position  int  `gorm:"not null;index:idx_schedule_block_entries_order,priority:3"`
+	ContentID uint `gorm:"not null;index"`
+	Content   Content
+}
+
+func (m *ScheduleBlock) toService() service.ScheduleBlock {
+	b := service.ScheduleBlock{
+		ID:           m.ID,
+		ChannelID:    m.ChannelID,
+		Title:        m.Title,
+		Days:         []string{},
+		Start:        m.Start,
+		End:          m.End,
+		Shuffle:      m.Shuffle,
+		ContentIDs:   []uint{},
+		FillerIDs:    []uint{},
+		CollectionID: m.CollectionID,
+	}
+	if m.Days != "" {
+		b.Days = strings.Split(m.Days, ",")
+	}
+	for _, e := range m.Entries {
+		if e.Content.ID == 0 {
+			continue
+		}
+		if e.Filler {
+			b.FillerIDs = append(b.FillerIDs, e.ContentID)
+			b.Filler = append(b.Filler, e.Content.toService())
+		} else {
+			b.ContentIDs = append(b.ContentIDs, e.ContentID)
+			b.Programmes = append(b.Programmes, e.Content.toService())
+		}
+	}
+	return b
+}
+
+func scheduleBlockEntries(b *service.ScheduleBlock) []ScheduleBlockEntry {
+	entries := make([]ScheduleBlockEntry, 0, len(b.ContentIDs)+len(b.FillerIDs))
+	for i, id := range b.ContentIDs {
+		entries = append(entries, ScheduleBlockEntry{BlockID: b.ID, ContentID: id, Position: i})
+	}
+	for i, id := range b.FillerIDs {
+		entries = append(entries, ScheduleBlockEntry{BlockID: b.ID, ContentID: id, Position: i, Filler: true})
+	}
+	return entries
+}
+
+type ScheduleBlockRepo struct {
+	db *gorm.DB
+}
+
+func NewScheduleBlockRepo(db *gorm.DB) *ScheduleBlockRepo {
+	return &ScheduleBlockRepo{db: db}
+}
+
+// withEntries loads blocks with their entries in order and the entries' content.
+func (r *ScheduleBlockRepo) withEntries(ctx context.Context) *gorm.DB {
+	return withEntryContent(withTracks(r.db.WithContext(ctx), "Entries.Content.Tracks"), "schedule_block_entries").
+		Preload("Entries.Content.Episode.Season.Show")
+}
+
+// ListByChannel returns the channel's blocks ordered by start time.
+func (r *ScheduleBlockRepo) ListByChannel(ctx context.Context, channelID uint) ([]service.ScheduleBlock, error) {
+	var ms []ScheduleBlock
+	if err := r.withEntries(ctx).Where("channel_id = ?", channelID).Order("start_time ASC, id ASC").Find(&ms).Error; err != nil {
+		return nil, err
+	}
+	blocks := make([]service.ScheduleBlock, len(ms))
+	for i, m := range ms {
+		blocks[i] = m.toService()
+	}
+	return blocks, nil
+}
+
+func (r *ScheduleBlockRepo) GetByID(ctx context.Context, channelID, id uint) (*service.ScheduleBlock, error) {
+	var m ScheduleBlock
+	if err := r.withEntries(ctx).Where("channel_id = ?", channelID).First(&m, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, service.ErrNotFound
+		}
+		return nil, err
+	}
+	b := m.toService()
+	return &b, nil
+}
+
+func (r *ScheduleBlockRepo) Create(ctx context.Context, b *service.ScheduleBlock) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		m := &ScheduleBlock{
+			ChannelID:    b.ChannelID,
+			Title:        b.Title,
+			Days:         strings.Join(b.Days, ","),
+			Start:        b.Start,
+			End:          b.End,
+			Shuffle:      b.Shuffle,
+			CollectionID: b.CollectionID,
+		}
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+		b.ID = m.ID
+		return createEntries(tx, b)
+	})
+}
+
+// Update replaces the block's rule and content.
+func (r *ScheduleBlockRepo) Update(ctx context.Context, b *service.ScheduleBlock) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&ScheduleBlock{}).Where("id = ? AND channel_id = ?", b.ID, b.ChannelID).Updates(map[string]any{
+			"title":         b.Title,
+			"days":          strings.Join(b.Days, ","),
+			"start_time":    b.Start,
+			"end_time":      b.End,
+			"shuffle":       b.Shuffle,
+			"collection_id": b.CollectionID,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return service.ErrNotFound
+		}
+		if err := tx.Where("block_id = ?", b.ID).Delete(&ScheduleBlockEntry{}).Error; err != nil {
+			return err
+		}
+		return createEntries(tx, b)
+	})
+}
+
+func (r *ScheduleBlockRepo) Delete(ctx context.Context, channelID, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("channel_id = ?", channelID).Delete(&ScheduleBlock{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return service.ErrNotFound
+		}
+		return tx.Where("block_id = ?", id).Delete(&ScheduleBlockEntry{}).Error
+	})
+}
+
+func createEntries(tx *gorm.DB, b *service.ScheduleBlock) error {
+	entries := scheduleBlockEntries(b)
+	if len(entries) == 0 {
+		return nil
+	}
+	return tx.Omit("Content").Create(&entries).Error
+}
+
+// deleteChannelBlocks removes every block on a channel with its entries.
+func deleteChannelBlocks(tx *gorm.DB, channelID uint) error {
+	blockIDs := tx.Model(&ScheduleBlock{}).Select("id").Where("channel_id = ?", channelID)
+	if err := tx.Where("block_id IN (?)", blockIDs).Delete(&ScheduleBlockEntry{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("channel_id = ?", channelID).Delete(&ScheduleBlock{}).Error
+}
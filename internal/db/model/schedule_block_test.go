@@ -0,0 +1,170 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+// contentIDs returns the IDs of the fixture's content, in playlist order.
+func (f *playlistFixture) contentIDs(t *testing.T) []uint {
+	t.Helper()
+	items, err := f.items.ListByChannel(context.Background(), f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	ids := make([]uint, len(items))
+	for i, it := range items {
+		ids[i] = it.ContentID
+	}
+	return ids
+}
+
+func contentTitles(content []service.Content) []string {
+	out := make([]string, len(content))
+	for i, c := range content {
+		out[i] = c.Title
+	}
+	return out
+}
+
+func TestScheduleBlockRepoRoundTrip(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b", "c")
+	ids := f.contentIDs(t)
+	ctx := context.Background()
+
+	b := &service.ScheduleBlock{
+		ChannelID:  f.chID,
+		Title:      "Mornings",
+		Days:       []string{"mon", "tue"},
+		Start:      "06:00",
+		End:        "09:00",
+		ContentIDs: []uint{ids[2], ids[0]},
+		FillerIDs:  []uint{ids[1]},
+	}
+	if err := f.blocks.Create(ctx, b); err != nil {
+		t.Fatalf("create block: %v", err)
+	}
+
+	got, err := f.blocks.GetByID(ctx, f.chID, b.ID)
+	if err != nil {
+		t.Fatalf("get block: %v", err)
+	}
+	if got.Title != "Mornings" || got.Start != "06:00" || got.End != "09:00" || !slices.Equal(got.Days, []string{"mon", "tue"}) {
+		t.Fatalf("unexpected block %+v", got)
+	}
+	if !slices.Equal(got.ContentIDs, []uint{ids[2], ids[0]}) || !slices.Equal(got.FillerIDs, []uint{ids[1]}) {
+		t.Fatalf("unexpected content %v, filler %v", got.ContentIDs, got.FillerIDs)
+	}
+	if want := []string{"c", "a"}; !slices.Equal(contentTitles(got.Programmes), want) {
+		t.Fatalf("expected programmes %v, got %v", want, contentTitles(got.Programmes))
+	}
+
+	got.Days = []string{}
+	got.Shuffle = true
+	got.ContentIDs = []uint{ids[1]}
+	got.FillerIDs = []uint{}
+	if err := f.blocks.Update(ctx, got); err != nil {
+		t.Fatalf("update block: %v", err)
+	}
+	list, err := f.blocks.ListByChannel(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("list blocks: %v", err)
+	}
+	if len(list) != 1 || !list[0].Shuffle || len(list[0].Days) != 0 || !slices.Equal(list[0].ContentIDs, []uint{ids[1]}) || len(list[0].FillerIDs) != 0 {
+		t.Fatalf("unexpected blocks after update %+v", list)
+	}
+}
+
+func TestScheduleBlockRepoListOrdersByStart(t *testing.T) {
+	f := newPlaylistFixture(t, "a")
+	ids := f.contentIDs(t)
+	ctx := context.Background()
+
+	for _, start := range []string{"18:00", "06:00"} {
+		b := &service.ScheduleBlock{ChannelID: f.chID, Start: start, End: "07:00", ContentIDs: ids}
+		if err := f.blocks.Create(ctx, b); err != nil {
+			t.Fatalf("create block: %v", err)
+		}
+	}
+	list, err := f.blocks.ListByChannel(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("list blocks: %v", err)
+	}
+	if len(list) != 2 || list[0].Start != "06:00" || list[1].Start != "18:00" {
+		t.Fatalf("unexpected order %+v", list)
+	}
+}
+
+func TestScheduleBlockRepoScopesToChannel(t *testing.T) {
+	f := newPlaylistFixture(t, "a")
+	ids := f.contentIDs(t)
+	ctx := context.Background()
+
+	b := &service.ScheduleBlock{ChannelID: f.chID, Start: "06:00", End: "07:00", ContentIDs: ids}
+	if err := f.blocks.Create(ctx, b); err != nil {
+		t.Fatalf("create block: %v", err)
+	}
+	if _, err := f.blocks.GetByID(ctx, f.chID+1, b.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	other := *b
+	other.ChannelID = f.chID + 1
+	if err := f.blocks.Update(ctx, &other); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := f.blocks.Delete(ctx, f.chID+1, b.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := f.blocks.Delete(ctx, f.chID, b.ID); err != nil {
+		t.Fatalf("delete block: %v", err)
+	}
+	if _, err := f.blocks.GetByID(ctx, f.chID, b.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestContentDeleteRemovesScheduleBlockEntries(t *testing.T) {
+	f := newPlaylistFixture(t, "a", "b")
+	ids := f.contentIDs(t)
+	ctx := context.Background()
+
+	b := &service.ScheduleBlock{ChannelID: f.chID, Start: "06:00", End: "07:00", ContentIDs: ids}
+	if err := f.blocks.Create(ctx, b); err != nil {
+		t.Fatalf("create block: %v", err)
+	}
+	if err := f.content.Delete(ctx, ids[0]); err != nil {
+		t.Fatalf("delete content: %v", err)
+	}
+	got, err := f.blocks.GetByID(ctx, f.chID, b.ID)
+	if err != nil {
+		t.Fatalf("get block: %v", err)
+	}
+	if !slices.Equal(got.ContentIDs, []uint{ids[1]}) {
+		t.Fatalf("expected only the remaining content, got %v", got.ContentIDs)
+	}
+}
+
+func TestChannelDeleteRemovesScheduleBlocks(t *testing.T) {
+	f := newPlaylistFixture(t, "a")
+	ids := f.contentIDs(t)
+	ctx := context.Background()
+
+	b := &service.ScheduleBlock{ChannelID: f.chID, Start: "06:00", End: "07:00", ContentIDs: ids}
+	if err := f.blocks.Create(ctx, b); err != nil {
+		t.Fatalf("create block: %v", err)
+	}
+	if err := f.channel.Delete(ctx, f.chID); err != nil {
+		t.Fatalf("delete channel: %v", err)
+	}
+	list, err := f.blocks.ListByChannel(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("list blocks: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected no blocks, got %+v", list)
+	}
+}
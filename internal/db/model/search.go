@@ -0,0 +1,212 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/gorm"
+)
+
+// searchTable is the FTS5 index of content. Its rowid is the content ID, and triggers keep it in
+// step with the content, content_tags and episodes tables.
+const searchTable = "content_search"
+
+// searchSnippetTokens is how many tokens a search snippet holds.
+const searchSnippetTokens = 12
+
+// searchRows selects the indexed text of live content: its title, its path, its tag names and,
+// for episodes, the show and episode titles.
+const searchRows = `SELECT c.id, c.title, c.path,
+	COALESCE((SELECT group_concat(t.name, ' ') FROM content_tags ct JOIN tags t ON t.id = ct.tag_id WHERE ct.content_id = c.id), ''),
+	COALESCE((SELECT s.title || ' ' || e.title FROM episodes e JOIN seasons se ON se.id = e.season_id JOIN shows s ON s.id = se.show_id WHERE e.content_id = c.id), '')
+	FROM content c WHERE c.deleted_at IS NULL`
+
+// reindexSQL re-reads the index rows of the content whose ID is id, an expression such as NEW.id.
+func reindexSQL(id string) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE rowid = %s; INSERT INTO %s(rowid, title, path, tags, episode) %s AND c.id = %s;",
+		searchTable, id, searchTable, searchRows, id)
+}
+
+// searchTriggers reindex content whenever its row, tags or episode change. Soft-deleted content
+// drops out of the index because searchRows skips it. Shows and tags are never renamed, so their
+// tables need no triggers.
+var searchTriggers = []struct{ name, event, id string }{
+	{"content_search_content_insert", "AFTER INSERT ON content", "NEW.id"},
+	{"content_search_content_update", "AFTER UPDATE ON content", "NEW.id"},
+	{"content_search_content_delete", "AFTER DELETE ON content", "OLD.id"},
+	{"content_search_tags_insert", "AFTER INSERT ON content_tags", "NEW.content_id"},
+	{"content_search_tags_delete", "AFTER DELETE ON content_tags", "OLD.content_id"},
+	{"content_search_episode_insert", "AFTER INSERT ON episodes", "NEW.content_id"},
+	{"content_search_episode_update", "AFTER UPDATE ON episodes", "NEW.content_id"},
+	{"content_search_episode_delete", "AFTER DELETE ON episodes", "OLD.content_id"},
+}
+
+// MigrateSearch creates the content search index and its triggers when SQLite has FTS5, and
+// reports whether it does. The index is only rebuilt from the library when it is new or its
+// triggers were missing or out of date, since it may then have missed changes. Without FTS5 the
+// triggers are dropped, as a database indexed by a build with FTS5 would otherwise fail every
+// write, and search falls back to substring matching. Run it after the tables it indexes are
+// migrated.
+func MigrateSearch(db *gorm.DB) (bool, error) {
+	available, err := fts5Available(db)
+	if err != nil {
+		return false, err
+	}
+	if !available {
+		for _, t := range searchTriggers {
+			if err := db.Exec("DROP TRIGGER IF EXISTS " + t.name).Error; err != nil {
+				return false, fmt.Errorf("drop search trigger: %w", err)
+			}
+		}
+		return false, nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		stale := !tx.Migrator().HasTable(searchTable)
+		create := fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(title, path, tags, episode, tokenize = 'unicode61 remove_diacritics 2')", searchTable)
+		if err := tx.Exec(create).Error; err != nil {
+			return err
+		}
+		for _, t := range searchTriggers {
+			want := fmt.Sprintf("CREATE TRIGGER %s %s BEGIN %s END", t.name, t.event, reindexSQL(t.id))
+			var got string
+			if err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'trigger' AND name = ?", t.name).Scan(&got).Error; err != nil {
+				return err
+			}
+			if got == want {
+				continue
+			}
+			stale = true
+			if err := tx.Exec("DROP TRIGGER IF EXISTS " + t.name).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(want).Error; err != nil {
+				return err
+			}
+		}
+		if !stale {
+			return nil
+		}
+		if err := tx.Exec("DELETE FROM " + searchTable).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf("INSERT INTO %s(rowid, title, path, tags, episode) %s", searchTable, searchRows)).Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("build search index: %w", err)
+	}
+	return true, nil
+}
+
+// fts5Available reports whether SQLite was compiled with FTS5.
+func fts5Available(db *gorm.DB) (bool, error) {
+	var used bool
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used).Error; err != nil {
+		return false, fmt.Errorf("check sqlite for fts5: %w", err)
+	}
+	return used, nil
+}
+
+// searchIndexed reports whether the search index can be queried: SQLite has FTS5 and the index
+// has been created.
+func searchIndexed(db *gorm.DB) bool {
+	available, err := fts5Available(db)
+	return err == nil && available && db.Migrator().HasTable(searchTable)
+}
+
+// searchTerms splits a query into its words.
+func searchTerms(query string) []string {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		if term = strings.ReplaceAll(term, `"`, ""); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// matchExpr builds an FTS5 query matching content with every term as a word prefix. Quoting each
+// term keeps operators and punctuation in the query from being read as FTS5 syntax.
+func matchExpr(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Search finds the content matching every word of query, best matches first. Title matches weigh
+// most, then tags and episode titles, then paths. Each result carries a snippet of the text that
+// matched, with the matches wrapped in <mark> tags. Without the search index it falls back to
+// matching substrings of titles, paths, tags and show titles, ordered by title. Whether the index is
+// there is checked once, on the repo's first search.
+func (r *ContentRepo) Search(ctx context.Context, query string, limit, offset int) ([]service.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if !r.searchIndexed() {
+		return r.searchLike(ctx, terms, limit, offset)
+	}
+
+	var hits []struct {
+		ID      uint
+		Snippet string
+	}
+	err := r.db.WithContext(ctx).Raw(
+		fmt.Sprintf(`SELECT rowid AS id, snippet(%[1]s, -1, '<mark>', '</mark>', '…', ?) AS snippet
+			FROM %[1]s WHERE %[1]s MATCH ? ORDER BY bm25(%[1]s, 10.0, 1.0, 5.0, 5.0), rowid LIMIT ? OFFSET ?`, searchTable),
+		searchSnippetTokens, matchExpr(terms), limit, offset,
+	).Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	var ms []Content
+	if err := r.withMetadata(ctx).Where("id IN ?", ids).Find(&ms).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*Content, len(ms))
+	for i := range ms {
+		byID[ms[i].ID] = &ms[i]
+	}
+	results := make([]service.SearchResult, 0, len(hits))
+	for _, h := range hits {
+		if m, ok := byID[h.ID]; ok {
+			results = append(results, service.SearchResult{Content: m.toService(), Snippet: h.Snippet})
+		}
+	}
+	return results, nil
+}
+
+// searchLike is Search without the FTS5 index. Its snippets are the content titles.
+func (r *ContentRepo) searchLike(ctx context.Context, terms []string, limit, offset int) ([]service.SearchResult, error) {
+	q := r.withMetadata(ctx).Model(&Content{})
+	for _, term := range terms {
+		like := "%" + escapeLike(term) + "%"
+		q = q.Where(`(content.title LIKE @like ESCAPE '\' OR content.path LIKE @like ESCAPE '\'
+			OR EXISTS (SELECT 1 FROM content_tags ct JOIN tags t ON t.id = ct.tag_id
+				WHERE ct.content_id = content.id AND t.name LIKE @like ESCAPE '\')
+			OR EXISTS (SELECT 1 FROM episodes e JOIN seasons se ON se.id = e.season_id JOIN shows s ON s.id = se.show_id
+				WHERE e.content_id = content.id AND (s.title LIKE @like ESCAPE '\' OR e.title LIKE @like ESCAPE '\')))`,
+			map[string]any{"like": like})
+	}
+	var ms []Content
+	if err := q.Order("content.title ASC").Order("content.id ASC").Limit(limit).Offset(offset).Find(&ms).Error; err != nil {
+		return nil, err
+	}
+	results := make([]service.SearchResult, len(ms))
+	for i := range ms {
+		results[i] = service.SearchResult{Content: ms[i].toService(), Snippet: ms[i].Title}
+	}
+	return results, nil
+}
@@ -0,0 +1,220 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+// newSearchRepo returns a content repo with the search index built, and whether SQLite has FTS5.
+// Without it, search falls back to substring matching.
+func newSearchRepo(t *testing.T) (*ContentRepo, bool) {
+	t.Helper()
+	repo := newTestRepo(t)
+	indexed, err := MigrateSearch(repo.db)
+	if err != nil {
+		t.Fatalf("migrate search: %v", err)
+	}
+	return repo, indexed
+}
+
+func searchTitles(t *testing.T, repo *ContentRepo, query string) []string {
+	t.Helper()
+	results, err := repo.Search(context.Background(), query, 100, 0)
+	if err != nil {
+		t.Fatalf("search %q: %v", query, err)
+	}
+	titles := make([]string, len(results))
+	for i, r := range results {
+		titles[i] = r.Title
+	}
+	return titles
+}
+
+func TestContentRepoSearchMatchesTitlePathTagsAndEpisodes(t *testing.T) {
+	repo, _ := newSearchRepo(t)
+	ctx := context.Background()
+	matrix := &service.Content{Title: "The Matrix", Path: "/media/movies/matrix-1999.mkv", Size: 1, Length: 1}
+	heat := &service.Content{Title: "Heat", Path: "/media/movies/heat.mkv", Size: 1, Length: 1}
+	columbo := &service.Content{
+		Title: "Murder by the Book", Path: "/media/tv/c/s01e02.mkv", Size: 1, Length: 1,
+		Episode: &service.Episode{Show: "Columbo", Season: 1, Episode: 2, Title: "Murder by the Book"},
+	}
+	for _, c := range []*service.Content{matrix, heat, columbo} {
+		if err := repo.Create(ctx, c); err != nil {
+			t.Fatalf("create content: %v", err)
+		}
+	}
+	if err := repo.SetTags(ctx, heat.ID, []string{"action", "crime"}); err != nil {
+		t.Fatalf("set tags: %v", err)
+	}
+
+	for query, want := range map[string]string{
+		"matr":         "The Matrix",
+		"1999":         "The Matrix",
+		"crime":        "Heat",
+		"colum":        "Murder by the Book",
+		"heat action":  "Heat",
+		`"AND" OR (*`:  "",
+		"matrix crime": "",
+	} {
+		got := searchTitles(t, repo, query)
+		if want == "" {
+			if len(got) != 0 {
+				t.Fatalf("search %q: expected no results, got %v", query, got)
+			}
+			continue
+		}
+		if len(got) != 1 || got[0] != want {
+			t.Fatalf("search %q: expected [%s], got %v", query, want, got)
+		}
+	}
+
+	if err := repo.SetTags(ctx, heat.ID, []string{"drama"}); err != nil {
+		t.Fatalf("set tags: %v", err)
+	}
+	if got := searchTitles(t, repo, "crime"); len(got) != 0 {
+		t.Fatalf("expected removed tag to stop matching, got %v", got)
+	}
+	if got := searchTitles(t, repo, "drama"); len(got) != 1 || got[0] != "Heat" {
+		t.Fatalf("expected new tag to match, got %v", got)
+	}
+
+	matrix.Title = "The Matrix Reloaded"
+	if err := repo.Update(ctx, matrix); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+	if got := searchTitles(t, repo, "reloaded"); len(got) != 1 {
+		t.Fatalf("expected updated title to match, got %v", got)
+	}
+
+	if err := repo.Delete(ctx, columbo.ID); err != nil {
+		t.Fatalf("delete content: %v", err)
+	}
+	if got := searchTitles(t, repo, "columbo"); len(got) != 0 {
+		t.Fatalf("expected deleted content to stop matching, got %v", got)
+	}
+}
+
+func TestContentRepoSearchPages(t *testing.T) {
+	repo, _ := newSearchRepo(t)
+	ctx := context.Background()
+	for _, title := range []string{"Space One", "Space Two", "Space Three"} {
+		if err := repo.Create(ctx, &service.Content{Title: title, Path: "/media/x.mkv", Size: 1, Length: 1}); err != nil {
+			t.Fatalf("create content: %v", err)
+		}
+	}
+
+	results, err := repo.Search(ctx, "space", 2, 1)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+}
+
+func TestContentRepoSearchRanksTitleMatchesWithSnippets(t *testing.T) {
+	repo, indexed := newSearchRepo(t)
+	if !indexed {
+		t.Skip("sqlite built without FTS5")
+	}
+	ctx := context.Background()
+	for _, c := range []*service.Content{
+		{Title: "Documentary", Path: "/media/space/cosmos.mkv", Size: 1, Length: 1},
+		{Title: "Space Odyssey", Path: "/media/2001.mkv", Size: 1, Length: 1},
+	} {
+		if err := repo.Create(ctx, c); err != nil {
+			t.Fatalf("create content: %v", err)
+		}
+	}
+
+	results, err := repo.Search(ctx, "space", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 2 || results[0].Title != "Space Odyssey" {
+		t.Fatalf("expected title match first, got %+v", results)
+	}
+	if !strings.Contains(results[0].Snippet, "<mark>Space</mark>") {
+		t.Fatalf("expected highlighted snippet, got %q", results[0].Snippet)
+	}
+}
+
+func TestMigrateSearchIndexesExistingContent(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	if err := repo.Create(ctx, &service.Content{Title: "Alien", Path: "/media/alien.mkv", Size: 1, Length: 1}); err != nil {
+		t.Fatalf("create content: %v", err)
+	}
+	for range 2 {
+		if _, err := MigrateSearch(repo.db); err != nil {
+			t.Fatalf("migrate search: %v", err)
+		}
+	}
+
+	if got := searchTitles(t, repo, "alien"); len(got) != 1 {
+		t.Fatalf("expected content created before the index to match, got %v", got)
+	}
+}
+
+func TestMigrateSearchRebuildsOnlyStaleIndex(t *testing.T) {
+	repo, indexed := newSearchRepo(t)
+	if !indexed {
+		t.Skip("sqlite built without FTS5")
+	}
+	ctx := context.Background()
+	if err := repo.Create(ctx, &service.Content{Title: "Alien", Path: "/media/alien.mkv", Size: 1, Length: 1}); err != nil {
+		t.Fatalf("create content: %v", err)
+	}
+	if err := repo.db.Exec("DELETE FROM " + searchTable).Error; err != nil {
+		t.Fatalf("clear index: %v", err)
+	}
+
+	if _, err := MigrateSearch(repo.db); err != nil {
+		t.Fatalf("migrate search: %v", err)
+	}
+	if got := searchTitles(t, repo, "alien"); len(got) != 0 {
+		t.Fatalf("expected an up-to-date index not to be rebuilt, got %v", got)
+	}
+
+	if err := repo.db.Exec("DROP TRIGGER " + searchTriggers[0].name).Error; err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	if _, err := MigrateSearch(repo.db); err != nil {
+		t.Fatalf("migrate search: %v", err)
+	}
+	if got := searchTitles(t, repo, "alien"); len(got) != 1 {
+		t.Fatalf("expected an index missing a trigger to be rebuilt, got %v", got)
+	}
+}
+
+func TestMigrateSearchWithoutFTS5DropsTriggers(t *testing.T) {
+	repo := newTestRepo(t)
+	if available, err := fts5Available(repo.db); err != nil || available {
+		t.Skip("sqlite built with FTS5")
+	}
+	// Stand in for the index and triggers left by a build with FTS5, whose writes fail here.
+	for _, stmt := range []string{
+		"CREATE TABLE " + searchTable + " (title TEXT)",
+		"CREATE TRIGGER " + searchTriggers[0].name + " AFTER INSERT ON content BEGIN SELECT RAISE(ABORT, 'no such module: fts5'); END",
+	} {
+		if err := repo.db.Exec(stmt).Error; err != nil {
+			t.Fatalf("exec %q: %v", stmt, err)
+		}
+	}
+
+	indexed, err := MigrateSearch(repo.db)
+	if err != nil || indexed {
+		t.Fatalf("expected no index and no error, got %v, %v", indexed, err)
+	}
+	ctx := context.Background()
+	if err := repo.Create(ctx, &service.Content{Title: "Alien", Path: "/media/alien.mkv", Size: 1, Length: 1}); err != nil {
+		t.Fatalf("create content: %v", err)
+	}
+	if got := searchTitles(t, repo, "alien"); len(got) != 1 {
+		t.Fatalf("expected substring search to find content, got %v", got)
+	}
+}
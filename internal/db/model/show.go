@@ -0,0 +1,158 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/gorm"
+)
+
+// episodeOrder sorts content joined to its episode and season in episode order.
+const episodeOrder = "seasons.number ASC, episodes.number ASC, episodes.air_date ASC, content.path ASC, content.id ASC"
+
+// Show is a series. It has seasons, which have episodes, which are content.
+type Show struct {
+	ID    uint   `gorm:"primarykey"`
+	Title string `gorm:"type:varchar(255);not null;uniqueIndex"`
+}
+
+// Season groups a show's episodes. Season 0 holds specials.
+type Season struct {
+	ID     uint `gorm:"primarykey"`
+	ShowID uint `gorm:"not null;uniqueIndex:idx_seasons_show_number,priority:1"`
+	Number int  `gorm:"not null;uniqueIndex:idx_seasons_show_number,priority:2"`
+	Show   Show
+}
+
+// Episode makes a Content row an episode of a season. AirDate is YYYY-MM-DD or empty.
+type Episode struct {
+	ID        uint   `gorm:"primarykey"`
+	ContentID uint   `gorm:"not null;uniqueIndex"`
+	SeasonID  uint   `gorm:"not null;index"`
+	Number    int    `gorm:"not null;default:0"`
+	Title     string `gorm:"type:varchar(255);not null;default:''"`
+	AirDate   string `gorm:"type:varchar(10);not null;default:''"`
+	Season    Season
+}
+
+func (m *Episode) toService() *service.Episode {
+	return &service.Episode{
+		ShowID:  m.Season.ShowID,
+		Show:    m.Season.Show.Title,
+		Season:  m.Season.Number,
+		Episode: m.Number,
+		Title:   m.Title,
+		AirDate: m.AirDate,
+	}
+}
+
+type ShowRepo struct {
+	db *gorm.DB
+}
+
+func NewShowRepo(db *gorm.DB) *ShowRepo {
+	return &ShowRepo{db: db}
+}
+
+// shows selects shows with their episode counts.
+func (r *ShowRepo) shows(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&Show{}).
+		Select("shows.id, shows.title, COUNT(episodes.id) AS episodes").
+		Joins("LEFT JOIN seasons ON seasons.show_id = shows.id").
+		Joins("LEFT JOIN episodes ON episodes.season_id = seasons.id").
+		Group("shows.id")
+}
+
+func (r *ShowRepo) List(ctx context.Context) ([]service.Show, error) {
+	var shows []service.Show
+	if err := r.shows(ctx).Order("shows.title ASC, shows.id ASC").Scan(&shows).Error; err != nil {
+		return nil, err
+	}
+	return shows, nil
+}
+
+func (r *ShowRepo) GetByID(ctx context.Context, id uint) (*service.Show, error) {
+	var shows []service.Show
+	if err := r.shows(ctx).Where("shows.id = ?", id).Scan(&shows).Error; err != nil {
+		return nil, err
+	}
+	if len(shows) == 0 {
+		return nil, service.ErrNotFound
+	}
+	return &shows[0], nil
+}
+
+func (r *ShowRepo) Episodes(ctx context.Context, showID uint) ([]service.Content, error) {
+	var ms []Content
+	err := withEpisode(r.db.WithContext(ctx)).
+		Joins("JOIN episodes ON episodes.content_id = content.id").
+		Joins("JOIN seasons ON seasons.id = episodes.season_id").
+		Where("seasons.show_id = ?", showID).
+		Order(episodeOrder).
+		Find(&ms).Error
+	if err != nil {
+		return nil, err
+	}
+	contents := make([]service.Content, len(ms))
+	for i, m := range ms {
+		contents[i] = m.toService()
+	}
+	return contents, nil
+}
+
+// withEpisode loads content with its episode, season and show.
+func withEpisode(db *gorm.DB) *gorm.DB {
+	return db.Preload("Episode.Season.Show")
+}
+
+// saveEpisode replaces the episode a content row is, creating its show and season as needed. A
+// nil e leaves the content without an episode.
+func saveEpisode(tx *gorm.DB, contentID uint, e *service.Episode) error {
+	if err := deleteEpisode(tx, contentID); err != nil {
+		return err
+	}
+	if e == nil {
+		return nil
+	}
+	show := Show{Title: e.Show}
+	if err := tx.Where("title = ?", e.Show).FirstOrCreate(&show).Error; err != nil {
+		return err
+	}
+	season := Season{ShowID: show.ID, Number: e.Season}
+	if err := tx.Where("show_id = ? AND number = ?", show.ID, e.Season).FirstOrCreate(&season).Error; err != nil {
+		return err
+	}
+	m := &Episode{ContentID: contentID, SeasonID: season.ID, Number: e.Episode, Title: e.Title, AirDate: e.AirDate}
+	if err := tx.Omit("Season").Create(m).Error; err != nil {
+		return err
+	}
+	e.ShowID = show.ID
+	return nil
+}
+
+// deleteEpisode removes the episode a content row is, and its season and show once they have no
+// episodes left.
+func deleteEpisode(tx *gorm.DB, contentID uint) error {
+	var m Episode
+	if err := tx.Preload("Season").Where("content_id = ?", contentID).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := tx.Delete(&m).Error; err != nil {
+		return err
+	}
+	var left int64
+	if err := tx.Model(&Episode{}).Where("season_id = ?", m.SeasonID).Count(&left).Error; err != nil || left > 0 {
+		return err
+	}
+	if err := tx.Delete(&Season{}, m.SeasonID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&Season{}).Where("show_id = ?", m.Season.ShowID).Count(&left).Error; err != nil || left > 0 {
+		return err
+	}
+	return tx.Delete(&Show{}, m.Season.ShowID).Error
+}
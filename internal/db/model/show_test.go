@@ -0,0 +1,146 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func createEpisodes(t *testing.T, f *playlistFixture, library []service.Content) {
+	t.Helper()
+	for i := range library {
+		library[i].Size = 1
+		library[i].Length = 60
+		if err := f.content.Create(context.Background(), &library[i]); err != nil {
+			t.Fatalf("create content: %v", err)
+		}
+	}
+}
+
+func TestShowRepoListsShowsAndEpisodesInOrder(t *testing.T) {
+	f := newPlaylistFixture(t)
+	shows := NewShowRepo(f.content.db)
+	ctx := context.Background()
+
+	library := []service.Content{
+		{Title: "s2e1", Path: "/tv/a.ts", Episode: &service.Episode{Show: "Cheers", Season: 2, Episode: 1}},
+		{Title: "s1e2", Path: "/tv/b.ts", Episode: &service.Episode{Show: "Cheers", Season: 1, Episode: 2, Title: "Sams Women"}},
+		{Title: "s1e1", Path: "/tv/c.ts", Episode: &service.Episode{Show: "Cheers", Season: 1, Episode: 1}},
+		{Title: "news", Path: "/tv/d.ts", Episode: &service.Episode{Show: "Nightly News", Season: 2024, AirDate: "2024-01-15"}},
+		{Title: "movie", Path: "/movies/e.ts"},
+	}
+	createEpisodes(t, f, library)
+
+	list, err := shows.List(ctx)
+	if err != nil {
+		t.Fatalf("list shows: %v", err)
+	}
+	if len(list) != 2 || list[0].Title != "Cheers" || list[0].Episodes != 3 || list[1].Title != "Nightly News" || list[1].Episodes != 1 {
+		t.Fatalf("unexpected shows %+v", list)
+	}
+	if library[0].Episode.ShowID != list[0].ID {
+		t.Fatalf("expected created content to learn its show id, got %+v", library[0].Episode)
+	}
+
+	episodes, err := shows.Episodes(ctx, list[0].ID)
+	if err != nil {
+		t.Fatalf("list episodes: %v", err)
+	}
+	if want := []string{"s1e1", "s1e2", "s2e1"}; !slices.Equal(contentTitles(episodes), want) {
+		t.Fatalf("expected episodes %v, got %v", want, contentTitles(episodes))
+	}
+	if e := episodes[1].Episode; e == nil || e.Show != "Cheers" || e.Title != "Sams Women" || e.ShowID != list[0].ID {
+		t.Fatalf("expected episodes to carry their details, got %+v", e)
+	}
+
+	members, err := f.collections.Members(ctx, &service.Collection{Query: &service.CollectionQuery{ShowID: list[0].ID}})
+	if err != nil {
+		t.Fatalf("members: %v", err)
+	}
+	if want := []string{"s1e1", "s1e2", "s2e1"}; !slices.Equal(contentTitles(members), want) {
+		t.Fatalf("expected a show collection in episode order %v, got %v", want, contentTitles(members))
+	}
+
+	if _, err := shows.GetByID(ctx, list[1].ID+1); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestContentRepoUpdateAndDeleteReplaceEpisodes(t *testing.T) {
+	f := newPlaylistFixture(t)
+	shows := NewShowRepo(f.content.db)
+	ctx := context.Background()
+
+	library := []service.Content{
+		{Title: "a", Path: "/tv/a.ts", Episode: &service.Episode{Show: "Cheers", Season: 1, Episode: 1}},
+		{Title: "b", Path: "/tv/b.ts", Episode: &service.Episode{Show: "Taxi", Season: 1, Episode: 1}},
+	}
+	createEpisodes(t, f, library)
+
+	moved := library[0]
+	moved.Episode = &service.Episode{Show: "Taxi", Season: 1, Episode: 2}
+	if err := f.content.Update(ctx, &moved); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+	got, err := f.content.GetByID(ctx, moved.ID)
+	if err != nil {
+		t.Fatalf("get content: %v", err)
+	}
+	if got.Episode == nil || got.Episode.Show != "Taxi" || got.Episode.Episode != 2 {
+		t.Fatalf("expected the episode to move to Taxi, got %+v", got.Episode)
+	}
+	list, err := shows.List(ctx)
+	if err != nil {
+		t.Fatalf("list shows: %v", err)
+	}
+	if len(list) != 1 || list[0].Title != "Taxi" || list[0].Episodes != 2 {
+		t.Fatalf("expected Cheers to be removed once empty, got %+v", list)
+	}
+
+	moved.Episode = nil
+	if err := f.content.Update(ctx, &moved); err != nil {
+		t.Fatalf("clear episode: %v", err)
+	}
+	if got, _ := f.content.GetByID(ctx, moved.ID); got.Episode != nil {
+		t.Fatalf("expected the episode to be cleared, got %+v", got.Episode)
+	}
+	if err := f.content.Delete(ctx, library[1].ID); err != nil {
+		t.Fatalf("delete content: %v", err)
+	}
+	if list, _ := shows.List(ctx); len(list) != 0 {
+		t.Fatalf("expected no shows left, got %+v", list)
+	}
+}
+
+func TestChannelItemRepoLoadsEpisodes(t *testing.T) {
+	f := newPlaylistFixture(t)
+	ctx := context.Background()
+
+	library := []service.Content{{Title: "a", Path: "/tv/a.ts", Episode: &service.Episode{Show: "Cheers", Season: 1, Episode: 3}}}
+	createEpisodes(t, f, library)
+	if err := f.items.Append(ctx, &service.ChannelItem{ChannelID: f.chID, ContentID: library[0].ID}); err != nil {
+		t.Fatalf("append item: %v", err)
+	}
+	b := &service.ScheduleBlock{ChannelID: f.chID, Start: "06:00", End: "07:00", ContentIDs: []uint{library[0].ID}}
+	if err := f.blocks.Create(ctx, b); err != nil {
+		t.Fatalf("create block: %v", err)
+	}
+
+	items, err := f.items.ListByChannel(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	if len(items) != 1 || items[0].Content.Episode == nil || items[0].Content.Episode.Show != "Cheers" {
+		t.Fatalf("expected playlist content to carry its episode, got %+v", items)
+	}
+	got, err := f.blocks.GetByID(ctx, f.chID, b.ID)
+	if err != nil {
+		t.Fatalf("get block: %v", err)
+	}
+	if len(got.Programmes) != 1 || got.Programmes[0].Episode == nil || got.Programmes[0].Episode.Episode != 3 {
+		t.Fatalf("expected block content to carry its episode, got %+v", got.Programmes)
+	}
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"github.com/iamseth/tiny-headend/internal/service"
+	"gorm.io/gorm"
+)
+
+// ContentTrack is an audio or subtitle track of a Content row. Position keeps the order the
+// prober listed them in.
+type ContentTrack struct {
+	ID        uint   `gorm:"primarykey"`
+	ContentID uint   `gorm:"not null;index"`
+	Position  int    `gorm:"not null"`
+	Kind      string `gorm:"type:varchar(16);not null"`
+	Index     int    `gorm:"column:stream_index;not null;default:0"`
+	Codec     string `gorm:"type:varchar(32);not null;default:''"`
+	Language  string `gorm:"type:varchar(35);not null;default:''"`
+	Title     string `gorm:"type:varchar(255);not null;default:''"`
+	Default   bool   `gorm:"column:is_default;not null;default:false"`
+	Forced    bool   `gorm:"not null;default:false"`
+	Path      string `gorm:"type:varchar(255);not null;default:''"`
+}
+
+func (m ContentTrack) toService() service.Track {
+	return service.Track{
+		Kind:     m.Kind,
+		Index:    m.Index,
+		Codec:    m.Codec,
+		Language: m.Language,
+		Title:    m.Title,
+		Default:  m.Default,
+		Forced:   m.Forced,
+		Path:     m.Path,
+	}
+}
+
+func trackList(ms []ContentTrack) []service.Track {
+	if len(ms) == 0 {
+		return nil
+	}
+	tracks := make([]service.Track, len(ms))
+	for i, m := range ms {
+		tracks[i] = m.toService()
+	}
+	return tracks
+}
+
+// withTracks preloads the tracks of the content at path, such as "Tracks" or "Content.Tracks", in
+// order.
+func withTracks(db *gorm.DB, path string) *gorm.DB {
+	return db.Preload(path, func(db *gorm.DB) *gorm.DB {
+		return db.Order("content_tracks.position ASC")
+	})
+}
+
+// saveTracks replaces the tracks of a content row.
+func saveTracks(tx *gorm.DB, contentID uint, tracks []service.Track) error {
+	if err := tx.Where("content_id = ?", contentID).Delete(&ContentTrack{}).Error; err != nil {
+		return err
+	}
+	if len(tracks) == 0 {
+		return nil
+	}
+	ms := make([]ContentTrack, len(tracks))
+	for i, t := range tracks {
+		ms[i] = ContentTrack{
+			ContentID: contentID,
+			Position:  i,
+			Kind:      t.Kind,
+			Index:     t.Index,
+			Codec:     t.Codec,
+			Language:  t.Language,
+			Title:     t.Title,
+			Default:   t.Default,
+			Forced:    t.Forced,
+			Path:      t.Path,
+		}
+	}
+	return tx.Create(&ms).Error
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func TestContentRepoStoresTracks(t *testing.T) {
+	f := newPlaylistFixture(t, "a")
+	ctx := context.Background()
+	items, err := f.items.ListByChannel(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	c := *items[0].Content
+	c.Tracks = []service.Track{
+		{Kind: service.TrackAudio, Index: 0, Codec: "aac", Language: "en", Default: true},
+		{Kind: service.TrackAudio, Index: 1, Codec: "ac3", Language: "de", Title: "Commentary"},
+		{Kind: service.TrackSubtitle, Codec: "subrip", Language: "fr", Forced: true, Path: "/media/a.fr.srt"},
+	}
+	c.Poster = "/media/a-poster.jpg"
+	if err := f.content.Update(ctx, &c); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+
+	got, err := f.content.GetByID(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("get content: %v", err)
+	}
+	if !slices.Equal(got.Tracks, c.Tracks) || got.Poster != c.Poster {
+		t.Fatalf("expected tracks %+v and poster %q, got %+v", c.Tracks, c.Poster, got)
+	}
+	// Content on a playlist comes with its tracks for playback.
+	items, err = f.items.ListByChannel(ctx, f.chID)
+	if err != nil {
+		t.Fatalf("list items: %v", err)
+	}
+	if !slices.Equal(items[0].Content.Tracks, c.Tracks) {
+		t.Fatalf("expected playlist content to have tracks, got %+v", items[0].Content.Tracks)
+	}
+
+	c.Tracks = c.Tracks[2:]
+	if err := f.content.Update(ctx, &c); err != nil {
+		t.Fatalf("update content: %v", err)
+	}
+	if got, _ = f.content.GetByID(ctx, c.ID); !slices.Equal(got.Tracks, c.Tracks) {
+		t.Fatalf("expected update to replace tracks, got %+v", got.Tracks)
+	}
+
+	if err := f.content.Delete(ctx, c.ID); err != nil {
+		t.Fatalf("delete content: %v", err)
+	}
+	var left int64
+	if err := f.content.db.Model(&ContentTrack{}).Count(&left).Error; err != nil || left != 0 {
+		t.Fatalf("expected tracks to be deleted with content, got %d (%v)", left, err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package epg builds XMLTV programme guides from channel timelines.
+package epg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const generatorName = "tiny-headend"
+
+// Generator renders the guide for every channel over a fixed window.
+type Generator struct {
+	channels *service.ChannelService
+	timeline *service.TimelineService
+	window   time.Duration
+}
+
+func New(channels *service.ChannelService, timeline *service.TimelineService, window time.Duration) *Generator {
+	return &Generator{channels: channels, timeline: timeline, window: window}
+}
+
+// Build returns the guide covering [now, now+window). The programme airing at now is included
+// with its real start time. Channel logos and programme posters are linked from base, the URL
+// this server is reached at, and left out when base is empty.
+func (g *Generator) Build(ctx context.Context, now time.Time, base string) (*TV, error) {
+	channels, err := g.channels.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tv := &TV{GeneratorName: generatorName}
+	end := now.Add(g.window)
+	for _, ch := range channels {
+		id := ChannelID(ch.ID)
+		channel := Channel{
+			ID:           id,
+			DisplayNames: []string{ch.Title, strconv.FormatUint(uint64(ch.ChannelNumber), 10)},
+		}
+		if ch.HasLogo && base != "" {
+			channel.Icon = &Icon{Src: LogoURL(base, ch.ID)}
+		}
+		tv.Channels = append(tv.Channels, channel)
+
+		airings, err := g.timeline.Programmes(ctx, ch.ID, now, end)
+		if err != nil {
+			return nil, fmt.Errorf("schedule channel %d: %w", ch.ID, err)
+		}
+		for _, a := range airings {
+			p := Programme{
+				Start:   Time(a.Start),
+				Stop:    Time(a.End),
+				Channel: id,
+				Title:   a.Item.Content.Title,
+			}
+			if e := a.Item.Content.Episode; e != nil {
+				describeEpisode(&p, e)
+			}
+			if a.Item.Content.Poster != "" && base != "" {
+				p.Icon = &Icon{Src: PosterURL(base, a.Item.Content.ID)}
+			}
+			tv.Programmes = append(tv.Programmes, p)
+		}
+	}
+	return tv, nil
+}
+
+// describeEpisode titles p after the episode's show and numbers it. A dated episode without a title
+// is subtitled with its air date.
+func describeEpisode(p *Programme, e *service.Episode) {
+	p.Title = e.Show
+	p.SubTitle = e.Title
+	if p.SubTitle == "" {
+		p.SubTitle = e.AirDate
+	}
+	p.Date = strings.ReplaceAll(e.AirDate, "-", "")
+	if e.Episode == 0 {
+		return
+	}
+	// xmltv_ns counts from zero and leaves the season out when it is unknown.
+	season := ""
+	if e.Season > 0 {
+		season = strconv.Itoa(e.Season - 1)
+	}
+	p.EpisodeNums = []EpisodeNum{
+		{System: "xmltv_ns", Value: fmt.Sprintf("%s.%d.", season, e.Episode-1)},
+		{System: "onscreen", Value: fmt.Sprintf("S%02dE%02d", e.Season, e.Episode)},
+	}
+}
+
+// ChannelID is the XMLTV channel id used for a channel in the guide.
+func ChannelID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// LogoURL is the URL of a channel's logo.
+func LogoURL(base string, channelID uint) string {
+	return fmt.Sprintf("%s/channels/%d/logo", base, channelID)
+}
+
+// PosterURL is the URL of a content item's poster.
+func PosterURL(base string, contentID uint) string {
+	return fmt.Sprintf("%s/content/%d/poster", base, contentID)
+}
@@ -0,0 +1,255 @@
+package epg
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+var testEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type stubChannelRepo struct {
+	channels []service.Channel
+}
+
+func (s stubChannelRepo) Create(context.Context, *service.Channel) error { return nil }
+
+func (s stubChannelRepo) GetByID(_ context.Context, id uint) (*service.Channel, error) {
+	for _, ch := range s.channels {
+		if ch.ID == id {
+			return &ch, nil
+		}
+	}
+	return nil, service.ErrNotFound
+}
+
+func (s stubChannelRepo) List(_ context.Context, limit, offset int) ([]service.Channel, error) {
+	if offset >= len(s.channels) {
+		return nil, nil
+	}
+	return s.channels[offset:min(offset+limit, len(s.channels))], nil
+}
+
+func (s stubChannelRepo) Update(context.Context, *service.Channel) error { return nil }
+func (s stubChannelRepo) Delete(context.Context, uint) error             { return nil }
+
+type stubChannelItemRepo struct {
+	items map[uint][]service.ChannelItem
+}
+
+func (s stubChannelItemRepo) ListByChannel(_ context.Context, channelID uint) ([]service.ChannelItem, error) {
+	return s.items[channelID], nil
+}
+
+func (s stubChannelItemRepo) Append(context.Context, *service.ChannelItem) error { return nil }
+func (s stubChannelItemRepo) Delete(context.Context, uint, uint) error           { return nil }
+func (s stubChannelItemRepo) Reorder(context.Context, uint, []uint) error        { return nil }
+
+func newTestGenerator(window time.Duration) *Generator {
+	channels := stubChannelRepo{channels: []service.Channel{
+		{ID: 1, Title: "News & Weather", ChannelNumber: 7, Loop: true},
+		{ID: 2, Title: "Empty", ChannelNumber: 8, Loop: true},
+	}}
+	items := stubChannelItemRepo{items: map[uint][]service.ChannelItem{
+		1: {
+			{ID: 1, ContentID: 10, Content: &service.Content{ID: 10, Title: "Morning", Length: 1800}},
+			{ID: 2, ContentID: 11, Content: &service.Content{ID: 11, Title: "Evening", Length: 3600}},
+		},
+	}}
+	return New(
+		service.NewChannelService(channels),
+		service.NewTimelineService(channels, items, testEpoch),
+		window,
+	)
+}
+
+func TestGeneratorBuildListsChannelsAndProgrammes(t *testing.T) {
+	g := newTestGenerator(2 * time.Hour)
+
+	tv, err := g.Build(context.Background(), testEpoch.Add(10*time.Minute), "")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if len(tv.Channels) != 2 {
+		t.Fatalf("expected 2 channels, got %d", len(tv.Channels))
+	}
+	if names := tv.Channels[0].DisplayNames; len(names) != 2 || names[0] != "News & Weather" || names[1] != "7" {
+		t.Fatalf("unexpected display names: %v", names)
+	}
+
+	wantTitles := []string{"Morning", "Evening", "Morning", "Evening"}
+	if len(tv.Programmes) != len(wantTitles) {
+		t.Fatalf("expected %d programmes, got %+v", len(wantTitles), tv.Programmes)
+	}
+	for i, p := range tv.Programmes {
+		if p.Title != wantTitles[i] || p.Channel != "1" {
+			t.Fatalf("programme %d: unexpected %+v", i, p)
+		}
+	}
+	if !time.Time(tv.Programmes[0].Start).Equal(testEpoch) {
+		t.Fatalf("expected the current programme to keep its start time, got %s", time.Time(tv.Programmes[0].Start))
+	}
+	if !time.Time(tv.Programmes[1].Stop).Equal(testEpoch.Add(90 * time.Minute)) {
+		t.Fatalf("expected stop from content length, got %s", time.Time(tv.Programmes[1].Stop))
+	}
+}
+
+func TestTVWriteRendersXMLTV(t *testing.T) {
+	tv, err := newTestGenerator(time.Hour).Build(context.Background(), testEpoch, "")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tv.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<!DOCTYPE tv SYSTEM "xmltv.dtd">`,
+		`<tv generator-info-name="tiny-headend">`,
+		`<channel id="1">`,
+		`<display-name>News &amp; Weather</display-name>`,
+		`<programme start="20240101000000 +0000" stop="20240101003000 +0000" channel="1">`,
+		`<title>Morning</title>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+type stubFillerPoolRepo struct {
+	pools []service.FillerPool
+}
+
+func (s stubFillerPoolRepo) ListByChannel(context.Context, uint) ([]service.FillerPool, error) {
+	return s.pools, nil
+}
+
+func (s stubFillerPoolRepo) GetByID(context.Context, uint, uint) (*service.FillerPool, error) {
+	return nil, service.ErrNotFound
+}
+
+func (s stubFillerPoolRepo) Create(context.Context, *service.FillerPool) error { return nil }
+func (s stubFillerPoolRepo) Update(context.Context, *service.FillerPool) error { return nil }
+func (s stubFillerPoolRepo) Delete(context.Context, uint, uint) error          { return nil }
+
+func TestGeneratorBuildShowsProgrammesOnBoundaries(t *testing.T) {
+	channels := stubChannelRepo{channels: []service.Channel{{ID: 1, Title: "News", ChannelNumber: 7, Loop: true, PadTo: 30}}}
+	items := stubChannelItemRepo{items: map[uint][]service.ChannelItem{
+		1: {
+			{ID: 1, ContentID: 10, Content: &service.Content{ID: 10, Title: "Morning", Length: 1320}},
+			{ID: 2, ContentID: 11, Content: &service.Content{ID: 11, Title: "Evening", Length: 2700}},
+		},
+	}}
+	pools := stubFillerPoolRepo{pools: []service.FillerPool{
+		{ID: 1, Content: []service.Content{{ID: 20, Title: "Ident", Length: 60}}},
+	}}
+	g := New(
+		service.NewChannelService(channels),
+		service.NewTimelineService(channels, items, testEpoch, service.WithFiller(pools)),
+		2*time.Hour,
+	)
+
+	// Start during the break after the first programme.
+	tv, err := g.Build(context.Background(), testEpoch.Add(25*time.Minute), "")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	want := []struct {
+		title       string
+		start, stop time.Duration
+	}{
+		{"Morning", 0, 30 * time.Minute},
+		{"Evening", 30 * time.Minute, 90 * time.Minute},
+		{"Morning", 90 * time.Minute, 120 * time.Minute},
+		{"Evening", 120 * time.Minute, 180 * time.Minute},
+	}
+	if len(tv.Programmes) != len(want) {
+		t.Fatalf("expected %d programmes, got %+v", len(want), tv.Programmes)
+	}
+	for i, w := range want {
+		p := tv.Programmes[i]
+		if p.Title != w.title || !time.Time(p.Start).Equal(testEpoch.Add(w.start)) || !time.Time(p.Stop).Equal(testEpoch.Add(w.stop)) {
+			t.Fatalf("programme %d: expected %s from %s to %s, got %+v", i, w.title, w.start, w.stop, p)
+		}
+	}
+}
+
+func TestGeneratorBuildDescribesEpisodes(t *testing.T) {
+	channels := stubChannelRepo{channels: []service.Channel{{ID: 1, Title: "Reruns", ChannelNumber: 9, Loop: true}}}
+	items := stubChannelItemRepo{items: map[uint][]service.ChannelItem{
+		1: {
+			{ID: 1, ContentID: 10, Content: &service.Content{ID: 10, Title: "Cheers S01E02", Length: 1800,
+				Episode: &service.Episode{Show: "Cheers", Season: 1, Episode: 2, Title: "Sams Women"}}},
+			{ID: 2, ContentID: 11, Content: &service.Content{ID: 11, Title: "Nightly News 2024-01-15", Length: 1800,
+				Episode: &service.Episode{Show: "Nightly News", Season: 2024, AirDate: "2024-01-15"}}},
+		},
+	}}
+	g := New(service.NewChannelService(channels), service.NewTimelineService(channels, items, testEpoch), time.Hour)
+
+	tv, err := g.Build(context.Background(), testEpoch, "")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tv.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"<title>Cheers</title>\n    <sub-title>Sams Women</sub-title>\n" +
+			`    <episode-num system="xmltv_ns">0.1.</episode-num>` + "\n" +
+			`    <episode-num system="onscreen">S01E02</episode-num>`,
+		"<title>Nightly News</title>\n    <sub-title>2024-01-15</sub-title>\n    <date>20240115</date>\n  </programme>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestGeneratorBuildLinksArtwork(t *testing.T) {
+	channels := stubChannelRepo{channels: []service.Channel{
+		{ID: 1, Title: "Films", ChannelNumber: 9, Loop: true, HasLogo: true},
+		{ID: 2, Title: "Plain", ChannelNumber: 10, Loop: true},
+	}}
+	items := stubChannelItemRepo{items: map[uint][]service.ChannelItem{
+		1: {
+			{ID: 1, ContentID: 10, Content: &service.Content{ID: 10, Title: "Heat", Length: 1800, Poster: "/media/heat/poster.jpg"}},
+			{ID: 2, ContentID: 11, Content: &service.Content{ID: 11, Title: "Ronin", Length: 1800}},
+		},
+	}}
+	g := New(service.NewChannelService(channels), service.NewTimelineService(channels, items, testEpoch), time.Hour)
+
+	tv, err := g.Build(context.Background(), testEpoch, "http://tv.local:8080")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if icon := tv.Channels[0].Icon; icon == nil || icon.Src != "http://tv.local:8080/channels/1/logo" {
+		t.Fatalf("expected a logo icon, got %+v", icon)
+	}
+	if tv.Channels[1].Icon != nil {
+		t.Fatalf("expected no icon for a channel without a logo, got %+v", tv.Channels[1].Icon)
+	}
+	if icon := tv.Programmes[0].Icon; icon == nil || icon.Src != "http://tv.local:8080/content/10/poster" {
+		t.Fatalf("expected a poster icon, got %+v", icon)
+	}
+	if tv.Programmes[1].Icon != nil {
+		t.Fatalf("expected no icon for content without a poster, got %+v", tv.Programmes[1].Icon)
+	}
+
+	if tv, err = g.Build(context.Background(), testEpoch, ""); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if tv.Channels[0].Icon != nil || tv.Programmes[0].Icon != nil {
+		t.Fatalf("expected no icons without a base URL")
+	}
+}
@@ -0,0 +1,73 @@
+package epg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// xmltvTimeFormat is the XMLTV timestamp layout, e.g. "20240101120000 +0000".
+const xmltvTimeFormat = "20060102150405 -0700"
+
+const xmltvDoctype = `<!DOCTYPE tv SYSTEM "xmltv.dtd">`
+
+// TV is the root element of an XMLTV document.
+type TV struct {
+	XMLName       xml.Name    `xml:"tv"`
+	GeneratorName string      `xml:"generator-info-name,attr,omitempty"`
+	Channels      []Channel   `xml:"channel"`
+	Programmes    []Programme `xml:"programme"`
+}
+
+type Channel struct {
+	ID           string   `xml:"id,attr"`
+	DisplayNames []string `xml:"display-name"`
+	Icon         *Icon    `xml:"icon"`
+}
+
+// Programme's child elements follow the order the XMLTV DTD requires.
+type Programme struct {
+	Start       Time         `xml:"start,attr"`
+	Stop        Time         `xml:"stop,attr"`
+	Channel     string       `xml:"channel,attr"`
+	Title       string       `xml:"title"`
+	SubTitle    string       `xml:"sub-title,omitempty"`
+	Date        string       `xml:"date,omitempty"`
+	Icon        *Icon        `xml:"icon"`
+	EpisodeNums []EpisodeNum `xml:"episode-num"`
+}
+
+// Icon points at an image for a channel or programme.
+type Icon struct {
+	Src string `xml:"src,attr"`
+}
+
+// EpisodeNum numbers an episode in the given system, e.g. "xmltv_ns" or "onscreen".
+type EpisodeNum struct {
+	System string `xml:"system,attr"`
+	Value  string `xml:",chardata"`
+}
+
+// Time is rendered in XMLTV timestamp format.
+type Time time.Time
+
+func (t Time) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	return xml.Attr{Name: name, Value: time.Time(t).UTC().Format(xmltvTimeFormat)}, nil
+}
+
+// Write renders tv as an indented XMLTV document.
+func (tv *TV) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header+xmltvDoctype+"\n"); err != nil {
+		return fmt.Errorf("write xmltv header: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(tv); err != nil {
+		return fmt.Errorf("encode xmltv: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("write xmltv trailer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	nethttp "net/http"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type ChannelHandler struct {
+	svc *service.ChannelService
+}
+
+func NewChannelHandler(svc *service.ChannelService) *ChannelHandler {
+	return &ChannelHandler{svc: svc}
+}
+
+type channelReq struct {
+	Title            string `json:"title"`
+	ChannelNumber    uint   `json:"channelNumber"`
+	Description      string `json:"description"`
+	Loop             *bool  `json:"loop"`
+	Shuffle          bool   `json:"shuffle"`
+	Profile          string `json:"profile"`
+	Timezone         string `json:"timezone"`
+	PadTo            int    `json:"padTo"`
+	AudioLanguage    string `json:"audioLanguage"`
+	SubtitleLanguage string `json:"subtitleLanguage"`
+}
+
+// channel builds a service.Channel from the request. Loop defaults to true
+// when omitted.
+func (req channelReq) channel(id uint) *service.Channel {
+	loop := req.Loop == nil || *req.Loop
+	return &service.Channel{
+		ID:               id,
+		Title:            req.Title,
+		ChannelNumber:    req.ChannelNumber,
+		Description:      req.Description,
+		Loop:             loop,
+		Shuffle:          req.Shuffle,
+		Profile:          req.Profile,
+		Timezone:         req.Timezone,
+		PadTo:            req.PadTo,
+		AudioLanguage:    req.AudioLanguage,
+		SubtitleLanguage: req.SubtitleLanguage,
+	}
+}
+
+func (h *ChannelHandler) Create(w nethttp.ResponseWriter, r *nethttp.Request) {
+	var req channelReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	c := req.channel(0)
+	if err := h.svc.Create(r.Context(), c); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(nethttp.StatusCreated)
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		slog.Error("encode create channel response", "error", err)
+	}
+}
+
+func (h *ChannelHandler) List(w nethttp.ResponseWriter, r *nethttp.Request) {
+	limit, offset, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+
+	channels, err := h.svc.List(r.Context(), limit, offset)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if channels == nil {
+		channels = []service.Channel{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(channels); err != nil {
+		slog.Error("encode list channels response", "error", err)
+	}
+}
+
+func (h *ChannelHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		slog.Error("encode get channel response", "error", err)
+	}
+}
+
+func (h *ChannelHandler) Update(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	var req channelReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	c := req.channel(id)
+	if err := h.svc.Update(r.Context(), c); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		slog.Error("encode update channel response", "error", err)
+	}
+}
+
+func (h *ChannelHandler) Delete(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), id); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(nethttp.StatusNoContent)
+}
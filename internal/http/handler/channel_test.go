@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const validChannelJSON = `{"title":"News","channelNumber":7,"description":"local news"}`
+
+type stubChannelRepo struct {
+	createFn func(context.Context, *service.Channel) error
+	getByID  func(context.Context, uint) (*service.Channel, error)
+	listFn   func(context.Context, int, int) ([]service.Channel, error)
+	updateFn func(context.Context, *service.Channel) error
+	deleteFn func(context.Context, uint) error
+}
+
+func (s *stubChannelRepo) Create(ctx context.Context, c *service.Channel) error {
+	if s.createFn == nil {
+		return nil
+	}
+	return s.createFn(ctx, c)
+}
+
+func (s *stubChannelRepo) GetByID(ctx context.Context, id uint) (*service.Channel, error) {
+	if s.getByID == nil {
+		return nil, service.ErrNotFound
+	}
+	return s.getByID(ctx, id)
+}
+
+func (s *stubChannelRepo) List(ctx context.Context, limit, offset int) ([]service.Channel, error) {
+	if s.listFn == nil {
+		return nil, nil
+	}
+	return s.listFn(ctx, limit, offset)
+}
+
+func (s *stubChannelRepo) Update(ctx context.Context, c *service.Channel) error {
+	if s.updateFn == nil {
+		return nil
+	}
+	return s.updateFn(ctx, c)
+}
+
+func (s *stubChannelRepo) Delete(ctx context.Context, id uint) error {
+	if s.deleteFn == nil {
+		return nil
+	}
+	return s.deleteFn(ctx, id)
+}
+
+func newTestChannelRouter(h *ChannelHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Post("/channels", h.Create)
+	r.Get("/channels", h.List)
+	r.Get("/channels/{id}", h.Get)
+	r.Put("/channels/{id}", h.Update)
+	r.Delete("/channels/{id}", h.Delete)
+	return r
+}
+
+func TestChannelHandlerCreateReturnsCreatedChannelWithID(t *testing.T) {
+	repo := &stubChannelRepo{
+		createFn: func(_ context.Context, c *service.Channel) error {
+			c.ID = 5
+			return nil
+		},
+	}
+	h := NewChannelHandler(service.NewChannelService(repo))
+
+	req := httptest.NewRequest(nethttp.MethodPost, "/channels", bytes.NewBufferString(validChannelJSON))
+	rec := httptest.NewRecorder()
+	newTestChannelRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusCreated {
+		t.Fatalf("expected %d, got %d", nethttp.StatusCreated, rec.Code)
+	}
+	var got service.Channel
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ID != 5 || got.Title != "News" || got.ChannelNumber != 7 {
+		t.Fatalf("unexpected channel: %+v", got)
+	}
+}
+
+func TestChannelHandlerCreateRejectsBadRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "bad json", body: `{"title":`, want: nethttp.StatusBadRequest},
+		{name: "unknown field", body: `{"title":"t","channelNumber":1,"extra":true}`, want: nethttp.StatusBadRequest},
+		{name: "trailing json", body: validChannelJSON + validChannelJSON, want: nethttp.StatusBadRequest},
+		{name: "validation", body: `{"title":"t","channelNumber":0}`, want: nethttp.StatusBadRequest},
+		{name: "unknown profile", body: `{"title":"t","channelNumber":1,"profile":"720p"}`, want: nethttp.StatusBadRequest},
+		{
+			name: "too large",
+			body: `{"title":"` + strings.Repeat("a", maxBodyBytes) + `","channelNumber":1}`,
+			want: nethttp.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &stubChannelRepo{
+				createFn: func(context.Context, *service.Channel) error {
+					t.Fatalf("Create should not be called")
+					return nil
+				},
+			}
+			h := NewChannelHandler(service.NewChannelService(repo))
+
+			req := httptest.NewRequest(nethttp.MethodPost, "/channels", bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+			newTestChannelRouter(h).ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestChannelHandlerListParsesPaginationAndEncodesEmptyArray(t *testing.T) {
+	var gotLimit, gotOffset int
+	repo := &stubChannelRepo{
+		listFn: func(_ context.Context, limit, offset int) ([]service.Channel, error) {
+			gotLimit, gotOffset = limit, offset
+			return nil, nil
+		},
+	}
+	h := NewChannelHandler(service.NewChannelService(repo))
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/channels?limit=10&offset=20", nil)
+	rec := httptest.NewRecorder()
+	newTestChannelRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if gotLimit != 10 || gotOffset != 20 {
+		t.Fatalf("expected limit 10 offset 20, got %d %d", gotLimit, gotOffset)
+	}
+	if strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Fatalf("expected empty array, got %q", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(nethttp.MethodGet, "/channels?limit=0", nil)
+	rec = httptest.NewRecorder()
+	newTestChannelRouter(h).ServeHTTP(rec, req)
+	if rec.Code != nethttp.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", nethttp.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestChannelHandlerGetMapsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		err  error
+		want int
+	}{
+		{name: "invalid id", path: "/channels/abc", want: nethttp.StatusBadRequest},
+		{name: "zero id", path: "/channels/0", want: nethttp.StatusBadRequest},
+		{name: "not found", path: "/channels/1", err: service.ErrNotFound, want: nethttp.StatusNotFound},
+		{name: "unknown", path: "/channels/1", err: errors.New("boom"), want: nethttp.StatusInternalServerError},
+		{name: "found", path: "/channels/1", want: nethttp.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &stubChannelRepo{
+				getByID: func(_ context.Context, id uint) (*service.Channel, error) {
+					if tc.err != nil {
+						return nil, tc.err
+					}
+					return &service.Channel{ID: id, Title: "News", ChannelNumber: 7}, nil
+				},
+			}
+			h := NewChannelHandler(service.NewChannelService(repo))
+
+			req := httptest.NewRequest(nethttp.MethodGet, tc.path, nil)
+			rec := httptest.NewRecorder()
+			newTestChannelRouter(h).ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestChannelHandlerUpdateReturnsUpdatedChannel(t *testing.T) {
+	var updated *service.Channel
+	repo := &stubChannelRepo{
+		updateFn: func(_ context.Context, c *service.Channel) error {
+			updated = c
+			return nil
+		},
+	}
+	h := NewChannelHandler(service.NewChannelService(repo))
+
+	req := httptest.NewRequest(nethttp.MethodPut, "/channels/3", bytes.NewBufferString(validChannelJSON))
+	rec := httptest.NewRecorder()
+	newTestChannelRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if updated == nil || updated.ID != 3 || updated.Title != "News" {
+		t.Fatalf("unexpected update: %+v", updated)
+	}
+}
+
+func TestChannelHandlerUpdateNotFoundReturnsNotFound(t *testing.T) {
+	repo := &stubChannelRepo{
+		updateFn: func(context.Context, *service.Channel) error {
+			return service.ErrNotFound
+		},
+	}
+	h := NewChannelHandler(service.NewChannelService(repo))
+
+	req := httptest.NewRequest(nethttp.MethodPut, "/channels/3", bytes.NewBufferString(validChannelJSON))
+	rec := httptest.NewRecorder()
+	newTestChannelRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusNotFound {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotFound, rec.Code)
+	}
+}
+
+func TestChannelHandlerDelete(t *testing.T) {
+	var gotID uint
+	repo := &stubChannelRepo{
+		deleteFn: func(_ context.Context, id uint) error {
+			gotID = id
+			if id == 9 {
+				return service.ErrNotFound
+			}
+			return nil
+		},
+	}
+	h := NewChannelHandler(service.NewChannelService(repo))
+
+	req := httptest.NewRequest(nethttp.MethodDelete, "/channels/4", nil)
+	rec := httptest.NewRecorder()
+	newTestChannelRouter(h).ServeHTTP(rec, req)
+	if rec.Code != nethttp.StatusNoContent {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNoContent, rec.Code)
+	}
+	if gotID != 4 {
+		t.Fatalf("expected id 4, got %d", gotID)
+	}
+
+	req = httptest.NewRequest(nethttp.MethodDelete, "/channels/9", nil)
+	rec = httptest.NewRecorder()
+	newTestChannelRouter(h).ServeHTTP(rec, req)
+	if rec.Code != nethttp.StatusNotFound {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotFound, rec.Code)
+	}
+}
+
+func TestChannelHandlerCreateDefaultsLoopToTrue(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantLoop bool
+	}{
+		{name: "omitted", body: validChannelJSON, wantLoop: true},
+		{name: "explicit false", body: `{"title":"News","channelNumber":7,"loop":false,"shuffle":true}`, wantLoop: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got service.Channel
+			repo := &stubChannelRepo{
+				createFn: func(_ context.Context, c *service.Channel) error {
+					got = *c
+					return nil
+				},
+			}
+			h := NewChannelHandler(service.NewChannelService(repo))
+
+			req := httptest.NewRequest(nethttp.MethodPost, "/channels", bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+			newTestChannelRouter(h).ServeHTTP(rec, req)
+
+			if rec.Code != nethttp.StatusCreated {
+				t.Fatalf("expected %d, got %d", nethttp.StatusCreated, rec.Code)
+			}
+			if got.Loop != tc.wantLoop {
+				t.Fatalf("expected loop %v, got %v", tc.wantLoop, got.Loop)
+			}
+		})
+	}
+}
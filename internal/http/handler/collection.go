@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	nethttp "net/http"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type CollectionHandler struct {
+	svc *service.CollectionService
+}
+
+func NewCollectionHandler(svc *service.CollectionService) *CollectionHandler {
+	return &CollectionHandler{svc: svc}
+}
+
+type collectionReq struct {
+	Name       string                   `json:"name"`
+	ContentIDs []uint                   `json:"contentIds"`
+	Query      *service.CollectionQuery `json:"query"`
+}
+
+type tagsReq struct {
+	Tags []string `json:"tags"`
+}
+
+func (req collectionReq) collection() *service.Collection {
+	c := &service.Collection{Name: req.Name, ContentIDs: req.ContentIDs, Query: req.Query}
+	if c.ContentIDs == nil {
+		c.ContentIDs = []uint{}
+	}
+	return c
+}
+
+func (h *CollectionHandler) List(w nethttp.ResponseWriter, r *nethttp.Request) {
+	collections, err := h.svc.List(r.Context())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if collections == nil {
+		collections = []service.Collection{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(collections); err != nil {
+		slog.Error("encode list collections response", "error", err)
+	}
+}
+
+func (h *CollectionHandler) Create(w nethttp.ResponseWriter, r *nethttp.Request) {
+	var req collectionReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	c := req.collection()
+	if err := h.svc.Create(r.Context(), c); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(nethttp.StatusCreated)
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		slog.Error("encode create collection response", "error", err)
+	}
+}
+
+func (h *CollectionHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		slog.Error("encode get collection response", "error", err)
+	}
+}
+
+// Content lists what the collection holds now, evaluating a smart collection's query.
+func (h *CollectionHandler) Content(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	contents, err := h.svc.Content(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if contents == nil {
+		contents = []service.Content{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(contents); err != nil {
+		slog.Error("encode list collection content response", "error", err)
+	}
+}
+
+func (h *CollectionHandler) Update(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	var req collectionReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	c := req.collection()
+	c.ID = id
+	if err := h.svc.Update(r.Context(), c); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		slog.Error("encode update collection response", "error", err)
+	}
+}
+
+func (h *CollectionHandler) Delete(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), id); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(nethttp.StatusNoContent)
+}
+
+// SetTags replaces the tags on a content item.
+func (h *CollectionHandler) SetTags(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	var req tagsReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	c, err := h.svc.SetTags(r.Context(), id, req.Tags)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		slog.Error("encode set tags response", "error", err)
+	}
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type stubCollectionRepo struct {
+	collections []service.Collection
+	members     []service.Content
+}
+
+func (s *stubCollectionRepo) List(context.Context) ([]service.Collection, error) {
+	return s.collections, nil
+}
+
+func (s *stubCollectionRepo) GetByID(_ context.Context, id uint) (*service.Collection, error) {
+	for _, c := range s.collections {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, service.ErrNotFound
+}
+
+func (s *stubCollectionRepo) Create(_ context.Context, c *service.Collection) error {
+	c.ID = 3
+	return nil
+}
+
+func (s *stubCollectionRepo) Update(ctx context.Context, c *service.Collection) error {
+	_, err := s.GetByID(ctx, c.ID)
+	return err
+}
+
+func (s *stubCollectionRepo) Delete(ctx context.Context, id uint) error {
+	_, err := s.GetByID(ctx, id)
+	return err
+}
+
+func (s *stubCollectionRepo) Members(context.Context, *service.Collection) ([]service.Content, error) {
+	return s.members, nil
+}
+
+type stubTagRepo struct {
+	tags []string
+}
+
+func (s *stubTagRepo) SetTags(_ context.Context, _ uint, tags []string) error {
+	s.tags = tags
+	return nil
+}
+
+func newTestCollectionRouter(h *CollectionHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Put("/content/{id}/tags", h.SetTags)
+	r.Get("/collections", h.List)
+	r.Post("/collections", h.Create)
+	r.Get("/collections/{id}", h.Get)
+	r.Get("/collections/{id}/content", h.Content)
+	r.Put("/collections/{id}", h.Update)
+	r.Delete("/collections/{id}", h.Delete)
+	return r
+}
+
+func TestCollectionHandlerCreateReturnsCollection(t *testing.T) {
+	h := NewCollectionHandler(service.NewCollectionService(existingContent(), &stubCollectionRepo{}, &stubTagRepo{}))
+
+	body := `{"name":"Cartoons","query":{"tag":"Kids","maxLength":900}}`
+	rec := httptest.NewRecorder()
+	newTestCollectionRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodPost, "/collections", bytes.NewBufferString(body)))
+
+	if rec.Code != nethttp.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", nethttp.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var got service.Collection
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ID != 3 || got.Name != "Cartoons" || got.Query == nil || got.Query.Tag != "kids" || got.Query.MaxLength != 900 || got.ContentIDs == nil {
+		t.Fatalf("unexpected collection %+v", got)
+	}
+}
+
+func TestCollectionHandlerSetTags(t *testing.T) {
+	tags := &stubTagRepo{}
+	h := NewCollectionHandler(service.NewCollectionService(existingContent(), &stubCollectionRepo{}, tags))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(nethttp.MethodPut, "/content/4/tags", bytes.NewBufferString(`{"tags":["Kids","news"]}`))
+	newTestCollectionRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", nethttp.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !slices.Equal(tags.tags, []string{"kids", "news"}) {
+		t.Fatalf("expected normalised tags, got %v", tags.tags)
+	}
+}
+
+func TestCollectionHandlerRoutes(t *testing.T) {
+	repo := &stubCollectionRepo{
+		collections: []service.Collection{{ID: 5, Name: "Cartoons", ContentIDs: []uint{1}}},
+		members:     []service.Content{{ID: 1, Title: "Pilot"}},
+	}
+	router := newTestCollectionRouter(NewCollectionHandler(service.NewCollectionService(existingContent(), repo, &stubTagRepo{})))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{method: nethttp.MethodGet, path: "/collections", want: nethttp.StatusOK},
+		{method: nethttp.MethodPost, path: "/collections", body: `{"name":"x","query":{}}`, want: nethttp.StatusBadRequest},
+		{method: nethttp.MethodPost, path: "/collections", body: `{"name":"x","unknown":1}`, want: nethttp.StatusBadRequest},
+		{method: nethttp.MethodGet, path: "/collections/5", want: nethttp.StatusOK},
+		{method: nethttp.MethodGet, path: "/collections/6", want: nethttp.StatusNotFound},
+		{method: nethttp.MethodGet, path: "/collections/5/content", want: nethttp.StatusOK},
+		{method: nethttp.MethodGet, path: "/collections/6/content", want: nethttp.StatusNotFound},
+		{method: nethttp.MethodPut, path: "/collections/5", body: `{"name":"Shorts","contentIds":[1]}`, want: nethttp.StatusOK},
+		{method: nethttp.MethodPut, path: "/collections/6", body: `{"name":"Shorts"}`, want: nethttp.StatusNotFound},
+		{method: nethttp.MethodPut, path: "/content/1/tags", body: `{"tags":[" "]}`, want: nethttp.StatusBadRequest},
+		{method: nethttp.MethodDelete, path: "/collections/6", want: nethttp.StatusNotFound},
+		{method: nethttp.MethodDelete, path: "/collections/5", want: nethttp.StatusNoContent},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body)))
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, rec.Code)
+		}
+	}
+}
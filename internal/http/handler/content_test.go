@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/iamseth/tiny-headend/internal/service"
@@ -194,7 +195,7 @@ func TestContentHandlerCreateTooLargeBodyReturnsRequestEntityTooLarge(t *testing
 	h := NewContentHandler(service.NewContentService(repo))
 
 	body := bytes.NewBufferString(
-		`{"title":"t","path":"` + strings.Repeat("a", maxContentBodyBytes) + `","size":1,"length":1}`,
+		`{"title":"t","path":"` + strings.Repeat("a", maxBodyBytes) + `","size":1,"length":1}`,
 	)
 	req := httptest.NewRequest(nethttp.MethodPost, "/content", body)
 	rec := httptest.NewRecorder()
@@ -324,6 +325,62 @@ func TestContentHandlerListReturnsContent(t *testing.T) {
 	}
 }
 
+type stubContentSearcher struct {
+	gotQuery  string
+	gotLimit  int
+	gotOffset int
+}
+
+func (s *stubContentSearcher) Search(_ context.Context, query string, limit, offset int) ([]service.SearchResult, error) {
+	s.gotQuery, s.gotLimit, s.gotOffset = query, limit, offset
+	return []service.SearchResult{{Content: service.Content{ID: 7, Title: "Heat"}, Snippet: "<mark>Heat</mark>"}}, nil
+}
+
+func TestContentHandlerListSearchesWithQuery(t *testing.T) {
+	searcher := &stubContentSearcher{}
+	repo := &stubContentRepo{
+		listFn: func(context.Context, int, int) ([]service.Content, error) {
+			t.Fatalf("expected search instead of list")
+			return nil, nil
+		},
+	}
+	h := NewContentHandler(service.NewContentService(repo, service.WithSearch(searcher)))
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/content?q=heat+1995&limit=5&offset=10", nil)
+	rec := httptest.NewRecorder()
+	newTestRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if searcher.gotQuery != "heat 1995" || searcher.gotLimit != 5 || searcher.gotOffset != 10 {
+		t.Fatalf("unexpected search args: %q limit=%d offset=%d", searcher.gotQuery, searcher.gotLimit, searcher.gotOffset)
+	}
+	var got []struct {
+		ID      uint   `json:"id"`
+		Title   string `json:"title"`
+		Snippet string `json:"snippet"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != 7 || got[0].Title != "Heat" || got[0].Snippet != "<mark>Heat</mark>" {
+		t.Fatalf("unexpected results: %+v", got)
+	}
+}
+
+func TestContentHandlerListEmptyQueryReturnsBadRequest(t *testing.T) {
+	h := NewContentHandler(service.NewContentService(&stubContentRepo{}, service.WithSearch(&stubContentSearcher{})))
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/content?q=+", nil)
+	rec := httptest.NewRecorder()
+	newTestRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", nethttp.StatusBadRequest, rec.Code)
+	}
+}
+
 func TestContentHandlerListDefaultsAndParsesPagination(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -461,7 +518,7 @@ func TestContentHandlerUpdateTooLargeBodyReturnsRequestEntityTooLarge(t *testing
 	h := NewContentHandler(service.NewContentService(repo))
 
 	body := bytes.NewBufferString(
-		`{"title":"t","path":"` + strings.Repeat("a", maxContentBodyBytes) + `","size":1,"length":1}`,
+		`{"title":"t","path":"` + strings.Repeat("a", maxBodyBytes) + `","size":1,"length":1}`,
 	)
 	req := httptest.NewRequest(nethttp.MethodPut, "/content/1", body)
 	rec := httptest.NewRecorder()
@@ -508,7 +565,11 @@ func TestContentHandlerUpdateNotFoundReturnsNotFound(t *testing.T) {
 }
 
 func TestContentHandlerUpdateReturnsUpdatedContent(t *testing.T) {
+	stored := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
 	repo := &stubContentRepo{
+		getByID: func(_ context.Context, id uint) (*service.Content, error) {
+			return &service.Content{ID: id, ModTime: stored}, nil
+		},
 		updateFn: func(_ context.Context, c *service.Content) error {
 			if c.ID != 12 {
 				t.Fatalf("expected id 12, got %d", c.ID)
@@ -533,6 +594,9 @@ func TestContentHandlerUpdateReturnsUpdatedContent(t *testing.T) {
 	if got.ID != 12 || got.Title != "t" || got.Path != "/tmp/f.ts" || got.Size != 1 || got.Length != 1 {
 		t.Fatalf("unexpected updated response: %+v", got)
 	}
+	if !got.ModTime.Equal(stored) {
+		t.Fatalf("expected stored mod time %v, got %v", stored, got.ModTime)
+	}
 }
 
 func TestContentHandlerDeleteInvalidIDReturnsBadRequest(t *testing.T) {
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"bytes"
+	"log/slog"
+	nethttp "net/http"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/epg"
+)
+
+type EPGHandler struct {
+	gen     *epg.Generator
+	baseURL string
+	now     func() time.Time
+}
+
+// NewEPGHandler returns a handler whose artwork URLs start with baseURL, or with the request's own
+// scheme and host when baseURL is empty.
+func NewEPGHandler(gen *epg.Generator, baseURL string) *EPGHandler {
+	return &EPGHandler{gen: gen, baseURL: baseURL, now: time.Now}
+}
+
+func (h *EPGHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
+	tv, err := h.gen.Build(r.Context(), h.now(), requestBaseURL(r, h.baseURL))
+	if err != nil {
+		slog.Error("build epg", "error", err)
+		writeErr(w, err)
+		return
+	}
+
+	// Render to a buffer first so a failure can still become a proper error response.
+	var buf bytes.Buffer
+	if err := tv.Write(&buf); err != nil {
+		slog.Error("render epg", "error", err)
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("write epg response", "error", err)
+	}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/epg"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func TestEPGHandlerGetRendersGuide(t *testing.T) {
+	channels := &stubChannelRepo{
+		getByID: func(_ context.Context, id uint) (*service.Channel, error) {
+			return &service.Channel{ID: id, Title: "News", ChannelNumber: 7, Loop: true}, nil
+		},
+		listFn: func(context.Context, int, int) ([]service.Channel, error) {
+			return []service.Channel{{ID: 3, Title: "News", ChannelNumber: 7, Loop: true}}, nil
+		},
+	}
+	items := &stubChannelItemRepo{items: []service.ChannelItem{
+		{ID: 1, ContentID: 1, Content: &service.Content{ID: 1, Title: "Pilot", Length: 3600}},
+	}}
+	gen := epg.New(service.NewChannelService(channels), service.NewTimelineService(channels, items, testEpoch), time.Hour)
+	h := NewEPGHandler(gen, "")
+	h.now = func() time.Time { return testEpoch }
+
+	rec := httptest.NewRecorder()
+	h.Get(rec, httptest.NewRequest(nethttp.MethodGet, "/epg.xml", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `<channel id="3">`) || !strings.Contains(body, `<title>Pilot</title>`) {
+		t.Fatalf("unexpected body:\n%s", body)
+	}
+}
+
+func TestEPGHandlerGetReturnsInternalErrorOnFailure(t *testing.T) {
+	channels := &stubChannelRepo{
+		listFn: func(context.Context, int, int) ([]service.Channel, error) {
+			return nil, errors.New("db down")
+		},
+	}
+	gen := epg.New(service.NewChannelService(channels), service.NewTimelineService(channels, &stubChannelItemRepo{}, testEpoch), time.Hour)
+
+	rec := httptest.NewRecorder()
+	NewEPGHandler(gen, "").Get(rec, httptest.NewRequest(nethttp.MethodGet, "/epg.xml", nil))
+
+	if rec.Code != nethttp.StatusInternalServerError {
+		t.Fatalf("expected %d, got %d", nethttp.StatusInternalServerError, rec.Code)
+	}
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/library"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+// mediaTypes covers the library's media extensions, most of which are missing from the system
+// MIME tables.
+var mediaTypes = map[string]string{
+	".avi":  "video/x-msvideo",
+	".m2ts": "video/mp2t",
+	".m4v":  "video/x-m4v",
+	".mkv":  "video/x-matroska",
+	".mov":  "video/quicktime",
+	".mp4":  "video/mp4",
+	".mpeg": "video/mpeg",
+	".mpg":  "video/mpeg",
+	".ts":   "video/mp2t",
+	".webm": "video/webm",
+}
+
+// FileHandler serves the media files behind content for on-demand playback.
+type FileHandler struct {
+	svc   *service.ContentService
+	roots []string
+}
+
+// NewFileHandler returns a handler that only serves files inside one of roots.
+func NewFileHandler(svc *service.ContentService, roots []string) *FileHandler {
+	return &FileHandler{svc: svc, roots: roots}
+}
+
+// Get serves the content's file with byte range, conditional request and ETag support.
+func (h *FileHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	f, st, path, ok := h.openFile(w, id, c.Path)
+	if !ok {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	// A whole film outlasts any server-wide write timeout.
+	rc := nethttp.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.Error("clear file write deadline", "content_id", id, "error", err)
+	}
+
+	w.Header().Set("Content-Type", mediaType(path))
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, st.ModTime().UnixNano(), st.Size()))
+	nethttp.ServeContent(w, r, filepath.Base(path), st.ModTime(), f)
+}
+
+// Poster serves the artwork image found next to the content's file.
+func (h *FileHandler) Poster(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if c.Poster == "" {
+		nethttp.Error(w, "content has no poster", nethttp.StatusNotFound)
+		return
+	}
+
+	f, st, path, ok := h.openFile(w, id, c.Poster)
+	if !ok {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	w.Header().Set("Content-Type", mediaType(path))
+	w.Header().Set("Cache-Control", artworkCacheControl)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, st.ModTime().UnixNano(), st.Size()))
+	nethttp.ServeContent(w, r, filepath.Base(path), st.ModTime(), f)
+}
+
+// openFile opens a file belonging to content, refusing files outside the media library. On
+// failure it writes the error response and returns false.
+func (h *FileHandler) openFile(w nethttp.ResponseWriter, id uint, path string) (*os.File, fs.FileInfo, string, bool) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			nethttp.Error(w, "file not found", nethttp.StatusNotFound)
+			return nil, nil, "", false
+		}
+		slog.Error("resolve content path", "content_id", id, "error", err)
+		writeErr(w, err)
+		return nil, nil, "", false
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		slog.Error("resolve content path", "content_id", id, "error", err)
+		writeErr(w, err)
+		return nil, nil, "", false
+	}
+	if !h.inLibrary(path) {
+		nethttp.Error(w, "file is outside the media library", nethttp.StatusForbidden)
+		return nil, nil, "", false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		slog.Error("open content file", "content_id", id, "error", err)
+		writeErr(w, err)
+		return nil, nil, "", false
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		slog.Error("stat content file", "content_id", id, "error", err)
+		writeErr(w, err)
+		return nil, nil, "", false
+	}
+	if st.IsDir() {
+		_ = f.Close()
+		nethttp.Error(w, "file not found", nethttp.StatusNotFound)
+		return nil, nil, "", false
+	}
+	return f, st, path, true
+}
+
+// inLibrary reports whether the resolved path lies under one of the roots, after resolving
+// symlinks in the roots too.
+func (h *FileHandler) inLibrary(path string) bool {
+	for _, root := range h.roots {
+		resolved, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if abs, err := filepath.Abs(resolved); err == nil && library.WithinRoot(abs, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func mediaType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if t, ok := mediaTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
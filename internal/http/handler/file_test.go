@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const testFileBody = "0123456789abcdef"
+
+func newTestFileRouter(h *FileHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/content/{id}/file", h.Get)
+	r.Get("/content/{id}/poster", h.Poster)
+	return r
+}
+
+// newTestFileHandler serves a library rooted at a temporary directory holding show.ts, with
+// content 1 pointing at path.
+func newTestFileHandler(t *testing.T, path func(root string) string) (*FileHandler, string) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "show.ts"), []byte(testFileBody), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	repo := &stubContentRepo{getByID: func(_ context.Context, id uint) (*service.Content, error) {
+		if id != 1 {
+			return nil, service.ErrNotFound
+		}
+		return &service.Content{ID: id, Title: "Show", Path: path(root)}, nil
+	}}
+	return NewFileHandler(service.NewContentService(repo), []string{root}), root
+}
+
+func TestFileHandlerGetServesFile(t *testing.T) {
+	h, _ := newTestFileHandler(t, func(root string) string { return filepath.Join(root, "show.ts") })
+
+	rec := httptest.NewRecorder()
+	newTestFileRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/content/1/file", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "video/mp2t" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if rec.Header().Get("Accept-Ranges") != "bytes" || rec.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected range and modification headers, got %v", rec.Header())
+	}
+	if rec.Body.String() != testFileBody {
+		t.Fatalf("unexpected body %q", rec.Body.String())
+	}
+}
+
+func TestFileHandlerGetServesRanges(t *testing.T) {
+	h, _ := newTestFileHandler(t, func(root string) string { return filepath.Join(root, "show.ts") })
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/content/1/file", nil)
+	req.Header.Set("Range", "bytes=4-7")
+	rec := httptest.NewRecorder()
+	newTestFileRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusPartialContent {
+		t.Fatalf("expected %d, got %d", nethttp.StatusPartialContent, rec.Code)
+	}
+	if rec.Body.String() != "4567" {
+		t.Fatalf("unexpected body %q", rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 4-7/16" {
+		t.Fatalf("unexpected content range %q", got)
+	}
+}
+
+func TestFileHandlerGetHonoursETag(t *testing.T) {
+	h, _ := newTestFileHandler(t, func(root string) string { return filepath.Join(root, "show.ts") })
+
+	first := httptest.NewRecorder()
+	newTestFileRouter(h).ServeHTTP(first, httptest.NewRequest(nethttp.MethodGet, "/content/1/file", nil))
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/content/1/file", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec := httptest.NewRecorder()
+	newTestFileRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusNotModified {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotModified, rec.Code)
+	}
+}
+
+func TestFileHandlerGetRejectsFilesOutsideLibrary(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret.ts")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path func(root string) string
+	}{
+		{name: "absolute path", path: func(string) string { return outside }},
+		{name: "dot-dot path", path: func(root string) string {
+			rel, err := filepath.Rel(root, outside)
+			if err != nil {
+				t.Fatalf("rel: %v", err)
+			}
+			return root + string(filepath.Separator) + rel
+		}},
+		{name: "symlink", path: func(root string) string {
+			link := filepath.Join(root, "link.ts")
+			if err := os.Symlink(outside, link); err != nil {
+				t.Fatalf("symlink: %v", err)
+			}
+			return link
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, _ := newTestFileHandler(t, tc.path)
+
+			rec := httptest.NewRecorder()
+			newTestFileRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/content/1/file", nil))
+
+			if rec.Code != nethttp.StatusForbidden {
+				t.Fatalf("expected %d, got %d", nethttp.StatusForbidden, rec.Code)
+			}
+		})
+	}
+}
+
+func TestFileHandlerGetMapsMissingContentAndFiles(t *testing.T) {
+	h, _ := newTestFileHandler(t, func(root string) string { return filepath.Join(root, "gone.ts") })
+
+	for _, path := range []string{"/content/1/file", "/content/2/file"} {
+		rec := httptest.NewRecorder()
+		newTestFileRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, path, nil))
+		if rec.Code != nethttp.StatusNotFound {
+			t.Fatalf("%s: expected %d, got %d", path, nethttp.StatusNotFound, rec.Code)
+		}
+	}
+}
+
+func TestFileHandlerPosterServesArtwork(t *testing.T) {
+	root := t.TempDir()
+	poster := filepath.Join(root, "poster.jpg")
+	if err := os.WriteFile(poster, []byte("jpeg"), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	outside := filepath.Join(t.TempDir(), "poster.jpg")
+	if err := os.WriteFile(outside, []byte("jpeg"), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	posters := map[uint]string{1: poster, 2: "", 3: outside}
+	repo := &stubContentRepo{getByID: func(_ context.Context, id uint) (*service.Content, error) {
+		p, ok := posters[id]
+		if !ok {
+			return nil, service.ErrNotFound
+		}
+		return &service.Content{ID: id, Title: "Show", Path: filepath.Join(root, "show.ts"), Poster: p}, nil
+	}}
+	h := NewFileHandler(service.NewContentService(repo), []string{root})
+
+	rec := httptest.NewRecorder()
+	newTestFileRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/content/1/poster", nil))
+	if rec.Code != nethttp.StatusOK || rec.Body.String() != "jpeg" {
+		t.Fatalf("expected the poster, got %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/jpeg" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != artworkCacheControl || rec.Header().Get("ETag") == "" {
+		t.Fatalf("expected caching headers, got %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/content/1/poster", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	newTestFileRouter(h).ServeHTTP(rec, req)
+	if rec.Code != nethttp.StatusNotModified {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotModified, rec.Code)
+	}
+
+	for path, want := range map[string]int{
+		"/content/2/poster": nethttp.StatusNotFound,
+		"/content/3/poster": nethttp.StatusForbidden,
+		"/content/4/poster": nethttp.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		newTestFileRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	nethttp "net/http"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type FillerHandler struct {
+	svc *service.FillerService
+}
+
+func NewFillerHandler(svc *service.FillerService) *FillerHandler {
+	return &FillerHandler{svc: svc}
+}
+
+type fillerPoolReq struct {
+	Title      string  `json:"title"`
+	MinBreak   float64 `json:"minBreak"`
+	MaxBreak   float64 `json:"maxBreak"`
+	ContentIDs []uint  `json:"contentIds"`
+}
+
+func (req fillerPoolReq) pool(channelID uint) *service.FillerPool {
+	return &service.FillerPool{
+		ChannelID:  channelID,
+		Title:      req.Title,
+		MinBreak:   req.MinBreak,
+		MaxBreak:   req.MaxBreak,
+		ContentIDs: req.ContentIDs,
+	}
+}
+
+func (h *FillerHandler) List(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	pools, err := h.svc.List(r.Context(), channelID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if pools == nil {
+		pools = []service.FillerPool{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pools); err != nil {
+		slog.Error("encode list filler pools response", "error", err)
+	}
+}
+
+func (h *FillerHandler) Create(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	var req fillerPoolReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	p := req.pool(channelID)
+	if err := h.svc.Create(r.Context(), p); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(nethttp.StatusCreated)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		slog.Error("encode create filler pool response", "error", err)
+	}
+}
+
+func (h *FillerHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	poolID, ok := parseURLID(w, r, "poolId")
+	if !ok {
+		return
+	}
+
+	p, err := h.svc.Get(r.Context(), channelID, poolID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		slog.Error("encode get filler pool response", "error", err)
+	}
+}
+
+func (h *FillerHandler) Update(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	poolID, ok := parseURLID(w, r, "poolId")
+	if !ok {
+		return
+	}
+
+	var req fillerPoolReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	p := req.pool(channelID)
+	p.ID = poolID
+	if err := h.svc.Update(r.Context(), p); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		slog.Error("encode update filler pool response", "error", err)
+	}
+}
+
+func (h *FillerHandler) Delete(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	poolID, ok := parseURLID(w, r, "poolId")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), channelID, poolID); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(nethttp.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type stubFillerPoolRepo struct {
+	pools []service.FillerPool
+}
+
+func (s *stubFillerPoolRepo) ListByChannel(context.Context, uint) ([]service.FillerPool, error) {
+	return s.pools, nil
+}
+
+func (s *stubFillerPoolRepo) GetByID(_ context.Context, _ uint, id uint) (*service.FillerPool, error) {
+	for _, p := range s.pools {
+		if p.ID == id {
+			return &p, nil
+		}
+	}
+	return nil, service.ErrNotFound
+}
+
+func (s *stubFillerPoolRepo) Create(_ context.Context, p *service.FillerPool) error {
+	p.ID = 3
+	return nil
+}
+
+func (s *stubFillerPoolRepo) Update(context.Context, *service.FillerPool) error {
+	return nil
+}
+
+func (s *stubFillerPoolRepo) Delete(_ context.Context, _ uint, id uint) error {
+	for _, p := range s.pools {
+		if p.ID == id {
+			return nil
+		}
+	}
+	return service.ErrNotFound
+}
+
+func newTestFillerRouter(h *FillerHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/channels/{id}/filler", h.List)
+	r.Post("/channels/{id}/filler", h.Create)
+	r.Get("/channels/{id}/filler/{poolId}", h.Get)
+	r.Put("/channels/{id}/filler/{poolId}", h.Update)
+	r.Delete("/channels/{id}/filler/{poolId}", h.Delete)
+	return r
+}
+
+func TestFillerHandlerCreateReturnsPool(t *testing.T) {
+	h := NewFillerHandler(service.NewFillerService(existingChannel(), existingContent(), &stubFillerPoolRepo{}))
+
+	body := `{"title":"Ads","minBreak":30,"maxBreak":180,"contentIds":[4,5]}`
+	rec := httptest.NewRecorder()
+	newTestFillerRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodPost, "/channels/2/filler", bytes.NewBufferString(body)))
+
+	if rec.Code != nethttp.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", nethttp.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var got service.FillerPool
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ID != 3 || got.ChannelID != 2 || got.MinBreak != 30 || got.MaxBreak != 180 || !slices.Equal(got.ContentIDs, []uint{4, 5}) {
+		t.Fatalf("unexpected pool %+v", got)
+	}
+}
+
+func TestFillerHandlerRoutes(t *testing.T) {
+	repo := &stubFillerPoolRepo{pools: []service.FillerPool{{ID: 5, ChannelID: 1, ContentIDs: []uint{1}}}}
+	router := newTestFillerRouter(NewFillerHandler(service.NewFillerService(existingChannel(), existingContent(), repo)))
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{method: nethttp.MethodGet, path: "/channels/1/filler", want: nethttp.StatusOK},
+		{method: nethttp.MethodPost, path: "/channels/1/filler", body: `{"minBreak":60,"maxBreak":30,"contentIds":[1]}`, want: nethttp.StatusBadRequest},
+		{method: nethttp.MethodGet, path: "/channels/1/filler/5", want: nethttp.StatusOK},
+		{method: nethttp.MethodGet, path: "/channels/1/filler/6", want: nethttp.StatusNotFound},
+		{method: nethttp.MethodPut, path: "/channels/1/filler/5", body: `{"contentIds":[1]}`, want: nethttp.StatusOK},
+		{method: nethttp.MethodPut, path: "/channels/1/filler/6", body: `{"contentIds":[1]}`, want: nethttp.StatusNotFound},
+		{method: nethttp.MethodDelete, path: "/channels/1/filler/6", want: nethttp.StatusNotFound},
+		{method: nethttp.MethodDelete, path: "/channels/1/filler/5", want: nethttp.StatusNoContent},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body)))
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	nethttp "net/http"
+	"strconv"
+
+	"github.com/iamseth/tiny-headend/internal/epg"
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/ssdp"
+)
+
+// Device identifies the emulated HDHomeRun tuner to network clients.
+type Device struct {
+	DeviceID     string
+	FriendlyName string
+	TunerCount   int
+}
+
+// HDHomeRunHandler implements the subset of the HDHomeRun HTTP API that Plex, Jellyfin and
+// Emby use to add a network tuner.
+type HDHomeRunHandler struct {
+	svc     *service.ChannelService
+	device  Device
+	baseURL string
+}
+
+func NewHDHomeRunHandler(svc *service.ChannelService, device Device, baseURL string) *HDHomeRunHandler {
+	return &HDHomeRunHandler{svc: svc, device: device, baseURL: baseURL}
+}
+
+type discoverResp struct {
+	FriendlyName    string
+	Manufacturer    string
+	ModelNumber     string
+	FirmwareName    string
+	FirmwareVersion string
+	DeviceID        string
+	DeviceAuth      string
+	BaseURL         string
+	LineupURL       string
+	TunerCount      int
+}
+
+type lineupEntry struct {
+	GuideNumber string
+	GuideName   string
+	URL         string
+	Logo        string `json:",omitempty"`
+}
+
+type lineupStatusResp struct {
+	ScanInProgress int
+	ScanPossible   int
+	Source         string
+	SourceList     []string
+}
+
+// deviceDescription is the UPnP root device description SSDP announcements point at.
+type deviceDescription struct {
+	XMLName     xml.Name          `xml:"urn:schemas-upnp-org:device-1-0 root"`
+	SpecVersion upnpSpecVersion   `xml:"specVersion"`
+	URLBase     string            `xml:"URLBase"`
+	Device      upnpDeviceElement `xml:"device"`
+}
+
+type upnpSpecVersion struct {
+	Major int `xml:"major"`
+	Minor int `xml:"minor"`
+}
+
+type upnpDeviceElement struct {
+	DeviceType   string `xml:"deviceType"`
+	FriendlyName string `xml:"friendlyName"`
+	Manufacturer string `xml:"manufacturer"`
+	ModelName    string `xml:"modelName"`
+	ModelNumber  string `xml:"modelNumber"`
+	SerialNumber string `xml:"serialNumber"`
+	UDN          string `xml:"UDN"`
+}
+
+func (h *HDHomeRunHandler) Discover(w nethttp.ResponseWriter, r *nethttp.Request) {
+	base := requestBaseURL(r, h.baseURL)
+	resp := discoverResp{
+		FriendlyName:    h.device.FriendlyName,
+		Manufacturer:    "tiny-headend",
+		ModelNumber:     "HDTC-2US",
+		FirmwareName:    "hdhomeruntc_atsc",
+		FirmwareVersion: "20200101",
+		DeviceID:        h.device.DeviceID,
+		DeviceAuth:      "tiny-headend",
+		BaseURL:         base,
+		LineupURL:       base + "/lineup.json",
+		TunerCount:      h.device.TunerCount,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("encode discover response", "error", err)
+	}
+}
+
+// DeviceXML serves the UPnP device description referenced by the LOCATION header of SSDP
+// announcements.
+func (h *HDHomeRunHandler) DeviceXML(w nethttp.ResponseWriter, r *nethttp.Request) {
+	desc := deviceDescription{
+		SpecVersion: upnpSpecVersion{Major: 1, Minor: 0},
+		URLBase:     requestBaseURL(r, h.baseURL),
+		Device: upnpDeviceElement{
+			DeviceType:   ssdp.DeviceType,
+			FriendlyName: h.device.FriendlyName,
+			Manufacturer: "tiny-headend",
+			ModelName:    "HDTC-2US",
+			ModelNumber:  "HDTC-2US",
+			SerialNumber: h.device.DeviceID,
+			UDN:          ssdp.UDN(h.device.DeviceID),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		slog.Error("encode device description", "error", err)
+		return
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(desc); err != nil {
+		slog.Error("encode device description", "error", err)
+	}
+}
+
+func (h *HDHomeRunHandler) Lineup(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channels, err := h.svc.All(r.Context())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	base := requestBaseURL(r, h.baseURL)
+	lineup := make([]lineupEntry, len(channels))
+	for i, ch := range channels {
+		lineup[i] = lineupEntry{
+			GuideNumber: strconv.FormatUint(uint64(ch.ChannelNumber), 10),
+			GuideName:   ch.Title,
+			URL:         StreamURL(base, ch.ID),
+		}
+		if ch.HasLogo {
+			lineup[i].Logo = epg.LogoURL(base, ch.ID)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(lineup); err != nil {
+		slog.Error("encode lineup response", "error", err)
+	}
+}
+
+// LineupStatus always reports an idle, finished scan: the lineup comes straight from the
+// database, so there is never anything to scan for.
+func (h *HDHomeRunHandler) LineupStatus(w nethttp.ResponseWriter, r *nethttp.Request) {
+	resp := lineupStatusResp{
+		ScanInProgress: 0,
+		ScanPossible:   1,
+		Source:         "Cable",
+		SourceList:     []string{"Cable"},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("encode lineup status response", "error", err)
+	}
+}
+
+// LineupPost accepts the scan=start and scan=abort requests clients send before reading the
+// lineup. Both complete immediately.
+func (h *HDHomeRunHandler) LineupPost(w nethttp.ResponseWriter, r *nethttp.Request) {
+	switch r.URL.Query().Get("scan") {
+	case "start", "abort":
+		w.WriteHeader(nethttp.StatusOK)
+	default:
+		nethttp.Error(w, "invalid scan", nethttp.StatusBadRequest)
+	}
+}
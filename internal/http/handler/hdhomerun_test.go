@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/ssdp"
+)
+
+var testDevice = Device{DeviceID: "ABCDEF01", FriendlyName: "Den Tuner", TunerCount: 3}
+
+func TestHDHomeRunHandlerDiscover(t *testing.T) {
+	h := NewHDHomeRunHandler(service.NewChannelService(&stubChannelRepo{}), testDevice, "")
+
+	rec := httptest.NewRecorder()
+	h.Discover(rec, httptest.NewRequest(nethttp.MethodGet, "http://headend.lan:8080/discover.json", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	var got discoverResp
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.DeviceID != "ABCDEF01" || got.FriendlyName != "Den Tuner" || got.TunerCount != 3 {
+		t.Fatalf("unexpected device fields: %+v", got)
+	}
+	if got.BaseURL != "http://headend.lan:8080" || got.LineupURL != "http://headend.lan:8080/lineup.json" {
+		t.Fatalf("unexpected urls: %+v", got)
+	}
+}
+
+func TestHDHomeRunHandlerDeviceXML(t *testing.T) {
+	h := NewHDHomeRunHandler(service.NewChannelService(&stubChannelRepo{}), testDevice, "http://tv.example.com")
+
+	rec := httptest.NewRecorder()
+	h.DeviceXML(rec, httptest.NewRequest(nethttp.MethodGet, "/device.xml", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	var got deviceDescription
+	if err := xml.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.URLBase != "http://tv.example.com" {
+		t.Fatalf("unexpected URLBase %q", got.URLBase)
+	}
+	if got.Device.DeviceType != ssdp.DeviceType || got.Device.FriendlyName != "Den Tuner" {
+		t.Fatalf("unexpected device: %+v", got.Device)
+	}
+	if got.Device.UDN != ssdp.UDN("ABCDEF01") {
+		t.Fatalf("expected UDN %q, got %q", ssdp.UDN("ABCDEF01"), got.Device.UDN)
+	}
+}
+
+func TestHDHomeRunHandlerLineup(t *testing.T) {
+	repo := lineupChannels(
+		service.Channel{ID: 1, Title: "News", ChannelNumber: 7},
+		service.Channel{ID: 4, Title: "Movies", ChannelNumber: 12, HasLogo: true},
+	)
+	h := NewHDHomeRunHandler(service.NewChannelService(repo), testDevice, "http://tv.example.com")
+
+	rec := httptest.NewRecorder()
+	h.Lineup(rec, httptest.NewRequest(nethttp.MethodGet, "/lineup.json", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	var got []lineupEntry
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := []lineupEntry{
+		{GuideNumber: "7", GuideName: "News", URL: "http://tv.example.com/channels/1/stream.ts"},
+		{GuideNumber: "12", GuideName: "Movies", URL: "http://tv.example.com/channels/4/stream.ts",
+			Logo: "http://tv.example.com/channels/4/logo"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("entry %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestHDHomeRunHandlerLineupEncodesEmptyArray(t *testing.T) {
+	h := NewHDHomeRunHandler(service.NewChannelService(&stubChannelRepo{}), testDevice, "")
+
+	rec := httptest.NewRecorder()
+	h.Lineup(rec, httptest.NewRequest(nethttp.MethodGet, "/lineup.json", nil))
+
+	if got := rec.Body.String(); got != "[]\n" {
+		t.Fatalf("expected empty array, got %q", got)
+	}
+}
+
+func TestHDHomeRunHandlerLineupStatus(t *testing.T) {
+	h := NewHDHomeRunHandler(service.NewChannelService(&stubChannelRepo{}), testDevice, "")
+
+	rec := httptest.NewRecorder()
+	h.LineupStatus(rec, httptest.NewRequest(nethttp.MethodGet, "/lineup_status.json", nil))
+
+	var got lineupStatusResp
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ScanInProgress != 0 || got.ScanPossible != 1 || got.Source != "Cable" {
+		t.Fatalf("unexpected status: %+v", got)
+	}
+}
+
+func TestHDHomeRunHandlerLineupPost(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{query: "?scan=start", want: nethttp.StatusOK},
+		{query: "?scan=abort", want: nethttp.StatusOK},
+		{query: "", want: nethttp.StatusBadRequest},
+		{query: "?scan=later", want: nethttp.StatusBadRequest},
+	}
+
+	h := NewHDHomeRunHandler(service.NewChannelService(&stubChannelRepo{}), testDevice, "")
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		h.LineupPost(rec, httptest.NewRequest(nethttp.MethodPost, "/lineup.post"+tc.query, nil))
+		if rec.Code != tc.want {
+			t.Fatalf("%q: expected %d, got %d", tc.query, tc.want, rec.Code)
+		}
+	}
+}
@@ -9,17 +9,21 @@ import (
 
 type healthCheckFunc func(context.Context) error
 
+// healthDetailsFunc reports the state of background components, keyed by component.
+type healthDetailsFunc func() map[string]any
+
 type HealthHandler struct {
-	check healthCheckFunc
+	check   healthCheckFunc
+	details healthDetailsFunc
 }
 
-func NewHealthHandler(check healthCheckFunc) *HealthHandler {
-	return &HealthHandler{check: check}
+func NewHealthHandler(check healthCheckFunc, details healthDetailsFunc) *HealthHandler {
+	return &HealthHandler{check: check, details: details}
 }
 
 func (h *HealthHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
 	status := nethttp.StatusOK
-	resp := map[string]string{"status": "ok"}
+	resp := map[string]any{"status": "ok"}
 
 	if h.check != nil {
 		if err := h.check(r.Context()); err != nil {
@@ -28,6 +32,9 @@ func (h *HealthHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
 			slog.Error("health check failed", "error", err)
 		}
 	}
+	if h.details != nil {
+		resp["details"] = h.details()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
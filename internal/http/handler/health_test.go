@@ -10,7 +10,7 @@ import (
 )
 
 func TestHealthHandlerGetHealthy(t *testing.T) {
-	h := NewHealthHandler(func(context.Context) error { return nil })
+	h := NewHealthHandler(func(context.Context) error { return nil }, nil)
 
 	req := httptest.NewRequest(nethttp.MethodGet, "/healthz", nil)
 	rec := httptest.NewRecorder()
@@ -30,7 +30,7 @@ func TestHealthHandlerGetHealthy(t *testing.T) {
 }
 
 func TestHealthHandlerGetUnhealthy(t *testing.T) {
-	h := NewHealthHandler(func(context.Context) error { return errors.New("db down") })
+	h := NewHealthHandler(func(context.Context) error { return errors.New("db down") }, nil)
 
 	req := httptest.NewRequest(nethttp.MethodGet, "/healthz", nil)
 	rec := httptest.NewRecorder()
@@ -48,3 +48,24 @@ func TestHealthHandlerGetUnhealthy(t *testing.T) {
 		t.Fatalf("expected status unhealthy, got %q", got["status"])
 	}
 }
+
+func TestHealthHandlerGetIncludesDetails(t *testing.T) {
+	h := NewHealthHandler(func(context.Context) error { return nil }, func() map[string]any {
+		return map[string]any{"multicast": []string{"running"}}
+	})
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+	h.Get(rec, req)
+
+	var got struct {
+		Status  string              `json:"status"`
+		Details map[string][]string `json:"details"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.Status != "ok" || len(got.Details["multicast"]) != 1 || got.Details["multicast"][0] != "running" {
+		t.Fatalf("unexpected response %+v", got)
+	}
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	nethttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/stream"
+)
+
+// HLSHandler serves channels as live HLS streams for browsers and mobile players. Each client
+// watching a channel holds one tuner from its first request until it has made none for idle.
+type HLSHandler struct {
+	seg      *stream.Segmenter
+	channels *service.ChannelService
+	sessions *service.SessionRegistry
+	idle     time.Duration
+	now      func() time.Time
+}
+
+func NewHLSHandler(seg *stream.Segmenter, channels *service.ChannelService, sessions *service.SessionRegistry, idle time.Duration) *HLSHandler {
+	return &HLSHandler{seg: seg, channels: channels, sessions: sessions, idle: idle, now: time.Now}
+}
+
+// hold keeps the session of the client watching the channel, writing an error response and
+// returning false when every tuner is busy. Players fetch from one address but from many ports, so
+// clients are told apart by host alone.
+func (h *HLSHandler) hold(w nethttp.ResponseWriter, r *nethttp.Request, id uint) (*service.ActiveSession, bool) {
+	client := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	sess, err := h.sessions.Hold(id, client, h.idle)
+	if err != nil {
+		slog.Warn("refuse hls stream", "channel_id", id, "client", client, "error", err)
+		writeErr(w, err)
+		return nil, false
+	}
+	return sess, true
+}
+
+// Master serves the channel's master playlist, which offers the media playlist along with the
+// programme's audio tracks and subtitle languages.
+func (h *HLSHandler) Master(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := h.hold(w, r, id); !ok {
+		return
+	}
+	ch, err := h.channels.Get(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	m, err := h.seg.Master(r.Context(), *ch, h.now())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, a := range m.Audio {
+		fmt.Fprintf(&buf, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",NAME=\"%s\"%s,DEFAULT=%s,AUTOSELECT=YES\n",
+			quoted(a.Name), languageAttr(a.Language), yesNo(a.Default))
+	}
+	for _, s := range m.Subtitles {
+		fmt.Fprintf(&buf, "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"%s\"%s,DEFAULT=%s,AUTOSELECT=YES,FORCED=%s,URI=\"subtitles/%s.m3u8\"\n",
+			quoted(s.Name), languageAttr(s.Language), yesNo(s.Default), yesNo(s.Forced), s.Tag())
+	}
+	fmt.Fprintf(&buf, "#EXT-X-STREAM-INF:BANDWIDTH=%d", m.Bandwidth)
+	if len(m.Audio) > 0 {
+		buf.WriteString(",AUDIO=\"audio\"")
+	}
+	if len(m.Subtitles) > 0 {
+		buf.WriteString(",SUBTITLES=\"subs\"")
+	}
+	buf.WriteString("\nindex.m3u8\n")
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("write hls master playlist response", "error", err)
+	}
+}
+
+// Playlist serves the channel's sliding-window media playlist. Segment URIs are relative, so
+// they resolve against whatever address the playlist was fetched from.
+func (h *HLSHandler) Playlist(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := h.hold(w, r, id); !ok {
+		return
+	}
+
+	pl, err := h.seg.Playlist(r.Context(), id, h.now())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	writeMediaPlaylist(w, pl, func(seq int64) string {
+		return fmt.Sprintf("segments/%d.ts", seq)
+	})
+}
+
+// SubtitlePlaylist serves the media playlist of the channel's subtitles in one language. Its
+// segments line up with the video segments.
+func (h *HLSHandler) SubtitlePlaylist(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := h.hold(w, r, id); !ok {
+		return
+	}
+
+	pl, err := h.seg.Playlist(r.Context(), id, h.now())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	lang := url.PathEscape(chi.URLParam(r, "lang"))
+	writeMediaPlaylist(w, pl, func(seq int64) string {
+		return fmt.Sprintf("%s/%d.vtt", lang, seq)
+	})
+}
+
+// SubtitleSegment serves one WebVTT segment of the channel's subtitles in one language.
+func (h *HLSHandler) SubtitleSegment(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	seq, err := strconv.ParseInt(chi.URLParam(r, "seq"), 10, 64)
+	if err != nil {
+		nethttp.Error(w, "invalid segment", nethttp.StatusBadRequest)
+		return
+	}
+	sess, ok := h.hold(w, r, id)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.seg.WriteSubtitles(r.Context(), id, chi.URLParam(r, "lang"), seq, &buf); err != nil {
+		slog.Error("cut hls subtitle segment", "channel_id", id, "sequence", seq, "error", err)
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vtt")
+	n, err := buf.WriteTo(w)
+	sess.Sent(int(n))
+	if err != nil {
+		slog.Error("write hls subtitle segment response", "error", err)
+	}
+}
+
+// writeMediaPlaylist writes a sliding-window media playlist, naming each segment by uri.
+func writeMediaPlaylist(w nethttp.ResponseWriter, pl *stream.MediaPlaylist, uri func(seq int64) string) {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&buf, "#EXT-X-TARGETDURATION:%d\n", pl.TargetDuration)
+	fmt.Fprintf(&buf, "#EXT-X-MEDIA-SEQUENCE:%d\n", pl.MediaSequence)
+	fmt.Fprintf(&buf, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", pl.DiscontinuitySequence)
+	for _, s := range pl.Segments {
+		if s.Discontinuity {
+			buf.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		fmt.Fprintf(&buf, "#EXTINF:%.3f,\n", s.Duration.Seconds())
+		buf.WriteString(uri(s.Sequence) + "\n")
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("write hls playlist response", "error", err)
+	}
+}
+
+// Segment serves one media segment of the channel.
+func (h *HLSHandler) Segment(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	seq, err := strconv.ParseInt(chi.URLParam(r, "seq"), 10, 64)
+	if err != nil {
+		nethttp.Error(w, "invalid segment", nethttp.StatusBadRequest)
+		return
+	}
+	sess, ok := h.hold(w, r, id)
+	if !ok {
+		return
+	}
+
+	// Cut the segment into a buffer first so a failure can still become a proper error response.
+	var buf bytes.Buffer
+	if err := h.seg.WriteSegment(r.Context(), id, seq, &buf); err != nil {
+		slog.Error("cut hls segment", "channel_id", id, "sequence", seq, "error", err)
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/mp2t")
+	n, err := buf.WriteTo(w)
+	sess.Sent(int(n))
+	if err != nil {
+		slog.Error("write hls segment response", "error", err)
+	}
+}
+
+// languageAttr is the LANGUAGE attribute of a rendition, left out when the language is unknown.
+func languageAttr(tag string) string {
+	if tag == "" {
+		return ""
+	}
+	return fmt.Sprintf(",LANGUAGE=\"%s\"", quoted(tag))
+}
+
+// quoted makes s safe inside a quoted playlist attribute, which cannot contain double quotes or
+// line breaks.
+func quoted(s string) string {
+	return strings.NewReplacer(`"`, "'", "\r", " ", "\n", " ").Replace(s)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
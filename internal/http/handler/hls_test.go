@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/stream"
+)
+
+func newTestHLSRouter(h *HLSHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/channels/{id}/master.m3u8", h.Master)
+	r.Get("/channels/{id}/index.m3u8", h.Playlist)
+	r.Get("/channels/{id}/segments/{seq}.ts", h.Segment)
+	r.Get("/channels/{id}/subtitles/{lang}.m3u8", h.SubtitlePlaylist)
+	r.Get("/channels/{id}/subtitles/{lang}/{seq}.vtt", h.SubtitleSegment)
+	return r
+}
+
+func TestHLSHandlerPlaylist(t *testing.T) {
+	items := &stubChannelItemRepo{items: []service.ChannelItem{
+		{ID: 1, ContentID: 1, Content: &service.Content{ID: 1, Title: "Pilot", Length: 20}},
+		{ID: 2, ContentID: 2, Content: &service.Content{ID: 2, Title: "Finale", Length: 15}},
+	}}
+	channels := loopingChannel()
+	timeline := service.NewTimelineService(channels, items, testEpoch)
+	h := NewHLSHandler(stream.NewSegmenter(timeline, 6*time.Second, 3), service.NewChannelService(channels), service.NewSessionRegistry(1), time.Minute)
+	h.now = func() time.Time { return testEpoch.Add(40 * time.Second) }
+
+	rec := httptest.NewRecorder()
+	newTestHLSRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/channels/4/index.m3u8", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.apple.mpegurl" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:12\n#EXT-X-MEDIA-SEQUENCE:4\n" +
+		"#EXT-X-DISCONTINUITY-SEQUENCE:1\n" +
+		"#EXTINF:10.000,\nsegments/4.ts\n" +
+		"#EXTINF:5.000,\nsegments/5.ts\n" +
+		"#EXT-X-DISCONTINUITY\n#EXTINF:7.000,\nsegments/6.ts\n"
+	if rec.Body.String() != want {
+		t.Fatalf("unexpected playlist:\n%s", rec.Body.String())
+	}
+}
+
+func TestHLSHandlerMaster(t *testing.T) {
+	channels := &stubChannelRepo{
+		getByID: func(_ context.Context, id uint) (*service.Channel, error) {
+			return &service.Channel{ID: id, Title: "News", Loop: true, AudioLanguage: "fr"}, nil
+		},
+	}
+	content := &service.Content{ID: 1, Title: "Pilot", Length: 20, Bitrate: 2_500_000, Tracks: []service.Track{
+		{Kind: service.TrackAudio, Index: 0, Codec: "aac", Language: "en", Default: true},
+		{Kind: service.TrackAudio, Index: 1, Codec: "aac", Language: "fr", Title: `Director's "cut"`},
+		{Kind: service.TrackSubtitle, Codec: "subrip", Language: "en", Path: "/media/pilot.en.srt"},
+		{Kind: service.TrackSubtitle, Codec: "subrip", Path: "/media/pilot.srt"},
+	}}
+	items := &stubChannelItemRepo{items: []service.ChannelItem{{ID: 1, ContentID: 1, Content: content}}}
+	timeline := service.NewTimelineService(channels, items, testEpoch)
+	h := NewHLSHandler(stream.NewSegmenter(timeline, 6*time.Second, 3), service.NewChannelService(channels), service.NewSessionRegistry(1), time.Minute)
+	h.now = func() time.Time { return testEpoch.Add(5 * time.Second) }
+
+	rec := httptest.NewRecorder()
+	newTestHLSRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/channels/4/master.m3u8", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n" +
+		"#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"audio\",NAME=\"Director's 'cut'\",LANGUAGE=\"fr\",DEFAULT=YES,AUTOSELECT=YES\n" +
+		"#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"English\",LANGUAGE=\"en\",DEFAULT=NO,AUTOSELECT=YES,FORCED=NO,URI=\"subtitles/en.m3u8\"\n" +
+		"#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",NAME=\"Subtitles\",DEFAULT=NO,AUTOSELECT=YES,FORCED=NO,URI=\"subtitles/und.m3u8\"\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=2500000,AUDIO=\"audio\",SUBTITLES=\"subs\"\nindex.m3u8\n"
+	if rec.Body.String() != want {
+		t.Fatalf("unexpected master playlist:\n%s", rec.Body.String())
+	}
+}
+
+func TestHLSHandlerSubtitlePlaylist(t *testing.T) {
+	items := &stubChannelItemRepo{items: []service.ChannelItem{
+		{ID: 1, ContentID: 1, Content: &service.Content{ID: 1, Title: "Pilot", Length: 20}},
+	}}
+	channels := loopingChannel()
+	timeline := service.NewTimelineService(channels, items, testEpoch)
+	h := NewHLSHandler(stream.NewSegmenter(timeline, 6*time.Second, 2), service.NewChannelService(channels), service.NewSessionRegistry(1), time.Minute)
+	h.now = func() time.Time { return testEpoch.Add(15 * time.Second) }
+
+	rec := httptest.NewRecorder()
+	newTestHLSRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/channels/4/subtitles/pt-BR.m3u8", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "\npt-BR/1.vtt\n") {
+		t.Fatalf("expected subtitle segment URIs, got:\n%s", rec.Body.String())
+	}
+}
+
+func TestHLSHandlerMapsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		channels *stubChannelRepo
+		wantCode int
+	}{
+		{name: "invalid id", path: "/channels/x/index.m3u8", channels: loopingChannel(), wantCode: nethttp.StatusBadRequest},
+		{name: "unknown channel", path: "/channels/4/index.m3u8", channels: &stubChannelRepo{}, wantCode: nethttp.StatusNotFound},
+		{name: "empty playlist", path: "/channels/4/index.m3u8", channels: loopingChannel(), wantCode: nethttp.StatusNotFound},
+		{name: "invalid segment", path: "/channels/4/segments/next.ts", channels: loopingChannel(), wantCode: nethttp.StatusBadRequest},
+		{name: "segment of empty playlist", path: "/channels/4/segments/7.ts", channels: loopingChannel(), wantCode: nethttp.StatusNotFound},
+		{name: "master of unknown channel", path: "/channels/4/master.m3u8", channels: &stubChannelRepo{}, wantCode: nethttp.StatusNotFound},
+		{name: "master of empty playlist", path: "/channels/4/master.m3u8", channels: loopingChannel(), wantCode: nethttp.StatusNotFound},
+		{name: "invalid subtitle segment", path: "/channels/4/subtitles/en/next.vtt", channels: loopingChannel(), wantCode: nethttp.StatusBadRequest},
+		{name: "subtitle segment of empty playlist", path: "/channels/4/subtitles/en/7.vtt", channels: loopingChannel(), wantCode: nethttp.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			timeline := service.NewTimelineService(tc.channels, &stubChannelItemRepo{}, testEpoch)
+			h := NewHLSHandler(stream.NewSegmenter(timeline, 6*time.Second, 3), service.NewChannelService(tc.channels), service.NewSessionRegistry(1), time.Minute)
+
+			rec := httptest.NewRecorder()
+			newTestHLSRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, tc.path, nil))
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d", tc.wantCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHLSHandlerHoldsOneTunerPerClient(t *testing.T) {
+	items := &stubChannelItemRepo{items: []service.ChannelItem{
+		{ID: 1, ContentID: 1, Content: &service.Content{ID: 1, Title: "Pilot", Length: 20}},
+	}}
+	channels := loopingChannel()
+	timeline := service.NewTimelineService(channels, items, testEpoch)
+	sessions := service.NewSessionRegistry(1)
+	h := NewHLSHandler(stream.NewSegmenter(timeline, 6*time.Second, 3), service.NewChannelService(channels), sessions, time.Minute)
+	h.now = func() time.Time { return testEpoch.Add(15 * time.Second) }
+	router := newTestHLSRouter(h)
+
+	get := func(path, remoteAddr string) int {
+		req := httptest.NewRequest(nethttp.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, addr := range []string{"192.0.2.1:5000", "192.0.2.1:5001"} {
+		if code := get("/channels/4/index.m3u8", addr); code != nethttp.StatusOK {
+			t.Fatalf("request from %s: expected %d, got %d", addr, nethttp.StatusOK, code)
+		}
+	}
+	got := sessions.List()
+	if len(got) != 1 || got[0].ChannelID != 4 || got[0].Client != "192.0.2.1" {
+		t.Fatalf("expected one session for the client, got %+v", got)
+	}
+
+	if code := get("/channels/4/index.m3u8", "192.0.2.2:5000"); code != nethttp.StatusServiceUnavailable {
+		t.Fatalf("expected %d for a second client, got %d", nethttp.StatusServiceUnavailable, code)
+	}
+	if code := get("/channels/4/subtitles/en/1.vtt", "192.0.2.2:5000"); code != nethttp.StatusServiceUnavailable {
+		t.Fatalf("expected %d for a second client's segment, got %d", nethttp.StatusServiceUnavailable, code)
+	}
+}
+
+func TestHLSHandlerRefusesStoppedClient(t *testing.T) {
+	items := &stubChannelItemRepo{items: []service.ChannelItem{
+		{ID: 1, ContentID: 1, Content: &service.Content{ID: 1, Title: "Pilot", Length: 20}},
+	}}
+	channels := loopingChannel()
+	timeline := service.NewTimelineService(channels, items, testEpoch)
+	sessions := service.NewSessionRegistry(1)
+	h := NewHLSHandler(stream.NewSegmenter(timeline, 6*time.Second, 3), service.NewChannelService(channels), sessions, time.Minute)
+	h.now = func() time.Time { return testEpoch.Add(15 * time.Second) }
+	router := chi.NewRouter()
+	router.Get("/channels/{id}/index.m3u8", h.Playlist)
+	router.Get("/channels/{id}/segments/{seq}.ts", h.Segment)
+	router.Delete("/sessions/{id}", NewSessionHandler(sessions).Delete)
+
+	do := func(method, path, remoteAddr string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do(nethttp.MethodGet, "/channels/4/index.m3u8", "192.0.2.1:5000"); code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, code)
+	}
+	got := sessions.List()
+	if len(got) != 1 {
+		t.Fatalf("expected one session, got %+v", got)
+	}
+	if code := do(nethttp.MethodDelete, fmt.Sprintf("/sessions/%d", got[0].ID), "198.51.100.1:5000"); code != nethttp.StatusNoContent {
+		t.Fatalf("expected %d stopping the session, got %d", nethttp.StatusNoContent, code)
+	}
+
+	if code := do(nethttp.MethodGet, "/channels/4/segments/2.ts", "192.0.2.1:5001"); code != nethttp.StatusForbidden {
+		t.Fatalf("expected %d for the stopped client, got %d", nethttp.StatusForbidden, code)
+	}
+	if got := sessions.List(); len(got) != 0 {
+		t.Fatalf("expected the stopped client to hold no tuner, got %+v", got)
+	}
+	if code := do(nethttp.MethodGet, "/channels/4/index.m3u8", "192.0.2.2:5000"); code != nethttp.StatusOK {
+		t.Fatalf("expected another client to get the freed tuner, got %d", code)
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	nethttp "net/http"
+	"strings"
+
+	"github.com/iamseth/tiny-headend/internal/epg"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const lineupGroup = "tiny-headend"
+
+// LineupHandler serves the channel lineup as an M3U playlist for IPTV players.
+type LineupHandler struct {
+	svc     *service.ChannelService
+	baseURL string
+}
+
+// NewLineupHandler returns a handler whose stream URLs start with baseURL, or with the request's
+// own scheme and host when baseURL is empty.
+func NewLineupHandler(svc *service.ChannelService, baseURL string) *LineupHandler {
+	return &LineupHandler{svc: svc, baseURL: baseURL}
+}
+
+func (h *LineupHandler) M3U(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channels, err := h.svc.All(r.Context())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	base := requestBaseURL(r, h.baseURL)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "#EXTM3U url-tvg=\"%s/epg.xml\"\n", base)
+	for _, ch := range channels {
+		logo := ""
+		if ch.HasLogo {
+			logo = fmt.Sprintf(" tvg-logo=\"%s\"", epg.LogoURL(base, ch.ID))
+		}
+		fmt.Fprintf(&buf, "#EXTINF:-1 tvg-id=\"%s\" tvg-chno=\"%d\" tvg-name=\"%s\"%s group-title=\"%s\",%s\n",
+			epg.ChannelID(ch.ID), ch.ChannelNumber, m3uAttr(ch.Title), logo, lineupGroup, m3uLine(ch.Title))
+		fmt.Fprintf(&buf, "%s\n", StreamURL(base, ch.ID))
+	}
+
+	w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("write lineup response", "error", err)
+	}
+}
+
+// StreamURL is the URL a client tunes to for a channel's live MPEG-TS stream.
+func StreamURL(base string, channelID uint) string {
+	return fmt.Sprintf("%s/channels/%d/stream.ts", base, channelID)
+}
+
+// m3uLine keeps a value on a single playlist line.
+func m3uLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// m3uAttr makes a value safe inside a double-quoted #EXTINF attribute.
+func m3uAttr(s string) string {
+	return strings.ReplaceAll(m3uLine(s), `"`, "'")
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func lineupChannels(channels ...service.Channel) *stubChannelRepo {
+	return &stubChannelRepo{
+		listFn: func(context.Context, int, int) ([]service.Channel, error) {
+			return channels, nil
+		},
+	}
+}
+
+func TestLineupHandlerM3UListsChannels(t *testing.T) {
+	repo := lineupChannels(
+		service.Channel{ID: 1, Title: "News", ChannelNumber: 7},
+		service.Channel{ID: 2, Title: `The "Movie" Channel`, ChannelNumber: 12, HasLogo: true},
+	)
+	h := NewLineupHandler(service.NewChannelService(repo), "")
+
+	req := httptest.NewRequest(nethttp.MethodGet, "http://headend.lan:8080/lineup.m3u", nil)
+	rec := httptest.NewRecorder()
+	h.M3U(rec, req)
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "audio/x-mpegurl; charset=utf-8" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	want := `#EXTM3U url-tvg="http://headend.lan:8080/epg.xml"
+#EXTINF:-1 tvg-id="1" tvg-chno="7" tvg-name="News" group-title="tiny-headend",News
+http://headend.lan:8080/channels/1/stream.ts
+#EXTINF:-1 tvg-id="2" tvg-chno="12" tvg-name="The 'Movie' Channel" tvg-logo="http://headend.lan:8080/channels/2/logo" group-title="tiny-headend",The "Movie" Channel
+http://headend.lan:8080/channels/2/stream.ts
+`
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("unexpected playlist:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLineupHandlerM3UUsesBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		tls     bool
+		want    string
+	}{
+		{name: "configured", baseURL: "https://tv.example.com", want: "https://tv.example.com/channels/1/stream.ts"},
+		{name: "tls request", tls: true, want: "https://headend.lan/channels/1/stream.ts"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := lineupChannels(service.Channel{ID: 1, Title: "News", ChannelNumber: 7})
+			h := NewLineupHandler(service.NewChannelService(repo), tc.baseURL)
+
+			req := httptest.NewRequest(nethttp.MethodGet, "http://headend.lan/lineup.m3u", nil)
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rec := httptest.NewRecorder()
+			h.M3U(rec, req)
+
+			if body := rec.Body.String(); !slices.Contains(strings.Split(body, "\n"), tc.want) {
+				t.Fatalf("expected stream url %q in:\n%s", tc.want, body)
+			}
+		})
+	}
+}
+
+func TestLineupHandlerM3UReturnsInternalErrorOnFailure(t *testing.T) {
+	repo := &stubChannelRepo{
+		listFn: func(context.Context, int, int) ([]service.Channel, error) {
+			return nil, errors.New("db down")
+		},
+	}
+	h := NewLineupHandler(service.NewChannelService(repo), "")
+
+	rec := httptest.NewRecorder()
+	h.M3U(rec, httptest.NewRequest(nethttp.MethodGet, "/lineup.m3u", nil))
+
+	if rec.Code != nethttp.StatusInternalServerError {
+		t.Fatalf("expected %d, got %d", nethttp.StatusInternalServerError, rec.Code)
+	}
+}
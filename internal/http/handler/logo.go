@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	nethttp "net/http"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+// artworkCacheControl lets clients and proxies keep logos and posters for an hour before
+// revalidating them.
+const artworkCacheControl = "public, max-age=3600"
+
+// logoField is the multipart form field a logo is uploaded in.
+const logoField = "logo"
+
+// LogoHandler uploads and serves channel logos.
+type LogoHandler struct {
+	svc *service.LogoService
+}
+
+func NewLogoHandler(svc *service.LogoService) *LogoHandler {
+	return &LogoHandler{svc: svc}
+}
+
+// Get serves the channel's logo with conditional request support.
+func (h *LogoHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	img, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Cache-Control", artworkCacheControl)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(img.Data)))
+	nethttp.ServeContent(w, r, "", img.ModTime, bytes.NewReader(img.Data))
+}
+
+// Put replaces the channel's logo with the image uploaded in the logo field of a multipart form.
+func (h *LogoHandler) Put(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	// Leave room for the multipart framing around the image.
+	r.Body = nethttp.MaxBytesReader(w, r.Body, service.MaxLogoBytes+maxBodyBytes)
+	if err := r.ParseMultipartForm(service.MaxLogoBytes); err != nil {
+		var maxErr *nethttp.MaxBytesError
+		if errors.As(err, &maxErr) {
+			nethttp.Error(w, "payload too large", nethttp.StatusRequestEntityTooLarge)
+			return
+		}
+		nethttp.Error(w, "bad multipart form", nethttp.StatusBadRequest)
+		return
+	}
+	defer func() {
+		_ = r.MultipartForm.RemoveAll()
+	}()
+
+	f, _, err := r.FormFile(logoField)
+	if err != nil {
+		nethttp.Error(w, "logo file is required", nethttp.StatusBadRequest)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		slog.Error("read uploaded logo", "channel_id", id, "error", err)
+		writeErr(w, err)
+		return
+	}
+
+	if err := h.svc.Set(r.Context(), id, data); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.WriteHeader(nethttp.StatusNoContent)
+}
+
+func (h *LogoHandler) Delete(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), id); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.WriteHeader(nethttp.StatusNoContent)
+}
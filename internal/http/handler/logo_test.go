@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+// testPNG is enough of a PNG file for its type to be recognised.
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+type stubLogoRepo struct {
+	logos map[uint]*service.Image
+}
+
+func (s *stubLogoRepo) SetLogo(_ context.Context, channelID uint, img *service.Image) error {
+	if channelID != 4 {
+		return service.ErrNotFound
+	}
+	s.logos[channelID] = img
+	return nil
+}
+
+func (s *stubLogoRepo) GetLogo(_ context.Context, channelID uint) (*service.Image, error) {
+	if img, ok := s.logos[channelID]; ok {
+		return img, nil
+	}
+	return nil, service.ErrNotFound
+}
+
+func (s *stubLogoRepo) DeleteLogo(_ context.Context, channelID uint) error {
+	if _, ok := s.logos[channelID]; !ok {
+		return service.ErrNotFound
+	}
+	delete(s.logos, channelID)
+	return nil
+}
+
+func newTestLogoRouter(h *LogoHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/channels/{id}/logo", h.Get)
+	r.Put("/channels/{id}/logo", h.Put)
+	r.Delete("/channels/{id}/logo", h.Delete)
+	return r
+}
+
+// logoUpload builds a multipart request uploading data in field.
+func logoUpload(t *testing.T, path, field string, data []byte) *nethttp.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile(field, "logo.png")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		t.Fatalf("write form file: %v", err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("close form: %v", err)
+	}
+	req := httptest.NewRequest(nethttp.MethodPut, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestLogoHandlerUploadAndServe(t *testing.T) {
+	repo := &stubLogoRepo{logos: map[uint]*service.Image{}}
+	router := newTestLogoRouter(NewLogoHandler(service.NewLogoService(repo)))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, logoUpload(t, "/channels/4/logo", "logo", testPNG))
+	if rec.Code != nethttp.StatusNoContent {
+		t.Fatalf("expected %d, got %d: %s", nethttp.StatusNoContent, rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/channels/4/logo", nil))
+	if rec.Code != nethttp.StatusOK || !bytes.Equal(rec.Body.Bytes(), testPNG) {
+		t.Fatalf("expected the uploaded logo, got %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	etag := rec.Header().Get("ETag")
+	if rec.Header().Get("Cache-Control") != artworkCacheControl || etag == "" || rec.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected caching headers, got %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/channels/4/logo", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != nethttp.StatusNotModified {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotModified, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodDelete, "/channels/4/logo", nil))
+	if rec.Code != nethttp.StatusNoContent {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNoContent, rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/channels/4/logo", nil))
+	if rec.Code != nethttp.StatusNotFound {
+		t.Fatalf("expected %d after delete, got %d", nethttp.StatusNotFound, rec.Code)
+	}
+}
+
+func TestLogoHandlerRejectsBadUploads(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      func(t *testing.T) *nethttp.Request
+		wantCode int
+	}{
+		{
+			name:     "invalid id",
+			req:      func(t *testing.T) *nethttp.Request { return logoUpload(t, "/channels/x/logo", "logo", testPNG) },
+			wantCode: nethttp.StatusBadRequest,
+		},
+		{
+			name:     "unknown channel",
+			req:      func(t *testing.T) *nethttp.Request { return logoUpload(t, "/channels/5/logo", "logo", testPNG) },
+			wantCode: nethttp.StatusNotFound,
+		},
+		{
+			name:     "wrong field",
+			req:      func(t *testing.T) *nethttp.Request { return logoUpload(t, "/channels/4/logo", "image", testPNG) },
+			wantCode: nethttp.StatusBadRequest,
+		},
+		{
+			name:     "not an image",
+			req:      func(t *testing.T) *nethttp.Request { return logoUpload(t, "/channels/4/logo", "logo", []byte("hello")) },
+			wantCode: nethttp.StatusBadRequest,
+		},
+		{
+			name: "not multipart",
+			req: func(t *testing.T) *nethttp.Request {
+				return httptest.NewRequest(nethttp.MethodPut, "/channels/4/logo", bytes.NewReader(testPNG))
+			},
+			wantCode: nethttp.StatusBadRequest,
+		},
+		{
+			name: "too large",
+			req: func(t *testing.T) *nethttp.Request {
+				return logoUpload(t, "/channels/4/logo", "logo", make([]byte, service.MaxLogoBytes+maxBodyBytes))
+			},
+			wantCode: nethttp.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &stubLogoRepo{logos: map[uint]*service.Image{}}
+			rec := httptest.NewRecorder()
+			newTestLogoRouter(NewLogoHandler(service.NewLogoService(repo))).ServeHTTP(rec, tc.req(t))
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tc.wantCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	nethttp "net/http"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type PlaylistHandler struct {
+	svc *service.PlaylistService
+}
+
+func NewPlaylistHandler(svc *service.PlaylistService) *PlaylistHandler {
+	return &PlaylistHandler{svc: svc}
+}
+
+type channelItemReq struct {
+	ContentID    uint `json:"contentId"`
+	CollectionID uint `json:"collectionId"`
+}
+
+type reorderReq struct {
+	ItemIDs []uint `json:"itemIds"`
+}
+
+func (h *PlaylistHandler) List(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := h.svc.Items(r.Context(), channelID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if items == nil {
+		items = []service.ChannelItem{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		slog.Error("encode list channel items response", "error", err)
+	}
+}
+
+func (h *PlaylistHandler) Add(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	var req channelItemReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	item := &service.ChannelItem{ChannelID: channelID, ContentID: req.ContentID, CollectionID: req.CollectionID}
+	if err := h.svc.Add(r.Context(), item); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(nethttp.StatusCreated)
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		slog.Error("encode add channel item response", "error", err)
+	}
+}
+
+func (h *PlaylistHandler) Remove(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	itemID, ok := parseURLID(w, r, "itemId")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Remove(r.Context(), channelID, itemID); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(nethttp.StatusNoContent)
+}
+
+func (h *PlaylistHandler) Reorder(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	var req reorderReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	items, err := h.svc.Reorder(r.Context(), channelID, req.ItemIDs)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if items == nil {
+		items = []service.ChannelItem{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		slog.Error("encode reorder channel items response", "error", err)
+	}
+}
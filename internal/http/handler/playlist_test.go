@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type stubChannelItemRepo struct {
+	items     []service.ChannelItem
+	appendFn  func(context.Context, *service.ChannelItem) error
+	deleteFn  func(context.Context, uint, uint) error
+	reorderFn func(context.Context, uint, []uint) error
+}
+
+func (s *stubChannelItemRepo) ListByChannel(context.Context, uint) ([]service.ChannelItem, error) {
+	return s.items, nil
+}
+
+func (s *stubChannelItemRepo) Append(ctx context.Context, item *service.ChannelItem) error {
+	if s.appendFn == nil {
+		return nil
+	}
+	return s.appendFn(ctx, item)
+}
+
+func (s *stubChannelItemRepo) Delete(ctx context.Context, channelID, itemID uint) error {
+	if s.deleteFn == nil {
+		return nil
+	}
+	return s.deleteFn(ctx, channelID, itemID)
+}
+
+func (s *stubChannelItemRepo) Reorder(ctx context.Context, channelID uint, itemIDs []uint) error {
+	if s.reorderFn == nil {
+		return nil
+	}
+	return s.reorderFn(ctx, channelID, itemIDs)
+}
+
+func existingChannel() *stubChannelRepo {
+	return &stubChannelRepo{
+		getByID: func(_ context.Context, id uint) (*service.Channel, error) {
+			return &service.Channel{ID: id, Title: "News", ChannelNumber: 7}, nil
+		},
+	}
+}
+
+func existingContent() *stubContentRepo {
+	return &stubContentRepo{
+		getByID: func(_ context.Context, id uint) (*service.Content, error) {
+			return &service.Content{ID: id, Title: "Pilot"}, nil
+		},
+	}
+}
+
+func newTestPlaylistRouter(h *PlaylistHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/channels/{id}/items", h.List)
+	r.Post("/channels/{id}/items", h.Add)
+	r.Put("/channels/{id}/items/order", h.Reorder)
+	r.Delete("/channels/{id}/items/{itemId}", h.Remove)
+	return r
+}
+
+func TestPlaylistHandlerListEncodesEmptyArray(t *testing.T) {
+	svc := service.NewPlaylistService(existingChannel(), existingContent(), &stubChannelItemRepo{})
+	h := NewPlaylistHandler(svc)
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/channels/1/items", nil)
+	rec := httptest.NewRecorder()
+	newTestPlaylistRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if got := rec.Body.String(); got != "[]\n" {
+		t.Fatalf("expected empty array, got %q", got)
+	}
+}
+
+func TestPlaylistHandlerListUnknownChannelReturnsNotFound(t *testing.T) {
+	svc := service.NewPlaylistService(&stubChannelRepo{}, existingContent(), &stubChannelItemRepo{})
+	h := NewPlaylistHandler(svc)
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/channels/1/items", nil)
+	rec := httptest.NewRecorder()
+	newTestPlaylistRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusNotFound {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotFound, rec.Code)
+	}
+}
+
+func TestPlaylistHandlerAddReturnsCreatedItem(t *testing.T) {
+	items := &stubChannelItemRepo{
+		appendFn: func(_ context.Context, item *service.ChannelItem) error {
+			item.ID = 4
+			return nil
+		},
+	}
+	h := NewPlaylistHandler(service.NewPlaylistService(existingChannel(), existingContent(), items))
+
+	req := httptest.NewRequest(nethttp.MethodPost, "/channels/2/items", bytes.NewBufferString(`{"contentId":3}`))
+	rec := httptest.NewRecorder()
+	newTestPlaylistRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusCreated {
+		t.Fatalf("expected %d, got %d", nethttp.StatusCreated, rec.Code)
+	}
+	var got service.ChannelItem
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ID != 4 || got.ChannelID != 2 || got.ContentID != 3 || got.Content == nil {
+		t.Fatalf("unexpected item: %+v", got)
+	}
+}
+
+func TestPlaylistHandlerAddRejectsBadRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "unknown field", body: `{"contentId":3,"extra":true}`, want: nethttp.StatusBadRequest},
+		{name: "missing content", body: `{}`, want: nethttp.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewPlaylistHandler(service.NewPlaylistService(existingChannel(), existingContent(), &stubChannelItemRepo{}))
+
+			req := httptest.NewRequest(nethttp.MethodPost, "/channels/2/items", bytes.NewBufferString(tc.body))
+			rec := httptest.NewRecorder()
+			newTestPlaylistRouter(h).ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPlaylistHandlerRemove(t *testing.T) {
+	var gotChannel, gotItem uint
+	items := &stubChannelItemRepo{
+		deleteFn: func(_ context.Context, channelID, itemID uint) error {
+			gotChannel, gotItem = channelID, itemID
+			return nil
+		},
+	}
+	h := NewPlaylistHandler(service.NewPlaylistService(existingChannel(), existingContent(), items))
+
+	req := httptest.NewRequest(nethttp.MethodDelete, "/channels/2/items/6", nil)
+	rec := httptest.NewRecorder()
+	newTestPlaylistRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusNoContent {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNoContent, rec.Code)
+	}
+	if gotChannel != 2 || gotItem != 6 {
+		t.Fatalf("expected channel 2 item 6, got %d %d", gotChannel, gotItem)
+	}
+
+	bad := httptest.NewRequest(nethttp.MethodDelete, "/channels/2/items/x", nil)
+	badRec := httptest.NewRecorder()
+	newTestPlaylistRouter(h).ServeHTTP(badRec, bad)
+	if badRec.Code != nethttp.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", nethttp.StatusBadRequest, badRec.Code)
+	}
+}
+
+func TestPlaylistHandlerReorder(t *testing.T) {
+	var got []uint
+	items := &stubChannelItemRepo{
+		items: []service.ChannelItem{{ID: 1}, {ID: 2}},
+		reorderFn: func(_ context.Context, _ uint, ids []uint) error {
+			got = ids
+			return nil
+		},
+	}
+	h := NewPlaylistHandler(service.NewPlaylistService(existingChannel(), existingContent(), items))
+
+	req := httptest.NewRequest(nethttp.MethodPut, "/channels/2/items/order", bytes.NewBufferString(`{"itemIds":[2,1]}`))
+	rec := httptest.NewRecorder()
+	newTestPlaylistRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if len(got) != 2 || got[0] != 2 {
+		t.Fatalf("unexpected reorder ids: %v", got)
+	}
+
+	badReq := httptest.NewRequest(nethttp.MethodPut, "/channels/2/items/order", bytes.NewBufferString(`{"itemIds":[1]}`))
+	badRec := httptest.NewRecorder()
+	newTestPlaylistRouter(h).ServeHTTP(badRec, badReq)
+	if badRec.Code != nethttp.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", nethttp.StatusBadRequest, badRec.Code)
+	}
+}
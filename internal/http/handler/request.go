@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const maxBodyBytes = 1 << 20
+
+func decodeRequest(w nethttp.ResponseWriter, r *nethttp.Request, dst any) bool {
+	r.Body = nethttp.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := decodeJSONBody(r, dst); err != nil {
+		var maxErr *nethttp.MaxBytesError
+		if errors.As(err, &maxErr) {
+			nethttp.Error(w, "payload too large", nethttp.StatusRequestEntityTooLarge)
+			return false
+		}
+		nethttp.Error(w, "bad json", nethttp.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func decodeJSONBody(r *nethttp.Request, dst any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("decode json body: %w", err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return errors.New("unexpected trailing json")
+	}
+	return nil
+}
+
+func parseID(w nethttp.ResponseWriter, r *nethttp.Request) (uint, bool) {
+	return parseURLID(w, r, "id")
+}
+
+func parseURLID(w nethttp.ResponseWriter, r *nethttp.Request, param string) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, param), 10, strconv.IntSize)
+	if err != nil {
+		nethttp.Error(w, "invalid id", nethttp.StatusBadRequest)
+		return 0, false
+	}
+	if id == 0 {
+		nethttp.Error(w, "invalid id", nethttp.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func parsePagination(w nethttp.ResponseWriter, r *nethttp.Request) (int, int, bool) {
+	const defaultLimit = 100
+	const maxLimit = 500
+	limit := defaultLimit
+	offset := 0
+
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsedLimit, err := strconv.Atoi(rawLimit)
+		if err != nil || parsedLimit <= 0 || parsedLimit > maxLimit {
+			nethttp.Error(w, "invalid limit", nethttp.StatusBadRequest)
+			return 0, 0, false
+		}
+		limit = parsedLimit
+	}
+
+	if rawOffset := r.URL.Query().Get("offset"); rawOffset != "" {
+		parsedOffset, err := strconv.Atoi(rawOffset)
+		if err != nil || parsedOffset < 0 {
+			nethttp.Error(w, "invalid offset", nethttp.StatusBadRequest)
+			return 0, 0, false
+		}
+		offset = parsedOffset
+	}
+
+	return limit, offset, true
+}
+
+// requestBaseURL returns configured when set, otherwise the scheme and host the request arrived on,
+// so that URLs handed to clients point back at this server.
+func requestBaseURL(r *nethttp.Request, configured string) string {
+	if configured != "" {
+		return configured
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func writeErr(w nethttp.ResponseWriter, err error) {
+	var ve service.ValidationError
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		nethttp.Error(w, "not found", nethttp.StatusNotFound)
+	case errors.Is(err, service.ErrNothingScheduled):
+		nethttp.Error(w, "nothing scheduled", nethttp.StatusNotFound)
+	case errors.As(err, &ve):
+		nethttp.Error(w, ve.Error(), nethttp.StatusBadRequest)
+	case errors.Is(err, service.ErrTunersBusy):
+		nethttp.Error(w, "all tuners are busy", nethttp.StatusServiceUnavailable)
+	case errors.Is(err, service.ErrSessionStopped):
+		nethttp.Error(w, "session was stopped", nethttp.StatusForbidden)
+	default:
+		nethttp.Error(w, "internal error", nethttp.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	nethttp "net/http"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type ScheduleHandler struct {
+	svc *service.ScheduleService
+}
+
+func NewScheduleHandler(svc *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{svc: svc}
+}
+
+type scheduleBlockReq struct {
+	Title        string   `json:"title"`
+	Days         []string `json:"days"`
+	Start        string   `json:"start"`
+	End          string   `json:"end"`
+	Shuffle      bool     `json:"shuffle"`
+	ContentIDs   []uint   `json:"contentIds"`
+	FillerIDs    []uint   `json:"fillerIds"`
+	CollectionID uint     `json:"collectionId"`
+}
+
+func (req scheduleBlockReq) block(channelID uint) *service.ScheduleBlock {
+	b := &service.ScheduleBlock{
+		ChannelID:    channelID,
+		Title:        req.Title,
+		Days:         req.Days,
+		Start:        req.Start,
+		End:          req.End,
+		Shuffle:      req.Shuffle,
+		ContentIDs:   req.ContentIDs,
+		FillerIDs:    req.FillerIDs,
+		CollectionID: req.CollectionID,
+	}
+	if b.ContentIDs == nil {
+		b.ContentIDs = []uint{}
+	}
+	if b.FillerIDs == nil {
+		b.FillerIDs = []uint{}
+	}
+	return b
+}
+
+func (h *ScheduleHandler) List(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	blocks, err := h.svc.List(r.Context(), channelID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if blocks == nil {
+		blocks = []service.ScheduleBlock{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(blocks); err != nil {
+		slog.Error("encode list schedule blocks response", "error", err)
+	}
+}
+
+func (h *ScheduleHandler) Create(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	var req scheduleBlockReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	b := req.block(channelID)
+	if err := h.svc.Create(r.Context(), b); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(nethttp.StatusCreated)
+	if err := json.NewEncoder(w).Encode(b); err != nil {
+		slog.Error("encode create schedule block response", "error", err)
+	}
+}
+
+func (h *ScheduleHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	blockID, ok := parseURLID(w, r, "blockId")
+	if !ok {
+		return
+	}
+
+	b, err := h.svc.Get(r.Context(), channelID, blockID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(b); err != nil {
+		slog.Error("encode get schedule block response", "error", err)
+	}
+}
+
+func (h *ScheduleHandler) Update(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	blockID, ok := parseURLID(w, r, "blockId")
+	if !ok {
+		return
+	}
+
+	var req scheduleBlockReq
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	b := req.block(channelID)
+	b.ID = blockID
+	if err := h.svc.Update(r.Context(), b); err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(b); err != nil {
+		slog.Error("encode update schedule block response", "error", err)
+	}
+}
+
+func (h *ScheduleHandler) Delete(w nethttp.ResponseWriter, r *nethttp.Request) {
+	channelID, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	blockID, ok := parseURLID(w, r, "blockId")
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), channelID, blockID); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(nethttp.StatusNoContent)
+}
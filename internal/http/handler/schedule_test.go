@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type stubScheduleBlockRepo struct {
+	blocks  []service.ScheduleBlock
+	created *service.ScheduleBlock
+	deleted uint
+}
+
+func (s *stubScheduleBlockRepo) ListByChannel(context.Context, uint) ([]service.ScheduleBlock, error) {
+	return s.blocks, nil
+}
+
+func (s *stubScheduleBlockRepo) GetByID(_ context.Context, _ uint, id uint) (*service.ScheduleBlock, error) {
+	for _, b := range s.blocks {
+		if b.ID == id {
+			return &b, nil
+		}
+	}
+	return nil, service.ErrNotFound
+}
+
+func (s *stubScheduleBlockRepo) Create(_ context.Context, b *service.ScheduleBlock) error {
+	b.ID = 4
+	s.created = b
+	return nil
+}
+
+func (s *stubScheduleBlockRepo) Update(context.Context, *service.ScheduleBlock) error {
+	return nil
+}
+
+func (s *stubScheduleBlockRepo) Delete(_ context.Context, _ uint, id uint) error {
+	for _, b := range s.blocks {
+		if b.ID == id {
+			s.deleted = id
+			return nil
+		}
+	}
+	return service.ErrNotFound
+}
+
+func newTestScheduleRouter(h *ScheduleHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/channels/{id}/blocks", h.List)
+	r.Post("/channels/{id}/blocks", h.Create)
+	r.Get("/channels/{id}/blocks/{blockId}", h.Get)
+	r.Put("/channels/{id}/blocks/{blockId}", h.Update)
+	r.Delete("/channels/{id}/blocks/{blockId}", h.Delete)
+	return r
+}
+
+func TestScheduleHandlerListEncodesEmptyArray(t *testing.T) {
+	h := NewScheduleHandler(service.NewScheduleService(existingChannel(), existingContent(), &stubScheduleBlockRepo{}))
+
+	rec := httptest.NewRecorder()
+	newTestScheduleRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/channels/1/blocks", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if got := rec.Body.String(); got != "[]\n" {
+		t.Fatalf("expected empty array, got %q", got)
+	}
+}
+
+func TestScheduleHandlerCreateReturnsBlock(t *testing.T) {
+	repo := &stubScheduleBlockRepo{}
+	h := NewScheduleHandler(service.NewScheduleService(existingChannel(), existingContent(), repo))
+
+	body := `{"title":"Mornings","days":["Mon","tue"],"start":"6:00","end":"09:00","contentIds":[3,1]}`
+	rec := httptest.NewRecorder()
+	newTestScheduleRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodPost, "/channels/2/blocks", bytes.NewBufferString(body)))
+
+	if rec.Code != nethttp.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", nethttp.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var got service.ScheduleBlock
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ID != 4 || got.ChannelID != 2 || got.Start != "06:00" || !slices.Equal(got.Days, []string{"mon", "tue"}) {
+		t.Fatalf("unexpected block %+v", got)
+	}
+	if !slices.Equal(got.ContentIDs, []uint{3, 1}) || got.FillerIDs == nil {
+		t.Fatalf("expected content ids and an empty filler list, got %+v", got)
+	}
+}
+
+func TestScheduleHandlerCreateRejectsInvalidBlock(t *testing.T) {
+	repo := &stubScheduleBlockRepo{}
+	h := NewScheduleHandler(service.NewScheduleService(existingChannel(), existingContent(), repo))
+
+	for _, body := range []string{
+		`{"start":"06:00","end":"09:00"}`,
+		`{"start":"6am","end":"09:00","contentIds":[1]}`,
+		`{"start":"06:00","end":"09:00","contentIds":[1],"unknown":true}`,
+	} {
+		rec := httptest.NewRecorder()
+		newTestScheduleRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodPost, "/channels/1/blocks", bytes.NewBufferString(body)))
+		if rec.Code != nethttp.StatusBadRequest {
+			t.Fatalf("%s: expected %d, got %d", body, nethttp.StatusBadRequest, rec.Code)
+		}
+	}
+	if repo.created != nil {
+		t.Fatal("expected nothing to be created")
+	}
+}
+
+func TestScheduleHandlerGetAndDelete(t *testing.T) {
+	repo := &stubScheduleBlockRepo{blocks: []service.ScheduleBlock{{ID: 5, ChannelID: 1, Start: "06:00", End: "09:00"}}}
+	router := newTestScheduleRouter(NewScheduleHandler(service.NewScheduleService(existingChannel(), existingContent(), repo)))
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{method: nethttp.MethodGet, path: "/channels/1/blocks/5", want: nethttp.StatusOK},
+		{method: nethttp.MethodGet, path: "/channels/1/blocks/6", want: nethttp.StatusNotFound},
+		{method: nethttp.MethodGet, path: "/channels/1/blocks/x", want: nethttp.StatusBadRequest},
+		{method: nethttp.MethodPut, path: "/channels/1/blocks/6", want: nethttp.StatusNotFound},
+		{method: nethttp.MethodDelete, path: "/channels/1/blocks/6", want: nethttp.StatusNotFound},
+		{method: nethttp.MethodDelete, path: "/channels/1/blocks/5", want: nethttp.StatusNoContent},
+	}
+	for _, tc := range tests {
+		body := bytes.NewBufferString(`{"start":"06:00","end":"09:00","contentIds":[1]}`)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, body))
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, rec.Code)
+		}
+	}
+	if repo.deleted != 5 {
+		t.Fatalf("expected block 5 to be deleted, got %d", repo.deleted)
+	}
+}
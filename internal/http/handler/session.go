@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	nethttp "net/http"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+// SessionHandler lists and stops live stream sessions.
+type SessionHandler struct {
+	sessions *service.SessionRegistry
+}
+
+func NewSessionHandler(sessions *service.SessionRegistry) *SessionHandler {
+	return &SessionHandler{sessions: sessions}
+}
+
+func (h *SessionHandler) List(w nethttp.ResponseWriter, _ *nethttp.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.sessions.List()); err != nil {
+		slog.Error("encode list sessions response", "error", err)
+	}
+}
+
+// Delete stops a session, disconnecting its viewer and freeing the tuner.
+func (h *SessionHandler) Delete(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.sessions.Stop(id); err != nil {
+		writeErr(w, err)
+		return
+	}
+	slog.Info("stopped stream session", "session_id", id)
+	w.WriteHeader(nethttp.StatusNoContent)
+}
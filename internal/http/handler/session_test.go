@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func newTestSessionRouter(h *SessionHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/sessions", h.List)
+	r.Delete("/sessions/{id}", h.Delete)
+	return r
+}
+
+func TestSessionHandlerListEncodesSessions(t *testing.T) {
+	sessions := service.NewSessionRegistry(2)
+	sess, _, err := sessions.Start(context.Background(), 4, "192.0.2.1:5000")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	sess.Sent(1316)
+	h := NewSessionHandler(sessions)
+
+	rec := httptest.NewRecorder()
+	newTestSessionRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/sessions", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	var got []service.Session
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != sess.ID || got[0].ChannelID != 4 || got[0].Client != "192.0.2.1:5000" ||
+		got[0].BytesSent != 1316 {
+		t.Fatalf("unexpected sessions %+v", got)
+	}
+}
+
+func TestSessionHandlerListEncodesEmptyArray(t *testing.T) {
+	h := NewSessionHandler(service.NewSessionRegistry(2))
+
+	rec := httptest.NewRecorder()
+	newTestSessionRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/sessions", nil))
+
+	if body := rec.Body.String(); body != "[]\n" {
+		t.Fatalf("expected empty array, got %q", body)
+	}
+}
+
+func TestSessionHandlerDelete(t *testing.T) {
+	sessions := service.NewSessionRegistry(2)
+	_, ctx, err := sessions.Start(context.Background(), 4, "192.0.2.1:5000")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	h := NewSessionHandler(sessions)
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "running session", path: "/sessions/1", want: nethttp.StatusNoContent},
+		{name: "already stopped", path: "/sessions/1", want: nethttp.StatusNotFound},
+		{name: "invalid id", path: "/sessions/x", want: nethttp.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		newTestSessionRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodDelete, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expected the stream to be cancelled")
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	nethttp "net/http"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type ShowHandler struct {
+	svc *service.ShowService
+}
+
+func NewShowHandler(svc *service.ShowService) *ShowHandler {
+	return &ShowHandler{svc: svc}
+}
+
+func (h *ShowHandler) List(w nethttp.ResponseWriter, r *nethttp.Request) {
+	shows, err := h.svc.List(r.Context())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if shows == nil {
+		shows = []service.Show{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(shows); err != nil {
+		slog.Error("encode list shows response", "error", err)
+	}
+}
+
+func (h *ShowHandler) Get(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	show, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(show); err != nil {
+		slog.Error("encode get show response", "error", err)
+	}
+}
+
+// Episodes lists the show's content in episode order.
+func (h *ShowHandler) Episodes(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	episodes, err := h.svc.Episodes(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	if episodes == nil {
+		episodes = []service.Content{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(episodes); err != nil {
+		slog.Error("encode list show episodes response", "error", err)
+	}
+}
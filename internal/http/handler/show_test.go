@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type stubShowRepo struct {
+	shows    []service.Show
+	episodes []service.Content
+}
+
+func (s *stubShowRepo) List(context.Context) ([]service.Show, error) {
+	return s.shows, nil
+}
+
+func (s *stubShowRepo) GetByID(_ context.Context, id uint) (*service.Show, error) {
+	for _, show := range s.shows {
+		if show.ID == id {
+			return &show, nil
+		}
+	}
+	return nil, service.ErrNotFound
+}
+
+func (s *stubShowRepo) Episodes(context.Context, uint) ([]service.Content, error) {
+	return s.episodes, nil
+}
+
+func newTestShowRouter(h *ShowHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/shows", h.List)
+	r.Get("/shows/{id}", h.Get)
+	r.Get("/shows/{id}/episodes", h.Episodes)
+	return r
+}
+
+func TestShowHandlerEpisodesReturnsContent(t *testing.T) {
+	repo := &stubShowRepo{
+		shows:    []service.Show{{ID: 2, Title: "Cheers", Episodes: 1}},
+		episodes: []service.Content{{ID: 7, Title: "Cheers S01E01", Episode: &service.Episode{ShowID: 2, Show: "Cheers", Season: 1, Episode: 1}}},
+	}
+	rec := httptest.NewRecorder()
+	newTestShowRouter(NewShowHandler(service.NewShowService(repo))).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/shows/2/episodes", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", nethttp.StatusOK, rec.Code, rec.Body.String())
+	}
+	var got []service.Content
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(got) != 1 || got[0].Episode == nil || got[0].Episode.Show != "Cheers" || got[0].Episode.Episode != 1 {
+		t.Fatalf("unexpected episodes %+v", got)
+	}
+}
+
+func TestShowHandlerRoutes(t *testing.T) {
+	router := newTestShowRouter(NewShowHandler(service.NewShowService(&stubShowRepo{shows: []service.Show{{ID: 2, Title: "Cheers"}}})))
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/shows", want: nethttp.StatusOK},
+		{path: "/shows/2", want: nethttp.StatusOK},
+		{path: "/shows/3", want: nethttp.StatusNotFound},
+		{path: "/shows/3/episodes", want: nethttp.StatusNotFound},
+		{path: "/shows/x", want: nethttp.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Fatalf("GET %s: expected %d, got %d", tc.path, tc.want, rec.Code)
+		}
+	}
+}
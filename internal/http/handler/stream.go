@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	nethttp "net/http"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/stream"
+)
+
+// StreamHandler serves channels as live MPEG-TS streams, each holding one of the tuners.
+type StreamHandler struct {
+	streamer *stream.Broadcaster
+	sessions *service.SessionRegistry
+}
+
+func NewStreamHandler(streamer *stream.Broadcaster, sessions *service.SessionRegistry) *StreamHandler {
+	return &StreamHandler{streamer: streamer, sessions: sessions}
+}
+
+// TS streams the channel from what is airing now until the client disconnects or its session is
+// stopped.
+func (h *StreamHandler) TS(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	sess, ctx, err := h.sessions.Start(r.Context(), id, r.RemoteAddr)
+	if err != nil {
+		slog.Warn("refuse stream", "channel_id", id, "client", r.RemoteAddr, "error", err)
+		writeErr(w, err)
+		return
+	}
+	defer sess.End()
+
+	// A live stream outlasts any server-wide write timeout.
+	rc := nethttp.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.Error("clear stream write deadline", "channel_id", id, "error", err)
+	}
+
+	out := &streamWriter{w: w, rc: rc, sess: sess}
+	err = h.streamer.Stream(ctx, id, out)
+	switch {
+	case err == nil:
+	case !out.started:
+		writeErr(w, err)
+	default:
+		slog.Error("stream channel", "channel_id", id, "error", err)
+	}
+}
+
+// streamWriter sends the response headers with the first bytes of the stream and flushes every
+// write, so errors raised before streaming starts can still become proper error responses.
+type streamWriter struct {
+	w       nethttp.ResponseWriter
+	rc      *nethttp.ResponseController
+	sess    *service.ActiveSession
+	started bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.started = true
+		sw.w.Header().Set("Content-Type", "video/mp2t")
+		sw.w.Header().Set("Cache-Control", "no-cache")
+	}
+	n, err := sw.w.Write(p)
+	sw.sess.Sent(n)
+	if err != nil {
+		return n, err
+	}
+	if err := sw.rc.Flush(); err != nil && !errors.Is(err, nethttp.ErrNotSupported) {
+		return n, err
+	}
+	return n, nil
+}
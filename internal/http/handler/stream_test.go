@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/stream"
+)
+
+func newTestStreamRouter(h *StreamHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/channels/{id}/stream.ts", h.TS)
+	return r
+}
+
+// writeTestTS writes a transport stream of three video packets, each opening a PES packet and
+// carrying a PCR two seconds after the previous one.
+func writeTestTS(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	for i := range 3 {
+		pcr := int64(i) * 2 * 90000
+		p := bytes.Repeat([]byte{0xFF}, 188)
+		copy(p, []byte{0x47, 0x41, 0x00, 0x30, 0x07, 0x10,
+			byte(pcr >> 25), byte(pcr >> 17), byte(pcr >> 9), byte(pcr >> 1), byte(pcr<<7) | 0x7E, 0x00})
+		copy(p[12:], []byte{0x00, 0x00, 0x01, 0xE0, 0x00, 0x00, 0x80, 0x00, 0x00})
+		buf.Write(p)
+	}
+	path := filepath.Join(t.TempDir(), "show.ts")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path
+}
+
+func TestStreamHandlerTSStreamsChannel(t *testing.T) {
+	channels := &stubChannelRepo{
+		getByID: func(_ context.Context, id uint) (*service.Channel, error) {
+			return &service.Channel{ID: id, Title: "News", ChannelNumber: 7}, nil
+		},
+	}
+	items := &stubChannelItemRepo{items: []service.ChannelItem{
+		{ID: 1, ContentID: 1, Content: &service.Content{ID: 1, Title: "Pilot", Path: writeTestTS(t), Length: 60}},
+	}}
+	// The non-looping channel started a moment ago, so the stream ends after its only programme.
+	timeline := service.NewTimelineService(channels, items, time.Now().Add(-time.Second))
+	sessions := service.NewSessionRegistry(1)
+	h := NewStreamHandler(stream.NewBroadcaster(stream.New(timeline)), sessions)
+
+	rec := httptest.NewRecorder()
+	newTestStreamRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/channels/4/stream.ts", nil))
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "video/mp2t" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	// Playback joins a second into the programme, after the first packet.
+	if rec.Body.Len() != 2*188 || rec.Body.Bytes()[0] != 0x47 {
+		t.Fatalf("expected two transport stream packets, got %d bytes", rec.Body.Len())
+	}
+	if got := sessions.List(); len(got) != 0 {
+		t.Fatalf("expected the session to end with the stream, got %+v", got)
+	}
+}
+
+func TestStreamHandlerTSRefusesWhenTunersAreBusy(t *testing.T) {
+	timeline := service.NewTimelineService(loopingChannel(), &stubChannelItemRepo{}, testEpoch)
+	sessions := service.NewSessionRegistry(1)
+	busy, _, err := sessions.Start(context.Background(), 9, "192.0.2.1:5000")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	h := NewStreamHandler(stream.NewBroadcaster(stream.New(timeline)), sessions)
+
+	rec := httptest.NewRecorder()
+	newTestStreamRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/channels/4/stream.ts", nil))
+
+	if rec.Code != nethttp.StatusServiceUnavailable {
+		t.Fatalf("expected %d, got %d", nethttp.StatusServiceUnavailable, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "all tuners are busy") {
+		t.Fatalf("unexpected body %q", rec.Body.String())
+	}
+
+	busy.End()
+	rec = httptest.NewRecorder()
+	newTestStreamRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/channels/4/stream.ts", nil))
+	if rec.Code == nethttp.StatusServiceUnavailable {
+		t.Fatal("expected the freed tuner to be available")
+	}
+}
+
+func TestStreamHandlerTSMapsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		channels *stubChannelRepo
+		wantCode int
+	}{
+		{name: "invalid id", path: "/channels/x/stream.ts", channels: loopingChannel(), wantCode: nethttp.StatusBadRequest},
+		{name: "unknown channel", path: "/channels/4/stream.ts", channels: &stubChannelRepo{}, wantCode: nethttp.StatusNotFound},
+		{name: "empty playlist", path: "/channels/4/stream.ts", channels: loopingChannel(), wantCode: nethttp.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			timeline := service.NewTimelineService(tc.channels, &stubChannelItemRepo{}, testEpoch)
+			h := NewStreamHandler(stream.NewBroadcaster(stream.New(timeline)), service.NewSessionRegistry(1))
+
+			rec := httptest.NewRecorder()
+			newTestStreamRouter(h).ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, tc.path, nil))
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d", tc.wantCode, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct == "video/mp2t" {
+				t.Fatal("error response sent with stream content type")
+			}
+		})
+	}
+}
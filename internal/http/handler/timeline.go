@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	nethttp "net/http"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type TimelineHandler struct {
+	svc *service.TimelineService
+}
+
+func NewTimelineHandler(svc *service.TimelineService) *TimelineHandler {
+	return &TimelineHandler{svc: svc}
+}
+
+func (h *TimelineHandler) Now(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+
+	a, err := h.svc.Now(r.Context(), id)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a); err != nil {
+		slog.Error("encode channel now response", "error", err)
+	}
+}
+
+// At reports what the channel airs at the RFC 3339 time given in the t query parameter.
+func (h *TimelineHandler) At(w nethttp.ResponseWriter, r *nethttp.Request) {
+	id, ok := parseID(w, r)
+	if !ok {
+		return
+	}
+	t, err := time.Parse(time.RFC3339, r.URL.Query().Get("t"))
+	if err != nil {
+		nethttp.Error(w, "invalid t", nethttp.StatusBadRequest)
+		return
+	}
+
+	a, err := h.svc.At(r.Context(), id, t)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a); err != nil {
+		slog.Error("encode channel at response", "error", err)
+	}
+}
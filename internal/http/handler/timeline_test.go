@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+var testEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestTimelineRouter(h *TimelineHandler) nethttp.Handler {
+	r := chi.NewRouter()
+	r.Get("/channels/{id}/now", h.Now)
+	r.Get("/channels/{id}/at", h.At)
+	return r
+}
+
+func loopingChannel() *stubChannelRepo {
+	return &stubChannelRepo{
+		getByID: func(_ context.Context, id uint) (*service.Channel, error) {
+			return &service.Channel{ID: id, Title: "News", ChannelNumber: 7, Loop: true}, nil
+		},
+	}
+}
+
+func TestTimelineHandlerAtReturnsAiring(t *testing.T) {
+	items := &stubChannelItemRepo{items: []service.ChannelItem{
+		{ID: 1, ContentID: 1, Content: &service.Content{ID: 1, Title: "Pilot", Length: 60}},
+		{ID: 2, ContentID: 2, Content: &service.Content{ID: 2, Title: "Finale", Length: 60}},
+	}}
+	h := NewTimelineHandler(service.NewTimelineService(loopingChannel(), items, testEpoch))
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/channels/4/at?t=2024-01-01T00:01:30Z", nil)
+	rec := httptest.NewRecorder()
+	newTestTimelineRouter(h).ServeHTTP(rec, req)
+
+	if rec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, rec.Code)
+	}
+	var got service.Airing
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ChannelID != 4 || got.Item.Content.Title != "Finale" || got.Offset != 30 {
+		t.Fatalf("unexpected airing: %+v", got)
+	}
+}
+
+func TestTimelineHandlerAtRejectsInvalidTime(t *testing.T) {
+	h := NewTimelineHandler(service.NewTimelineService(loopingChannel(), &stubChannelItemRepo{}, testEpoch))
+
+	for _, path := range []string{"/channels/4/at", "/channels/4/at?t=tomorrow", "/channels/x/at?t=2024-01-01T00:00:00Z"} {
+		req := httptest.NewRequest(nethttp.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		newTestTimelineRouter(h).ServeHTTP(rec, req)
+
+		if rec.Code != nethttp.StatusBadRequest {
+			t.Fatalf("%s: expected %d, got %d", path, nethttp.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestTimelineHandlerNowMapsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels *stubChannelRepo
+		wantBody string
+	}{
+		{name: "unknown channel", channels: &stubChannelRepo{}, wantBody: "not found\n"},
+		{name: "empty playlist", channels: loopingChannel(), wantBody: "nothing scheduled\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewTimelineHandler(service.NewTimelineService(tc.channels, &stubChannelItemRepo{}, testEpoch))
+
+			req := httptest.NewRequest(nethttp.MethodGet, "/channels/4/now", nil)
+			rec := httptest.NewRecorder()
+			newTestTimelineRouter(h).ServeHTTP(rec, req)
+
+			if rec.Code != nethttp.StatusNotFound {
+				t.Fatalf("expected %d, got %d", nethttp.StatusNotFound, rec.Code)
+			}
+			if rec.Body.String() != tc.wantBody {
+				t.Fatalf("expected body %q, got %q", tc.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
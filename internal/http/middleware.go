@@ -31,6 +31,12 @@ func (r *statusRecorder) Write(b []byte) (int, error) {
 	return n, nil
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, so handlers behind the logger
+// can still flush and clear their write deadlines.
+func (r *statusRecorder) Unwrap() nethttp.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func requestLogger(next nethttp.Handler) nethttp.Handler {
 	return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
 		start := time.Now()
@@ -6,14 +6,30 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/iamseth/tiny-headend/internal/epg"
 	"github.com/iamseth/tiny-headend/internal/http/handler"
 	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/stream"
 )
 
 // Deps holds the dependencies for the server.
 type Deps struct {
 	Content     *service.ContentService
+	Channel     *service.ChannelService
+	Playlist    *service.PlaylistService
+	Schedule    *service.ScheduleService
+	Filler      *service.FillerService
+	Collections *service.CollectionService
+	Shows       *service.ShowService
+	Logos       *service.LogoService
+	Timeline    *service.TimelineService
+	EPG         *epg.Generator
+	Stream      *stream.Broadcaster
+	HLS         *stream.Segmenter
+	Sessions    *service.SessionRegistry
 	HealthCheck func(ctx context.Context) error
+	// HealthDetails, when set, adds the state of background components to /healthz.
+	HealthDetails func() map[string]any
 }
 
 // Config holds the configuration for the server.
@@ -24,6 +40,15 @@ type Config struct {
 	WriteTimeout      time.Duration
 	IdleTimeout       time.Duration
 	MaxHeaderBytes    int
+	// BaseURL is the externally reachable URL of the server. When empty, URLs handed to clients
+	// are built from the request's host.
+	BaseURL string
+	// Device identifies the emulated HDHomeRun tuner.
+	Device handler.Device
+	// LibraryRoots are the directories content files may be served from.
+	LibraryRoots []string
+	// HLSSessionIdle is how long an HLS client keeps its tuner after its last request.
+	HLSSessionIdle time.Duration
 }
 
 func New(cfg Config, deps Deps) *nethttp.Server {
@@ -31,13 +56,80 @@ func New(cfg Config, deps Deps) *nethttp.Server {
 	router.Use(requestLogger, recoverPanic)
 
 	contentH := handler.NewContentHandler(deps.Content)
-	healthH := handler.NewHealthHandler(deps.HealthCheck)
+	fileH := handler.NewFileHandler(deps.Content, cfg.LibraryRoots)
+	channelH := handler.NewChannelHandler(deps.Channel)
+	playlistH := handler.NewPlaylistHandler(deps.Playlist)
+	scheduleH := handler.NewScheduleHandler(deps.Schedule)
+	fillerH := handler.NewFillerHandler(deps.Filler)
+	collectionH := handler.NewCollectionHandler(deps.Collections)
+	showH := handler.NewShowHandler(deps.Shows)
+	logoH := handler.NewLogoHandler(deps.Logos)
+	timelineH := handler.NewTimelineHandler(deps.Timeline)
+	epgH := handler.NewEPGHandler(deps.EPG, cfg.BaseURL)
+	lineupH := handler.NewLineupHandler(deps.Channel, cfg.BaseURL)
+	hdhrH := handler.NewHDHomeRunHandler(deps.Channel, cfg.Device, cfg.BaseURL)
+	streamH := handler.NewStreamHandler(deps.Stream, deps.Sessions)
+	sessionH := handler.NewSessionHandler(deps.Sessions)
+	hlsH := handler.NewHLSHandler(deps.HLS, deps.Channel, deps.Sessions, cfg.HLSSessionIdle)
+	healthH := handler.NewHealthHandler(deps.HealthCheck, deps.HealthDetails)
 	router.Get("/healthz", healthH.Get)
+	router.Get("/epg.xml", epgH.Get)
+	router.Get("/lineup.m3u", lineupH.M3U)
+	router.Get("/lineup.m3u8", lineupH.M3U)
+	router.Get("/discover.json", hdhrH.Discover)
+	router.Get("/device.xml", hdhrH.DeviceXML)
+	router.Get("/lineup.json", hdhrH.Lineup)
+	router.Get("/lineup_status.json", hdhrH.LineupStatus)
+	router.Post("/lineup.post", hdhrH.LineupPost)
 	router.Post("/content", contentH.Create)
 	router.Get("/content", contentH.List)
 	router.Get("/content/{id}", contentH.Get)
+	router.Get("/content/{id}/file", fileH.Get)
+	router.Get("/content/{id}/poster", fileH.Poster)
 	router.Put("/content/{id}", contentH.Update)
 	router.Delete("/content/{id}", contentH.Delete)
+	router.Put("/content/{id}/tags", collectionH.SetTags)
+	router.Post("/collections", collectionH.Create)
+	router.Get("/collections", collectionH.List)
+	router.Get("/collections/{id}", collectionH.Get)
+	router.Get("/collections/{id}/content", collectionH.Content)
+	router.Put("/collections/{id}", collectionH.Update)
+	router.Delete("/collections/{id}", collectionH.Delete)
+	router.Get("/shows", showH.List)
+	router.Get("/shows/{id}", showH.Get)
+	router.Get("/shows/{id}/episodes", showH.Episodes)
+	router.Post("/channels", channelH.Create)
+	router.Get("/channels", channelH.List)
+	router.Get("/channels/{id}", channelH.Get)
+	router.Put("/channels/{id}", channelH.Update)
+	router.Delete("/channels/{id}", channelH.Delete)
+	router.Get("/channels/{id}/logo", logoH.Get)
+	router.Put("/channels/{id}/logo", logoH.Put)
+	router.Delete("/channels/{id}/logo", logoH.Delete)
+	router.Get("/channels/{id}/items", playlistH.List)
+	router.Post("/channels/{id}/items", playlistH.Add)
+	router.Put("/channels/{id}/items/order", playlistH.Reorder)
+	router.Delete("/channels/{id}/items/{itemId}", playlistH.Remove)
+	router.Get("/channels/{id}/blocks", scheduleH.List)
+	router.Post("/channels/{id}/blocks", scheduleH.Create)
+	router.Get("/channels/{id}/blocks/{blockId}", scheduleH.Get)
+	router.Put("/channels/{id}/blocks/{blockId}", scheduleH.Update)
+	router.Delete("/channels/{id}/blocks/{blockId}", scheduleH.Delete)
+	router.Get("/channels/{id}/filler", fillerH.List)
+	router.Post("/channels/{id}/filler", fillerH.Create)
+	router.Get("/channels/{id}/filler/{poolId}", fillerH.Get)
+	router.Put("/channels/{id}/filler/{poolId}", fillerH.Update)
+	router.Delete("/channels/{id}/filler/{poolId}", fillerH.Delete)
+	router.Get("/channels/{id}/now", timelineH.Now)
+	router.Get("/channels/{id}/at", timelineH.At)
+	router.Get("/channels/{id}/stream.ts", streamH.TS)
+	router.Get("/channels/{id}/master.m3u8", hlsH.Master)
+	router.Get("/channels/{id}/index.m3u8", hlsH.Playlist)
+	router.Get("/channels/{id}/segments/{seq}.ts", hlsH.Segment)
+	router.Get("/channels/{id}/subtitles/{lang}.m3u8", hlsH.SubtitlePlaylist)
+	router.Get("/channels/{id}/subtitles/{lang}/{seq}.vtt", hlsH.SubtitleSegment)
+	router.Get("/sessions", sessionH.List)
+	router.Delete("/sessions/{id}", sessionH.Delete)
 
 	return &nethttp.Server{
 		Addr:              cfg.Addr,
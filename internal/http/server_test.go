@@ -3,15 +3,23 @@ package http
 import (
 	"bytes"
 	"context"
+	"io"
 	nethttp "net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/iamseth/tiny-headend/internal/epg"
 	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/stream"
 )
 
-const validContentJSON = `{"title":"t","path":"/tmp/f.ts","size":1,"length":1}`
+const (
+	validContentJSON = `{"title":"t","path":"/tmp/f.ts","size":1,"length":1}`
+	validChannelJSON = `{"title":"News","channelNumber":7,"description":"local news"}`
+)
 
 type serverStubContentRepo struct{}
 
@@ -36,6 +44,151 @@ func (serverStubContentRepo) Delete(context.Context, uint) error {
 	return nil
 }
 
+type serverStubChannelRepo struct{}
+
+func (serverStubChannelRepo) Create(_ context.Context, c *service.Channel) error {
+	c.ID = 1
+	return nil
+}
+
+func (serverStubChannelRepo) GetByID(context.Context, uint) (*service.Channel, error) {
+	return nil, service.ErrNotFound
+}
+
+func (serverStubChannelRepo) List(context.Context, int, int) ([]service.Channel, error) {
+	return nil, nil
+}
+
+func (serverStubChannelRepo) Update(context.Context, *service.Channel) error {
+	return nil
+}
+
+func (serverStubChannelRepo) Delete(context.Context, uint) error {
+	return nil
+}
+
+type serverStubChannelItemRepo struct{}
+
+func (serverStubChannelItemRepo) ListByChannel(context.Context, uint) ([]service.ChannelItem, error) {
+	return nil, nil
+}
+
+func (serverStubChannelItemRepo) Append(context.Context, *service.ChannelItem) error {
+	return nil
+}
+
+func (serverStubChannelItemRepo) Delete(context.Context, uint, uint) error {
+	return service.ErrNotFound
+}
+
+func (serverStubChannelItemRepo) Reorder(context.Context, uint, []uint) error {
+	return nil
+}
+
+type serverStubScheduleBlockRepo struct{}
+
+func (serverStubScheduleBlockRepo) ListByChannel(context.Context, uint) ([]service.ScheduleBlock, error) {
+	return nil, nil
+}
+
+func (serverStubScheduleBlockRepo) GetByID(context.Context, uint, uint) (*service.ScheduleBlock, error) {
+	return nil, service.ErrNotFound
+}
+
+func (serverStubScheduleBlockRepo) Create(context.Context, *service.ScheduleBlock) error {
+	return nil
+}
+
+func (serverStubScheduleBlockRepo) Update(context.Context, *service.ScheduleBlock) error {
+	return nil
+}
+
+func (serverStubScheduleBlockRepo) Delete(context.Context, uint, uint) error {
+	return service.ErrNotFound
+}
+
+type serverStubFillerPoolRepo struct{}
+
+func (serverStubFillerPoolRepo) ListByChannel(context.Context, uint) ([]service.FillerPool, error) {
+	return nil, nil
+}
+
+func (serverStubFillerPoolRepo) GetByID(context.Context, uint, uint) (*service.FillerPool, error) {
+	return nil, service.ErrNotFound
+}
+
+func (serverStubFillerPoolRepo) Create(context.Context, *service.FillerPool) error {
+	return nil
+}
+
+func (serverStubFillerPoolRepo) Update(context.Context, *service.FillerPool) error {
+	return nil
+}
+
+func (serverStubFillerPoolRepo) Delete(context.Context, uint, uint) error {
+	return service.ErrNotFound
+}
+
+type serverStubCollectionRepo struct{}
+
+func (serverStubCollectionRepo) List(context.Context) ([]service.Collection, error) {
+	return nil, nil
+}
+
+func (serverStubCollectionRepo) GetByID(context.Context, uint) (*service.Collection, error) {
+	return nil, service.ErrNotFound
+}
+
+func (serverStubCollectionRepo) Create(context.Context, *service.Collection) error {
+	return nil
+}
+
+func (serverStubCollectionRepo) Update(context.Context, *service.Collection) error {
+	return nil
+}
+
+func (serverStubCollectionRepo) Delete(context.Context, uint) error {
+	return service.ErrNotFound
+}
+
+func (serverStubCollectionRepo) Members(context.Context, *service.Collection) ([]service.Content, error) {
+	return nil, nil
+}
+
+type serverStubTagRepo struct{}
+
+func (serverStubTagRepo) SetTags(context.Context, uint, []string) error {
+	return service.ErrNotFound
+}
+
+type serverStubLogoRepo struct{}
+
+func (serverStubLogoRepo) SetLogo(context.Context, uint, *service.Image) error {
+	return service.ErrNotFound
+}
+
+func (serverStubLogoRepo) GetLogo(context.Context, uint) (*service.Image, error) {
+	return nil, service.ErrNotFound
+}
+
+func (serverStubLogoRepo) DeleteLogo(context.Context, uint) error {
+	return service.ErrNotFound
+}
+
+type serverStubShowRepo struct{}
+
+func (serverStubShowRepo) List(context.Context) ([]service.Show, error) {
+	return nil, nil
+}
+
+func (serverStubShowRepo) GetByID(context.Context, uint) (*service.Show, error) {
+	return nil, service.ErrNotFound
+}
+
+func (serverStubShowRepo) Episodes(context.Context, uint) ([]service.Content, error) {
+	return nil, nil
+}
+
 func TestNewConfiguresServerAndRoutes(t *testing.T) {
 	cfg := Config{
 		Addr:              ":1234",
@@ -46,8 +199,30 @@ func TestNewConfiguresServerAndRoutes(t *testing.T) {
 		MaxHeaderBytes:    1024,
 	}
 
+	channelSvc := service.NewChannelService(serverStubChannelRepo{})
+	timelineSvc := service.NewTimelineService(serverStubChannelRepo{}, serverStubChannelItemRepo{}, time.Unix(0, 0))
 	srv := New(cfg, Deps{
-		Content:     service.NewContentService(serverStubContentRepo{}),
+		Content: service.NewContentService(serverStubContentRepo{}),
+		Channel: channelSvc,
+		Playlist: service.NewPlaylistService(
+			serverStubChannelRepo{}, serverStubContentRepo{}, serverStubChannelItemRepo{},
+		),
+		Schedule: service.NewScheduleService(
+			serverStubChannelRepo{}, serverStubContentRepo{}, serverStubScheduleBlockRepo{},
+		),
+		Filler: service.NewFillerService(
+			serverStubChannelRepo{}, serverStubContentRepo{}, serverStubFillerPoolRepo{},
+		),
+		Collections: service.NewCollectionService(
+			serverStubContentRepo{}, serverStubCollectionRepo{}, serverStubTagRepo{},
+		),
+		Shows:       service.NewShowService(serverStubShowRepo{}),
+		Logos:       service.NewLogoService(serverStubLogoRepo{}),
+		Timeline:    timelineSvc,
+		EPG:         epg.New(channelSvc, timelineSvc, time.Hour),
+		Stream:      stream.NewBroadcaster(stream.New(timelineSvc)),
+		HLS:         stream.NewSegmenter(timelineSvc, 6*time.Second, 3),
+		Sessions:    service.NewSessionRegistry(2),
 		HealthCheck: func(context.Context) error { return nil },
 	})
 
@@ -88,4 +263,221 @@ func TestNewConfiguresServerAndRoutes(t *testing.T) {
 	if createRec.Code != nethttp.StatusCreated {
 		t.Fatalf("expected %d, got %d", nethttp.StatusCreated, createRec.Code)
 	}
+
+	channelReq := httptest.NewRequest(
+		nethttp.MethodPost,
+		"/channels",
+		bytes.NewBufferString(validChannelJSON),
+	)
+	channelRec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(channelRec, channelReq)
+	if channelRec.Code != nethttp.StatusCreated {
+		t.Fatalf("expected %d, got %d", nethttp.StatusCreated, channelRec.Code)
+	}
+
+	missingReq := httptest.NewRequest(nethttp.MethodGet, "/channels/1", nil)
+	missingRec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(missingRec, missingReq)
+	if missingRec.Code != nethttp.StatusNotFound {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotFound, missingRec.Code)
+	}
+
+	itemsReq := httptest.NewRequest(nethttp.MethodGet, "/channels/1/items", nil)
+	itemsRec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(itemsRec, itemsReq)
+	if itemsRec.Code != nethttp.StatusNotFound {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotFound, itemsRec.Code)
+	}
+
+	for _, req := range []*nethttp.Request{
+		httptest.NewRequest(nethttp.MethodGet, "/channels/1/blocks", nil),
+		httptest.NewRequest(nethttp.MethodGet, "/channels/1/logo", nil),
+		httptest.NewRequest(nethttp.MethodDelete, "/channels/1/logo", nil),
+		httptest.NewRequest(nethttp.MethodGet, "/channels/1/blocks/2", nil),
+		httptest.NewRequest(nethttp.MethodDelete, "/channels/1/blocks/2", nil),
+		httptest.NewRequest(nethttp.MethodGet, "/channels/1/filler", nil),
+		httptest.NewRequest(nethttp.MethodGet, "/channels/1/filler/2", nil),
+		httptest.NewRequest(nethttp.MethodDelete, "/channels/1/filler/2", nil),
+		httptest.NewRequest(nethttp.MethodGet, "/collections/2", nil),
+		httptest.NewRequest(nethttp.MethodGet, "/collections/2/content", nil),
+		httptest.NewRequest(nethttp.MethodDelete, "/collections/2", nil),
+		httptest.NewRequest(nethttp.MethodPut, "/content/2/tags", bytes.NewBufferString(`{"tags":["kids"]}`)),
+		httptest.NewRequest(nethttp.MethodGet, "/shows/2", nil),
+		httptest.NewRequest(nethttp.MethodGet, "/shows/2/episodes", nil),
+	} {
+		blocksRec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(blocksRec, req)
+		if blocksRec.Code != nethttp.StatusNotFound {
+			t.Fatalf("%s %s: expected %d, got %d", req.Method, req.URL.Path, nethttp.StatusNotFound, blocksRec.Code)
+		}
+	}
+
+	epgReq := httptest.NewRequest(nethttp.MethodGet, "/epg.xml", nil)
+	epgRec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(epgRec, epgReq)
+	if epgRec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, epgRec.Code)
+	}
+
+	for _, path := range []string{"/lineup.m3u", "/lineup.m3u8", "/discover.json", "/device.xml", "/lineup.json", "/lineup_status.json"} {
+		lineupRec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(lineupRec, httptest.NewRequest(nethttp.MethodGet, path, nil))
+		if lineupRec.Code != nethttp.StatusOK {
+			t.Fatalf("%s: expected %d, got %d", path, nethttp.StatusOK, lineupRec.Code)
+		}
+	}
+
+	scanRec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(scanRec, httptest.NewRequest(nethttp.MethodPost, "/lineup.post?scan=start", nil))
+	if scanRec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, scanRec.Code)
+	}
+
+	nowReq := httptest.NewRequest(nethttp.MethodGet, "/channels/1/now", nil)
+	nowRec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(nowRec, nowReq)
+	if nowRec.Code != nethttp.StatusNotFound {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotFound, nowRec.Code)
+	}
+
+	for _, path := range []string{"/channels/1/stream.ts", "/channels/1/index.m3u8", "/channels/1/segments/5.ts", "/content/1/file"} {
+		streamRec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(streamRec, httptest.NewRequest(nethttp.MethodGet, path, nil))
+		if streamRec.Code != nethttp.StatusNotFound {
+			t.Fatalf("%s: expected %d, got %d", path, nethttp.StatusNotFound, streamRec.Code)
+		}
+	}
+
+	sessionsRec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(sessionsRec, httptest.NewRequest(nethttp.MethodGet, "/sessions", nil))
+	if sessionsRec.Code != nethttp.StatusOK {
+		t.Fatalf("expected %d, got %d", nethttp.StatusOK, sessionsRec.Code)
+	}
+	stopRec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(stopRec, httptest.NewRequest(nethttp.MethodDelete, "/sessions/1", nil))
+	if stopRec.Code != nethttp.StatusNotFound {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotFound, stopRec.Code)
+	}
+
+	removeReq := httptest.NewRequest(nethttp.MethodDelete, "/channels/1/items/2", nil)
+	removeRec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(removeRec, removeReq)
+	if removeRec.Code != nethttp.StatusNotFound {
+		t.Fatalf("expected %d, got %d", nethttp.StatusNotFound, removeRec.Code)
+	}
+}
+
+type serverLiveChannelRepo struct {
+	serverStubChannelRepo
+}
+
+func (serverLiveChannelRepo) GetByID(_ context.Context, id uint) (*service.Channel, error) {
+	return &service.Channel{ID: id, Title: "News", ChannelNumber: 7}, nil
+}
+
+type serverLiveChannelItemRepo struct {
+	serverStubChannelItemRepo
+	items []service.ChannelItem
+}
+
+func (r serverLiveChannelItemRepo) ListByChannel(context.Context, uint) ([]service.ChannelItem, error) {
+	return r.items, nil
+}
+
+// newTestServer serves the server New builds over loopback.
+func newTestServer(t *testing.T, cfg Config, deps Deps) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = New(cfg, deps)
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// writeSpacedTS writes a transport stream of three video packets, each opening a PES packet and
+// carrying a PCR 2.5 seconds after the previous one.
+func writeSpacedTS(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	for i := range 3 {
+		pcr := int64(i) * 5 * 90000 / 2
+		p := bytes.Repeat([]byte{0xFF}, 188)
+		copy(p, []byte{0x47, 0x41, 0x00, 0x30, 0x07, 0x10,
+			byte(pcr >> 25), byte(pcr >> 17), byte(pcr >> 9), byte(pcr >> 1), byte(pcr<<7) | 0x7E, 0x00})
+		copy(p[12:], []byte{0x00, 0x00, 0x01, 0xE0, 0x00, 0x00, 0x80, 0x00, 0x00})
+		buf.Write(p)
+	}
+	path := filepath.Join(t.TempDir(), "show.ts")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path
+}
+
+func TestStreamOutlastsWriteTimeout(t *testing.T) {
+	items := serverLiveChannelItemRepo{items: []service.ChannelItem{
+		{ID: 1, ContentID: 1, Content: &service.Content{ID: 1, Title: "Pilot", Path: writeSpacedTS(t), Length: 7.5}},
+	}}
+	// Joining a second in, the stream holds its last packet back for half a second.
+	timeline := service.NewTimelineService(serverLiveChannelRepo{}, items, time.Now().Add(-time.Second))
+	ts := newTestServer(t, Config{WriteTimeout: 100 * time.Millisecond}, Deps{
+		Channel:  service.NewChannelService(serverLiveChannelRepo{}),
+		Timeline: timeline,
+		Stream:   stream.NewBroadcaster(stream.New(timeline)),
+		Sessions: service.NewSessionRegistry(1),
+	})
+
+	resp, err := ts.Client().Get(ts.URL + "/channels/4/stream.ts")
+	if err != nil {
+		t.Fatalf("get stream: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	if resp.StatusCode != nethttp.StatusOK || len(body) != 2*188 {
+		t.Fatalf("expected two packets with status %d, got %d bytes with status %d", nethttp.StatusOK, len(body), resp.StatusCode)
+	}
+}
+
+type serverFileContentRepo struct {
+	serverStubContentRepo
+	path string
+}
+
+func (r serverFileContentRepo) GetByID(_ context.Context, id uint) (*service.Content, error) {
+	return &service.Content{ID: id, Title: "Film", Path: r.path}, nil
+}
+
+func TestFileDownloadOutlastsWriteTimeout(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "film.ts")
+	// Larger than the socket buffers, so the server is still writing when the client reads.
+	data := bytes.Repeat([]byte{0x47}, 16<<20)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	ts := newTestServer(t, Config{WriteTimeout: 100 * time.Millisecond, LibraryRoots: []string{root}}, Deps{
+		Content: service.NewContentService(serverFileContentRepo{path: path}),
+	})
+
+	resp, err := ts.Client().Get(ts.URL + "/content/1/file")
+	if err != nil {
+		t.Fatalf("get file: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	// A slow client starts reading after the write timeout has passed.
+	time.Sleep(300 * time.Millisecond)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if len(body) != len(data) {
+		t.Fatalf("expected %d bytes, got %d", len(data), len(body))
+	}
 }
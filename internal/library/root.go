@@ -0,0 +1,17 @@
+// Package library holds the path rules shared by the scanner, which walks the media library, and
+// the handlers that serve files from it.
+package library
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// WithinRoot reports whether path is root itself or lives underneath it.
+func WithinRoot(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
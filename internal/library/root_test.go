@@ -0,0 +1,23 @@
+package library
+
+import "testing"
+
+func TestWithinRoot(t *testing.T) {
+	tests := []struct {
+		root string
+		path string
+		want bool
+	}{
+		{root: "/media", path: "/media", want: true},
+		{root: "/media", path: "/media/a/b.mp4", want: true},
+		{root: "/media", path: "/media2/b.mp4", want: false},
+		{root: "/media", path: "/media/../etc/passwd", want: false},
+		{root: "/media", path: "/", want: false},
+	}
+
+	for _, tc := range tests {
+		if got := WithinRoot(tc.root, tc.path); got != tc.want {
+			t.Fatalf("WithinRoot(%q, %q) = %v, want %v", tc.root, tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package multicast pushes channels' live streams to UDP multicast groups, so set-top boxes and
+// players on the LAN can tune in without HTTP.
+package multicast
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// datagramSize carries seven transport stream packets per datagram, the usual payload for
+	// MPEG-TS over UDP that fits a 1500 byte MTU.
+	datagramSize = 7 * 188
+	// retryInterval is how long an output waits before restarting after its stream failed.
+	retryInterval = 10 * time.Second
+)
+
+// States an output reports in its Status.
+const (
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateFailed   = "failed"
+	StateStopped  = "stopped"
+)
+
+// Source streams a channel's continuous MPEG-TS, like stream.Broadcaster.
+type Source interface {
+	Stream(ctx context.Context, channelID uint, w io.Writer) error
+}
+
+// Output sends one channel to a multicast group.
+type Output struct {
+	ChannelID uint
+	// Group is the multicast address and port, such as 239.0.0.1:1234.
+	Group string
+	TTL   int
+	// Interface names the network interface to send from. Empty leaves the choice to the system.
+	Interface string
+}
+
+// Status reports what an output is doing.
+type Status struct {
+	ChannelID uint      `json:"channelId"`
+	Group     string    `json:"group"`
+	Interface string    `json:"interface,omitempty"`
+	State     string    `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	Since     time.Time `json:"since,omitzero"`
+	BytesSent int64     `json:"bytesSent"`
+}
+
+// Sender runs a set of multicast outputs.
+type Sender struct {
+	source  Source
+	outputs []Output
+	// dial opens the socket an output sends its datagrams on.
+	dial  func(Output) (net.Conn, error)
+	retry time.Duration
+	now   func() time.Time
+
+	mu       sync.Mutex
+	statuses []Status
+}
+
+func New(source Source, outputs []Output) *Sender {
+	statuses := make([]Status, len(outputs))
+	for i, o := range outputs {
+		statuses[i] = Status{ChannelID: o.ChannelID, Group: o.Group, Interface: o.Interface, State: StateStopped}
+	}
+	return &Sender{
+		source:   source,
+		outputs:  outputs,
+		dial:     dial,
+		retry:    retryInterval,
+		now:      time.Now,
+		statuses: statuses,
+	}
+}
+
+// Run sends every output until ctx is done, restarting outputs whose stream fails.
+func (s *Sender) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := range s.outputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run(ctx, i)
+		}()
+	}
+	wg.Wait()
+}
+
+// Status returns the state of every output, ordered by channel and group.
+func (s *Sender) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := slices.Clone(s.statuses)
+	slices.SortFunc(out, func(a, b Status) int {
+		return cmp.Or(cmp.Compare(a.ChannelID, b.ChannelID), cmp.Compare(a.Group, b.Group))
+	})
+	return out
+}
+
+func (s *Sender) run(ctx context.Context, i int) {
+	o := s.outputs[i]
+	for {
+		s.setState(i, StateStarting, nil)
+		err := s.send(ctx, i)
+		if ctx.Err() != nil {
+			s.setState(i, StateStopped, nil)
+			return
+		}
+		if err == nil {
+			err = errors.New("stream ended")
+		}
+		slog.Error("multicast output failed", "channel_id", o.ChannelID, "group", o.Group, "error", err,
+			"retry_in", s.retry)
+		s.setState(i, StateFailed, err)
+
+		select {
+		case <-ctx.Done():
+			s.setState(i, StateStopped, nil)
+			return
+		case <-time.After(s.retry):
+		}
+	}
+}
+
+// send streams output i to its group until ctx is done or the stream fails.
+func (s *Sender) send(ctx context.Context, i int) error {
+	o := s.outputs[i]
+	conn, err := s.dial(o)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	slog.Info("multicast output started", "channel_id", o.ChannelID, "group", o.Group)
+	s.setState(i, StateRunning, nil)
+	return s.source.Stream(ctx, o.ChannelID, &datagramWriter{conn: conn, sent: func(n int) { s.addSent(i, n) }})
+}
+
+func (s *Sender) setState(i int, state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := &s.statuses[i]
+	st.State, st.Error, st.Since = state, "", s.now()
+	if err != nil {
+		st.Error = err.Error()
+	}
+}
+
+func (s *Sender) addSent(i, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[i].BytesSent += int64(n)
+}
+
+// datagramWriter splits the stream into datagrams of up to datagramSize bytes. The stream is
+// written in whole packets, so datagrams never split one.
+type datagramWriter struct {
+	conn net.Conn
+	sent func(n int)
+}
+
+func (w *datagramWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), datagramSize)
+		if _, err := w.conn.Write(p[:n]); err != nil {
+			return written, fmt.Errorf("send datagram: %w", err)
+		}
+		w.sent(n)
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// dial opens a UDP socket sending to o's group with its TTL and interface.
+func dial(o Output) (net.Conn, error) {
+	group, err := net.ResolveUDPAddr("udp4", o.Group)
+	if err != nil {
+		return nil, fmt.Errorf("resolve group %s: %w", o.Group, err)
+	}
+	var ifaceIP net.IP
+	if o.Interface != "" {
+		if ifaceIP, err = interfaceIPv4(o.Interface); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := net.DialUDP("udp4", nil, group)
+	if err != nil {
+		return nil, fmt.Errorf("dial group %s: %w", o.Group, err)
+	}
+	if err := setMulticastOptions(conn, o.TTL, ifaceIP); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("configure multicast socket for %s: %w", o.Group, err)
+	}
+	return conn, nil
+}
+
+// interfaceIPv4 returns the first IPv4 address of the named interface.
+func interfaceIPv4(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("interface %s addresses: %w", name, err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.To4(), nil
+		}
+	}
+	return nil, fmt.Errorf("interface %s has no IPv4 address", name)
+}
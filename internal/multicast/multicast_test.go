@@ -0,0 +1,167 @@
+package multicast
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource writes packets to every viewer, then waits for ctx or fails with err.
+type fakeSource struct {
+	packets int
+	err     error
+
+	mu    sync.Mutex
+	calls []uint
+}
+
+func (f *fakeSource) Stream(ctx context.Context, channelID uint, w io.Writer) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, channelID)
+	f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	if _, err := w.Write(make([]byte, f.packets*188)); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeSource) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+// listen returns a local UDP socket standing in for the multicast group.
+func listen(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readDatagram(t *testing.T, conn *net.UDPConn) int {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("set deadline: %v", err)
+	}
+	buf := make([]byte, 2048)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return n
+}
+
+func waitForState(t *testing.T, s *Sender, state string) Status {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		st := s.Status()[0]
+		if st.State == state {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected state %s, got %+v", state, st)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSenderSendsDatagramsOfWholePackets(t *testing.T) {
+	group := listen(t)
+	source := &fakeSource{packets: 10}
+	s := New(source, []Output{{ChannelID: 3, Group: group.LocalAddr().String(), TTL: 1}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+
+	if n := readDatagram(t, group); n != 7*188 {
+		t.Fatalf("expected a datagram of 7 packets, got %d bytes", n)
+	}
+	if n := readDatagram(t, group); n != 3*188 {
+		t.Fatalf("expected a datagram of 3 packets, got %d bytes", n)
+	}
+	st := waitForState(t, s, StateRunning)
+	if st.ChannelID != 3 || st.BytesSent != 10*188 || st.Since.IsZero() {
+		t.Fatalf("unexpected status %+v", st)
+	}
+
+	cancel()
+	<-done
+	if st := s.Status()[0]; st.State != StateStopped || st.Error != "" {
+		t.Fatalf("expected the output to stop cleanly, got %+v", st)
+	}
+}
+
+func TestSenderRetriesFailedStream(t *testing.T) {
+	group := listen(t)
+	source := &fakeSource{err: errors.New("nothing is scheduled")}
+	s := New(source, []Output{{ChannelID: 1, Group: group.LocalAddr().String(), TTL: 1}})
+	s.retry = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+
+	st := waitForState(t, s, StateFailed)
+	if st.Error != "nothing is scheduled" {
+		t.Fatalf("expected the stream error, got %+v", st)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for source.callCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the output to be restarted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestSenderReportsDialFailure(t *testing.T) {
+	source := &fakeSource{}
+	s := New(source, []Output{{ChannelID: 1, Group: "239.0.0.1:1234", TTL: 1, Interface: "no-such-interface0"}})
+	s.retry = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	if st := waitForState(t, s, StateFailed); st.Interface != "no-such-interface0" || st.Error == "" {
+		t.Fatalf("unexpected status %+v", st)
+	}
+	if source.callCount() != 0 {
+		t.Fatal("expected nothing to be streamed without a socket")
+	}
+}
+
+func TestDialSetsMulticastOptions(t *testing.T) {
+	conn, err := dial(Output{ChannelID: 1, Group: "239.0.0.1:1234", TTL: 4})
+	if err != nil {
+		t.Skipf("no multicast route: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if got := conn.RemoteAddr().String(); got != "239.0.0.1:1234" {
+		t.Fatalf("expected to send to the group, got %s", got)
+	}
+}
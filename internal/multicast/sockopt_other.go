@@ -0,0 +1,13 @@
+//go:build !unix
+
+package multicast
+
+import (
+	"errors"
+	"net"
+)
+
+// setMulticastOptions is only implemented on Unix systems.
+func setMulticastOptions(*net.UDPConn, int, net.IP) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package multicast
+
+import (
+	"net"
+	"syscall"
+)
+
+// setMulticastOptions sets the TTL of conn's multicast datagrams and, when ifaceIP is set, the
+// interface they leave from.
+func setMulticastOptions(conn *net.UDPConn, ttl int, ifaceIP net.IP) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptByte(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, byte(ttl))
+		if sockErr != nil || ifaceIP == nil {
+			return
+		}
+		var addr [4]byte
+		copy(addr[:], ifaceIP.To4())
+		sockErr = syscall.SetsockoptInet4Addr(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_IF, addr)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,35 @@
+package probe
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// artworkExts are the image formats artwork is looked for in.
+var artworkExts = []string{".jpg", ".jpeg", ".png"}
+
+// Poster finds the artwork image for the media file at path. An image named after the file, such
+// as movie-poster.jpg, movie-thumb.jpg or movie.jpg, is preferred over one for the whole
+// directory, poster.jpg or folder.jpg. Names are matched without regard to case. It returns an
+// empty path when there is none.
+func Poster(path string) (string, error) {
+	d, err := ReadDir(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return d.Poster(path), nil
+}
+
+// Poster finds the artwork image in d for the media file at path, as the Poster function does.
+func (d *Dir) Poster(path string) string {
+	base := filepath.Base(path)
+	stem := strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base)))
+	for _, name := range []string{stem + "-poster", stem + "-thumb", stem, "poster", "folder"} {
+		for _, ext := range artworkExts {
+			if found, ok := d.images[name+ext]; ok {
+				return filepath.Join(d.path, found)
+			}
+		}
+	}
+	return ""
+}
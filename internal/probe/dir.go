@@ -0,0 +1,42 @@
+package probe
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Dir is the listing of a media directory, read once so the sidecars and artwork of every media
+// file in it can be found without listing it again.
+type Dir struct {
+	path string
+	// subtitles are the names of the sidecar subtitle files.
+	subtitles []string
+	// images maps the lower-cased names of the artwork images to their names.
+	images map[string]string
+}
+
+// ReadDir lists the media directory at path.
+func ReadDir(path string) (*Dir, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("read media directory: %w", err)
+	}
+	d := &Dir{path: path, images: map[string]string{}}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if _, ok := sidecarCodecs[ext]; ok {
+			d.subtitles = append(d.subtitles, name)
+		}
+		if slices.Contains(artworkExts, ext) {
+			d.images[strings.ToLower(name)] = name
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,207 @@
+package probe
+
+import "errors"
+
+var errBitstreamEnd = errors.New("unexpected end of bitstream")
+
+// maxRefFramesInPOCCycle is the most num_ref_frames_in_pic_order_cnt_cycle the H.264 spec allows.
+const maxRefFramesInPOCCycle = 255
+
+// highProfiles lists H.264 profile_idc values whose SPS carries chroma and bit depth fields.
+var highProfiles = map[uint]struct{}{
+	44: {}, 83: {}, 86: {}, 100: {}, 110: {}, 118: {}, 122: {}, 128: {}, 134: {}, 135: {}, 138: {}, 139: {}, 244: {},
+}
+
+// h264Dimensions finds the first sequence parameter set in an Annex B byte stream and returns the
+// cropped picture size it describes.
+func h264Dimensions(stream []byte) (int, int) {
+	for _, nal := range annexBNALUnits(stream) {
+		if len(nal) > 1 && nal[0]&0x1F == 7 {
+			w, h, err := parseH264SPS(unescapeRBSP(nal[1:]))
+			if err == nil {
+				return w, h
+			}
+		}
+	}
+	return 0, 0
+}
+
+func annexBNALUnits(b []byte) [][]byte {
+	var units [][]byte
+	start := -1
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] != 0 || b[i+1] != 0 || b[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			end := i
+			if end > start && b[end-1] == 0 {
+				end--
+			}
+			units = append(units, b[start:end])
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start < len(b) {
+		units = append(units, b[start:])
+	}
+	return units
+}
+
+// unescapeRBSP removes emulation prevention bytes (00 00 03 -> 00 00).
+func unescapeRBSP(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 3 {
+			zeros = 0
+			continue
+		}
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func parseH264SPS(rbsp []byte) (int, int, error) {
+	br := &bitReader{data: rbsp}
+
+	profile := br.bits(8)
+	br.bits(16) // constraint flags and level_idc
+	br.ue()     // seq_parameter_set_id
+
+	chromaFormat := uint(1)
+	if _, ok := highProfiles[profile]; ok {
+		chromaFormat = br.ue()
+		if chromaFormat == 3 {
+			br.bits(1) // separate_colour_plane_flag
+		}
+		br.ue()    // bit_depth_luma_minus8
+		br.ue()    // bit_depth_chroma_minus8
+		br.bits(1) // qpprime_y_zero_transform_bypass_flag
+		if br.bits(1) == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := range lists {
+				if br.bits(1) == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					skipScalingList(br, size)
+				}
+			}
+		}
+	}
+
+	br.ue() // log2_max_frame_num_minus4
+	switch br.ue() {
+	case 0:
+		br.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		br.bits(1) // delta_pic_order_always_zero_flag
+		br.se()    // offset_for_non_ref_pic
+		br.se()    // offset_for_top_to_bottom_field
+		cycle := br.ue()
+		if cycle > maxRefFramesInPOCCycle {
+			return 0, 0, malformed("h264 sps has %d reference frames in its picture order count cycle", cycle)
+		}
+		for i := uint(0); i < cycle && br.err == nil; i++ {
+			br.se() // offset_for_ref_frame
+		}
+	}
+	br.ue()    // max_num_ref_frames
+	br.bits(1) // gaps_in_frame_num_value_allowed_flag
+
+	widthMBs := br.ue() + 1
+	heightMapUnits := br.ue() + 1
+	frameMBsOnly := br.bits(1)
+	if frameMBsOnly == 0 {
+		br.bits(1) // mb_adaptive_frame_field_flag
+	}
+	br.bits(1) // direct_8x8_inference_flag
+
+	var cropLeft, cropRight, cropTop, cropBottom uint
+	if br.bits(1) == 1 {
+		cropLeft, cropRight, cropTop, cropBottom = br.ue(), br.ue(), br.ue(), br.ue()
+	}
+	if br.err != nil {
+		return 0, 0, br.err
+	}
+
+	cropUnitX, cropUnitY := uint(1), 2-frameMBsOnly
+	switch chromaFormat {
+	case 1:
+		cropUnitX, cropUnitY = 2, 2*(2-frameMBsOnly)
+	case 2:
+		cropUnitX = 2
+	}
+
+	width := int(widthMBs*16) - int(cropUnitX*(cropLeft+cropRight))
+	height := int((2-frameMBsOnly)*heightMapUnits*16) - int(cropUnitY*(cropTop+cropBottom))
+	if width <= 0 || height <= 0 {
+		return 0, 0, errBitstreamEnd
+	}
+	return width, height, nil
+}
+
+func skipScalingList(br *bitReader, size int) {
+	last, next := 8, 8
+	for range size {
+		if next != 0 {
+			next = (last + br.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// bitReader reads big-endian bit fields and Exp-Golomb codes. The first read past the end of the
+// data sets err and all later reads return zero.
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (br *bitReader) bits(n int) uint {
+	var v uint
+	for range n {
+		if br.pos >= len(br.data)*8 {
+			br.err = errBitstreamEnd
+			return 0
+		}
+		bit := (br.data[br.pos/8] >> (7 - br.pos%8)) & 1
+		v = v<<1 | uint(bit)
+		br.pos++
+	}
+	return v
+}
+
+func (br *bitReader) ue() uint {
+	zeros := 0
+	for br.bits(1) == 0 {
+		if br.err != nil || zeros > 31 {
+			br.err = errBitstreamEnd
+			return 0
+		}
+		zeros++
+	}
+	return (1<<zeros - 1) + br.bits(zeros)
+}
+
+func (br *bitReader) se() int {
+	v := br.ue()
+	if v%2 == 1 {
+		return int(v+1) / 2
+	}
+	return -int(v / 2)
+}
@@ -0,0 +1,342 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	ebmlIDHeader        = 0x1A45DFA3
+	ebmlIDSegment       = 0x18538067
+	ebmlIDInfo          = 0x1549A966
+	ebmlIDTimecodeScale = 0x2AD7B1
+	ebmlIDDuration      = 0x4489
+	ebmlIDTracks        = 0x1654AE6B
+	ebmlIDTrackEntry    = 0xAE
+	ebmlIDTrackType     = 0x83
+	ebmlIDCodecID       = 0x86
+	ebmlIDName          = 0x536E
+	ebmlIDLanguage      = 0x22B59C
+	ebmlIDLanguageIETF  = 0x22B59D
+	ebmlIDFlagDefault   = 0x88
+	ebmlIDFlagForced    = 0x55AA
+	ebmlIDVideo         = 0xE0
+	ebmlIDPixelWidth    = 0xB0
+	ebmlIDPixelHeight   = 0xBA
+
+	mkvTrackVideo    = 1
+	mkvTrackAudio    = 2
+	mkvTrackSubtitle = 17
+
+	// mkvDefaultLanguage is the language of tracks that do not give one.
+	mkvDefaultLanguage = "eng"
+
+	defaultTimecodeScale = 1000000
+	maxEBMLMasterSize    = 16 << 20
+	ebmlUnknownSize      = -1
+)
+
+var mkvCodecPrefixes = []struct {
+	prefix string
+	codec  string
+}{
+	{prefix: "V_MPEG4/ISO/AVC", codec: "h264"},
+	{prefix: "V_MPEGH/ISO/HEVC", codec: "hevc"},
+	{prefix: "V_MPEG4/ISO", codec: "mpeg4"},
+	{prefix: "V_MPEG2", codec: "mpeg2video"},
+	{prefix: "V_VP8", codec: "vp8"},
+	{prefix: "V_VP9", codec: "vp9"},
+	{prefix: "V_AV1", codec: "av1"},
+	{prefix: "A_AAC", codec: "aac"},
+	{prefix: "A_AC3", codec: "ac3"},
+	{prefix: "A_EAC3", codec: "eac3"},
+	{prefix: "A_DTS", codec: "dts"},
+	{prefix: "A_OPUS", codec: "opus"},
+	{prefix: "A_VORBIS", codec: "vorbis"},
+	{prefix: "A_FLAC", codec: "flac"},
+	{prefix: "A_MPEG/L3", codec: "mp3"},
+	{prefix: "A_MPEG/L2", codec: "mp2"},
+	{prefix: "S_TEXT/UTF8", codec: "subrip"},
+	{prefix: "S_TEXT/ASS", codec: "ass"},
+	{prefix: "S_TEXT/SSA", codec: "ass"},
+	{prefix: "S_TEXT/WEBVTT", codec: "webvtt"},
+	{prefix: "S_HDMV/PGS", codec: "hdmv_pgs_subtitle"},
+	{prefix: "S_VOBSUB", codec: "dvd_subtitle"},
+	{prefix: "S_DVBSUB", codec: "dvb_subtitle"},
+}
+
+type ebmlElement struct {
+	id         uint32
+	dataOffset int64
+	size       int64
+}
+
+// ebmlChild is an element decoded from in-memory master element data.
+type ebmlChild struct {
+	id   uint32
+	data []byte
+}
+
+func probeMatroska(r io.ReaderAt, size int64) (*Info, error) {
+	header, err := readEBMLElement(r, 0, size)
+	if err != nil || header.id != ebmlIDHeader {
+		return nil, malformed("matroska file has no EBML header")
+	}
+	if header.size == ebmlUnknownSize || header.size > size-header.dataOffset {
+		return nil, malformed("matroska EBML header runs past the end of the file")
+	}
+
+	segment, err := readEBMLElement(r, header.dataOffset+header.size, size)
+	if err != nil || segment.id != ebmlIDSegment {
+		return nil, malformed("matroska file has no segment")
+	}
+	segmentEnd := size
+	if segment.size != ebmlUnknownSize {
+		segmentEnd = min(size, segment.dataOffset+segment.size)
+	}
+
+	info := &Info{Container: ContainerMatroska}
+	var seenInfo, seenTracks bool
+
+	for offset := segment.dataOffset; offset < segmentEnd && !(seenInfo && seenTracks); {
+		el, err := readEBMLElement(r, offset, segmentEnd)
+		if err != nil {
+			return nil, err
+		}
+		if el.size == ebmlUnknownSize {
+			// Only clusters are commonly written with an unknown size, and they follow the headers we need.
+			break
+		}
+
+		switch el.id {
+		case ebmlIDInfo:
+			data, err := readEBMLData(r, el)
+			if err != nil {
+				return nil, err
+			}
+			info.Duration = parseMatroskaInfo(data)
+			seenInfo = true
+		case ebmlIDTracks:
+			data, err := readEBMLData(r, el)
+			if err != nil {
+				return nil, err
+			}
+			parseMatroskaTracks(data, info)
+			seenTracks = true
+		}
+		offset = el.dataOffset + el.size
+	}
+
+	return info, nil
+}
+
+func readEBMLElement(r io.ReaderAt, offset, limit int64) (ebmlElement, error) {
+	if offset < 0 || offset >= limit {
+		return ebmlElement{}, malformed("matroska element at offset %d is past the end of its parent", offset)
+	}
+	buf := make([]byte, 12)
+	n, err := r.ReadAt(buf[:min(int64(len(buf)), limit-offset)], offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return ebmlElement{}, fmt.Errorf("read matroska element: %w", err)
+	}
+	el, headerLen, ok := parseEBMLHeader(buf[:n])
+	if !ok {
+		return ebmlElement{}, malformed("invalid matroska element at offset %d", offset)
+	}
+	el.dataOffset = offset + int64(headerLen)
+	return el, nil
+}
+
+func readEBMLData(r io.ReaderAt, el ebmlElement) ([]byte, error) {
+	if el.size > maxEBMLMasterSize {
+		return nil, malformed("matroska element %x is too large (%d bytes)", el.id, el.size)
+	}
+	data := make([]byte, el.size)
+	if _, err := r.ReadAt(data, el.dataOffset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read matroska element data: %w", err)
+	}
+	return data, nil
+}
+
+// parseEBMLHeader decodes an element ID and data size from the start of b.
+func parseEBMLHeader(b []byte) (ebmlElement, int, bool) {
+	id, idLen, ok := readVint(b, true)
+	if !ok || idLen > 4 {
+		return ebmlElement{}, 0, false
+	}
+	size, sizeLen, ok := readVint(b[idLen:], false)
+	if !ok {
+		return ebmlElement{}, 0, false
+	}
+	el := ebmlElement{id: uint32(id), size: int64(size)}
+	if size == 1<<(7*sizeLen)-1 {
+		el.size = ebmlUnknownSize
+	}
+	return el, idLen + sizeLen, true
+}
+
+// readVint decodes an EBML variable-length integer. IDs keep their length marker bit; sizes do not.
+func readVint(b []byte, keepMarker bool) (uint64, int, bool) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, false
+	}
+	length := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 || len(b) < length {
+		return 0, 0, false
+	}
+	v := uint64(b[0])
+	if !keepMarker {
+		v &= uint64(0xFF >> length)
+	}
+	for _, c := range b[1:length] {
+		v = v<<8 | uint64(c)
+	}
+	return v, length, true
+}
+
+// ebmlChildren splits master element data into child elements, stopping at the first truncated one.
+func ebmlChildren(data []byte) []ebmlChild {
+	var children []ebmlChild
+	for len(data) > 0 {
+		el, headerLen, ok := parseEBMLHeader(data)
+		if !ok || el.size == ebmlUnknownSize || int64(headerLen)+el.size > int64(len(data)) {
+			break
+		}
+		children = append(children, ebmlChild{id: el.id, data: data[headerLen : int64(headerLen)+el.size]})
+		data = data[int64(headerLen)+el.size:]
+	}
+	return children
+}
+
+func parseMatroskaInfo(data []byte) time.Duration {
+	scale := uint64(defaultTimecodeScale)
+	var duration float64
+	for _, child := range ebmlChildren(data) {
+		switch child.id {
+		case ebmlIDTimecodeScale:
+			if v := ebmlUint(child.data); v > 0 {
+				scale = v
+			}
+		case ebmlIDDuration:
+			duration = ebmlFloat(child.data)
+		}
+	}
+	if duration <= 0 || math.IsNaN(duration) || math.IsInf(duration, 0) {
+		return 0
+	}
+	return time.Duration(duration * float64(scale))
+}
+
+// parseMatroskaTracks records the first video and audio codecs in info and lists its audio and
+// subtitle tracks.
+func parseMatroskaTracks(data []byte, info *Info) {
+	counts := map[string]int{}
+	for _, entry := range ebmlChildren(data) {
+		if entry.id != ebmlIDTrackEntry {
+			continue
+		}
+		var trackType uint64
+		var codecID, name, language, languageIETF string
+		isDefault, forced := true, false
+		var width, height int
+		for _, field := range ebmlChildren(entry.data) {
+			switch field.id {
+			case ebmlIDTrackType:
+				trackType = ebmlUint(field.data)
+			case ebmlIDCodecID:
+				codecID = strings.TrimRight(string(field.data), "\x00")
+			case ebmlIDName:
+				name = strings.TrimRight(string(field.data), "\x00")
+			case ebmlIDLanguage:
+				language = strings.TrimRight(string(field.data), "\x00")
+			case ebmlIDLanguageIETF:
+				languageIETF = strings.TrimRight(string(field.data), "\x00")
+			case ebmlIDFlagDefault:
+				isDefault = ebmlUint(field.data) != 0
+			case ebmlIDFlagForced:
+				forced = ebmlUint(field.data) != 0
+			case ebmlIDVideo:
+				for _, v := range ebmlChildren(field.data) {
+					switch v.id {
+					case ebmlIDPixelWidth:
+						width = int(ebmlUint(v.data))
+					case ebmlIDPixelHeight:
+						height = int(ebmlUint(v.data))
+					}
+				}
+			}
+		}
+
+		var kind string
+		switch trackType {
+		case mkvTrackVideo:
+			if info.VideoCodec == "" {
+				info.VideoCodec = matroskaCodec(codecID)
+				info.Width, info.Height = width, height
+			}
+			continue
+		case mkvTrackAudio:
+			if info.AudioCodec == "" {
+				info.AudioCodec = matroskaCodec(codecID)
+			}
+			kind = TrackAudio
+		case mkvTrackSubtitle:
+			kind = TrackSubtitle
+		default:
+			continue
+		}
+
+		// The IETF tag, when present, supersedes the older ISO 639-2 one.
+		switch {
+		case languageIETF != "":
+			language = languageIETF
+		case language == "":
+			language = mkvDefaultLanguage
+		}
+		info.Tracks = append(info.Tracks, Track{
+			Kind:     kind,
+			Index:    counts[kind],
+			Codec:    matroskaCodec(codecID),
+			Language: trackLanguage(language),
+			Title:    name,
+			Default:  isDefault,
+			Forced:   forced,
+		})
+		counts[kind]++
+	}
+}
+
+func matroskaCodec(codecID string) string {
+	for _, c := range mkvCodecPrefixes {
+		if strings.HasPrefix(codecID, c.prefix) {
+			return c.codec
+		}
+	}
+	return strings.ToLower(codecID)
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func ebmlFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
@@ -0,0 +1,164 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func ebml(id uint32, payload ...[]byte) []byte {
+	body := concat(payload...)
+	var idBytes []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(idBytes) > 0 {
+			idBytes = append(idBytes, b)
+		}
+	}
+	size := binary.BigEndian.AppendUint64(nil, uint64(len(body)))
+	size[0] = 0x01 // 8-byte size vint
+	return concat(idBytes, size, body)
+}
+
+func ebmlUintBytes(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func buildMKV(t *testing.T) []byte {
+	t.Helper()
+	header := ebml(ebmlIDHeader, ebml(0x4282, []byte("matroska")))
+	info := ebml(ebmlIDInfo,
+		ebml(ebmlIDTimecodeScale, ebmlUintBytes(1000000)),
+		ebml(ebmlIDDuration, binary.BigEndian.AppendUint64(nil, math.Float64bits(12345))),
+	)
+	tracks := ebml(ebmlIDTracks,
+		ebml(ebmlIDTrackEntry,
+			ebml(ebmlIDTrackType, []byte{mkvTrackVideo}),
+			ebml(ebmlIDCodecID, []byte("V_MPEGH/ISO/HEVC")),
+			ebml(ebmlIDVideo,
+				ebml(ebmlIDPixelWidth, ebmlUintBytes(3840)),
+				ebml(ebmlIDPixelHeight, ebmlUintBytes(2160)),
+			),
+		),
+		ebml(ebmlIDTrackEntry,
+			ebml(ebmlIDTrackType, []byte{mkvTrackAudio}),
+			ebml(ebmlIDCodecID, []byte("A_OPUS")),
+		),
+	)
+	// Segment and cluster use the unknown-size marker, as live muxers write them.
+	unknown := []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	cluster := concat([]byte{0x1F, 0x43, 0xB6, 0x75}, unknown, make([]byte, 64))
+	return concat(header, []byte{0x18, 0x53, 0x80, 0x67}, unknown, info, tracks, cluster)
+}
+
+func TestProbeMatroskaReadsInfoAndTracks(t *testing.T) {
+	data := buildMKV(t)
+
+	info, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if info.Container != ContainerMatroska {
+		t.Fatalf("expected matroska container, got %q", info.Container)
+	}
+	if info.Duration != 12345*time.Millisecond {
+		t.Fatalf("expected 12.345s, got %s", info.Duration)
+	}
+	if info.VideoCodec != "hevc" || info.AudioCodec != "opus" {
+		t.Fatalf("unexpected codecs: %+v", info)
+	}
+	if info.Width != 3840 || info.Height != 2160 {
+		t.Fatalf("unexpected resolution: %dx%d", info.Width, info.Height)
+	}
+}
+
+func TestProbeMatroskaListsTracksWithLanguages(t *testing.T) {
+	header := ebml(ebmlIDHeader, ebml(0x4282, []byte("matroska")))
+	tracks := ebml(ebmlIDTracks,
+		ebml(ebmlIDTrackEntry,
+			ebml(ebmlIDTrackType, []byte{mkvTrackVideo}),
+			ebml(ebmlIDCodecID, []byte("V_MPEG4/ISO/AVC")),
+		),
+		// Without a language element a track is English.
+		ebml(ebmlIDTrackEntry,
+			ebml(ebmlIDTrackType, []byte{mkvTrackAudio}),
+			ebml(ebmlIDCodecID, []byte("A_AAC")),
+		),
+		ebml(ebmlIDTrackEntry,
+			ebml(ebmlIDTrackType, []byte{mkvTrackAudio}),
+			ebml(ebmlIDCodecID, []byte("A_AC3")),
+			ebml(ebmlIDLanguage, []byte("ger")),
+			ebml(ebmlIDName, []byte("Commentary")),
+			ebml(ebmlIDFlagDefault, []byte{0}),
+		),
+		ebml(ebmlIDTrackEntry,
+			ebml(ebmlIDTrackType, []byte{mkvTrackSubtitle}),
+			ebml(ebmlIDCodecID, []byte("S_TEXT/UTF8")),
+			ebml(ebmlIDLanguage, []byte("fre")),
+			ebml(ebmlIDLanguageIETF, []byte("fr-CA")),
+			ebml(ebmlIDFlagDefault, []byte{0}),
+			ebml(ebmlIDFlagForced, []byte{1}),
+		),
+	)
+	data := concat(header, ebml(ebmlIDSegment, ebml(ebmlIDInfo), tracks))
+
+	info, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	want := []Track{
+		{Kind: TrackAudio, Index: 0, Codec: "aac", Language: "eng", Default: true},
+		{Kind: TrackAudio, Index: 1, Codec: "ac3", Language: "ger", Title: "Commentary"},
+		{Kind: TrackSubtitle, Index: 0, Codec: "subrip", Language: "fr-CA", Forced: true},
+	}
+	if !slices.Equal(info.Tracks, want) {
+		t.Fatalf("unexpected tracks: %+v", info.Tracks)
+	}
+}
+
+func TestProbeMatroskaWithoutSegmentIsMalformed(t *testing.T) {
+	data := ebml(ebmlIDHeader, ebml(0x4282, []byte("webm")))
+
+	_, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected ErrMalformed, got %v", err)
+	}
+}
+
+func TestProbeMatroskaWithTruncatedHeaderIsMalformed(t *testing.T) {
+	// The EBML header declares 0x1000 bytes, far more than the file holds.
+	truncated := concat([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x50, 0x00}, []byte("matroska00"))
+	unknown := concat([]byte{0x1A, 0x45, 0xDF, 0xA3}, []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, []byte("matroska"))
+
+	for name, data := range map[string][]byte{"past end": truncated, "unknown size": unknown} {
+		_, err := Reader(bytes.NewReader(data), int64(len(data)))
+		if !errors.Is(err, ErrMalformed) {
+			t.Fatalf("%s: expected ErrMalformed, got %v", name, err)
+		}
+	}
+}
+
+func TestReadVint(t *testing.T) {
+	tests := []struct {
+		in     []byte
+		marker bool
+		want   uint64
+		length int
+	}{
+		{in: []byte{0x81}, want: 1, length: 1},
+		{in: []byte{0x40, 0x02}, want: 2, length: 2},
+		{in: []byte{0x1A, 0x45, 0xDF, 0xA3}, marker: true, want: ebmlIDHeader, length: 4},
+	}
+	for _, tc := range tests {
+		got, n, ok := readVint(tc.in, tc.marker)
+		if !ok || got != tc.want || n != tc.length {
+			t.Fatalf("readVint(%x) = %d, %d, %v", tc.in, got, n, ok)
+		}
+	}
+	if _, _, ok := readVint([]byte{0x00}, false); ok {
+		t.Fatal("expected invalid vint")
+	}
+}
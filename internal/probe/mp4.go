@@ -0,0 +1,283 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const maxMoovSize = 64 << 20
+
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	".mp3": "mp3",
+	"tx3g": "mov_text",
+	"wvtt": "webvtt",
+	"stpp": "ttml",
+	"c608": "eia_608",
+}
+
+// mp4SubtitleHandlers are the handler types of subtitle and caption tracks.
+var mp4SubtitleHandlers = map[string]bool{
+	"sbtl": true,
+	"subt": true,
+	"text": true,
+	"clcp": true,
+}
+
+// mp4Box is a box header and its payload.
+type mp4Box struct {
+	typ     string
+	payload []byte
+}
+
+func probeMP4(r io.ReaderAt, size int64) (*Info, error) {
+	moov, err := readMoov(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Container: ContainerMP4}
+	var longestTrack time.Duration
+
+	counts := map[string]int{}
+
+	for _, b := range mp4Children(moov) {
+		switch b.typ {
+		case "mvhd":
+			info.Duration = parseMvhd(b.payload)
+		case "trak":
+			if d := parseTrak(b.payload, info, counts); d > longestTrack {
+				longestTrack = d
+			}
+		}
+	}
+	if info.Duration == 0 {
+		info.Duration = longestTrack
+	}
+
+	return info, nil
+}
+
+// readMoov walks the top-level boxes and returns the payload of the movie box.
+func readMoov(r io.ReaderAt, size int64) ([]byte, error) {
+	var offset int64
+	hdr := make([]byte, 16)
+
+	for offset+8 <= size {
+		if _, err := r.ReadAt(hdr[:8], offset); err != nil {
+			return nil, fmt.Errorf("read mp4 box header: %w", err)
+		}
+		boxSize := int64(binary.BigEndian.Uint32(hdr[:4]))
+		typ := string(hdr[4:8])
+		headerLen := int64(8)
+
+		switch boxSize {
+		case 0:
+			boxSize = size - offset
+		case 1:
+			if _, err := r.ReadAt(hdr[8:16], offset+8); err != nil {
+				return nil, fmt.Errorf("read mp4 box header: %w", err)
+			}
+			boxSize = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			headerLen = 16
+		}
+		if boxSize < headerLen || offset+boxSize > size {
+			return nil, malformed("mp4 box %q at offset %d has invalid size %d", typ, offset, boxSize)
+		}
+
+		if typ == "moov" {
+			payloadLen := boxSize - headerLen
+			if payloadLen > maxMoovSize {
+				return nil, malformed("mp4 moov box is too large (%d bytes)", payloadLen)
+			}
+			payload := make([]byte, payloadLen)
+			if _, err := r.ReadAt(payload, offset+headerLen); err != nil && !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("read mp4 moov box: %w", err)
+			}
+			return payload, nil
+		}
+		offset += boxSize
+	}
+
+	return nil, malformed("mp4 file has no moov box")
+}
+
+// mp4Children splits a box payload into its child boxes, stopping at the first truncated box.
+func mp4Children(b []byte) []mp4Box {
+	var boxes []mp4Box
+	for len(b) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(b[:4]))
+		typ := string(b[4:8])
+		headerLen := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return boxes
+			}
+			size = binary.BigEndian.Uint64(b[8:16])
+			headerLen = 16
+		}
+		if size < headerLen || size > uint64(len(b)) {
+			return boxes
+		}
+		boxes = append(boxes, mp4Box{typ: typ, payload: b[headerLen:size]})
+		b = b[size:]
+	}
+	return boxes
+}
+
+func mp4Child(b []byte, path ...string) []byte {
+	for _, typ := range path {
+		var found []byte
+		for _, child := range mp4Children(b) {
+			if child.typ == typ {
+				found = child.payload
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		b = found
+	}
+	return b
+}
+
+func parseMvhd(b []byte) time.Duration {
+	if len(b) < 4 {
+		return 0
+	}
+	if b[0] == 1 {
+		if len(b) < 32 {
+			return 0
+		}
+		return scaleDuration(binary.BigEndian.Uint64(b[24:32]), binary.BigEndian.Uint32(b[20:24]))
+	}
+	if len(b) < 20 {
+		return 0
+	}
+	return scaleDuration(uint64(binary.BigEndian.Uint32(b[16:20])), binary.BigEndian.Uint32(b[12:16]))
+}
+
+// parseTrak records the codec and dimensions of a track in info, lists audio and subtitle tracks
+// and returns the track duration. counts holds how many tracks of each kind came before.
+func parseTrak(trak []byte, info *Info, counts map[string]int) time.Duration {
+	mdia := mp4Child(trak, "mdia")
+	if mdia == nil {
+		return 0
+	}
+
+	var duration time.Duration
+	mdhd := mp4Child(mdia, "mdhd")
+	if mdhd != nil {
+		duration = parseMvhd(mdhd)
+	}
+
+	hdlr := mp4Child(mdia, "hdlr")
+	if len(hdlr) < 12 {
+		return duration
+	}
+	handler := string(hdlr[8:12])
+
+	stsd := mp4Child(mdia, "minf", "stbl", "stsd")
+	if len(stsd) < 16 {
+		return duration
+	}
+	entry := stsd[8:]
+	entrySize := binary.BigEndian.Uint32(entry[:4])
+	fourcc := string(entry[4:8])
+	codec, ok := mp4Codecs[fourcc]
+	if !ok {
+		codec = fourcc
+	}
+
+	switch handler {
+	case "vide":
+		if info.VideoCodec != "" {
+			return duration
+		}
+		info.VideoCodec = codec
+		info.Width, info.Height = trackDimensions(mp4Child(trak, "tkhd"))
+		if (info.Width == 0 || info.Height == 0) && entrySize >= 36 && len(entry) >= 36 {
+			info.Width = int(binary.BigEndian.Uint16(entry[32:34]))
+			info.Height = int(binary.BigEndian.Uint16(entry[34:36]))
+		}
+	case "soun":
+		if info.AudioCodec == "" {
+			info.AudioCodec = codec
+		}
+		addMP4Track(info, counts, TrackAudio, codec, mdhd)
+	default:
+		if mp4SubtitleHandlers[handler] {
+			addMP4Track(info, counts, TrackSubtitle, codec, mdhd)
+		}
+	}
+
+	return duration
+}
+
+func addMP4Track(info *Info, counts map[string]int, kind, codec string, mdhd []byte) {
+	info.Tracks = append(info.Tracks, Track{
+		Kind:     kind,
+		Index:    counts[kind],
+		Codec:    codec,
+		Language: trackLanguage(mdhdLanguage(mdhd)),
+	})
+	counts[kind]++
+}
+
+// mdhdLanguage unpacks the ISO 639-2 code stored as three 5-bit letters after the media header's
+// duration.
+func mdhdLanguage(b []byte) string {
+	off := 20
+	if len(b) > 0 && b[0] == 1 {
+		off = 32
+	}
+	if len(b) < off+2 {
+		return ""
+	}
+	packed := binary.BigEndian.Uint16(b[off : off+2])
+	lang := make([]byte, 3)
+	for i := range lang {
+		c := byte(packed>>(10-5*i)&0x1F) + 0x60
+		if c < 'a' || c > 'z' {
+			return ""
+		}
+		lang[i] = c
+	}
+	return string(lang)
+}
+
+// trackDimensions reads the 16.16 fixed-point width and height stored at the end of a tkhd box.
+func trackDimensions(tkhd []byte) (int, int) {
+	if len(tkhd) < 84 {
+		return 0, 0
+	}
+	tail := tkhd[len(tkhd)-8:]
+	return int(binary.BigEndian.Uint32(tail[:4]) >> 16), int(binary.BigEndian.Uint32(tail[4:]) >> 16)
+}
+
+func scaleDuration(units uint64, timescale uint32) time.Duration {
+	if timescale == 0 || units == 0 || units == 1<<64-1 || units == 1<<32-1 {
+		return 0
+	}
+	seconds := units / uint64(timescale)
+	remainder := units % uint64(timescale)
+	return time.Duration(seconds)*time.Second + time.Duration(remainder*uint64(time.Second)/uint64(timescale))
+}
@@ -0,0 +1,153 @@
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func buildBox(typ string, payload ...[]byte) []byte {
+	body := concat(payload...)
+	return concat(u32(uint32(8+len(body))), []byte(typ), body)
+}
+
+func buildTkhd(width, height uint16) []byte {
+	payload := make([]byte, 84)
+	copy(payload[76:], u16(width))
+	copy(payload[80:], u16(height))
+	return buildBox("tkhd", payload)
+}
+
+func buildMdhd(timescale, duration uint32) []byte {
+	return buildBox("mdhd", make([]byte, 12), u32(timescale), u32(duration), make([]byte, 4))
+}
+
+func buildTrak(handler, fourcc string, width, height uint16, timescale, duration uint32) []byte {
+	entry := buildBox(fourcc, make([]byte, 24), u16(width), u16(height), make([]byte, 50))
+	stsd := buildBox("stsd", make([]byte, 4), u32(1), entry)
+	hdlr := buildBox("hdlr", make([]byte, 8), []byte(handler), make([]byte, 13))
+	return buildBox("trak",
+		buildTkhd(width, height),
+		buildBox("mdia",
+			buildMdhd(timescale, duration),
+			hdlr,
+			buildBox("minf", buildBox("stbl", stsd)),
+		),
+	)
+}
+
+func buildMP4(t *testing.T) []byte {
+	t.Helper()
+	mvhd := buildBox("mvhd", make([]byte, 12), u32(1000), u32(90500), make([]byte, 80))
+	moov := buildBox("moov",
+		mvhd,
+		buildTrak("vide", "avc1", 1920, 1080, 24000, 2172000),
+		buildTrak("soun", "mp4a", 0, 0, 48000, 4344000),
+	)
+	return concat(
+		buildBox("ftyp", []byte("isom"), u32(512), []byte("isomavc1")),
+		buildBox("mdat", bytes.Repeat([]byte{0xAA}, 1000)),
+		moov,
+	)
+}
+
+func TestProbeMP4ReadsMovieHeaderAndTracks(t *testing.T) {
+	data := buildMP4(t)
+
+	info, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+
+	if info.Container != ContainerMP4 {
+		t.Fatalf("expected mp4 container, got %q", info.Container)
+	}
+	if info.Duration != 90500*time.Millisecond {
+		t.Fatalf("expected 90.5s, got %s", info.Duration)
+	}
+	if info.VideoCodec != "h264" || info.AudioCodec != "aac" {
+		t.Fatalf("unexpected codecs: %+v", info)
+	}
+	if info.Width != 1920 || info.Height != 1080 {
+		t.Fatalf("unexpected resolution: %dx%d", info.Width, info.Height)
+	}
+	if info.Size != int64(len(data)) {
+		t.Fatalf("expected size %d, got %d", len(data), info.Size)
+	}
+}
+
+func TestProbeMP4ListsTracksWithLanguages(t *testing.T) {
+	// The language is packed as three 5-bit letters, each offset by 0x60.
+	lang := func(code string) []byte {
+		return u16(uint16(code[0]-0x60)<<10 | uint16(code[1]-0x60)<<5 | uint16(code[2]-0x60))
+	}
+	trak := func(handler, fourcc, code string) []byte {
+		entry := buildBox(fourcc, make([]byte, 28))
+		return buildBox("trak", buildBox("mdia",
+			buildBox("mdhd", make([]byte, 12), u32(1000), u32(1000), lang(code), make([]byte, 2)),
+			buildBox("hdlr", make([]byte, 8), []byte(handler), make([]byte, 13)),
+			buildBox("minf", buildBox("stbl", buildBox("stsd", make([]byte, 4), u32(1), entry))),
+		))
+	}
+	mvhd := buildBox("mvhd", make([]byte, 12), u32(1000), u32(1000), make([]byte, 80))
+	data := concat(
+		buildBox("ftyp", []byte("isom")),
+		buildBox("moov", mvhd,
+			trak("soun", "mp4a", "eng"),
+			trak("soun", "ac-3", "und"),
+			trak("sbtl", "tx3g", "spa"),
+		),
+	)
+
+	info, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	want := []Track{
+		{Kind: TrackAudio, Index: 0, Codec: "aac", Language: "eng"},
+		{Kind: TrackAudio, Index: 1, Codec: "ac3"},
+		{Kind: TrackSubtitle, Index: 0, Codec: "mov_text", Language: "spa"},
+	}
+	if !slices.Equal(info.Tracks, want) {
+		t.Fatalf("unexpected tracks: %+v", info.Tracks)
+	}
+}
+
+func TestProbeMP4FallsBackToTrackDuration(t *testing.T) {
+	mvhd := buildBox("mvhd", make([]byte, 12), u32(1000), u32(0), make([]byte, 80))
+	data := concat(
+		buildBox("ftyp", []byte("isom")),
+		buildBox("moov", mvhd, buildTrak("vide", "hvc1", 0, 0, 90000, 900000)),
+	)
+
+	info, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if info.Duration != 10*time.Second {
+		t.Fatalf("expected 10s from track header, got %s", info.Duration)
+	}
+	if info.VideoCodec != "hevc" {
+		t.Fatalf("expected hevc, got %q", info.VideoCodec)
+	}
+}
+
+func TestProbeMP4WithoutMoovIsMalformed(t *testing.T) {
+	data := concat(buildBox("ftyp", []byte("isom")), buildBox("mdat", make([]byte, 16)))
+
+	_, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected ErrMalformed, got %v", err)
+	}
+}
+
+func TestProbeMP4WithTruncatedBoxIsMalformed(t *testing.T) {
+	data := concat(buildBox("ftyp", []byte("isom")), u32(4096), []byte("moov"), make([]byte, 16))
+
+	_, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected ErrMalformed, got %v", err)
+	}
+}
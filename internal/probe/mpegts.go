@@ -0,0 +1,367 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	tsSyncByte      = 0x47
+	tsPacketSize    = 188
+	m2tsPacketSize  = 192
+	tsScanWindow    = 8 << 20
+	pcrClockRate    = 90000
+	pcrWrap         = 1 << 33
+	maxSPSSearchLen = 512 << 10
+)
+
+var tsStreamTypes = map[byte]struct {
+	video bool
+	codec string
+}{
+	0x01: {video: true, codec: "mpeg1video"},
+	0x02: {video: true, codec: "mpeg2video"},
+	0x10: {video: true, codec: "mpeg4"},
+	0x1B: {video: true, codec: "h264"},
+	0x24: {video: true, codec: "hevc"},
+	0x03: {codec: "mp2"},
+	0x04: {codec: "mp2"},
+	0x0F: {codec: "aac"},
+	0x11: {codec: "aac_latm"},
+	0x81: {codec: "ac3"},
+	0x87: {codec: "eac3"},
+}
+
+// tsDescriptorCodecs identifies audio carried as private data (stream type 0x06) by descriptor tag.
+var tsDescriptorCodecs = map[byte]string{
+	0x6A: "ac3",
+	0x7A: "eac3",
+	0x7B: "dts",
+}
+
+const (
+	tsStreamPrivate = 0x06
+	tsStreamPGS     = 0x90
+
+	tsDescriptorLanguage   = 0x0A
+	tsDescriptorSubtitling = 0x59
+	tsCodecDVBSubtitle     = "dvb_subtitle"
+	tsCodecPGSSubtitle     = "hdmv_pgs_subtitle"
+)
+
+type tsPacket struct {
+	pid     uint16
+	pusi    bool
+	pcr     int64
+	hasPCR  bool
+	payload []byte
+}
+
+// detectTSPacketSize reports whether the file is an MPEG transport stream and the size of its packets.
+// At least three consecutive packets must carry a sync byte.
+func detectTSPacketSize(r io.ReaderAt) (int, bool) {
+	for _, ps := range []int{tsPacketSize, m2tsPacketSize} {
+		prefix := ps - tsPacketSize
+		buf := make([]byte, ps*3)
+		n, err := r.ReadAt(buf, 0)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, false
+		}
+		if n < len(buf) {
+			return 0, false
+		}
+		ok := true
+		for i := prefix; i < n; i += ps {
+			if buf[i] != tsSyncByte {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return ps, true
+		}
+	}
+	return 0, false
+}
+
+func probeMPEGTS(r io.ReaderAt, size int64, packetSize int) (*Info, error) {
+	headLen := min(size, tsScanWindow)
+	head := make([]byte, headLen)
+	if _, err := r.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read transport stream head: %w", err)
+	}
+
+	info := &Info{Container: ContainerMPEGTS}
+	var (
+		pmtPID     = -1
+		pcrPID     = -1
+		videoPID   = -1
+		videoCodec string
+		firstPCR   = map[uint16]int64{}
+		videoData  []byte
+	)
+
+	for _, pkt := range tsPackets(head, packetSize) {
+		if pkt.hasPCR {
+			if _, ok := firstPCR[pkt.pid]; !ok {
+				firstPCR[pkt.pid] = pkt.pcr
+			}
+		}
+
+		switch {
+		case pkt.pid == 0 && pkt.pusi && pmtPID < 0:
+			pmtPID = parsePAT(pkt.payload)
+		case int(pkt.pid) == pmtPID && pkt.pusi && pcrPID < 0:
+			pcrPID, videoPID = parsePMT(pkt.payload, info)
+			videoCodec = info.VideoCodec
+		case int(pkt.pid) == videoPID && videoCodec == "h264" && len(videoData) < maxSPSSearchLen:
+			videoData = append(videoData, pesPayload(pkt)...)
+		}
+	}
+
+	if pmtPID < 0 {
+		return nil, malformed("transport stream has no program association table")
+	}
+	if pcrPID < 0 {
+		return nil, malformed("transport stream has no program map table")
+	}
+	if info.Width == 0 && len(videoData) > 0 {
+		info.Width, info.Height = h264Dimensions(videoData)
+	}
+
+	start, ok := firstPCR[uint16(pcrPID)]
+	if !ok {
+		return info, nil
+	}
+	end, err := lastPCR(r, size, packetSize, uint16(pcrPID))
+	if err != nil {
+		return nil, err
+	}
+	if end >= 0 {
+		info.Duration = pcrDuration(start, end)
+	}
+
+	return info, nil
+}
+
+// lastPCR returns the last PCR carried on pid near the end of the stream, or -1 if there is none.
+func lastPCR(r io.ReaderAt, size int64, packetSize int, pid uint16) (int64, error) {
+	tailLen := min(size, tsScanWindow)
+	tail := make([]byte, tailLen)
+	if _, err := r.ReadAt(tail, size-tailLen); err != nil && !errors.Is(err, io.EOF) {
+		return -1, fmt.Errorf("read transport stream tail: %w", err)
+	}
+
+	last := int64(-1)
+	for _, pkt := range tsPackets(tail[tsAlign(tail, packetSize):], packetSize) {
+		if pkt.pid == pid && pkt.hasPCR {
+			last = pkt.pcr
+		}
+	}
+	return last, nil
+}
+
+// tsAlign returns the offset of the first packet boundary in b.
+func tsAlign(b []byte, packetSize int) int {
+	prefix := packetSize - tsPacketSize
+	for i := prefix; i+2*packetSize < len(b); i++ {
+		if b[i] == tsSyncByte && b[i+packetSize] == tsSyncByte && b[i+2*packetSize] == tsSyncByte {
+			return i - prefix
+		}
+	}
+	return len(b)
+}
+
+func tsPackets(b []byte, packetSize int) []tsPacket {
+	prefix := packetSize - tsPacketSize
+	packets := make([]tsPacket, 0, len(b)/packetSize)
+	for off := 0; off+packetSize <= len(b); off += packetSize {
+		p := b[off+prefix : off+packetSize]
+		if p[0] != tsSyncByte {
+			continue
+		}
+		pkt := tsPacket{
+			pid:  uint16(p[1]&0x1F)<<8 | uint16(p[2]),
+			pusi: p[1]&0x40 != 0,
+		}
+		afc := (p[3] >> 4) & 0x3
+		payloadStart := 4
+		if afc&0x2 != 0 {
+			afLen := int(p[4])
+			if afLen > 0 && 5+afLen <= len(p) && p[5]&0x10 != 0 && afLen >= 7 {
+				pkt.hasPCR = true
+				pkt.pcr = int64(p[6])<<25 | int64(p[7])<<17 | int64(p[8])<<9 | int64(p[9])<<1 | int64(p[10])>>7
+			}
+			payloadStart = 5 + afLen
+		}
+		if afc&0x1 != 0 && payloadStart < len(p) {
+			pkt.payload = p[payloadStart:]
+		}
+		packets = append(packets, pkt)
+	}
+	return packets
+}
+
+// psiSection strips the pointer field from a PSI payload and returns the section bytes.
+func psiSection(payload []byte) []byte {
+	if len(payload) < 1 || int(payload[0])+1 > len(payload) {
+		return nil
+	}
+	section := payload[1+int(payload[0]):]
+	if len(section) < 3 {
+		return nil
+	}
+	length := int(section[1]&0x0F)<<8 | int(section[2])
+	if 3+length > len(section) {
+		return section
+	}
+	return section[:3+length]
+}
+
+// parsePAT returns the PMT PID of the first program, or -1.
+func parsePAT(payload []byte) int {
+	section := psiSection(payload)
+	if len(section) < 12 || section[0] != 0x00 {
+		return -1
+	}
+	programs := section[8 : len(section)-4]
+	for i := 0; i+4 <= len(programs); i += 4 {
+		number := int(programs[i])<<8 | int(programs[i+1])
+		if number == 0 {
+			continue
+		}
+		return int(programs[i+2]&0x1F)<<8 | int(programs[i+3])
+	}
+	return -1
+}
+
+// parsePMT records the first video and audio codecs in info, lists its audio and subtitle tracks
+// and returns the PCR and video PIDs.
+func parsePMT(payload []byte, info *Info) (int, int) {
+	section := psiSection(payload)
+	if len(section) < 16 || section[0] != 0x02 {
+		return -1, -1
+	}
+	pcrPID := int(section[8]&0x1F)<<8 | int(section[9])
+	infoLen := int(section[10]&0x0F)<<8 | int(section[11])
+	if 12+infoLen > len(section)-4 {
+		return -1, -1
+	}
+
+	videoPID := -1
+	counts := map[string]int{}
+	addTrack := func(kind, codec, language string) {
+		info.Tracks = append(info.Tracks, Track{Kind: kind, Index: counts[kind], Codec: codec, Language: trackLanguage(language)})
+		counts[kind]++
+	}
+	streams := section[12+infoLen : len(section)-4]
+	for i := 0; i+5 <= len(streams); {
+		streamType := streams[i]
+		pid := int(streams[i+1]&0x1F)<<8 | int(streams[i+2])
+		esInfoLen := int(streams[i+3]&0x0F)<<8 | int(streams[i+4])
+		end := min(i+5+esInfoLen, len(streams))
+		descriptors := streams[i+5 : end]
+		i = end
+
+		if st, ok := tsStreamTypes[streamType]; ok && st.video {
+			if info.VideoCodec == "" {
+				info.VideoCodec = st.codec
+				videoPID = pid
+			}
+			continue
+		}
+		if codec := tsAudioCodec(streamType, descriptors); codec != "" {
+			if info.AudioCodec == "" {
+				info.AudioCodec = codec
+			}
+			addTrack(TrackAudio, codec, descriptorLanguage(descriptors, tsDescriptorLanguage))
+			continue
+		}
+		switch {
+		case streamType == tsStreamPrivate && findDescriptor(descriptors, tsDescriptorSubtitling) != nil:
+			addTrack(TrackSubtitle, tsCodecDVBSubtitle, descriptorLanguage(descriptors, tsDescriptorSubtitling))
+		case streamType == tsStreamPGS:
+			addTrack(TrackSubtitle, tsCodecPGSSubtitle, descriptorLanguage(descriptors, tsDescriptorLanguage))
+		}
+	}
+
+	return pcrPID, videoPID
+}
+
+// TSAudioStream reports whether the elementary stream of a PMT with streamType and descriptors
+// is one of the audio tracks the probe lists, which are indexed in PMT order.
+func TSAudioStream(streamType byte, descriptors []byte) bool {
+	return tsAudioCodec(streamType, descriptors) != ""
+}
+
+// tsAudioCodec returns the codec of an audio elementary stream, or empty for other streams.
+func tsAudioCodec(streamType byte, descriptors []byte) string {
+	if st, ok := tsStreamTypes[streamType]; ok {
+		if st.video {
+			return ""
+		}
+		return st.codec
+	}
+	if streamType == tsStreamPrivate {
+		return descriptorCodec(descriptors)
+	}
+	return ""
+}
+
+func descriptorCodec(descriptors []byte) string {
+	for i := 0; i+2 <= len(descriptors); {
+		tag := descriptors[i]
+		length := int(descriptors[i+1])
+		if codec, ok := tsDescriptorCodecs[tag]; ok {
+			return codec
+		}
+		i += 2 + length
+	}
+	return ""
+}
+
+// findDescriptor returns the body of the first descriptor with tag, or nil.
+func findDescriptor(descriptors []byte, tag byte) []byte {
+	for i := 0; i+2 <= len(descriptors); {
+		length := int(descriptors[i+1])
+		end := min(i+2+length, len(descriptors))
+		if descriptors[i] == tag {
+			return descriptors[i+2 : end]
+		}
+		i = end
+	}
+	return nil
+}
+
+// descriptorLanguage reads the ISO 639-2 code that starts the body of the descriptor with tag. The
+// ISO 639 language and DVB subtitling descriptors both begin with one.
+func descriptorLanguage(descriptors []byte, tag byte) string {
+	body := findDescriptor(descriptors, tag)
+	if len(body) < 3 {
+		return ""
+	}
+	return string(body[:3])
+}
+
+// pesPayload strips the PES header from packets that start a PES packet.
+func pesPayload(pkt tsPacket) []byte {
+	if !pkt.pusi {
+		return pkt.payload
+	}
+	p := pkt.payload
+	if len(p) < 9 || p[0] != 0 || p[1] != 0 || p[2] != 1 {
+		return nil
+	}
+	headerEnd := 9 + int(p[8])
+	if headerEnd > len(p) {
+		return nil
+	}
+	return p[headerEnd:]
+}
+
+func pcrDuration(start, end int64) time.Duration {
+	ticks := (end - start + pcrWrap) % pcrWrap
+	return time.Duration(ticks) * time.Second / pcrClockRate
+}
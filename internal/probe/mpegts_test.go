@@ -0,0 +1,295 @@
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	testPMTPID   = 0x1000
+	testVideoPID = 0x100
+	testAudioPID = 0x101
+)
+
+// bitWriter builds bitstreams for SPS fixtures.
+type bitWriter struct {
+	out   []byte
+	nbits int
+}
+
+func (w *bitWriter) bits(v uint, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.nbits%8 == 0 {
+			w.out = append(w.out, 0)
+		}
+		if (v>>i)&1 == 1 {
+			w.out[len(w.out)-1] |= 1 << (7 - w.nbits%8)
+		}
+		w.nbits++
+	}
+}
+
+func (w *bitWriter) ue(v uint) {
+	v++
+	n := 0
+	for x := v; x > 1; x >>= 1 {
+		n++
+	}
+	w.bits(0, n)
+	w.bits(v, n+1)
+}
+
+// buildSPS encodes a High profile 4:2:0 SPS for 1920x1080 (1088 coded lines cropped by 8).
+func buildSPS() []byte {
+	w := &bitWriter{}
+	w.bits(100, 8) // profile_idc
+	w.bits(0, 8)   // constraint flags
+	w.bits(40, 8)  // level_idc
+	w.ue(0)        // seq_parameter_set_id
+	w.ue(1)        // chroma_format_idc
+	w.ue(0)        // bit_depth_luma_minus8
+	w.ue(0)        // bit_depth_chroma_minus8
+	w.bits(0, 1)   // qpprime_y_zero_transform_bypass_flag
+	w.bits(0, 1)   // seq_scaling_matrix_present_flag
+	w.ue(0)        // log2_max_frame_num_minus4
+	w.ue(0)        // pic_order_cnt_type
+	w.ue(2)        // log2_max_pic_order_cnt_lsb_minus4
+	w.ue(4)        // max_num_ref_frames
+	w.bits(0, 1)   // gaps_in_frame_num_value_allowed_flag
+	w.ue(119)      // pic_width_in_mbs_minus1
+	w.ue(67)       // pic_height_in_map_units_minus1
+	w.bits(1, 1)   // frame_mbs_only_flag
+	w.bits(1, 1)   // direct_8x8_inference_flag
+	w.bits(1, 1)   // frame_cropping_flag
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(4)
+	w.bits(1, 1) // vui_parameters_present_flag (unused)
+	return append([]byte{0x67}, w.out...)
+}
+
+func tsPacketBytes(pid uint16, pusi bool, pcr int64, payload []byte) []byte {
+	p := make([]byte, 0, tsPacketSize)
+	b1 := byte(pid>>8) & 0x1F
+	if pusi {
+		b1 |= 0x40
+	}
+	p = append(p, tsSyncByte, b1, byte(pid))
+
+	var af []byte
+	if pcr >= 0 {
+		af = []byte{0x10,
+			byte(pcr >> 25), byte(pcr >> 17), byte(pcr >> 9), byte(pcr >> 1), byte(pcr<<7) | 0x7E, 0x00}
+	}
+	stuffing := tsPacketSize - 4 - len(payload)
+	if af != nil {
+		stuffing -= 1 + len(af)
+	}
+	if stuffing > 0 && af == nil {
+		af = []byte{}
+		stuffing--
+	}
+	if af != nil {
+		for range stuffing {
+			af = append(af, 0xFF)
+		}
+		flags := byte(0x30)
+		if len(payload) == 0 {
+			flags = 0x20
+		}
+		p = append(p, flags, byte(len(af)))
+		p = append(p, af...)
+	} else {
+		p = append(p, 0x10)
+	}
+	p = append(p, payload...)
+	return p[:tsPacketSize]
+}
+
+func psiPayload(tableID byte, body []byte) []byte {
+	length := len(body) + 5 + 4
+	section := concat([]byte{0x00, tableID, 0xB0 | byte(length>>8), byte(length), 0x00, 0x01, 0xC1, 0x00, 0x00}, body, make([]byte, 4))
+	return section
+}
+
+func buildPAT() []byte {
+	return tsPacketBytes(0, true, -1, psiPayload(0x00, concat(u16(1), u16(0xE000|testPMTPID))))
+}
+
+func buildPMT(streams ...[]byte) []byte {
+	body := concat(u16(0xE000|testVideoPID), u16(0xF000))
+	for _, s := range streams {
+		body = append(body, s...)
+	}
+	return tsPacketBytes(testPMTPID, true, -1, psiPayload(0x02, body))
+}
+
+func pmtStream(streamType byte, pid uint16, descriptors ...byte) []byte {
+	return concat([]byte{streamType}, u16(0xE000|pid), u16(0xF000|uint16(len(descriptors))), descriptors)
+}
+
+func buildTS(t *testing.T, startPCR, endPCR int64) []byte {
+	t.Helper()
+	pes := concat([]byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0x80, 5, 0x21, 0, 1, 0, 1}, []byte{0, 0, 0, 1}, buildSPS(), []byte{0, 0, 1, 0x68, 0xCE})
+	packets := [][]byte{
+		buildPAT(),
+		buildPMT(pmtStream(0x1B, testVideoPID), pmtStream(0x06, testAudioPID, 0x6A, 0x01, 0x00)),
+		tsPacketBytes(testVideoPID, true, startPCR, pes),
+	}
+	for range 20 {
+		packets = append(packets, tsPacketBytes(testAudioPID, false, -1, bytes.Repeat([]byte{0x11}, 184)))
+	}
+	packets = append(packets, tsPacketBytes(testVideoPID, false, endPCR, bytes.Repeat([]byte{0x22}, 100)))
+	return concat(packets...)
+}
+
+func TestProbeMPEGTSReadsProgramAndPCRSpan(t *testing.T) {
+	data := buildTS(t, 900000, 900000+30*pcrClockRate)
+
+	info, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if info.Container != ContainerMPEGTS {
+		t.Fatalf("expected mpegts container, got %q", info.Container)
+	}
+	if info.Duration != 30*time.Second {
+		t.Fatalf("expected 30s, got %s", info.Duration)
+	}
+	if info.VideoCodec != "h264" || info.AudioCodec != "ac3" {
+		t.Fatalf("unexpected codecs: %+v", info)
+	}
+	if info.Width != 1920 || info.Height != 1080 {
+		t.Fatalf("unexpected resolution: %dx%d", info.Width, info.Height)
+	}
+	if want := int64(len(data)) * 8 / 30; info.Bitrate != want {
+		t.Fatalf("expected bitrate %d, got %d", want, info.Bitrate)
+	}
+}
+
+func TestProbeMPEGTSListsTracksWithLanguages(t *testing.T) {
+	data := concat(
+		buildPAT(),
+		buildPMT(
+			pmtStream(0x1B, testVideoPID),
+			pmtStream(0x0F, testAudioPID, 0x0A, 0x04, 'e', 'n', 'g', 0x00),
+			pmtStream(0x06, testAudioPID+1, 0x6A, 0x01, 0x00, 0x0A, 0x04, 'f', 'r', 'a', 0x00),
+			pmtStream(0x06, testAudioPID+2, 0x59, 0x08, 'd', 'e', 'u', 0x10, 0x00, 0x01, 0x00, 0x01),
+		),
+		tsPacketBytes(testVideoPID, true, 0, nil),
+	)
+
+	info, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	want := []Track{
+		{Kind: TrackAudio, Index: 0, Codec: "aac", Language: "eng"},
+		{Kind: TrackAudio, Index: 1, Codec: "ac3", Language: "fra"},
+		{Kind: TrackSubtitle, Index: 0, Codec: "dvb_subtitle", Language: "deu"},
+	}
+	if !slices.Equal(info.Tracks, want) {
+		t.Fatalf("unexpected tracks: %+v", info.Tracks)
+	}
+	if info.AudioCodec != "aac" {
+		t.Fatalf("expected the first audio codec, got %q", info.AudioCodec)
+	}
+}
+
+func TestProbeMPEGTSHandlesPCRWrap(t *testing.T) {
+	data := buildTS(t, pcrWrap-5*pcrClockRate, 5*pcrClockRate)
+
+	info, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if info.Duration != 10*time.Second {
+		t.Fatalf("expected 10s across wrap, got %s", info.Duration)
+	}
+}
+
+func TestProbeM2TSUsesTimecodePrefixedPackets(t *testing.T) {
+	ts := buildTS(t, 0, 12*pcrClockRate)
+	var data []byte
+	for i := 0; i < len(ts); i += tsPacketSize {
+		data = append(data, 0, 0, 0, 0)
+		data = append(data, ts[i:i+tsPacketSize]...)
+	}
+
+	info, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if info.Container != ContainerMPEGTS || info.Duration != 12*time.Second {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+}
+
+func TestProbeMPEGTSWithoutPATIsMalformed(t *testing.T) {
+	var data []byte
+	for range 4 {
+		data = append(data, tsPacketBytes(testAudioPID, false, -1, bytes.Repeat([]byte{0x11}, 184))...)
+	}
+
+	_, err := Reader(bytes.NewReader(data), int64(len(data)))
+	if !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected ErrMalformed, got %v", err)
+	}
+}
+
+func TestParseH264SPSRejectsLongPicOrderCntCycle(t *testing.T) {
+	for _, cycle := range []uint{maxRefFramesInPOCCycle + 1, 1 << 31} {
+		w := &bitWriter{}
+		w.bits(66, 8) // profile_idc
+		w.bits(0, 8)  // constraint flags
+		w.bits(30, 8) // level_idc
+		w.ue(0)       // seq_parameter_set_id
+		w.ue(0)       // log2_max_frame_num_minus4
+		w.ue(1)       // pic_order_cnt_type
+		w.bits(0, 1)  // delta_pic_order_always_zero_flag
+		w.ue(0)       // offset_for_non_ref_pic
+		w.ue(0)       // offset_for_top_to_bottom_field
+		w.ue(cycle)   // num_ref_frames_in_pic_order_cnt_cycle
+
+		_, _, err := parseH264SPS(w.out)
+		if !errors.Is(err, ErrMalformed) {
+			t.Fatalf("cycle %d: expected ErrMalformed, got %v", cycle, err)
+		}
+	}
+}
+
+func TestParseH264SPSStopsAtEndOfBitstream(t *testing.T) {
+	w := &bitWriter{}
+	w.bits(66, 8) // profile_idc
+	w.bits(0, 8)  // constraint flags
+	w.bits(30, 8) // level_idc
+	w.ue(0)       // seq_parameter_set_id
+	w.ue(0)       // log2_max_frame_num_minus4
+	w.ue(1)       // pic_order_cnt_type
+	w.bits(0, 1)  // delta_pic_order_always_zero_flag
+	w.ue(0)       // offset_for_non_ref_pic
+	w.ue(0)       // offset_for_top_to_bottom_field
+	w.ue(200)     // num_ref_frames_in_pic_order_cnt_cycle, with no offsets following
+
+	_, _, err := parseH264SPS(w.out)
+	if !errors.Is(err, errBitstreamEnd) {
+		t.Fatalf("expected errBitstreamEnd, got %v", err)
+	}
+}
+
+func TestParseH264SPSHandlesEmulationPrevention(t *testing.T) {
+	sps := buildSPS()
+	w, h := h264Dimensions(concat([]byte{0, 0, 0, 1}, sps))
+	if w != 1920 || h != 1080 {
+		t.Fatalf("expected 1920x1080, got %dx%d", w, h)
+	}
+
+	escaped := unescapeRBSP([]byte{0x00, 0x00, 0x03, 0x01, 0x00, 0x00, 0x03, 0x00})
+	if !bytes.Equal(escaped, []byte{0x00, 0x00, 0x01, 0x00, 0x00, 0x00}) {
+		t.Fatalf("unexpected unescaped bytes: %x", escaped)
+	}
+}
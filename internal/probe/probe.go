@@ -0,0 +1,178 @@
+// Package probe reads container headers of media files to report their duration, codecs,
+// resolution, bitrate and audio and subtitle tracks without decoding any media or shelling out to
+// external tools.
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Containers the probe recognises, as stored in Info.Container.
+const (
+	ContainerMP4      = "mp4"
+	ContainerMPEGTS   = "mpegts"
+	ContainerMatroska = "matroska"
+)
+
+// Track kinds.
+const (
+	TrackAudio    = "audio"
+	TrackSubtitle = "subtitle"
+)
+
+// ErrMalformed is returned when a file looks like a supported container but its headers cannot be parsed.
+var ErrMalformed = errors.New("malformed media file")
+
+// Info describes a media file. Fields the probe could not determine are left at their zero value.
+type Info struct {
+	Container  string
+	Size       int64
+	Duration   time.Duration
+	VideoCodec string
+	AudioCodec string
+	Width      int
+	Height     int
+	Bitrate    int64
+	// Tracks lists the audio and subtitle tracks in the file, followed by any sidecar subtitle
+	// files next to it.
+	Tracks []Track
+	// Poster is the artwork image found next to the file, or empty when there is none.
+	Poster string
+}
+
+// Track is an audio or subtitle track.
+type Track struct {
+	Kind string
+	// Index counts the file's tracks of the same kind from zero, in the order ffmpeg numbers
+	// them. It is zero for sidecar files.
+	Index int
+	Codec string
+	// Language is the tag the file gives, such as eng or en-US, or empty when it gives none.
+	Language string
+	Title    string
+	Default  bool
+	Forced   bool
+	// Path is the sidecar file holding the track, or empty for a track inside the media file.
+	Path string
+}
+
+// Prober probes files on the local filesystem.
+type Prober struct{}
+
+func (Prober) Probe(path string) (*Info, error) {
+	return File(path)
+}
+
+// ProbeIn is Probe given dir, the listing of the file's directory.
+func (Prober) ProbeIn(dir *Dir, path string) (*Info, error) {
+	return FileIn(dir, path)
+}
+
+// File probes the media file at path and looks for sidecar subtitle files and artwork next to it.
+// Files in containers the probe does not understand are not an error; only their size, sidecars
+// and artwork are reported.
+func File(path string) (*Info, error) {
+	return FileIn(nil, path)
+}
+
+// FileIn is File finding sidecars and artwork in dir, the listing of the file's directory read
+// beforehand, so probing every file in a directory lists it once. A nil dir, or the listing of
+// another directory, is read again.
+func FileIn(dir *Dir, path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open media file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat media file: %w", err)
+	}
+	if st.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	info, err := Reader(f, st.Size())
+	if err != nil {
+		return nil, err
+	}
+	if dir == nil || dir.path != filepath.Dir(path) {
+		if dir, err = ReadDir(filepath.Dir(path)); err != nil {
+			return nil, err
+		}
+	}
+	info.Tracks = append(info.Tracks, dir.Sidecars(path)...)
+	info.Poster = dir.Poster(path)
+	return info, nil
+}
+
+// Reader probes size bytes of media readable from r.
+func Reader(r io.ReaderAt, size int64) (*Info, error) {
+	head := make([]byte, 12)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read media header: %w", err)
+	}
+	head = head[:n]
+
+	var info *Info
+	switch {
+	case isMatroska(head):
+		info, err = probeMatroska(r, size)
+	case isMP4(head):
+		info, err = probeMP4(r, size)
+	default:
+		packetSize, ok := detectTSPacketSize(r)
+		if !ok {
+			return &Info{Size: size}, nil
+		}
+		info, err = probeMPEGTS(r, size, packetSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info.Size = size
+	if info.Duration > 0 {
+		info.Bitrate = int64(float64(size*8) / info.Duration.Seconds())
+	}
+	return info, nil
+}
+
+func isMatroska(head []byte) bool {
+	return bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3})
+}
+
+func isMP4(head []byte) bool {
+	if len(head) < 8 {
+		return false
+	}
+	switch string(head[4:8]) {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide", "pnot":
+		return true
+	}
+	return false
+}
+
+// trackLanguage drops the undetermined language tag.
+func trackLanguage(lang string) string {
+	lang = strings.TrimSpace(strings.TrimRight(lang, "\x00"))
+	if strings.EqualFold(lang, "und") {
+		return ""
+	}
+	return lang
+}
+
+func malformed(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrMalformed, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,170 @@
+package probe
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	return path
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestFileReportsSizeForUnknownContainers(t *testing.T) {
+	path := writeTempFile(t, "clip.avi", []byte("RIFF....AVI LIST"))
+
+	info, err := File(path)
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if info.Size != 16 || info.Container != "" || info.Duration != 0 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+}
+
+func TestFileReturnsErrorForMissingFile(t *testing.T) {
+	if _, err := File(filepath.Join(t.TempDir(), "missing.mp4")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestFileReturnsErrorForDirectory(t *testing.T) {
+	if _, err := File(t.TempDir()); err == nil {
+		t.Fatal("expected error for directory")
+	}
+}
+
+func TestProberProbesFile(t *testing.T) {
+	path := writeTempFile(t, "movie.mp4", buildMP4(t))
+
+	info, err := Prober{}.Probe(path)
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if info.Container != ContainerMP4 {
+		t.Fatalf("expected mp4 container, got %+v", info)
+	}
+	if info.Bitrate <= 0 {
+		t.Fatalf("expected bitrate to be computed, got %+v", info)
+	}
+}
+
+func TestFileFindsSidecarSubtitles(t *testing.T) {
+	path := writeTempFile(t, "Movie.mp4", buildMP4(t))
+	dir := filepath.Dir(path)
+	for _, name := range []string{"Movie.srt", "Movie.en.forced.srt", "Movie.SDH.pt-BR.vtt", "Movie.ass", "Movie 2.srt", "Other.en.srt", "Movie.nfo"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatalf("write sidecar: %v", err)
+		}
+	}
+
+	info, err := File(path)
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	var sidecars []Track
+	for _, tr := range info.Tracks {
+		if tr.Path != "" {
+			sidecars = append(sidecars, tr)
+		}
+	}
+	want := []Track{
+		{Kind: TrackSubtitle, Codec: "webvtt", Language: "pt-BR", Title: "SDH", Path: filepath.Join(dir, "Movie.SDH.pt-BR.vtt")},
+		{Kind: TrackSubtitle, Codec: "ass", Path: filepath.Join(dir, "Movie.ass")},
+		{Kind: TrackSubtitle, Codec: "subrip", Language: "en", Forced: true, Path: filepath.Join(dir, "Movie.en.forced.srt")},
+		{Kind: TrackSubtitle, Codec: "subrip", Path: filepath.Join(dir, "Movie.srt")},
+	}
+	if !slices.Equal(sidecars, want) {
+		t.Fatalf("unexpected sidecars: %+v", sidecars)
+	}
+}
+
+func TestFileInUsesTheDirectoryListingGiven(t *testing.T) {
+	path := writeTempFile(t, "Movie.mp4", buildMP4(t))
+	dir := filepath.Dir(path)
+	listing, err := ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	// A sidecar added after the listing was read is only found by listing the directory again.
+	if err := os.WriteFile(filepath.Join(dir, "Movie.srt"), nil, 0o644); err != nil {
+		t.Fatalf("write sidecar: %v", err)
+	}
+
+	sidecars := func(d *Dir) int {
+		t.Helper()
+		info, err := FileIn(d, path)
+		if err != nil {
+			t.Fatalf("probe: %v", err)
+		}
+		n := 0
+		for _, tr := range info.Tracks {
+			if tr.Path != "" {
+				n++
+			}
+		}
+		return n
+	}
+	if n := sidecars(listing); n != 0 {
+		t.Fatalf("expected the listing given to be used, got %d sidecars", n)
+	}
+	if n := sidecars(nil); n != 1 {
+		t.Fatalf("expected the directory to be listed without a listing, got %d sidecars", n)
+	}
+}
+
+func TestPosterPrefersFileArtwork(t *testing.T) {
+	tests := []struct {
+		name   string
+		images []string
+		want   string
+	}{
+		{name: "none", images: []string{"Other.jpg", "Movie.nfo"}, want: ""},
+		{name: "folder", images: []string{"folder.jpg"}, want: "folder.jpg"},
+		{name: "poster over folder", images: []string{"folder.jpg", "Poster.PNG"}, want: "Poster.PNG"},
+		{name: "named after file", images: []string{"poster.jpg", "Movie.jpeg"}, want: "Movie.jpeg"},
+		{name: "thumb", images: []string{"Movie.jpg", "movie-thumb.jpg"}, want: "movie-thumb.jpg"},
+		{name: "poster named after file", images: []string{"Movie-thumb.jpg", "Movie-poster.jpg"}, want: "Movie-poster.jpg"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeTempFile(t, "Movie.mp4", buildMP4(t))
+			dir := filepath.Dir(path)
+			for _, name := range tc.images {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatalf("write image: %v", err)
+				}
+			}
+
+			info, err := File(path)
+			if err != nil {
+				t.Fatalf("probe: %v", err)
+			}
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(dir, tc.want)
+			}
+			if info.Poster != want {
+				t.Fatalf("expected poster %q, got %q", want, info.Poster)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package probe
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// sidecarCodecs maps the extensions of subtitle files kept next to media to their codecs.
+var sidecarCodecs = map[string]string{
+	".srt": "subrip",
+	".vtt": "webvtt",
+	".ass": "ass",
+	".ssa": "ass",
+}
+
+// captionTags mark subtitles for the deaf and hard of hearing. They look like language codes but
+// are kept as titles.
+var captionTags = map[string]bool{"sdh": true, "cc": true}
+
+// languageTagPattern matches language tags such as en, eng and pt-BR in sidecar file names.
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+// Sidecars finds the subtitle files next to the media file at path. A sidecar shares the media
+// file's name up to its extension and may add dot-separated tags before its own: a language, such
+// as movie.en.srt, and forced or default flags. Other tags become the track's title.
+func Sidecars(path string) ([]Track, error) {
+	d, err := ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	return d.Sidecars(path), nil
+}
+
+// Sidecars finds the subtitle files in d for the media file at path, as the Sidecars function does.
+func (d *Dir) Sidecars(path string) []Track {
+	base := filepath.Base(path)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+	var tracks []Track
+	for _, name := range d.subtitles {
+		ext := filepath.Ext(name)
+		if !strings.HasPrefix(name, stem) {
+			continue
+		}
+		rest := strings.TrimSuffix(name[len(stem):], ext)
+		if rest != "" && rest[0] != '.' {
+			continue
+		}
+		t := Track{Kind: TrackSubtitle, Codec: sidecarCodecs[strings.ToLower(ext)], Path: filepath.Join(d.path, name)}
+		var title []string
+		for _, tag := range strings.Split(strings.TrimPrefix(rest, "."), ".") {
+			switch {
+			case tag == "":
+			case strings.EqualFold(tag, "forced"):
+				t.Forced = true
+			case strings.EqualFold(tag, "default"):
+				t.Default = true
+			case t.Language == "" && !captionTags[strings.ToLower(tag)] && languageTagPattern.MatchString(tag):
+				t.Language = trackLanguage(tag)
+			default:
+				title = append(title, tag)
+			}
+		}
+		t.Title = strings.Join(title, " ")
+		tracks = append(tracks, t)
+	}
+	return tracks
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+var (
+	// seasonEpisodePattern matches S01E02, s1e2 and S01.E02.
+	seasonEpisodePattern = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])s(\d{1,3})[ ._-]?e(\d{1,4})`)
+	// crossPattern matches 1x02. It must stand alone so resolutions like 1920x1080 do not match.
+	crossPattern = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])(\d{1,2})x(\d{2,3})(?:$|[^0-9a-z])`)
+	// datePattern matches 2024-01-15 and 2024.01.15.
+	datePattern = regexp.MustCompile(`(?:^|[^0-9])(\d{4})[-. ](\d{2})[-. ](\d{2})(?:$|[^0-9])`)
+	// seasonDirPattern matches directories that group a show's files by season.
+	seasonDirPattern = regexp.MustCompile(`(?i)^(season[ ._-]*\d+|specials)$`)
+	// releaseTagPattern matches the first word of the release details that often follow a title.
+	releaseTagPattern = regexp.MustCompile(`(?i)^(\d{3,4}[pi]|[hx]26[45]|hevc|xvid|hdtv|web|webrip|web-?dl|bluray|bdrip|dvdrip|proper|repack|internal|aac|ac3)$`)
+)
+
+// parseEpisode reads the show, season and episode out of a media file's name. The show is the
+// text before the episode marker, or the file's directory when the name starts with the marker,
+// skipping a "Season N" or "Specials" directory. It returns nil when the name has no marker.
+func parseEpisode(path string) *service.Episode {
+	base := filepath.Base(path)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+
+	e := &service.Episode{}
+	var loc []int
+	if m := seasonEpisodePattern.FindStringSubmatchIndex(name); m != nil {
+		loc = m
+		e.Season, _ = strconv.Atoi(name[m[2]:m[3]])
+		e.Episode, _ = strconv.Atoi(name[m[4]:m[5]])
+	} else if m := crossPattern.FindStringSubmatchIndex(name); m != nil {
+		loc = m
+		e.Season, _ = strconv.Atoi(name[m[2]:m[3]])
+		e.Episode, _ = strconv.Atoi(name[m[4]:m[5]])
+	} else if m := datePattern.FindStringSubmatchIndex(name); m != nil {
+		date, err := time.Parse("2006-01-02", name[m[2]:m[3]]+"-"+name[m[4]:m[5]]+"-"+name[m[6]:m[7]])
+		if err != nil {
+			return nil
+		}
+		loc = m
+		e.Season = date.Year()
+		e.AirDate = date.Format("2006-01-02")
+	} else {
+		return nil
+	}
+
+	e.Show = cleanName(name[:loc[0]])
+	if e.Show == "" {
+		e.Show = showFromDir(filepath.Dir(path))
+	}
+	if e.Show == "" {
+		return nil
+	}
+	e.Title = episodeTitle(name[loc[1]:])
+	return e
+}
+
+// showFromDir names a show after the directory holding its files.
+func showFromDir(dir string) string {
+	name := filepath.Base(dir)
+	if seasonDirPattern.MatchString(name) {
+		name = filepath.Base(filepath.Dir(dir))
+	}
+	if name == "." || name == string(filepath.Separator) {
+		return ""
+	}
+	return cleanName(name)
+}
+
+// episodeTitle cleans the text after an episode marker, dropping any release details.
+func episodeTitle(s string) string {
+	words := strings.Fields(strings.NewReplacer("_", " ", ".", " ").Replace(s))
+	for i, w := range words {
+		if releaseTagPattern.MatchString(strings.Trim(w, "[]()")) {
+			words = words[:i]
+			break
+		}
+	}
+	return cleanName(strings.Join(words, " "))
+}
+
+// cleanName turns dots and underscores into spaces and trims separators from the ends.
+func cleanName(s string) string {
+	s = strings.NewReplacer("_", " ", ".", " ").Replace(s)
+	return strings.Join(strings.Fields(strings.Trim(s, " -[]")), " ")
+}
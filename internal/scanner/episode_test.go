@@ -0,0 +1,80 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func TestParseEpisode(t *testing.T) {
+	tests := []struct {
+		path string
+		want *service.Episode
+	}{
+		{path: "/tv/The.Office.S02E03.The.Fire.720p.HDTV.x264.mkv", want: &service.Episode{Show: "The Office", Season: 2, Episode: 3, Title: "The Fire"}},
+		{path: "/tv/the_office_s2e3.mkv", want: &service.Episode{Show: "the office", Season: 2, Episode: 3}},
+		{path: "/tv/Cheers - 1x02 - Sams Women.avi", want: &service.Episode{Show: "Cheers", Season: 1, Episode: 2, Title: "Sams Women"}},
+		{path: "/tv/Cheers/Season 1/S01E04.mkv", want: &service.Episode{Show: "Cheers", Season: 1, Episode: 4}},
+		{path: "/tv/Cheers/Specials/s00e01 Behind the Bar.mkv", want: &service.Episode{Show: "Cheers", Season: 0, Episode: 1, Title: "Behind the Bar"}},
+		{path: "/tv/Nightly News/2024.01.15.mp4", want: &service.Episode{Show: "Nightly News", Season: 2024, AirDate: "2024-01-15"}},
+		{path: "/tv/Nightly News 2024-01-15 Storm Special.ts", want: &service.Episode{Show: "Nightly News", Season: 2024, AirDate: "2024-01-15", Title: "Storm Special"}},
+		{path: "/movies/Big.Movie.1920x1080.mkv"},
+		{path: "/movies/Heat 2024-13-40.mkv"},
+		{path: "/S01E01.mkv"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			got := parseEpisode(filepath.FromSlash(tc.path))
+			if tc.want == nil {
+				if got != nil {
+					t.Fatalf("expected no episode, got %+v", got)
+				}
+				return
+			}
+			if got == nil || *got != *tc.want {
+				t.Fatalf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestScanSetsEpisodes(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "Cheers", "Cheers.S01E02.mkv")
+	writeFile(t, path, "abc")
+
+	repo := newMemContentRepo()
+	s := New(service.NewContentService(repo), []string{root}, time.Minute)
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	got, _ := repo.byPath(path)
+	if got.Episode == nil || got.Episode.Show != "Cheers" || got.Episode.Season != 1 || got.Episode.Episode != 2 {
+		t.Fatalf("expected an episode of Cheers, got %+v", got.Episode)
+	}
+
+	// Content scanned before episodes were parsed picks one up without the file changing.
+	got.Episode = nil
+	repo.content[got.ID] = got
+	res, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("second scan: %v", err)
+	}
+	if got, _ = repo.byPath(path); res.Updated != 1 || got.Episode == nil {
+		t.Fatalf("expected the episode to be filled in, got %+v %+v", res, got.Episode)
+	}
+
+	// Episode data set by hand is kept.
+	got.Episode = &service.Episode{Show: "Cheers (1982)", Season: 1, Episode: 2}
+	repo.content[got.ID] = got
+	res, err = s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("third scan: %v", err)
+	}
+	if got, _ = repo.byPath(path); res.Unchanged != 1 || got.Episode.Show != "Cheers (1982)" {
+		t.Fatalf("expected the episode to be kept, got %+v %+v", res, got.Episode)
+	}
+}
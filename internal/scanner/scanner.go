@@ -0,0 +1,301 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/library"
+	"github.com/iamseth/tiny-headend/internal/probe"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const listPageSize = 500
+
+var mediaExtensions = map[string]struct{}{
+	".avi":  {},
+	".m2ts": {},
+	".m4v":  {},
+	".mkv":  {},
+	".mov":  {},
+	".mp4":  {},
+	".mpeg": {},
+	".mpg":  {},
+	".ts":   {},
+	".webm": {},
+}
+
+// Result summarizes the changes made by a single scan.
+type Result struct {
+	Created   int
+	Updated   int
+	Removed   int
+	Unchanged int
+}
+
+// Scanner keeps content rows in sync with the media files found under a set of root directories.
+type Scanner struct {
+	svc      *service.ContentService
+	roots    []string
+	interval time.Duration
+}
+
+func New(svc *service.ContentService, roots []string, interval time.Duration) *Scanner {
+	cleaned := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if strings.TrimSpace(root) == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+		cleaned = append(cleaned, filepath.Clean(root))
+	}
+	return &Scanner{svc: svc, roots: cleaned, interval: interval}
+}
+
+// Run scans immediately and then once per interval until ctx is canceled.
+func (s *Scanner) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.scanAndLog(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scanner) scanAndLog(ctx context.Context) {
+	start := time.Now()
+	res, err := s.Scan(ctx)
+	if err != nil {
+		slog.Error("library scan failed", "error", err)
+	}
+	slog.Info("library scan complete",
+		"created", res.Created,
+		"updated", res.Updated,
+		"removed", res.Removed,
+		"unchanged", res.Unchanged,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+// Scan walks every root once, creating content for new files, updating content whose size or
+// modification time changed and removing content whose file no longer exists under a root.
+// Rows under a root that could not be walked are left untouched.
+func (s *Scanner) Scan(ctx context.Context) (Result, error) {
+	var res Result
+
+	existing, err := s.loadExisting(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	seen := make(map[string]struct{})
+	dirs := dirCache{}
+	var failedRoots []string
+	var errs []error
+
+	for _, root := range s.roots {
+		walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root {
+					return err
+				}
+				slog.Warn("skipping unreadable path", "path", path, "error", err)
+				return nil
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if strings.HasPrefix(d.Name(), ".") && path != root {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || !isMediaFile(path) {
+				return nil
+			}
+
+			seen[path] = struct{}{}
+			if err := s.syncFile(ctx, path, d, dirs, existing[path], &res); err != nil {
+				slog.Error("failed to sync media file", "path", path, "error", err)
+			}
+			return nil
+		})
+		if walkErr != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return res, ctxErr
+			}
+			failedRoots = append(failedRoots, root)
+			errs = append(errs, fmt.Errorf("walk scan root %s: %w", root, walkErr))
+		}
+	}
+
+	for path, c := range existing {
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		root, ok := s.rootFor(path)
+		if !ok || slices.Contains(failedRoots, root) {
+			continue
+		}
+		if err := s.svc.Delete(ctx, c.ID); err != nil && !errors.Is(err, service.ErrNotFound) {
+			errs = append(errs, fmt.Errorf("remove content %d: %w", c.ID, err))
+			continue
+		}
+		res.Removed++
+	}
+
+	return res, errors.Join(errs...)
+}
+
+func (s *Scanner) syncFile(ctx context.Context, path string, d fs.DirEntry, dirs dirCache, current *service.Content, res *Result) error {
+	info, err := d.Info()
+	if err != nil {
+		return fmt.Errorf("stat media file: %w", err)
+	}
+
+	dir, err := dirs.get(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	// The sidecars and artwork tell whether anything next to the file changed. When the content is
+	// probed, the probe finds the same ones in the same listing.
+	sidecars := dir.Sidecars(path)
+	poster := dir.Poster(path)
+	svc := s.svc.InDir(dir)
+
+	if current == nil {
+		c := &service.Content{
+			Title:   titleFromPath(path),
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Tracks:  service.TracksFromProbe(sidecars),
+			Poster:  poster,
+			Episode: parseEpisode(path),
+		}
+		if err := svc.Create(ctx, c); err != nil {
+			return err
+		}
+		res.Created++
+		return nil
+	}
+
+	// Content that is not an episode yet picks one up from its name, so existing libraries are
+	// sorted into shows. Episode data that is already set, by a scan or by hand, is kept.
+	episode := current.Episode
+	if episode == nil {
+		episode = parseEpisode(path)
+	}
+	// Sidecar subtitle files and artwork come and go without touching the media file.
+	tracks := withSidecars(current.Tracks, sidecars)
+	if current.Size == info.Size() && current.ModTime.Equal(info.ModTime()) && episode == current.Episode &&
+		slices.Equal(tracks, current.Tracks) && poster == current.Poster {
+		res.Unchanged++
+		return nil
+	}
+
+	updated := *current
+	updated.Episode = episode
+	updated.Tracks = tracks
+	updated.Poster = poster
+	if current.Size != info.Size() || !current.ModTime.Equal(info.ModTime()) {
+		updated.Size = info.Size()
+		updated.ModTime = info.ModTime()
+		updated.Length = 0
+	}
+	if err := svc.Update(ctx, &updated); err != nil {
+		return err
+	}
+	res.Updated++
+	return nil
+}
+
+// dirCache holds the listings of the directories on the path being walked, so each directory is
+// read once per scan however many media files it holds, by the scanner and the probe alike. Files of a directory can be visited on
+// both sides of its subdirectories, so its ancestors stay cached while the walk is below them.
+type dirCache map[string]*probe.Dir
+
+func (c dirCache) get(path string) (*probe.Dir, error) {
+	if d, ok := c[path]; ok {
+		return d, nil
+	}
+	for cached := range c {
+		if !strings.HasPrefix(path, cached+string(filepath.Separator)) {
+			delete(c, cached)
+		}
+	}
+	d, err := probe.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	c[path] = d
+	return d, nil
+}
+
+// withSidecars replaces the sidecar tracks among tracks with sidecars, keeping the tracks inside
+// the media file.
+func withSidecars(tracks []service.Track, sidecars []probe.Track) []service.Track {
+	var out []service.Track
+	for _, t := range tracks {
+		if t.Path == "" {
+			out = append(out, t)
+		}
+	}
+	return append(out, service.TracksFromProbe(sidecars)...)
+}
+
+func (s *Scanner) loadExisting(ctx context.Context) (map[string]*service.Content, error) {
+	existing := make(map[string]*service.Content)
+	for offset := 0; ; offset += listPageSize {
+		page, err := s.svc.List(ctx, listPageSize, offset)
+		if err != nil {
+			return nil, fmt.Errorf("load existing content: %w", err)
+		}
+		for i := range page {
+			existing[page[i].Path] = &page[i]
+		}
+		if len(page) < listPageSize {
+			return existing, nil
+		}
+	}
+}
+
+func (s *Scanner) rootFor(path string) (string, bool) {
+	for _, root := range s.roots {
+		if library.WithinRoot(root, path) {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+func isMediaFile(path string) bool {
+	_, ok := mediaExtensions[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+func titleFromPath(path string) string {
+	base := filepath.Base(path)
+	title := strings.TrimSuffix(base, filepath.Ext(base))
+	title = strings.NewReplacer("_", " ", ".", " ").Replace(title)
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return base
+	}
+	return title
+}
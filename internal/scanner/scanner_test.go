@@ -0,0 +1,382 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/probe"
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+type memContentRepo struct {
+	nextID  uint
+	content map[uint]service.Content
+}
+
+func newMemContentRepo() *memContentRepo {
+	return &memContentRepo{content: make(map[uint]service.Content)}
+}
+
+func (r *memContentRepo) Create(_ context.Context, c *service.Content) error {
+	r.nextID++
+	c.ID = r.nextID
+	r.content[c.ID] = *c
+	return nil
+}
+
+func (r *memContentRepo) GetByID(_ context.Context, id uint) (*service.Content, error) {
+	c, ok := r.content[id]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+	return &c, nil
+}
+
+func (r *memContentRepo) List(_ context.Context, limit, offset int) ([]service.Content, error) {
+	ids := make([]uint, 0, len(r.content))
+	for id := range r.content {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var out []service.Content
+	for i := offset; i < len(ids) && len(out) < limit; i++ {
+		out = append(out, r.content[ids[i]])
+	}
+	return out, nil
+}
+
+func (r *memContentRepo) Update(_ context.Context, c *service.Content) error {
+	if _, ok := r.content[c.ID]; !ok {
+		return service.ErrNotFound
+	}
+	r.content[c.ID] = *c
+	return nil
+}
+
+func (r *memContentRepo) Delete(_ context.Context, id uint) error {
+	if _, ok := r.content[id]; !ok {
+		return service.ErrNotFound
+	}
+	delete(r.content, id)
+	return nil
+}
+
+func (r *memContentRepo) byPath(path string) (service.Content, bool) {
+	for _, c := range r.content {
+		if c.Path == path {
+			return c, true
+		}
+	}
+	return service.Content{}, false
+}
+
+type fakeProber struct {
+	durations map[string]time.Duration
+}
+
+func (p fakeProber) Probe(path string) (*probe.Info, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &probe.Info{Container: "mpegts", Size: st.Size(), Duration: p.durations[filepath.Base(path)]}, nil
+}
+
+// listingProber is a fakeProber that counts the directory listings it is handed, and fails when
+// it would have to list a directory itself.
+type listingProber struct {
+	fakeProber
+	listings map[*probe.Dir]int
+}
+
+func (p listingProber) Probe(path string) (*probe.Info, error) {
+	return nil, fmt.Errorf("probe %s without its directory listing", path)
+}
+
+func (p listingProber) ProbeIn(dir *probe.Dir, path string) (*probe.Info, error) {
+	p.listings[dir]++
+	return p.fakeProber.Probe(path)
+}
+
+func writeFile(t *testing.T, path string, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+}
+
+func TestScanCreatesContentForMediaFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "Movie_Night.mp4"), "abc")
+	writeFile(t, filepath.Join(root, "shows", "Episode.TS"), "abcdef")
+	writeFile(t, filepath.Join(root, "notes.txt"), "ignored")
+	writeFile(t, filepath.Join(root, ".hidden", "secret.mkv"), "ignored")
+
+	repo := newMemContentRepo()
+	s := New(service.NewContentService(repo), []string{root}, time.Minute)
+
+	res, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if res.Created != 2 {
+		t.Fatalf("expected 2 created, got %+v", res)
+	}
+
+	movie, ok := repo.byPath(filepath.Join(root, "Movie_Night.mp4"))
+	if !ok {
+		t.Fatalf("expected movie to be registered")
+	}
+	if movie.Title != "Movie Night" || movie.Size != 3 || movie.ModTime.IsZero() {
+		t.Fatalf("unexpected movie content: %+v", movie)
+	}
+	if _, ok := repo.byPath(filepath.Join(root, ".hidden", "secret.mkv")); ok {
+		t.Fatalf("expected hidden directories to be skipped")
+	}
+}
+
+func TestScanSkipsUnchangedAndUpdatesChangedFiles(t *testing.T) {
+	root := t.TempDir()
+	same := filepath.Join(root, "same.mp4")
+	changed := filepath.Join(root, "changed.mkv")
+	writeFile(t, same, "abc")
+	writeFile(t, changed, "abc")
+
+	repo := newMemContentRepo()
+	s := New(service.NewContentService(repo), []string{root}, time.Minute)
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+
+	c, _ := repo.byPath(changed)
+	c.Title = "Renamed By Hand"
+	repo.content[c.ID] = c
+
+	writeFile(t, changed, "abcdefgh")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(changed, future, future); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	res, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("second scan: %v", err)
+	}
+	if res.Unchanged != 1 || res.Updated != 1 || res.Created != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	got, _ := repo.byPath(changed)
+	if got.Size != 8 || !got.ModTime.Equal(future) {
+		t.Fatalf("expected size and mtime to be refreshed, got %+v", got)
+	}
+	if got.Title != "Renamed By Hand" {
+		t.Fatalf("expected title to be preserved, got %q", got.Title)
+	}
+}
+
+func TestScanRemovesMissingFilesUnderRootsOnly(t *testing.T) {
+	root := t.TempDir()
+	gone := filepath.Join(root, "gone.mp4")
+	writeFile(t, gone, "abc")
+
+	repo := newMemContentRepo()
+	outside := &service.Content{Title: "manual", Path: "/elsewhere/manual.mp4"}
+	if err := repo.Create(context.Background(), outside); err != nil {
+		t.Fatalf("seed content: %v", err)
+	}
+
+	s := New(service.NewContentService(repo), []string{root}, time.Minute)
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatalf("remove file: %v", err)
+	}
+
+	res, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("second scan: %v", err)
+	}
+	if res.Removed != 1 {
+		t.Fatalf("expected 1 removed, got %+v", res)
+	}
+	if _, ok := repo.byPath(gone); ok {
+		t.Fatalf("expected missing file to be removed")
+	}
+	if _, ok := repo.content[outside.ID]; !ok {
+		t.Fatalf("expected content outside scan roots to be kept")
+	}
+}
+
+func TestScanKeepsContentWhenRootIsUnavailable(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.mp4"), "abc")
+
+	repo := newMemContentRepo()
+	s := New(service.NewContentService(repo), []string{root}, time.Minute)
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatalf("remove root: %v", err)
+	}
+
+	res, err := s.Scan(context.Background())
+	if err == nil {
+		t.Fatalf("expected error for missing root")
+	}
+	if res.Removed != 0 || len(repo.content) != 1 {
+		t.Fatalf("expected content to be kept when root is unavailable, got %+v", res)
+	}
+}
+
+func TestScanProbesNewAndChangedFiles(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "show.ts")
+	writeFile(t, path, "abc")
+
+	prober := fakeProber{durations: map[string]time.Duration{"show.ts": 30 * time.Second}}
+	repo := newMemContentRepo()
+	s := New(service.NewContentService(repo, service.WithProber(prober)), []string{root}, time.Minute)
+
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+	got, _ := repo.byPath(path)
+	if got.Length != 30 || got.Container != "mpegts" {
+		t.Fatalf("expected probed length and container, got %+v", got)
+	}
+
+	prober.durations["show.ts"] = 45 * time.Second
+	writeFile(t, path, "abcdef")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("second scan: %v", err)
+	}
+	got, _ = repo.byPath(path)
+	if got.Length != 45 || got.Size != 6 {
+		t.Fatalf("expected re-probed length and size, got %+v", got)
+	}
+}
+
+func TestScanProbesWithTheDirectoryListingItRead(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.ts", "b.ts"} {
+		writeFile(t, filepath.Join(root, name), "abc")
+	}
+
+	prober := listingProber{listings: map[*probe.Dir]int{}}
+	repo := newMemContentRepo()
+	s := New(service.NewContentService(repo, service.WithProber(prober)), []string{root}, time.Minute)
+	res, err := s.Scan(context.Background())
+	if err != nil || res.Created != 2 {
+		t.Fatalf("expected two files created, got %+v (%v)", res, err)
+	}
+	if len(prober.listings) != 1 {
+		t.Fatalf("expected both files probed with one listing, got %d", len(prober.listings))
+	}
+}
+
+func TestScanPicksUpSidecarSubtitles(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "movie.mkv")
+	writeFile(t, path, "abc")
+
+	repo := newMemContentRepo()
+	s := New(service.NewContentService(repo), []string{root}, time.Minute)
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+
+	sidecar := filepath.Join(root, "movie.eng.srt")
+	writeFile(t, sidecar, "1\n00:00:01,000 --> 00:00:02,000\nHello\n")
+	res, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("second scan: %v", err)
+	}
+	if res.Updated != 1 {
+		t.Fatalf("expected a new sidecar to update the content, got %+v", res)
+	}
+	got, _ := repo.byPath(path)
+	want := service.Track{Kind: service.TrackSubtitle, Codec: "subrip", Language: "en", Path: sidecar}
+	if len(got.Tracks) != 1 || got.Tracks[0] != want {
+		t.Fatalf("expected sidecar track %+v, got %+v", want, got.Tracks)
+	}
+
+	if res, err = s.Scan(context.Background()); err != nil || res.Unchanged != 1 {
+		t.Fatalf("expected an unchanged rescan, got %+v (%v)", res, err)
+	}
+}
+
+func TestScanPicksUpArtwork(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "movie.mkv")
+	writeFile(t, path, "abc")
+	folder := filepath.Join(root, "folder.jpg")
+	writeFile(t, folder, "jpeg")
+
+	repo := newMemContentRepo()
+	s := New(service.NewContentService(repo), []string{root}, time.Minute)
+	if _, err := s.Scan(context.Background()); err != nil {
+		t.Fatalf("first scan: %v", err)
+	}
+	if got, _ := repo.byPath(path); got.Poster != folder {
+		t.Fatalf("expected poster %q, got %q", folder, got.Poster)
+	}
+
+	poster := filepath.Join(root, "movie-poster.jpg")
+	writeFile(t, poster, "jpeg")
+	res, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("second scan: %v", err)
+	}
+	if got, _ := repo.byPath(path); res.Updated != 1 || got.Poster != poster {
+		t.Fatalf("expected the file's own poster to replace the folder's, got %+v and %q", res, got.Poster)
+	}
+
+	if res, err = s.Scan(context.Background()); err != nil || res.Unchanged != 1 {
+		t.Fatalf("expected an unchanged rescan, got %+v (%v)", res, err)
+	}
+}
+
+func TestDirCacheKeepsAncestorsOfTheWalk(t *testing.T) {
+	root := t.TempDir()
+	a, sub, b := filepath.Join(root, "a"), filepath.Join(root, "a", "sub"), filepath.Join(root, "b")
+	for _, dir := range []string{sub, b} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+
+	c := dirCache{}
+	get := func(path string) *probe.Dir {
+		t.Helper()
+		d, err := c.get(path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		return d
+	}
+	first := get(a)
+	get(sub)
+	if get(a) != first {
+		t.Fatalf("expected the listing of a to be reused after visiting its subdirectory")
+	}
+	get(b)
+	if len(c) != 1 || c[b] == nil {
+		t.Fatalf("expected only b to stay cached, got %d listings", len(c))
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/probe"
 )
 
 type Channel struct {
@@ -11,6 +14,43 @@ type Channel struct {
 	Title         string `json:"title"`
 	ChannelNumber uint   `json:"channelNumber"`
 	Description   string `json:"description"`
+	Loop          bool   `json:"loop"`
+	Shuffle       bool   `json:"shuffle"`
+	// Profile names the transcode profile the channel is played through. Empty plays files as
+	// they are.
+	Profile string `json:"profile"`
+	// Timezone is the IANA zone schedule blocks are timed in. Empty means UTC.
+	Timezone string `json:"timezone"`
+	// PadTo, in minutes, pads the break after each programme so the next one starts on the hour
+	// or a fraction of it. Zero inserts only the filler pools' minimum breaks.
+	PadTo int `json:"padTo"`
+	// AudioLanguage and SubtitleLanguage are the language tags, such as en, the channel prefers
+	// for audio and subtitle tracks. Empty leaves audio to the file's default and subtitles off.
+	AudioLanguage    string `json:"audioLanguage"`
+	SubtitleLanguage string `json:"subtitleLanguage"`
+	// HasLogo reports whether a logo was uploaded for the channel. It is filled in when the
+	// channel is loaded and ignored when saving.
+	HasLogo bool `json:"hasLogo"`
+}
+
+// Plays reports whether the channel can stream content. Files are remuxed as they are rather than
+// demuxed, so without a transcode profile only MPEG transport streams play. Content in a container
+// the probe did not recognise is let through.
+func (c Channel) Plays(content *Content) bool {
+	return c.Profile != "" || content.Container == "" || content.Container == probe.ContainerMPEGTS
+}
+
+// checkPlays returns a validation error unless ch can stream content.
+func checkPlays(ch *Channel, content *Content) error {
+	if ch.Plays(content) {
+		return nil
+	}
+	return ErrValidation(fmt.Sprintf("content %d is %s, and a channel without a transcode profile only plays mpegts", content.ID, content.Container))
+}
+
+// Location returns the time zone the channel's schedule blocks are timed in.
+func (c Channel) Location() (*time.Location, error) {
+	return time.LoadLocation(c.Timezone)
 }
 
 type ChannelRepo interface {
@@ -21,16 +61,33 @@ type ChannelRepo interface {
 	Delete(ctx context.Context, id uint) error
 }
 
+type ChannelOption func(*ChannelService)
+
+// WithProfiles names the transcode profiles channels may pick. Without it, channels cannot have a
+// profile.
+func WithProfiles(names ...string) ChannelOption {
+	return func(s *ChannelService) {
+		for _, name := range names {
+			s.profiles[name] = true
+		}
+	}
+}
+
 type ChannelService struct {
-	repo ChannelRepo
+	repo     ChannelRepo
+	profiles map[string]bool
 }
 
-func NewChannelService(repo ChannelRepo) *ChannelService {
-	return &ChannelService{repo: repo}
+func NewChannelService(repo ChannelRepo, opts ...ChannelOption) *ChannelService {
+	s := &ChannelService{repo: repo, profiles: map[string]bool{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *ChannelService) Create(ctx context.Context, c *Channel) error {
-	if err := validateChannel(c); err != nil {
+	if err := s.validate(c); err != nil {
 		return err
 	}
 	if err := s.repo.Create(ctx, c); err != nil {
@@ -55,11 +112,27 @@ func (s *ChannelService) List(ctx context.Context, limit, offset int) ([]Channel
 	return channels, nil
 }
 
+// All returns every channel in ID order, paging through the repo.
+func (s *ChannelService) All(ctx context.Context) ([]Channel, error) {
+	const pageSize = 500
+	var all []Channel
+	for offset := 0; ; offset += pageSize {
+		page, err := s.List(ctx, pageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < pageSize {
+			return all, nil
+		}
+	}
+}
+
 func (s *ChannelService) Update(ctx context.Context, c *Channel) error {
 	if c == nil || c.ID == 0 {
 		return ErrValidation("id must be greater than zero")
 	}
-	if err := validateChannel(c); err != nil {
+	if err := s.validate(c); err != nil {
 		return err
 	}
 	if err := s.repo.Update(ctx, c); err != nil {
@@ -75,6 +148,16 @@ func (s *ChannelService) Delete(ctx context.Context, id uint) error {
 	return nil
 }
 
+func (s *ChannelService) validate(c *Channel) error {
+	if err := validateChannel(c); err != nil {
+		return err
+	}
+	if c.Profile != "" && !s.profiles[c.Profile] {
+		return ErrValidation(fmt.Sprintf("unknown profile %q", c.Profile))
+	}
+	return nil
+}
+
 func validateChannel(c *Channel) error {
 	if c == nil {
 		return ErrValidation("channel is required")
@@ -85,5 +168,15 @@ func validateChannel(c *Channel) error {
 	if c.ChannelNumber == 0 {
 		return ErrValidation("channel number must be greater than zero")
 	}
-	return nil
+	c.Timezone = strings.TrimSpace(c.Timezone)
+	if _, err := c.Location(); err != nil || strings.EqualFold(c.Timezone, "local") {
+		return ErrValidation(fmt.Sprintf("unknown timezone %q", c.Timezone))
+	}
+	if c.PadTo < 0 || c.PadTo > 60 || (c.PadTo > 0 && 60%c.PadTo != 0) {
+		return ErrValidation("pad to must be zero or a number of minutes dividing an hour")
+	}
+	if err := validateLanguage("audio language", &c.AudioLanguage); err != nil {
+		return err
+	}
+	return validateLanguage("subtitle language", &c.SubtitleLanguage)
 }
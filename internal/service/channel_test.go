@@ -73,12 +73,20 @@ func TestChannelServiceCreateValidatesInput(t *testing.T) {
 		{name: "empty title", in: &Channel{Title: "", ChannelNumber: 1, Description: "desc"}},
 		{name: "whitespace title", in: &Channel{Title: " ", ChannelNumber: 1, Description: "desc"}},
 		{name: "zero channel number", in: &Channel{Title: "ABC", ChannelNumber: 0, Description: "desc"}},
+		{name: "unknown profile", in: &Channel{Title: "ABC", ChannelNumber: 1, Profile: "4k"}},
+		{name: "unknown timezone", in: &Channel{Title: "ABC", ChannelNumber: 1, Timezone: "Mars/Olympus"}},
+		{name: "local timezone", in: &Channel{Title: "ABC", ChannelNumber: 1, Timezone: "Local"}},
+		{name: "negative pad", in: &Channel{Title: "ABC", ChannelNumber: 1, PadTo: -30}},
+		{name: "pad not dividing an hour", in: &Channel{Title: "ABC", ChannelNumber: 1, PadTo: 25}},
+		{name: "pad over an hour", in: &Channel{Title: "ABC", ChannelNumber: 1, PadTo: 120}},
+		{name: "invalid audio language", in: &Channel{Title: "ABC", ChannelNumber: 1, AudioLanguage: "English!"}},
+		{name: "invalid subtitle language", in: &Channel{Title: "ABC", ChannelNumber: 1, SubtitleLanguage: "e"}},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			repo := &stubChannelRepo{}
-			svc := NewChannelService(repo)
+			svc := NewChannelService(repo, WithProfiles("720p"))
 
 			err := svc.Create(context.Background(), tc.in)
 			var ve ValidationError
@@ -109,6 +117,55 @@ func TestChannelServiceCreateCallsRepoForValidInput(t *testing.T) {
 	}
 }
 
+func TestChannelServiceCreateAcceptsTimezone(t *testing.T) {
+	repo := &stubChannelRepo{}
+	svc := NewChannelService(repo)
+
+	ch := &Channel{Title: "ABC", ChannelNumber: 7, Timezone: " Europe/London "}
+	if err := svc.Create(context.Background(), ch); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if ch.Timezone != "Europe/London" {
+		t.Fatalf("expected trimmed timezone, got %q", ch.Timezone)
+	}
+}
+
+func TestChannelServiceCreateCanonicalizesLanguages(t *testing.T) {
+	repo := &stubChannelRepo{}
+	svc := NewChannelService(repo)
+
+	ch := &Channel{Title: "ABC", ChannelNumber: 7, AudioLanguage: " ENG ", SubtitleLanguage: "fre_CA"}
+	if err := svc.Create(context.Background(), ch); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if ch.AudioLanguage != "en" || ch.SubtitleLanguage != "fr-CA" {
+		t.Fatalf("expected en and fr-CA, got %q and %q", ch.AudioLanguage, ch.SubtitleLanguage)
+	}
+}
+
+func TestChannelServiceCreateAcceptsKnownProfile(t *testing.T) {
+	repo := &stubChannelRepo{}
+	svc := NewChannelService(repo, WithProfiles("720p", "1080p"))
+
+	err := svc.Create(context.Background(), &Channel{Title: "ABC", ChannelNumber: 7, Profile: "1080p"})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !repo.createCalled {
+		t.Fatalf("expected repo create to be called")
+	}
+}
+
+func TestChannelServiceRejectsProfilesWhenNoneConfigured(t *testing.T) {
+	svc := NewChannelService(&stubChannelRepo{})
+
+	err := svc.Update(context.Background(), &Channel{ID: 1, Title: "ABC", ChannelNumber: 7, Profile: "720p"})
+	var ve ValidationError
+	if !errors.As(err, &ve) || !strings.Contains(err.Error(), "720p") {
+		t.Fatalf("expected validation error naming the profile, got %v", err)
+	}
+}
+
 func TestChannelServiceCreateWrapsRepoError(t *testing.T) {
 	repoErr := errors.New("db create failed")
 	repo := &stubChannelRepo{createErr: repoErr}
@@ -329,3 +386,21 @@ func TestChannelServiceDeleteWrapsRepoError(t *testing.T) {
 		t.Fatalf("expected contextual message, got %v", err)
 	}
 }
+
+func TestChannelServiceAllReturnsEveryChannel(t *testing.T) {
+	repo := &stubChannelRepo{listChannels: []Channel{{ID: 1}, {ID: 2}}}
+	svc := NewChannelService(repo)
+
+	got, err := svc.All(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if len(got) != 2 || repo.gotOffset != 0 {
+		t.Fatalf("expected 2 channels from a single page, got %d (offset %d)", len(got), repo.gotOffset)
+	}
+
+	repo.listErr = errors.New("db list failed")
+	if _, err := svc.All(context.Background()); !errors.Is(err, repo.listErr) {
+		t.Fatalf("expected wrapped repo error, got %v", err)
+	}
+}
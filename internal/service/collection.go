@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// maxTagLength is the longest tag name, in bytes.
+const maxTagLength = 64
+
+// Collection is a named group of content. A manual collection lists its content by ID; a smart
+// collection has a Query instead and holds whatever content matches it at the time it is used.
+type Collection struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	// ContentIDs is the content of a manual collection, in order. It is empty for a smart one.
+	ContentIDs []uint `json:"contentIds"`
+	// Query makes the collection smart.
+	Query *CollectionQuery `json:"query,omitempty"`
+}
+
+// CollectionQuery selects content for a smart collection. Content must match every condition
+// that is set.
+type CollectionQuery struct {
+	// Tag matches content carrying the tag.
+	Tag string `json:"tag,omitempty"`
+	// PathPrefix matches content whose path starts with it.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// MinLength and MaxLength bound the content's length in seconds.
+	MinLength float64 `json:"minLength,omitempty"`
+	MaxLength float64 `json:"maxLength,omitempty"`
+	// Title matches content whose title contains it, ignoring case.
+	Title string `json:"title,omitempty"`
+	// ShowID matches the show's episodes. A collection with a show plays in episode order.
+	ShowID uint `json:"showId,omitempty"`
+}
+
+type CollectionRepo interface {
+	List(ctx context.Context) ([]Collection, error)
+	GetByID(ctx context.Context, id uint) (*Collection, error)
+	Create(ctx context.Context, c *Collection) error
+	Update(ctx context.Context, c *Collection) error
+	// Delete removes the collection and the playlist items that play it, and detaches it from
+	// schedule blocks.
+	Delete(ctx context.Context, id uint) error
+	// Members returns the content in c: a manual collection's in its order, and a smart
+	// collection's matches in episode order when it selects a show and by path otherwise.
+	Members(ctx context.Context, c *Collection) ([]Content, error)
+}
+
+type TagRepo interface {
+	// SetTags replaces the tags on a content item.
+	SetTags(ctx context.Context, contentID uint, tags []string) error
+}
+
+// CollectionService manages content tags and collections.
+type CollectionService struct {
+	content     ContentRepo
+	collections CollectionRepo
+	tags        TagRepo
+}
+
+func NewCollectionService(content ContentRepo, collections CollectionRepo, tags TagRepo) *CollectionService {
+	return &CollectionService{content: content, collections: collections, tags: tags}
+}
+
+// SetTags replaces the tags on a content item and returns the item. Tags are trimmed, lower-cased
+// and deduplicated.
+func (s *CollectionService) SetTags(ctx context.Context, contentID uint, tags []string) (*Content, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tags.SetTags(ctx, contentID, tags); err != nil {
+		return nil, fmt.Errorf("set tags: %w", err)
+	}
+	c, err := s.content.GetByID(ctx, contentID)
+	if err != nil {
+		return nil, fmt.Errorf("get content by id: %w", err)
+	}
+	return c, nil
+}
+
+func (s *CollectionService) List(ctx context.Context) ([]Collection, error) {
+	collections, err := s.collections.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list collections: %w", err)
+	}
+	return collections, nil
+}
+
+func (s *CollectionService) Get(ctx context.Context, id uint) (*Collection, error) {
+	c, err := s.collections.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get collection by id: %w", err)
+	}
+	return c, nil
+}
+
+// Content returns the content a collection holds right now.
+func (s *CollectionService) Content(ctx context.Context, id uint) ([]Content, error) {
+	c, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.collections.Members(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("list collection content: %w", err)
+	}
+	return members, nil
+}
+
+func (s *CollectionService) Create(ctx context.Context, c *Collection) error {
+	if err := s.validate(ctx, c); err != nil {
+		return err
+	}
+	if err := s.collections.Create(ctx, c); err != nil {
+		return fmt.Errorf("create collection: %w", err)
+	}
+	return nil
+}
+
+func (s *CollectionService) Update(ctx context.Context, c *Collection) error {
+	if c == nil || c.ID == 0 {
+		return ErrValidation("id must be greater than zero")
+	}
+	if err := s.validate(ctx, c); err != nil {
+		return err
+	}
+	if err := s.collections.Update(ctx, c); err != nil {
+		return fmt.Errorf("update collection: %w", err)
+	}
+	return nil
+}
+
+func (s *CollectionService) Delete(ctx context.Context, id uint) error {
+	if err := s.collections.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete collection: %w", err)
+	}
+	return nil
+}
+
+func (s *CollectionService) validate(ctx context.Context, c *Collection) error {
+	if c == nil {
+		return ErrValidation("collection is required")
+	}
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return ErrValidation("name is required")
+	}
+	if c.Query != nil {
+		if len(c.ContentIDs) > 0 {
+			return ErrValidation("a collection has either content ids or a query, not both")
+		}
+		return validateQuery(c.Query)
+	}
+	if c.ContentIDs == nil {
+		c.ContentIDs = []uint{}
+	}
+	for _, id := range c.ContentIDs {
+		if _, err := s.content.GetByID(ctx, id); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return ErrValidation(fmt.Sprintf("content %d does not exist", id))
+			}
+			return fmt.Errorf("get content by id: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateQuery(q *CollectionQuery) error {
+	q.PathPrefix = strings.TrimSpace(q.PathPrefix)
+	q.Title = strings.TrimSpace(q.Title)
+	if q.Tag != "" {
+		tags, err := normalizeTags([]string{q.Tag})
+		if err != nil {
+			return err
+		}
+		q.Tag = tags[0]
+	}
+	if q.MinLength < 0 || q.MaxLength < 0 {
+		return ErrValidation("query lengths must be non-negative")
+	}
+	if q.MaxLength > 0 && q.MaxLength < q.MinLength {
+		return ErrValidation("query max length must be at least min length")
+	}
+	if *q == (CollectionQuery{}) {
+		return ErrValidation("query must set at least one condition")
+	}
+	return nil
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			return nil, ErrValidation("tags must not be empty")
+		}
+		if len(t) > maxTagLength {
+			return nil, ErrValidation(fmt.Sprintf("tag %q is longer than %d bytes", t, maxTagLength))
+		}
+		out = append(out, t)
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// checkCollection returns a validation error unless the collection exists in repo, which is nil
+// when collections are not available.
+func checkCollection(ctx context.Context, repo CollectionRepo, id uint) error {
+	if repo == nil {
+		return ErrValidation("collections are not available")
+	}
+	if _, err := repo.GetByID(ctx, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrValidation(fmt.Sprintf("collection %d does not exist", id))
+		}
+		return fmt.Errorf("get collection by id: %w", err)
+	}
+	return nil
+}
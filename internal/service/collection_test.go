@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubCollectionRepo struct {
+	collections []Collection
+	members     map[uint][]Content
+	created     *Collection
+	updated     *Collection
+}
+
+func (s *stubCollectionRepo) List(context.Context) ([]Collection, error) {
+	return s.collections, nil
+}
+
+func (s *stubCollectionRepo) GetByID(_ context.Context, id uint) (*Collection, error) {
+	for _, c := range s.collections {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *stubCollectionRepo) Create(_ context.Context, c *Collection) error {
+	c.ID = 100
+	s.created = c
+	return nil
+}
+
+func (s *stubCollectionRepo) Update(_ context.Context, c *Collection) error {
+	if _, err := s.GetByID(context.Background(), c.ID); err != nil {
+		return err
+	}
+	s.updated = c
+	return nil
+}
+
+func (s *stubCollectionRepo) Delete(context.Context, uint) error {
+	return nil
+}
+
+func (s *stubCollectionRepo) Members(_ context.Context, c *Collection) ([]Content, error) {
+	return s.members[c.ID], nil
+}
+
+type stubTagRepo struct {
+	contentID uint
+	tags      []string
+	err       error
+}
+
+func (s *stubTagRepo) SetTags(_ context.Context, contentID uint, tags []string) error {
+	s.contentID = contentID
+	s.tags = tags
+	return s.err
+}
+
+func TestCollectionServiceCreateValidatesInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      Collection
+		content *stubRepo
+		wantErr string
+	}{
+		{name: "no name", in: Collection{Name: " "}, wantErr: "name is required"},
+		{name: "ids and query", in: Collection{Name: "a", ContentIDs: []uint{1}, Query: &CollectionQuery{Tag: "x"}}, wantErr: "not both"},
+		{name: "empty query", in: Collection{Name: "a", Query: &CollectionQuery{PathPrefix: " "}}, wantErr: "at least one condition"},
+		{name: "negative length", in: Collection{Name: "a", Query: &CollectionQuery{MinLength: -1}}, wantErr: "non-negative"},
+		{name: "max below min", in: Collection{Name: "a", Query: &CollectionQuery{MinLength: 60, MaxLength: 30}}, wantErr: "max length"},
+		{name: "long tag", in: Collection{Name: "a", Query: &CollectionQuery{Tag: strings.Repeat("x", 65)}}, wantErr: "longer than"},
+		{name: "missing content", in: Collection{Name: "a", ContentIDs: []uint{4}}, content: &stubRepo{getByIDErr: ErrNotFound}, wantErr: "content 4 does not exist"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content := tc.content
+			if content == nil {
+				content = &stubRepo{}
+			}
+			collections := &stubCollectionRepo{}
+			err := NewCollectionService(content, collections, &stubTagRepo{}).Create(context.Background(), &tc.in)
+			var ve ValidationError
+			if !errors.As(err, &ve) || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected validation error containing %q, got %v", tc.wantErr, err)
+			}
+			if collections.created != nil {
+				t.Fatal("expected nothing to be created")
+			}
+		})
+	}
+}
+
+func TestCollectionServiceCreateAndUpdate(t *testing.T) {
+	collections := &stubCollectionRepo{collections: []Collection{{ID: 1, Name: "Cartoons"}}}
+	svc := NewCollectionService(&stubRepo{}, collections, &stubTagRepo{})
+
+	c := &Collection{Name: " Cartoons ", Query: &CollectionQuery{Tag: " Kids ", PathPrefix: " /media/cartoons/ "}}
+	if err := svc.Create(context.Background(), c); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if collections.created != c || c.Name != "Cartoons" || c.Query.Tag != "kids" || c.Query.PathPrefix != "/media/cartoons/" {
+		t.Fatalf("expected a normalised collection to be created, got %+v %+v", collections.created, c.Query)
+	}
+	if err := svc.Update(context.Background(), &Collection{ID: 1, Name: "Shorts", ContentIDs: []uint{2}}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if collections.updated == nil || collections.updated.Name != "Shorts" {
+		t.Fatalf("expected collection to be updated, got %+v", collections.updated)
+	}
+	if err := svc.Update(context.Background(), &Collection{ID: 2, Name: "Shorts"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown collection, got %v", err)
+	}
+}
+
+func TestCollectionServiceSetTagsNormalises(t *testing.T) {
+	tags := &stubTagRepo{}
+	svc := NewCollectionService(&stubRepo{getContent: &Content{ID: 3}}, &stubCollectionRepo{}, tags)
+
+	if _, err := svc.SetTags(context.Background(), 3, []string{" Kids", "cartoon", "KIDS"}); err != nil {
+		t.Fatalf("set tags: %v", err)
+	}
+	if tags.contentID != 3 || !slices.Equal(tags.tags, []string{"cartoon", "kids"}) {
+		t.Fatalf("expected sorted, deduplicated tags on content 3, got %d %v", tags.contentID, tags.tags)
+	}
+
+	_, err := svc.SetTags(context.Background(), 3, []string{""})
+	var ve ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected a validation error for an empty tag, got %v", err)
+	}
+
+	tags.err = ErrNotFound
+	if _, err := svc.SetTags(context.Background(), 4, nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unknown content, got %v", err)
+	}
+}
+
+func TestPlaylistServiceAddCollection(t *testing.T) {
+	collections := &stubCollectionRepo{collections: []Collection{{ID: 5, Name: "Cartoons"}}}
+	items := &stubChannelItemRepo{}
+	svc := NewPlaylistService(&stubChannelRepo{}, &stubRepo{}, items, WithPlaylistCollections(collections))
+
+	item := &ChannelItem{ChannelID: 1, CollectionID: 5}
+	if err := svc.Add(context.Background(), item); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if items.appended != item || item.Content != nil {
+		t.Fatalf("expected the collection item to be appended without content, got %+v", items.appended)
+	}
+
+	tests := []struct {
+		name    string
+		svc     *PlaylistService
+		item    *ChannelItem
+		wantMsg string
+	}{
+		{name: "both", svc: svc, item: &ChannelItem{ChannelID: 1, ContentID: 2, CollectionID: 5}, wantMsg: "not both"},
+		{name: "missing collection", svc: svc, item: &ChannelItem{ChannelID: 1, CollectionID: 6}, wantMsg: "collection 6 does not exist"},
+		{name: "without collections", svc: NewPlaylistService(&stubChannelRepo{}, &stubRepo{}, items), item: &ChannelItem{ChannelID: 1, CollectionID: 5}, wantMsg: "not available"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.svc.Add(context.Background(), tc.item)
+			var ve ValidationError
+			if !errors.As(err, &ve) || !strings.Contains(err.Error(), tc.wantMsg) {
+				t.Fatalf("expected validation error containing %q, got %v", tc.wantMsg, err)
+			}
+		})
+	}
+}
+
+func TestScheduleServiceCreateWithCollection(t *testing.T) {
+	collections := &stubCollectionRepo{collections: []Collection{{ID: 5, Name: "Cartoons"}}}
+	blocks := &stubScheduleBlockRepo{}
+	svc := NewScheduleService(&stubChannelRepo{}, &stubRepo{}, blocks, WithScheduleCollections(collections))
+
+	b := &ScheduleBlock{ChannelID: 1, Start: "06:00", End: "09:00", CollectionID: 5}
+	if err := svc.Create(context.Background(), b); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	tests := []struct {
+		name    string
+		block   ScheduleBlock
+		wantMsg string
+	}{
+		{name: "both", block: ScheduleBlock{ContentIDs: []uint{1}, CollectionID: 5}, wantMsg: "not both"},
+		{name: "missing collection", block: ScheduleBlock{CollectionID: 6}, wantMsg: "collection 6 does not exist"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.block.ChannelID, tc.block.Start, tc.block.End = 1, "10:00", "11:00"
+			err := svc.Create(context.Background(), &tc.block)
+			var ve ValidationError
+			if !errors.As(err, &ve) || !strings.Contains(err.Error(), tc.wantMsg) {
+				t.Fatalf("expected validation error containing %q, got %v", tc.wantMsg, err)
+			}
+		})
+	}
+}
+
+func TestTimelineServicePlaysCollections(t *testing.T) {
+	collections := &stubCollectionRepo{
+		collections: []Collection{{ID: 5, Name: "Cartoons"}, {ID: 6, Name: "Mornings"}},
+		members: map[uint][]Content{
+			5: {testContent(30, 5), testContent(31, 5)},
+			6: {testContent(40, 20)},
+		},
+	}
+	items := testItems(600)
+	items = append(items, ChannelItem{ID: 2, ChannelID: 1, CollectionID: 5, Position: 1})
+	block := ScheduleBlock{ID: 7, Start: "06:00", End: "07:00", CollectionID: 6}
+	ch := Channel{ID: 1, Loop: true}
+	svc := NewTimelineService(&stubChannelRepo{getChannel: &ch}, &stubChannelItemRepo{items: items}, testEpoch,
+		WithSchedule(&stubScheduleBlockRepo{blocks: []ScheduleBlock{block}}), WithCollections(collections))
+
+	airings, err := svc.Schedule(context.Background(), 1, testEpoch, testEpoch.Add(20*time.Minute))
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	var got []uint
+	for _, a := range airings {
+		got = append(got, a.Item.ContentID)
+	}
+	if want := []uint{1, 30, 31}; !slices.Equal(got, want) {
+		t.Fatalf("expected the collection to play after item 1, got %v", got)
+	}
+	if airings[1].Item.ID != 2 || airings[1].Item.CollectionID != 5 {
+		t.Fatalf("expected collection airings to keep their playlist item, got %+v", airings[1].Item)
+	}
+
+	a, err := svc.At(context.Background(), 1, testEpoch.Add(6*time.Hour+time.Minute))
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if a.BlockID != 7 || a.Item.ContentID != 40 {
+		t.Fatalf("expected the block to play its collection, got %+v", a)
+	}
+}
@@ -3,15 +3,45 @@ package service
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/probe"
 )
 
+// minLengthTolerance is the smallest difference, in seconds, allowed between a client-supplied
+// length and the probed duration. Longer files allow up to 1% difference.
+const minLengthTolerance = 1.0
+
+// maxSearchQueryLen is the longest search query accepted, in bytes.
+const maxSearchQueryLen = 256
+
 type Content struct {
-	ID     uint    `json:"id"`
-	Title  string  `json:"title"`
-	Path   string  `json:"path"`
-	Size   int64   `json:"size"`
-	Length float64 `json:"length"`
+	ID         uint      `json:"id"`
+	Title      string    `json:"title"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Length     float64   `json:"length"`
+	ModTime    time.Time `json:"modTime"`
+	Container  string    `json:"container"`
+	VideoCodec string    `json:"videoCodec"`
+	AudioCodec string    `json:"audioCodec"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	Bitrate    int64     `json:"bitrate"`
+	// Tracks are the audio and subtitle tracks read from the media file and the sidecar subtitle
+	// files next to it. Updating content replaces them.
+	Tracks []Track `json:"tracks,omitempty"`
+	// Poster is the artwork image found next to the media file. Like tracks, it is read from the
+	// file's directory.
+	Poster string `json:"poster,omitempty"`
+	// Episode places the content in a show, or is nil for content that is not an episode.
+	// Updating content replaces it.
+	Episode *Episode `json:"episode,omitempty"`
+	// Tags are set through CollectionService.SetTags; creating or updating content leaves them
+	// alone.
+	Tags []string `json:"tags,omitempty"`
 }
 
 type ContentRepo interface {
@@ -22,18 +52,92 @@ type ContentRepo interface {
 	Delete(ctx context.Context, id uint) error
 }
 
+// SearchResult is content matching a search query.
+type SearchResult struct {
+	Content
+	// Snippet is an excerpt of the text that matched, with the matching words wrapped in <mark>
+	// tags. The excerpt itself is not HTML-escaped.
+	Snippet string `json:"snippet"`
+}
+
+// ContentSearcher finds content by the words in its title, path, tags and episode details.
+type ContentSearcher interface {
+	Search(ctx context.Context, query string, limit, offset int) ([]SearchResult, error)
+}
+
+// Prober inspects the media file at a path.
+type Prober interface {
+	Probe(path string) (*probe.Info, error)
+}
+
+// DirProber is a Prober that can be handed the listing of the file's directory, so that probing
+// many files in one directory lists it once.
+type DirProber interface {
+	Prober
+	ProbeIn(dir *probe.Dir, path string) (*probe.Info, error)
+}
+
+// dirProber probes files in one directory with its listing read beforehand.
+type dirProber struct {
+	DirProber
+	dir *probe.Dir
+}
+
+func (p dirProber) Probe(path string) (*probe.Info, error) {
+	return p.ProbeIn(p.dir, path)
+}
+
+type ContentOption func(*ContentService)
+
+// WithProber makes the service read size, duration and stream details from the file at Content.Path
+// on create and update. Client-supplied size and length must then agree with the file.
+func WithProber(p Prober) ContentOption {
+	return func(s *ContentService) {
+		s.prober = p
+	}
+}
+
+// WithSearch lets the service search content.
+func WithSearch(searcher ContentSearcher) ContentOption {
+	return func(s *ContentService) {
+		s.searcher = searcher
+	}
+}
+
 type ContentService struct {
-	repo ContentRepo
+	repo     ContentRepo
+	prober   Prober
+	searcher ContentSearcher
+}
+
+func NewContentService(repo ContentRepo, opts ...ContentOption) *ContentService {
+	s := &ContentService{repo: repo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func NewContentService(repo ContentRepo) *ContentService {
-	return &ContentService{repo: repo}
+// InDir returns the service probing files with dir, the listing of the directory holding the
+// content it is given, rather than listing the directory again for each file. The scanner uses it
+// to read each directory once per scan. Without a DirProber the service is returned as it is.
+func (s *ContentService) InDir(dir *probe.Dir) *ContentService {
+	p, ok := s.prober.(DirProber)
+	if !ok {
+		return s
+	}
+	in := *s
+	in.prober = dirProber{DirProber: p, dir: dir}
+	return &in
 }
 
 func (s *ContentService) Create(ctx context.Context, c *Content) error {
 	if err := validateContent(c); err != nil {
 		return err
 	}
+	if err := s.applyProbe(c); err != nil {
+		return err
+	}
 	if err := s.repo.Create(ctx, c); err != nil {
 		return fmt.Errorf("create content: %w", err)
 	}
@@ -56,6 +160,25 @@ func (s *ContentService) List(ctx context.Context, limit, offset int) ([]Content
 	return contents, nil
 }
 
+// Search finds the content matching every word of query, best matches first.
+func (s *ContentService) Search(ctx context.Context, query string, limit, offset int) ([]SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrValidation("search query is required")
+	}
+	if len(query) > maxSearchQueryLen {
+		return nil, ErrValidation(fmt.Sprintf("search query must be at most %d bytes", maxSearchQueryLen))
+	}
+	if s.searcher == nil {
+		return nil, fmt.Errorf("search content: no searcher configured")
+	}
+	results, err := s.searcher.Search(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("search content: %w", err)
+	}
+	return results, nil
+}
+
 func (s *ContentService) Update(ctx context.Context, c *Content) error {
 	if c == nil || c.ID == 0 {
 		return ErrValidation("id must be greater than zero")
@@ -63,6 +186,17 @@ func (s *ContentService) Update(ctx context.Context, c *Content) error {
 	if err := validateContent(c); err != nil {
 		return err
 	}
+	if err := s.applyProbe(c); err != nil {
+		return err
+	}
+	if c.ModTime.IsZero() {
+		// Updates that leave the modification time out keep the one recorded by the scanner.
+		current, err := s.repo.GetByID(ctx, c.ID)
+		if err != nil {
+			return fmt.Errorf("update content: %w", err)
+		}
+		c.ModTime = current.ModTime
+	}
 	if err := s.repo.Update(ctx, c); err != nil {
 		return fmt.Errorf("update content: %w", err)
 	}
@@ -76,6 +210,43 @@ func (s *ContentService) Delete(ctx context.Context, id uint) error {
 	return nil
 }
 
+// applyProbe fills c from the media file it points at, rejecting a size or length that contradicts
+// the file. A length is only checked when the container reports a duration.
+func (s *ContentService) applyProbe(c *Content) error {
+	if s.prober == nil {
+		return nil
+	}
+
+	info, err := s.prober.Probe(c.Path)
+	if err != nil {
+		return ErrValidation(fmt.Sprintf("unable to read media file at path: %v", err))
+	}
+
+	if c.Size != 0 && c.Size != info.Size {
+		return ErrValidation(fmt.Sprintf("size %d does not match file size %d", c.Size, info.Size))
+	}
+	c.Size = info.Size
+
+	if info.Duration > 0 {
+		actual := info.Duration.Seconds()
+		tolerance := math.Max(minLengthTolerance, actual/100)
+		if c.Length != 0 && math.Abs(c.Length-actual) > tolerance {
+			return ErrValidation(fmt.Sprintf("length %.3f does not match media duration %.3f", c.Length, actual))
+		}
+		c.Length = actual
+	}
+
+	c.Container = info.Container
+	c.VideoCodec = info.VideoCodec
+	c.AudioCodec = info.AudioCodec
+	c.Width = info.Width
+	c.Height = info.Height
+	c.Bitrate = info.Bitrate
+	c.Tracks = TracksFromProbe(info.Tracks)
+	c.Poster = info.Poster
+	return nil
+}
+
 func validateContent(c *Content) error {
 	if c == nil {
 		return ErrValidation("content is required")
@@ -92,5 +263,8 @@ func validateContent(c *Content) error {
 	if c.Length < 0 {
 		return ErrValidation("length must be non-negative")
 	}
+	if c.Episode != nil {
+		return validateEpisode(c.Episode)
+	}
 	return nil
 }
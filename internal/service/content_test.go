@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"errors"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/probe"
 )
 
 type stubRepo struct {
@@ -24,6 +28,7 @@ type stubRepo struct {
 	gotLimit      int
 	gotOffset     int
 	gotDeleteID   uint
+	gotUpdated    *Content
 }
 
 func (s *stubRepo) Create(context.Context, *Content) error {
@@ -53,8 +58,9 @@ func (s *stubRepo) List(_ context.Context, limit, offset int) ([]Content, error)
 	return s.listContents, nil
 }
 
-func (s *stubRepo) Update(context.Context, *Content) error {
+func (s *stubRepo) Update(_ context.Context, c *Content) error {
 	s.updateCalled = true
+	s.gotUpdated = c
 	return s.updateErr
 }
 
@@ -64,6 +70,20 @@ func (s *stubRepo) Delete(_ context.Context, id uint) error {
 	return s.deleteErr
 }
 
+type stubProber struct {
+	info    *probe.Info
+	err     error
+	gotPath string
+}
+
+func (p *stubProber) Probe(path string) (*probe.Info, error) {
+	p.gotPath = path
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.info, nil
+}
+
 func TestContentServiceCreateValidatesInput(t *testing.T) {
 	cases := []struct {
 		name string
@@ -75,6 +95,10 @@ func TestContentServiceCreateValidatesInput(t *testing.T) {
 		{name: "empty path", in: &Content{Title: "ok", Path: "", Size: 1, Length: 1}},
 		{name: "negative size", in: &Content{Title: "ok", Path: "/tmp/f.ts", Size: -1, Length: 1}},
 		{name: "negative length", in: &Content{Title: "ok", Path: "/tmp/f.ts", Size: 1, Length: -1}},
+		{name: "episode without show", in: &Content{Title: "ok", Path: "/tmp/f.ts", Episode: &Episode{Show: " ", Season: 1, Episode: 1}}},
+		{name: "negative season", in: &Content{Title: "ok", Path: "/tmp/f.ts", Episode: &Episode{Show: "a", Season: -1, Episode: 1}}},
+		{name: "bad air date", in: &Content{Title: "ok", Path: "/tmp/f.ts", Episode: &Episode{Show: "a", AirDate: "15/01/2024"}}},
+		{name: "unnumbered episode", in: &Content{Title: "ok", Path: "/tmp/f.ts", Episode: &Episode{Show: "a", Season: 1}}},
 	}
 
 	for _, tc := range cases {
@@ -210,6 +234,42 @@ func TestContentServiceUpdateCallsRepoForValidInput(t *testing.T) {
 	}
 }
 
+func TestContentServiceUpdateKeepsStoredModTime(t *testing.T) {
+	stored := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := &stubRepo{getContent: &Content{ID: 1, ModTime: stored}}
+	svc := NewContentService(repo)
+
+	c := &Content{ID: 1, Title: "title", Path: "/tmp/file.ts", Size: 10, Length: 1.5}
+	if err := svc.Update(context.Background(), c); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if !repo.gotUpdated.ModTime.Equal(stored) || !c.ModTime.Equal(stored) {
+		t.Fatalf("expected stored mod time %v, got %v", stored, repo.gotUpdated.ModTime)
+	}
+
+	given := stored.Add(time.Hour)
+	c = &Content{ID: 1, Title: "title", Path: "/tmp/file.ts", Size: 10, Length: 1.5, ModTime: given}
+	if err := svc.Update(context.Background(), c); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if !repo.gotUpdated.ModTime.Equal(given) {
+		t.Fatalf("expected given mod time %v, got %v", given, repo.gotUpdated.ModTime)
+	}
+}
+
+func TestContentServiceUpdateMissingContentReturnsNotFound(t *testing.T) {
+	repo := &stubRepo{getByIDErr: ErrNotFound}
+	svc := NewContentService(repo)
+
+	err := svc.Update(context.Background(), &Content{ID: 1, Title: "title", Path: "/tmp/file.ts", Size: 10, Length: 1.5})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got: %v", err)
+	}
+	if repo.updateCalled {
+		t.Fatalf("expected repo update not to be called")
+	}
+}
+
 func TestContentServiceUpdateWrapsRepoError(t *testing.T) {
 	repoErr := errors.New("db update failed")
 	repo := &stubRepo{updateErr: repoErr}
@@ -370,3 +430,169 @@ func TestValidationErrorErrorReturnsMessage(t *testing.T) {
 		t.Fatalf("expected message to round-trip, got %q", got)
 	}
 }
+
+func TestContentServiceCreateFillsFieldsFromProbe(t *testing.T) {
+	repo := &stubRepo{}
+	prober := &stubProber{info: &probe.Info{
+		Container:  "mp4",
+		Size:       2048,
+		Duration:   90 * time.Second,
+		VideoCodec: "h264",
+		AudioCodec: "aac",
+		Width:      1280,
+		Height:     720,
+		Bitrate:    182,
+		Tracks: []probe.Track{
+			{Kind: probe.TrackAudio, Codec: "aac", Language: "eng"},
+			{Kind: probe.TrackSubtitle, Codec: "subrip", Language: "ger", Path: "/media/a.de.srt"},
+		},
+		Poster: "/media/poster.jpg",
+	}}
+	svc := NewContentService(repo, WithProber(prober))
+
+	c := &Content{Title: "t", Path: "/media/a.mp4"}
+	if err := svc.Create(context.Background(), c); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if prober.gotPath != "/media/a.mp4" {
+		t.Fatalf("expected probe of content path, got %q", prober.gotPath)
+	}
+	if !repo.createCalled {
+		t.Fatalf("expected repo create to be called")
+	}
+	if c.Size != 2048 || c.Length != 90 || c.Container != "mp4" || c.VideoCodec != "h264" ||
+		c.AudioCodec != "aac" || c.Width != 1280 || c.Height != 720 || c.Bitrate != 182 ||
+		c.Poster != "/media/poster.jpg" {
+		t.Fatalf("expected probed fields to be filled, got %+v", c)
+	}
+	want := []Track{
+		{Kind: TrackAudio, Codec: "aac", Language: "en"},
+		{Kind: TrackSubtitle, Codec: "subrip", Language: "de", Path: "/media/a.de.srt"},
+	}
+	if !slices.Equal(c.Tracks, want) {
+		t.Fatalf("expected tracks with canonical languages, got %+v", c.Tracks)
+	}
+}
+
+func TestContentServiceCreateAcceptsMatchingClientValues(t *testing.T) {
+	prober := &stubProber{info: &probe.Info{Size: 2048, Duration: 90 * time.Second}}
+	svc := NewContentService(&stubRepo{}, WithProber(prober))
+
+	c := &Content{Title: "t", Path: "/media/a.mp4", Size: 2048, Length: 90.4}
+	if err := svc.Create(context.Background(), c); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if c.Length != 90 {
+		t.Fatalf("expected probed length to replace client value, got %v", c.Length)
+	}
+}
+
+func TestContentServiceCreateKeepsClientLengthWhenDurationUnknown(t *testing.T) {
+	prober := &stubProber{info: &probe.Info{Size: 2048}}
+	svc := NewContentService(&stubRepo{}, WithProber(prober))
+
+	c := &Content{Title: "t", Path: "/media/a.avi", Length: 42}
+	if err := svc.Create(context.Background(), c); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if c.Length != 42 || c.Size != 2048 {
+		t.Fatalf("expected client length and probed size, got %+v", c)
+	}
+}
+
+func TestContentServiceRejectsValuesContradictingProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *Content
+		err  error
+		want string
+	}{
+		{name: "size mismatch", in: &Content{Title: "t", Path: "/a.mp4", Size: 1}, want: "size"},
+		{name: "length mismatch", in: &Content{Title: "t", Path: "/a.mp4", Length: 120}, want: "length"},
+		{name: "unreadable file", in: &Content{Title: "t", Path: "/a.mp4"}, err: errors.New("no such file"), want: "unable to read"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &stubRepo{}
+			prober := &stubProber{info: &probe.Info{Size: 2048, Duration: 90 * time.Second}, err: tc.err}
+			svc := NewContentService(repo, WithProber(prober))
+
+			err := svc.Create(context.Background(), tc.in)
+			var ve ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+			if !strings.Contains(ve.Error(), tc.want) {
+				t.Fatalf("expected message mentioning %q, got %q", tc.want, ve.Error())
+			}
+			if repo.createCalled {
+				t.Fatalf("repo create should not be called")
+			}
+
+			repo.updateCalled = false
+			tc.in.ID = 1
+			if err := svc.Update(context.Background(), tc.in); !errors.As(err, &ve) {
+				t.Fatalf("expected validation error on update, got %v", err)
+			}
+			if repo.updateCalled {
+				t.Fatalf("repo update should not be called")
+			}
+		})
+	}
+}
+
+type stubSearcher struct {
+	results   []SearchResult
+	err       error
+	gotQuery  string
+	gotLimit  int
+	gotOffset int
+}
+
+func (s *stubSearcher) Search(_ context.Context, query string, limit, offset int) ([]SearchResult, error) {
+	s.gotQuery, s.gotLimit, s.gotOffset = query, limit, offset
+	return s.results, s.err
+}
+
+func TestContentServiceSearchTrimsQuery(t *testing.T) {
+	searcher := &stubSearcher{results: []SearchResult{{Content: Content{ID: 1, Title: "Heat"}, Snippet: "<mark>Heat</mark>"}}}
+	svc := NewContentService(&stubRepo{}, WithSearch(searcher))
+
+	got, err := svc.Search(context.Background(), "  heat  ", 20, 5)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if searcher.gotQuery != "heat" || searcher.gotLimit != 20 || searcher.gotOffset != 5 {
+		t.Fatalf("unexpected search args: %q limit=%d offset=%d", searcher.gotQuery, searcher.gotLimit, searcher.gotOffset)
+	}
+	if len(got) != 1 || got[0].ID != 1 {
+		t.Fatalf("unexpected results: %+v", got)
+	}
+}
+
+func TestContentServiceSearchValidatesQuery(t *testing.T) {
+	searcher := &stubSearcher{}
+	svc := NewContentService(&stubRepo{}, WithSearch(searcher))
+
+	for _, query := range []string{"", "   ", strings.Repeat("a", maxSearchQueryLen+1)} {
+		_, err := svc.Search(context.Background(), query, 10, 0)
+		var ve ValidationError
+		if !errors.As(err, &ve) {
+			t.Fatalf("query of %d bytes: expected validation error, got: %v", len(query), err)
+		}
+	}
+	if searcher.gotQuery != "" {
+		t.Fatalf("expected searcher not to be called, got query %q", searcher.gotQuery)
+	}
+}
+
+func TestContentServiceSearchWrapsError(t *testing.T) {
+	searchErr := errors.New("db search failed")
+	svc := NewContentService(&stubRepo{}, WithSearch(&stubSearcher{err: searchErr}))
+
+	_, err := svc.Search(context.Background(), "heat", 10, 0)
+	if !errors.Is(err, searchErr) {
+		t.Fatalf("expected wrapped search error, got: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Cursor is where a looping, in-order channel has got to in its playlist: the item that last
+// finished airing, or that was on air when the playlist was last edited, and when it aired. The
+// playlist carries on after the item from End, so edits and restarts do not move what airs next.
+// Times are on the playlist's own timeline, which runs on underneath schedule blocks.
+type Cursor struct {
+	ChannelID uint `json:"channelId"`
+	ItemID    uint `json:"itemId"`
+	// ContentID tells apart the pieces of content played by a collection item.
+	ContentID uint `json:"contentId"`
+	// Position is the item's index in the playlist, with collection items expanded. It places the
+	// playlist when the item has since been removed.
+	Position int       `json:"position"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+type CursorRepo interface {
+	// Get returns ErrNotFound when the channel has no cursor yet.
+	Get(ctx context.Context, channelID uint) (*Cursor, error)
+	// Save creates or replaces the channel's cursor.
+	Save(ctx context.Context, c *Cursor) error
+}
+
+// WithCursors anchors the playlists of looping, in-order channels at their saved cursors instead
+// of at epoch.
+func WithCursors(cursors CursorRepo) TimelineOption {
+	return func(s *TimelineService) {
+		s.cursors = cursors
+	}
+}
+
+// SaveCursors moves the cursor of every looping, in-order channel on to the last playlist item to
+// finish airing by now. Run regularly, it keeps programming where it was across restarts even when
+// content lengths or collections change in the meantime.
+func (s *TimelineService) SaveCursors(ctx context.Context) error {
+	if s.cursors == nil {
+		return nil
+	}
+	channels, err := NewChannelService(s.channels).All(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	var errs []error
+	for _, ch := range channels {
+		if !usesCursor(ch) {
+			continue
+		}
+		if err := s.advance(ctx, ch.ID, now); err != nil {
+			errs = append(errs, fmt.Errorf("channel %d: %w", ch.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// advance saves the slot before the one the playlist airs at t as the channel's cursor. A cursor
+// held past t for an edit is kept until it has aired.
+func (s *TimelineService) advance(ctx context.Context, channelID uint, t time.Time) error {
+	tl, err := s.load(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	_, pos, start, ok := tl.slotAt(t)
+	if !ok || (tl.cursor != nil && !start.After(tl.cursor.End)) {
+		return nil
+	}
+	prev := (pos + len(tl.slots) - 1) % len(tl.slots)
+	return s.saveCursor(ctx, tl, prev, start.Add(-tl.slots[prev].length), start)
+}
+
+// hold saves the slot on air now as the channel's cursor, so that an edit to the playlist takes
+// effect once it ends. An item being removed is cut short instead, so the next one starts now.
+func (s *TimelineService) hold(ctx context.Context, channelID, removing uint) error {
+	if s.cursors == nil {
+		return nil
+	}
+	tl, err := s.load(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	if !usesCursor(tl.channel) {
+		return nil
+	}
+	now := s.now()
+	_, pos, start, ok := tl.slotAt(now)
+	if !ok {
+		return nil
+	}
+	end := start.Add(tl.slots[pos].length)
+	if tl.slots[pos].parts[0].item.ID == removing {
+		end = now
+	}
+	return s.saveCursor(ctx, tl, pos, start, end)
+}
+
+func (s *TimelineService) saveCursor(ctx context.Context, tl *timeline, pos int, start, end time.Time) error {
+	item := tl.slots[pos].parts[0].item
+	c := &Cursor{
+		ChannelID: tl.channel.ID,
+		ItemID:    item.ID,
+		ContentID: item.ContentID,
+		Position:  pos,
+		Start:     start,
+		End:       end,
+	}
+	if err := s.cursors.Save(ctx, c); err != nil {
+		return fmt.Errorf("save cursor: %w", err)
+	}
+	return nil
+}
+
+// usesCursor reports whether ch is anchored at a cursor. A shuffled channel's order changes every
+// cycle and a channel that does not loop plays its playlist once from epoch, so neither is.
+func usesCursor(ch Channel) bool {
+	return ch.Loop && !ch.Shuffle
+}
+
+// anchor lays the playlist out so that the slot after c's item starts at c.End. When the item is
+// no longer in the playlist, the slot that took its position starts then instead. The playlist's
+// origin stays within a cycle before epoch so sequence numbers keep counting from there.
+func (tl *timeline) anchor(c Cursor) {
+	if tl.total <= 0 {
+		return
+	}
+	next := min(c.Position, len(tl.slots))
+	found := -1
+	for i, s := range tl.slots {
+		it := s.parts[0].item
+		if it.ID != c.ItemID || it.ContentID != c.ContentID {
+			continue
+		}
+		// The same content can be in a collection twice; take the copy nearest the cursor.
+		if found < 0 || abs(i-c.Position) < abs(found-c.Position) {
+			found = i
+		}
+	}
+	if found >= 0 {
+		next = found + 1
+	}
+	var offset time.Duration
+	for _, s := range tl.slots[:next] {
+		offset += s.length
+	}
+	shift := c.End.Add(-offset).Sub(tl.epoch) % tl.total
+	if shift > 0 {
+		shift -= tl.total
+	}
+	tl.origin = tl.epoch.Add(shift)
+	tl.cursor = &c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
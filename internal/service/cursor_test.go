@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type stubCursorRepo struct {
+	cursor *Cursor
+	saves  int
+}
+
+func (s *stubCursorRepo) Get(context.Context, uint) (*Cursor, error) {
+	if s.cursor == nil {
+		return nil, ErrNotFound
+	}
+	c := *s.cursor
+	return &c, nil
+}
+
+func (s *stubCursorRepo) Save(_ context.Context, c *Cursor) error {
+	saved := *c
+	s.cursor = &saved
+	s.saves++
+	return nil
+}
+
+// reordered returns items in the order of ids, renumbering their positions.
+func reordered(items []ChannelItem, ids ...uint) []ChannelItem {
+	var out []ChannelItem
+	for _, id := range ids {
+		for _, it := range items {
+			if it.ID == id {
+				it.Position = len(out)
+				out = append(out, it)
+			}
+		}
+	}
+	return out
+}
+
+func newCursorTimeline(ch Channel, items *stubChannelItemRepo, cursors *stubCursorRepo, now time.Time) *TimelineService {
+	channels := &stubChannelRepo{getChannel: &ch, listChannels: []Channel{ch}}
+	svc := NewTimelineService(channels, items, testEpoch, WithCursors(cursors))
+	svc.now = func() time.Time { return now }
+	return svc
+}
+
+func assertAiring(t *testing.T, svc *TimelineService, at time.Duration, wantItem uint, wantStart time.Duration) {
+	t.Helper()
+	a, err := svc.At(context.Background(), 1, testEpoch.Add(at))
+	if err != nil {
+		t.Fatalf("at %s: %v", at, err)
+	}
+	if a.Item.ID != wantItem || !a.Start.Equal(testEpoch.Add(wantStart)) {
+		t.Fatalf("at %s: expected item %d from %s, got item %d from %s", at, wantItem, wantStart, a.Item.ID, a.Start.Sub(testEpoch))
+	}
+}
+
+func TestPlaylistEditsTakeEffectAtNextItem(t *testing.T) {
+	all := testItems(600, 600, 600)
+	items := &stubChannelItemRepo{items: all}
+	cursors := &stubCursorRepo{}
+	now := 15 * time.Minute
+	svc := newCursorTimeline(Channel{ID: 1, Loop: true}, items, cursors, testEpoch.Add(now))
+	playlist := NewPlaylistService(&stubChannelRepo{}, &stubRepo{}, items, WithPlaylistTimeline(svc))
+
+	if _, err := playlist.Reorder(context.Background(), 1, []uint{3, 2, 1}); err != nil {
+		t.Fatalf("reorder: %v", err)
+	}
+	items.items = reordered(all, 3, 2, 1)
+	if c := cursors.cursor; c == nil || c.ItemID != 2 || !c.End.Equal(testEpoch.Add(20*time.Minute)) {
+		t.Fatalf("expected the cursor to hold item 2 until it ends, got %+v", c)
+	}
+	// Item 2 plays out, then the playlist carries on in its new order.
+	assertAiring(t, svc, now, 2, 10*time.Minute)
+	assertAiring(t, svc, 20*time.Minute, 1, 20*time.Minute)
+	assertAiring(t, svc, 30*time.Minute, 3, 30*time.Minute)
+
+	if err := playlist.Remove(context.Background(), 1, 2); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	items.items = reordered(all, 3, 1)
+	// The item on air is cut short and the one after it starts straight away.
+	assertAiring(t, svc, now, 1, now)
+	assertAiring(t, svc, now+10*time.Minute, 3, now+10*time.Minute)
+}
+
+func TestTimelineServiceSaveCursors(t *testing.T) {
+	all := testItems(600, 600, 600)
+	items := &stubChannelItemRepo{items: all}
+	held := &Cursor{ChannelID: 1, ItemID: 2, ContentID: 2, Position: 1, Start: testEpoch.Add(10 * time.Minute), End: testEpoch.Add(20 * time.Minute)}
+	cursors := &stubCursorRepo{cursor: held}
+
+	// A cursor held for an edit is kept until its item has aired.
+	svc := newCursorTimeline(Channel{ID: 1, Loop: true}, items, cursors, testEpoch.Add(17*time.Minute))
+	if err := svc.SaveCursors(context.Background()); err != nil {
+		t.Fatalf("save cursors: %v", err)
+	}
+	if cursors.saves != 0 {
+		t.Fatalf("expected the held cursor to be kept, got %+v", cursors.cursor)
+	}
+
+	svc.now = func() time.Time { return testEpoch.Add(35 * time.Minute) }
+	if err := svc.SaveCursors(context.Background()); err != nil {
+		t.Fatalf("save cursors: %v", err)
+	}
+	if c := cursors.cursor; c.ItemID != 3 || c.Position != 2 || !c.End.Equal(testEpoch.Add(30*time.Minute)) {
+		t.Fatalf("expected the cursor to move on to item 3, got %+v", c)
+	}
+
+	// After a restart with a longer first item, programming carries on from the cursor.
+	items.items = testItems(900, 600, 600)
+	assertAiring(t, svc, 35*time.Minute, 1, 30*time.Minute)
+	assertAiring(t, svc, 45*time.Minute, 2, 45*time.Minute)
+
+	shuffled := &stubCursorRepo{}
+	svc = newCursorTimeline(Channel{ID: 1, Loop: true, Shuffle: true}, items, shuffled, testEpoch.Add(time.Hour))
+	if err := svc.SaveCursors(context.Background()); err != nil {
+		t.Fatalf("save cursors: %v", err)
+	}
+	if shuffled.saves != 0 {
+		t.Fatalf("expected shuffled channels to have no cursor, got %+v", shuffled.cursor)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FillerPool is content, such as bumpers, idents or ads, that a channel plays in a break after
+// each programme of its playlist.
+type FillerPool struct {
+	ID        uint   `json:"id"`
+	ChannelID uint   `json:"channelId"`
+	Title     string `json:"title"`
+	// MinBreak and MaxBreak bound, in seconds, how long the pool plays in each break. A MaxBreak of
+	// zero lets the pool play as long as it takes to reach the channel's next boundary.
+	MinBreak float64 `json:"minBreak"`
+	MaxBreak float64 `json:"maxBreak"`
+	// ContentIDs is the content the pool plays, in order.
+	ContentIDs []uint `json:"contentIds"`
+	// Content holds the content behind ContentIDs, in order, when the pool is loaded for a
+	// timeline.
+	Content []Content `json:"-"`
+}
+
+type FillerPoolRepo interface {
+	// ListByChannel returns the channel's pools in break order with their content loaded.
+	ListByChannel(ctx context.Context, channelID uint) ([]FillerPool, error)
+	GetByID(ctx context.Context, channelID, id uint) (*FillerPool, error)
+	Create(ctx context.Context, p *FillerPool) error
+	Update(ctx context.Context, p *FillerPool) error
+	Delete(ctx context.Context, channelID, id uint) error
+}
+
+// FillerService manages the filler pools of each channel.
+type FillerService struct {
+	channels ChannelRepo
+	content  ContentRepo
+	pools    FillerPoolRepo
+}
+
+func NewFillerService(channels ChannelRepo, content ContentRepo, pools FillerPoolRepo) *FillerService {
+	return &FillerService{channels: channels, content: content, pools: pools}
+}
+
+func (s *FillerService) List(ctx context.Context, channelID uint) ([]FillerPool, error) {
+	if _, err := s.channels.GetByID(ctx, channelID); err != nil {
+		return nil, fmt.Errorf("get channel by id: %w", err)
+	}
+	pools, err := s.pools.ListByChannel(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("list filler pools: %w", err)
+	}
+	return pools, nil
+}
+
+func (s *FillerService) Get(ctx context.Context, channelID, id uint) (*FillerPool, error) {
+	p, err := s.pools.GetByID(ctx, channelID, id)
+	if err != nil {
+		return nil, fmt.Errorf("get filler pool by id: %w", err)
+	}
+	return p, nil
+}
+
+func (s *FillerService) Create(ctx context.Context, p *FillerPool) error {
+	if err := s.validate(ctx, p); err != nil {
+		return err
+	}
+	if err := s.pools.Create(ctx, p); err != nil {
+		return fmt.Errorf("create filler pool: %w", err)
+	}
+	return nil
+}
+
+func (s *FillerService) Update(ctx context.Context, p *FillerPool) error {
+	if p == nil || p.ID == 0 {
+		return ErrValidation("id must be greater than zero")
+	}
+	if _, err := s.pools.GetByID(ctx, p.ChannelID, p.ID); err != nil {
+		return fmt.Errorf("get filler pool by id: %w", err)
+	}
+	if err := s.validate(ctx, p); err != nil {
+		return err
+	}
+	if err := s.pools.Update(ctx, p); err != nil {
+		return fmt.Errorf("update filler pool: %w", err)
+	}
+	return nil
+}
+
+func (s *FillerService) Delete(ctx context.Context, channelID, id uint) error {
+	if err := s.pools.Delete(ctx, channelID, id); err != nil {
+		return fmt.Errorf("delete filler pool: %w", err)
+	}
+	return nil
+}
+
+func (s *FillerService) validate(ctx context.Context, p *FillerPool) error {
+	if p == nil {
+		return ErrValidation("pool is required")
+	}
+	ch, err := s.channels.GetByID(ctx, p.ChannelID)
+	if err != nil {
+		return fmt.Errorf("get channel by id: %w", err)
+	}
+	p.Title = strings.TrimSpace(p.Title)
+	if p.MinBreak < 0 {
+		return ErrValidation("min break must not be negative")
+	}
+	if p.MaxBreak < 0 || (p.MaxBreak > 0 && p.MaxBreak < p.MinBreak) {
+		return ErrValidation("max break must be zero or at least min break")
+	}
+	if len(p.ContentIDs) == 0 {
+		return ErrValidation("content ids must list at least one content")
+	}
+	for _, id := range p.ContentIDs {
+		c, err := s.content.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return ErrValidation(fmt.Sprintf("content %d does not exist", id))
+			}
+			return fmt.Errorf("get content by id: %w", err)
+		}
+		if err := checkPlays(ch, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// breaker lays out the breaks a channel's filler pools play after each programme.
+type breaker struct {
+	pad   time.Duration
+	pools []breakPool
+}
+
+// breakPool is a filler pool with its playable content.
+type breakPool struct {
+	min, max time.Duration
+	items    []ChannelItem
+	lengths  []time.Duration
+}
+
+// newBreaker returns the breaker for a channel. Pools without any content of known length that
+// the channel plays are left out.
+func newBreaker(ch Channel, pools []FillerPool) *breaker {
+	b := &breaker{pad: time.Duration(ch.PadTo) * time.Minute}
+	for _, p := range pools {
+		bp := breakPool{min: secondsToDuration(p.MinBreak), max: secondsToDuration(p.MaxBreak)}
+		bp.items, bp.lengths = playable(ch, p.Content)
+		if len(bp.items) > 0 {
+			b.pools = append(b.pools, bp)
+		}
+	}
+	return b
+}
+
+// after returns the break that follows the k-th programme of the playlist. Every pool plays at
+// least its minimum, in order. When the channel pads, the pools then play on up to their maximums
+// until the programme and its break fill a whole number of pad intervals, so that a programme
+// starting on a boundary is followed by one that does too.
+func (b *breaker) after(k int, length time.Duration) []part {
+	if len(b.pools) == 0 {
+		return nil
+	}
+	var least, most time.Duration
+	limited := true
+	for _, p := range b.pools {
+		least += p.min
+		most += p.max
+		limited = limited && p.max > 0
+	}
+	target := least
+	if b.pad > 0 {
+		target = (length+least+b.pad-1)/b.pad*b.pad - length
+		if limited && target > most {
+			target = most
+		}
+	}
+
+	var parts []part
+	extra := target - least
+	for _, p := range b.pools {
+		d := p.min
+		room := p.max - p.min
+		if p.max == 0 {
+			room = extra
+		}
+		add := min(extra, room)
+		d += add
+		extra -= add
+		parts = append(parts, p.play(k, d)...)
+	}
+	return parts
+}
+
+// play returns d of the pool's content, starting at its k-th item so that consecutive breaks
+// work through the pool, and cutting the last item short.
+func (p breakPool) play(k int, d time.Duration) []part {
+	var parts []part
+	for j := k; d > 0; j++ {
+		i := j % len(p.items)
+		l := min(p.lengths[i], d)
+		parts = append(parts, part{item: p.items[i], length: l, filler: true})
+		d -= l
+	}
+	return parts
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubFillerPoolRepo struct {
+	pools   []FillerPool
+	created *FillerPool
+	updated *FillerPool
+}
+
+func (s *stubFillerPoolRepo) ListByChannel(context.Context, uint) ([]FillerPool, error) {
+	return s.pools, nil
+}
+
+func (s *stubFillerPoolRepo) GetByID(_ context.Context, _ uint, id uint) (*FillerPool, error) {
+	for _, p := range s.pools {
+		if p.ID == id {
+			return &p, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *stubFillerPoolRepo) Create(_ context.Context, p *FillerPool) error {
+	p.ID = 100
+	s.created = p
+	return nil
+}
+
+func (s *stubFillerPoolRepo) Update(_ context.Context, p *FillerPool) error {
+	s.updated = p
+	return nil
+}
+
+func (s *stubFillerPoolRepo) Delete(context.Context, uint, uint) error {
+	return nil
+}
+
+func TestFillerServiceCreateValidatesInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      FillerPool
+		content *stubRepo
+		wantErr string
+	}{
+		{name: "negative min", in: FillerPool{MinBreak: -1, ContentIDs: []uint{1}}, wantErr: "min break"},
+		{name: "max below min", in: FillerPool{MinBreak: 60, MaxBreak: 30, ContentIDs: []uint{1}}, wantErr: "max break"},
+		{name: "negative max", in: FillerPool{MaxBreak: -5, ContentIDs: []uint{1}}, wantErr: "max break"},
+		{name: "no content", in: FillerPool{MinBreak: 10}, wantErr: "content ids must list"},
+		{name: "missing content", in: FillerPool{ContentIDs: []uint{4}}, content: &stubRepo{getByIDErr: ErrNotFound}, wantErr: "content 4 does not exist"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content := tc.content
+			if content == nil {
+				content = &stubRepo{}
+			}
+			pools := &stubFillerPoolRepo{}
+			err := NewFillerService(&stubChannelRepo{}, content, pools).Create(context.Background(), &tc.in)
+			var ve ValidationError
+			if !errors.As(err, &ve) || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected validation error containing %q, got %v", tc.wantErr, err)
+			}
+			if pools.created != nil {
+				t.Fatal("expected nothing to be created")
+			}
+		})
+	}
+}
+
+func TestFillerServiceCreateAndUpdate(t *testing.T) {
+	pools := &stubFillerPoolRepo{pools: []FillerPool{{ID: 1, ContentIDs: []uint{1}}}}
+	svc := NewFillerService(&stubChannelRepo{}, &stubRepo{}, pools)
+
+	p := &FillerPool{Title: " Ads ", MinBreak: 30, ContentIDs: []uint{1, 2}}
+	if err := svc.Create(context.Background(), p); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if pools.created != p || p.Title != "Ads" {
+		t.Fatalf("expected pool to be created with a trimmed title, got %+v", pools.created)
+	}
+	if err := svc.Update(context.Background(), &FillerPool{ID: 1, MaxBreak: 120, ContentIDs: []uint{2}}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if pools.updated == nil || pools.updated.MaxBreak != 120 {
+		t.Fatalf("expected pool to be updated, got %+v", pools.updated)
+	}
+	if err := svc.Update(context.Background(), &FillerPool{ID: 2, ContentIDs: []uint{2}}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown pool, got %v", err)
+	}
+}
+
+func breakLengths(parts []part) (total time.Duration, items []uint) {
+	for _, p := range parts {
+		total += p.length
+		items = append(items, p.item.ContentID)
+	}
+	return total, items
+}
+
+func TestBreakerAfter(t *testing.T) {
+	idents := FillerPool{MinBreak: 5, MaxBreak: 10, Content: []Content{{ID: 20, Length: 5}}}
+	ads := FillerPool{MinBreak: 30, Content: []Content{{ID: 30, Length: 30}, {ID: 31, Length: 60}}}
+	capped := FillerPool{MinBreak: 30, MaxBreak: 60, Content: []Content{{ID: 30, Length: 30}}}
+
+	tests := []struct {
+		name      string
+		padTo     int
+		pools     []FillerPool
+		k         int
+		length    time.Duration
+		wantTotal time.Duration
+	}{
+		{name: "no pools", padTo: 30, length: 25 * time.Minute},
+		{name: "minimum without padding", pools: []FillerPool{idents, ads}, length: 25 * time.Minute, wantTotal: 35 * time.Second},
+		{name: "pads to the next boundary", padTo: 30, pools: []FillerPool{idents, ads}, length: 25 * time.Minute, wantTotal: 5 * time.Minute},
+		{name: "skips a boundary the minimum cannot reach", padTo: 30, pools: []FillerPool{idents, ads}, length: 29*time.Minute + 50*time.Second, wantTotal: 30*time.Minute + 10*time.Second},
+		{name: "on a boundary already", padTo: 30, pools: []FillerPool{{Content: idents.Content}}, length: 30 * time.Minute},
+		{name: "stops at the maximum", padTo: 30, pools: []FillerPool{idents, capped}, length: 20 * time.Minute, wantTotal: 70 * time.Second},
+		{name: "ignores pools without content", padTo: 30, pools: []FillerPool{{MinBreak: 60}}, length: 20 * time.Minute},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newBreaker(Channel{ID: 1, PadTo: tc.padTo}, tc.pools)
+			total, _ := breakLengths(b.after(tc.k, tc.length))
+			if total != tc.wantTotal {
+				t.Fatalf("expected a break of %s, got %s", tc.wantTotal, total)
+			}
+		})
+	}
+
+	b := newBreaker(Channel{ID: 1, PadTo: 30}, []FillerPool{idents, ads})
+	parts := b.after(1, 25*time.Minute)
+	_, items := breakLengths(parts)
+	// Idents play up to their maximum, then ads starting from the second one fill the rest.
+	if want := []uint{20, 20, 31, 30, 31, 30, 31, 30, 31}; !slices.Equal(items, want) {
+		t.Fatalf("expected %v, got %v", want, items)
+	}
+	if last := parts[len(parts)-1]; last.length != 20*time.Second || !last.filler {
+		t.Fatalf("expected the last ad to be cut to fit, got %+v", last)
+	}
+}
+
+func newFillerTimeline(ch Channel, pools ...FillerPool) *TimelineService {
+	channels := &stubChannelRepo{getChannel: &ch}
+	items := &stubChannelItemRepo{items: testItems(25*60, 50*60)}
+	return NewTimelineService(channels, items, testEpoch, WithFiller(&stubFillerPoolRepo{pools: pools}))
+}
+
+func TestTimelineServicePlaysBreaksBetweenProgrammes(t *testing.T) {
+	bumper := FillerPool{ID: 1, Content: []Content{{ID: 20, Length: 120}}}
+	svc := newFillerTimeline(Channel{ID: 1, Loop: true, PadTo: 30}, bumper)
+
+	airings, err := svc.Schedule(context.Background(), 1, testEpoch, testEpoch.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	for i, a := range airings {
+		if a.Sequence != int64(i) {
+			t.Fatalf("airing %d: expected sequence %d, got %d", i, i, a.Sequence)
+		}
+		if i > 0 && !a.Start.Equal(airings[i-1].End) {
+			t.Fatalf("airing %d does not follow the last: %+v", i, a)
+		}
+		if !a.Filler && a.Start.Sub(testEpoch)%(30*time.Minute) != 0 {
+			t.Fatalf("expected programme %d to start on a boundary, got %s", i, a.Start)
+		}
+	}
+	// Each cycle is 25 minutes plus three bumpers, the last cut short, then 50 minutes plus five.
+	if len(airings) != 2*(1+3+1+5) || airings[3].End.Sub(airings[3].Start) != time.Minute {
+		t.Fatalf("unexpected airings %+v", airings)
+	}
+
+	a, err := svc.At(context.Background(), 1, testEpoch.Add(time.Hour+56*time.Minute))
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if !a.Filler || a.Item.ContentID != 20 || a.Sequence != 11 {
+		t.Fatalf("expected the second cycle's first bumper, got %+v", a)
+	}
+}
+
+func TestTimelineServiceProgrammesFoldBreaks(t *testing.T) {
+	bumper := FillerPool{ID: 1, Content: []Content{{ID: 20, Length: 120}}}
+	svc := newFillerTimeline(Channel{ID: 1, Loop: true, PadTo: 30}, bumper)
+
+	// Starting in the middle of a break still shows the programme the break follows.
+	programmes, err := svc.Programmes(context.Background(), 1, testEpoch.Add(28*time.Minute), testEpoch.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("programmes: %v", err)
+	}
+	want := []struct {
+		content    uint
+		start, end time.Duration
+	}{
+		{1, 0, 30 * time.Minute},
+		{2, 30 * time.Minute, 90 * time.Minute},
+		{1, 90 * time.Minute, 120 * time.Minute},
+	}
+	if len(programmes) != len(want) {
+		t.Fatalf("expected %d programmes, got %+v", len(want), programmes)
+	}
+	for i, w := range want {
+		p := programmes[i]
+		if p.Filler || p.Item.ContentID != w.content || !p.Start.Equal(testEpoch.Add(w.start)) || !p.End.Equal(testEpoch.Add(w.end)) {
+			t.Fatalf("programme %d: expected content %d from %s to %s, got %+v", i, w.content, w.start, w.end, p)
+		}
+	}
+}
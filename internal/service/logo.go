@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MaxLogoBytes is the largest channel logo that can be uploaded.
+const MaxLogoBytes = 2 << 20
+
+// logoTypes are the image formats a channel logo may be in.
+var logoTypes = map[string]bool{"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true}
+
+// Image is an uploaded picture.
+type Image struct {
+	ContentType string
+	Data        []byte
+	ModTime     time.Time
+}
+
+type LogoRepo interface {
+	// SetLogo replaces the channel's logo. It returns ErrNotFound when the channel does not exist.
+	SetLogo(ctx context.Context, channelID uint, img *Image) error
+	GetLogo(ctx context.Context, channelID uint) (*Image, error)
+	DeleteLogo(ctx context.Context, channelID uint) error
+}
+
+// LogoService stores the logos that lineups and the guide show for channels.
+type LogoService struct {
+	repo LogoRepo
+	now  func() time.Time
+}
+
+func NewLogoService(repo LogoRepo) *LogoService {
+	return &LogoService{repo: repo, now: time.Now}
+}
+
+// Set replaces the channel's logo with the image in data, whose format is told from its content.
+func (s *LogoService) Set(ctx context.Context, channelID uint, data []byte) error {
+	if len(data) == 0 {
+		return ErrValidation("logo is empty")
+	}
+	if len(data) > MaxLogoBytes {
+		return ErrValidation(fmt.Sprintf("logo is larger than %d bytes", MaxLogoBytes))
+	}
+	contentType := http.DetectContentType(data)
+	if !logoTypes[contentType] {
+		return ErrValidation("logo must be a PNG, JPEG, GIF or WebP image")
+	}
+
+	img := &Image{ContentType: contentType, Data: data, ModTime: s.now().UTC().Truncate(time.Second)}
+	if err := s.repo.SetLogo(ctx, channelID, img); err != nil {
+		return fmt.Errorf("set channel logo: %w", err)
+	}
+	return nil
+}
+
+func (s *LogoService) Get(ctx context.Context, channelID uint) (*Image, error) {
+	img, err := s.repo.GetLogo(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("get channel logo: %w", err)
+	}
+	return img, nil
+}
+
+func (s *LogoService) Delete(ctx context.Context, channelID uint) error {
+	if err := s.repo.DeleteLogo(ctx, channelID); err != nil {
+		return fmt.Errorf("delete channel logo: %w", err)
+	}
+	return nil
+}
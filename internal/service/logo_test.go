@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type stubLogoRepo struct {
+	logos map[uint]*Image
+}
+
+func (s *stubLogoRepo) SetLogo(_ context.Context, channelID uint, img *Image) error {
+	if channelID != 1 {
+		return ErrNotFound
+	}
+	s.logos[channelID] = img
+	return nil
+}
+
+func (s *stubLogoRepo) GetLogo(_ context.Context, channelID uint) (*Image, error) {
+	if img, ok := s.logos[channelID]; ok {
+		return img, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *stubLogoRepo) DeleteLogo(_ context.Context, channelID uint) error {
+	if _, ok := s.logos[channelID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.logos, channelID)
+	return nil
+}
+
+// pngHeader is the signature PNG files start with.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+func TestLogoServiceSetDetectsImageType(t *testing.T) {
+	repo := &stubLogoRepo{logos: map[uint]*Image{}}
+	svc := NewLogoService(repo)
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 500, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	if err := svc.Set(context.Background(), 1, pngHeader); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	img, err := svc.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if img.ContentType != "image/png" || !img.ModTime.Equal(now.Truncate(time.Second)) {
+		t.Fatalf("unexpected logo %+v", img)
+	}
+}
+
+func TestLogoServiceSetRejectsInvalidImages(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "not an image", data: []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"/>")},
+		{name: "too large", data: append(append([]byte{}, pngHeader...), make([]byte, MaxLogoBytes)...)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewLogoService(&stubLogoRepo{logos: map[uint]*Image{}})
+			var ve ValidationError
+			if err := svc.Set(context.Background(), 1, tc.data); !errors.As(err, &ve) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLogoServiceSetUnknownChannel(t *testing.T) {
+	svc := NewLogoService(&stubLogoRepo{logos: map[uint]*Image{}})
+	if err := svc.Set(context.Background(), 2, pngHeader); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
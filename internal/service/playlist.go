@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ChannelItem is one entry in a channel's ordered playlist. An item plays either one piece of
+// content or, when CollectionID is set, everything in a collection.
+type ChannelItem struct {
+	ID           uint     `json:"id"`
+	ChannelID    uint     `json:"channelId"`
+	ContentID    uint     `json:"contentId"`
+	CollectionID uint     `json:"collectionId,omitempty"`
+	Position     int      `json:"position"`
+	Content      *Content `json:"content,omitempty"`
+}
+
+type ChannelItemRepo interface {
+	ListByChannel(ctx context.Context, channelID uint) ([]ChannelItem, error)
+	Append(ctx context.Context, item *ChannelItem) error
+	Delete(ctx context.Context, channelID, itemID uint) error
+	Reorder(ctx context.Context, channelID uint, itemIDs []uint) error
+}
+
+// PlaylistService manages the content programmed onto each channel.
+type PlaylistService struct {
+	channels    ChannelRepo
+	content     ContentRepo
+	items       ChannelItemRepo
+	collections CollectionRepo
+	timeline    *TimelineService
+}
+
+type PlaylistOption func(*PlaylistService)
+
+// WithPlaylistCollections lets playlist items play a collection instead of a single piece of
+// content.
+func WithPlaylistCollections(collections CollectionRepo) PlaylistOption {
+	return func(s *PlaylistService) {
+		s.collections = collections
+	}
+}
+
+// WithPlaylistTimeline holds the channel's cursor at the item on air before each edit, so edits to
+// looping, in-order channels take effect at the next item boundary instead of moving what is on
+// air. The timeline needs cursors for this to have any effect.
+func WithPlaylistTimeline(timeline *TimelineService) PlaylistOption {
+	return func(s *PlaylistService) {
+		s.timeline = timeline
+	}
+}
+
+func NewPlaylistService(channels ChannelRepo, content ContentRepo, items ChannelItemRepo, opts ...PlaylistOption) *PlaylistService {
+	s := &PlaylistService{channels: channels, content: content, items: items}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *PlaylistService) Items(ctx context.Context, channelID uint) ([]ChannelItem, error) {
+	if _, err := s.channels.GetByID(ctx, channelID); err != nil {
+		return nil, fmt.Errorf("get channel by id: %w", err)
+	}
+	items, err := s.items.ListByChannel(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("list channel items: %w", err)
+	}
+	return items, nil
+}
+
+// Add appends item to the end of its channel's playlist.
+func (s *PlaylistService) Add(ctx context.Context, item *ChannelItem) error {
+	if item == nil {
+		return ErrValidation("item is required")
+	}
+	if item.ContentID != 0 && item.CollectionID != 0 {
+		return ErrValidation("an item has either a content id or a collection id, not both")
+	}
+	if item.ContentID == 0 && item.CollectionID == 0 {
+		return ErrValidation("content id must be greater than zero")
+	}
+	ch, err := s.channels.GetByID(ctx, item.ChannelID)
+	if err != nil {
+		return fmt.Errorf("get channel by id: %w", err)
+	}
+	var c *Content
+	if item.CollectionID != 0 {
+		if err := checkCollection(ctx, s.collections, item.CollectionID); err != nil {
+			return err
+		}
+	} else {
+		if c, err = s.content.GetByID(ctx, item.ContentID); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return ErrValidation(fmt.Sprintf("content %d does not exist", item.ContentID))
+			}
+			return fmt.Errorf("get content by id: %w", err)
+		}
+		if err := checkPlays(ch, c); err != nil {
+			return err
+		}
+	}
+	if err := s.hold(ctx, item.ChannelID, 0); err != nil {
+		return err
+	}
+	if err := s.items.Append(ctx, item); err != nil {
+		return fmt.Errorf("append channel item: %w", err)
+	}
+	item.Content = c
+	return nil
+}
+
+func (s *PlaylistService) Remove(ctx context.Context, channelID, itemID uint) error {
+	if err := s.hold(ctx, channelID, itemID); err != nil {
+		return err
+	}
+	if err := s.items.Delete(ctx, channelID, itemID); err != nil {
+		return fmt.Errorf("delete channel item: %w", err)
+	}
+	return nil
+}
+
+// Reorder rearranges a channel's playlist. itemIDs must list every item on
+// the channel exactly once, in the new order.
+func (s *PlaylistService) Reorder(ctx context.Context, channelID uint, itemIDs []uint) ([]ChannelItem, error) {
+	current, err := s.Items(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if len(itemIDs) != len(current) {
+		return nil, ErrValidation(fmt.Sprintf("item ids must list all %d items on the channel", len(current)))
+	}
+	known := make(map[uint]bool, len(current))
+	for _, it := range current {
+		known[it.ID] = true
+	}
+	seen := make(map[uint]bool, len(itemIDs))
+	for _, id := range itemIDs {
+		if !known[id] {
+			return nil, ErrValidation(fmt.Sprintf("item %d is not on this channel", id))
+		}
+		if seen[id] {
+			return nil, ErrValidation(fmt.Sprintf("item %d is listed more than once", id))
+		}
+		seen[id] = true
+	}
+	if err := s.hold(ctx, channelID, 0); err != nil {
+		return nil, err
+	}
+	if err := s.items.Reorder(ctx, channelID, itemIDs); err != nil {
+		return nil, fmt.Errorf("reorder channel items: %w", err)
+	}
+	items, err := s.items.ListByChannel(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("list channel items: %w", err)
+	}
+	return items, nil
+}
+
+// hold saves the channel's cursor ahead of an edit that removes item removing, or none when it is
+// zero.
+func (s *PlaylistService) hold(ctx context.Context, channelID, removing uint) error {
+	if s.timeline == nil {
+		return nil
+	}
+	if err := s.timeline.hold(ctx, channelID, removing); err != nil {
+		return fmt.Errorf("hold channel cursor: %w", err)
+	}
+	return nil
+}
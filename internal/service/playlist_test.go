@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type stubChannelItemRepo struct {
+	items        []ChannelItem
+	listErr      error
+	appendErr    error
+	deleteErr    error
+	reorderErr   error
+	appended     *ChannelItem
+	gotReorder   []uint
+	gotDeleteIDs [2]uint
+}
+
+func (s *stubChannelItemRepo) ListByChannel(context.Context, uint) ([]ChannelItem, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	return s.items, nil
+}
+
+func (s *stubChannelItemRepo) Append(_ context.Context, item *ChannelItem) error {
+	if s.appendErr != nil {
+		return s.appendErr
+	}
+	item.ID = 9
+	s.appended = item
+	return nil
+}
+
+func (s *stubChannelItemRepo) Delete(_ context.Context, channelID, itemID uint) error {
+	s.gotDeleteIDs = [2]uint{channelID, itemID}
+	return s.deleteErr
+}
+
+func (s *stubChannelItemRepo) Reorder(_ context.Context, _ uint, itemIDs []uint) error {
+	s.gotReorder = itemIDs
+	return s.reorderErr
+}
+
+func TestPlaylistServiceItemsRequiresChannel(t *testing.T) {
+	channels := &stubChannelRepo{getErr: ErrNotFound}
+	svc := NewPlaylistService(channels, &stubRepo{}, &stubChannelItemRepo{})
+
+	_, err := svc.Items(context.Background(), 4)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if channels.gotGetID != 4 {
+		t.Fatalf("expected channel lookup for 4, got %d", channels.gotGetID)
+	}
+}
+
+func TestPlaylistServiceAddAppendsItemWithContent(t *testing.T) {
+	content := &stubRepo{getContent: &Content{ID: 3, Title: "Pilot"}}
+	items := &stubChannelItemRepo{}
+	svc := NewPlaylistService(&stubChannelRepo{}, content, items)
+
+	item := &ChannelItem{ChannelID: 1, ContentID: 3}
+	if err := svc.Add(context.Background(), item); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if items.appended == nil || item.ID != 9 {
+		t.Fatalf("expected item to be appended, got %+v", item)
+	}
+	if item.Content == nil || item.Content.Title != "Pilot" {
+		t.Fatalf("expected content to be attached, got %+v", item.Content)
+	}
+}
+
+func TestPlaylistServiceAddValidatesInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    *ChannelItem
+		content *stubRepo
+		wantMsg string
+	}{
+		{name: "nil item", item: nil, content: &stubRepo{}, wantMsg: "item is required"},
+		{name: "zero content", item: &ChannelItem{ChannelID: 1}, content: &stubRepo{}, wantMsg: "content id must be greater than zero"},
+		{
+			name:    "unknown content",
+			item:    &ChannelItem{ChannelID: 1, ContentID: 8},
+			content: &stubRepo{getByIDErr: ErrNotFound},
+			wantMsg: "content 8 does not exist",
+		},
+		{
+			name:    "content not in a transport stream",
+			item:    &ChannelItem{ChannelID: 1, ContentID: 8},
+			content: &stubRepo{getContent: &Content{ID: 8, Container: "mp4"}},
+			wantMsg: "content 8 is mp4",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			items := &stubChannelItemRepo{}
+			svc := NewPlaylistService(&stubChannelRepo{}, tc.content, items)
+
+			err := svc.Add(context.Background(), tc.item)
+			var ve ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if !strings.Contains(ve.Error(), tc.wantMsg) {
+				t.Fatalf("expected %q, got %q", tc.wantMsg, ve.Error())
+			}
+			if items.appended != nil {
+				t.Fatalf("expected append not to be called")
+			}
+		})
+	}
+}
+
+func TestPlaylistServiceAddAcceptsAnyContainerWithProfile(t *testing.T) {
+	content := &stubRepo{getContent: &Content{ID: 3, Title: "Pilot", Container: "matroska"}}
+	items := &stubChannelItemRepo{}
+	svc := NewPlaylistService(&stubChannelRepo{getChannel: &Channel{ID: 1, Profile: "720p"}}, content, items)
+
+	if err := svc.Add(context.Background(), &ChannelItem{ChannelID: 1, ContentID: 3}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if items.appended == nil {
+		t.Fatal("expected item to be appended")
+	}
+}
+
+func TestPlaylistServiceRemoveWrapsRepoError(t *testing.T) {
+	items := &stubChannelItemRepo{deleteErr: ErrNotFound}
+	svc := NewPlaylistService(&stubChannelRepo{}, &stubRepo{}, items)
+
+	err := svc.Remove(context.Background(), 2, 5)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if items.gotDeleteIDs != [2]uint{2, 5} {
+		t.Fatalf("unexpected delete ids: %v", items.gotDeleteIDs)
+	}
+}
+
+func TestPlaylistServiceReorderRequiresPermutation(t *testing.T) {
+	current := []ChannelItem{{ID: 1}, {ID: 2}, {ID: 3}}
+	tests := []struct {
+		name    string
+		ids     []uint
+		wantMsg string
+	}{
+		{name: "missing item", ids: []uint{1, 2}, wantMsg: "must list all 3 items"},
+		{name: "unknown item", ids: []uint{1, 2, 7}, wantMsg: "item 7 is not on this channel"},
+		{name: "duplicate item", ids: []uint{1, 2, 2}, wantMsg: "item 2 is listed more than once"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			items := &stubChannelItemRepo{items: current}
+			svc := NewPlaylistService(&stubChannelRepo{}, &stubRepo{}, items)
+
+			_, err := svc.Reorder(context.Background(), 1, tc.ids)
+			var ve ValidationError
+			if !errors.As(err, &ve) || !strings.Contains(ve.Error(), tc.wantMsg) {
+				t.Fatalf("expected validation error %q, got %v", tc.wantMsg, err)
+			}
+			if items.gotReorder != nil {
+				t.Fatalf("expected reorder not to be called")
+			}
+		})
+	}
+}
+
+func TestPlaylistServiceReorderCallsRepo(t *testing.T) {
+	items := &stubChannelItemRepo{items: []ChannelItem{{ID: 1}, {ID: 2}}}
+	svc := NewPlaylistService(&stubChannelRepo{}, &stubRepo{}, items)
+
+	if _, err := svc.Reorder(context.Background(), 1, []uint{2, 1}); err != nil {
+		t.Fatalf("reorder: %v", err)
+	}
+	if len(items.gotReorder) != 2 || items.gotReorder[0] != 2 || items.gotReorder[1] != 1 {
+		t.Fatalf("unexpected reorder ids: %v", items.gotReorder)
+	}
+}
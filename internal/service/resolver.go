@@ -0,0 +1,379 @@
+package service
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// checkpointEvery is how much of a schedule's walk passes between the checkpoints kept of it.
+const checkpointEvery = 24 * time.Hour
+
+// schedule resolves the timeline of a channel whose schedule blocks take over from its playlist at
+// set times of day. It walks forward from epoch one block airing at a time, filling the time
+// between airings from the playlist, so that in-order blocks carry on where their last airing
+// stopped and every airing gets the next sequence number. The walk is kept between lookups, which
+// makes looking up increasing times cheap, and checkpoints of it are kept in memo, which lets
+// lookups start near the time they look up rather than at epoch.
+type schedule struct {
+	tl     *timeline
+	blocks []*scheduledBlock
+	loc    *time.Location
+	walk   walkState
+	memo   *walkMemo
+}
+
+// walkState is how far a schedule's walk has got.
+type walkState struct {
+	// at is the time walked to, which is epoch or the start or end of a block airing.
+	at time.Time
+	// seq is the sequence number of the first airing at or after at.
+	seq int64
+	// cursors holds, for each in-order block, the index of the programme its next airing starts
+	// with.
+	cursors map[uint]int
+}
+
+// scheduledBlock is a block with its playable programmes and filler.
+type scheduledBlock struct {
+	block         ScheduleBlock
+	rule          blockRule
+	items         []ChannelItem
+	lengths       []time.Duration
+	filler        []ChannelItem
+	fillerLengths []time.Duration
+}
+
+// occurrence is one airing of a block.
+type occurrence struct {
+	block      *scheduledBlock
+	start, end time.Time
+}
+
+// schedule has the channel's blocks take over from its playlist from epoch on. Blocks without any
+// content of known length are left out.
+func (tl *timeline) schedule(blocks []ScheduleBlock) error {
+	loc, err := tl.channel.Location()
+	if err != nil {
+		return fmt.Errorf("load timezone: %w", err)
+	}
+	s := &schedule{tl: tl, loc: loc, memo: &walkMemo{}}
+	for _, b := range blocks {
+		rule, err := parseBlockRule(b.Days, b.Start, b.End)
+		if err != nil {
+			return fmt.Errorf("schedule block %d: %w", b.ID, err)
+		}
+		sb := &scheduledBlock{block: b, rule: rule}
+		sb.items, sb.lengths = playable(tl.channel, b.Programmes)
+		sb.filler, sb.fillerLengths = playable(tl.channel, b.Filler)
+		if len(sb.items) > 0 {
+			s.blocks = append(s.blocks, sb)
+		}
+	}
+	if len(s.blocks) > 0 {
+		s.reset()
+		tl.sched = s
+	}
+	return nil
+}
+
+// playable returns the content of known length that ch plays as items of ch, with their lengths.
+func playable(ch Channel, content []Content) ([]ChannelItem, []time.Duration) {
+	var items []ChannelItem
+	var lengths []time.Duration
+	for i, c := range content {
+		if c.Length <= 0 || !ch.Plays(&c) {
+			continue
+		}
+		items = append(items, ChannelItem{ChannelID: ch.ID, ContentID: c.ID, Position: i, Content: &c})
+		lengths = append(lengths, secondsToDuration(c.Length))
+	}
+	return items, lengths
+}
+
+func (s *schedule) reset() {
+	s.walk = walkState{at: s.tl.epoch, cursors: map[uint]int{}}
+}
+
+// seek moves the walk to the last checkpoint at or before t when that is nearer t than the walk
+// is, and otherwise back to epoch when the walk is past t.
+func (s *schedule) seek(t time.Time) {
+	cp, ok := s.memo.before(t)
+	switch {
+	case ok && (cp.at.After(s.walk.at) || t.Before(s.walk.at)):
+		s.walk = cp
+	case t.Before(s.walk.at):
+		s.reset()
+	}
+}
+
+// fingerprint identifies everything a schedule's walk depends on, so a walk memo is only reused for
+// a timeline that would walk the same way.
+func (s *schedule) fingerprint() [sha256.Size]byte {
+	h := sha256.New()
+	tl := s.tl
+	fmt.Fprintln(h, tl.channel.ID, tl.channel.Loop, tl.channel.Shuffle, s.loc, tl.epoch.UnixNano(), tl.origin.UnixNano())
+	for _, sl := range tl.slots {
+		for _, p := range sl.parts {
+			fmt.Fprint(h, p.item.ContentID, p.length, p.filler, ";")
+		}
+		fmt.Fprintln(h)
+	}
+	for _, b := range s.blocks {
+		fmt.Fprintln(h, b.block.ID, b.block.Days, b.block.Start, b.block.End, b.block.Shuffle)
+		for i, it := range b.items {
+			fmt.Fprint(h, it.ContentID, b.lengths[i], ";")
+		}
+		fmt.Fprintln(h)
+		for i, it := range b.filler {
+			fmt.Fprint(h, it.ContentID, b.fillerLengths[i], ";")
+		}
+		fmt.Fprintln(h)
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// walkMemo keeps checkpoints of a schedule's walk, about a day of walk apart. It is shared by the
+// timelines of a channel loaded while its schedule is unchanged, so they need not walk from epoch.
+type walkMemo struct {
+	mu sync.Mutex
+	// checkpoints are in order of at.
+	checkpoints []walkState
+}
+
+// before returns a copy of the last checkpoint at or before t.
+func (m *walkMemo) before(t time.Time) (walkState, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := sort.Search(len(m.checkpoints), func(i int) bool { return m.checkpoints[i].at.After(t) })
+	if i == 0 {
+		return walkState{}, false
+	}
+	cp := m.checkpoints[i-1]
+	cp.cursors = maps.Clone(cp.cursors)
+	return cp, true
+}
+
+// add records w when it is at least checkpointEvery past the last checkpoint, or past from when
+// there is none.
+func (m *walkMemo) add(w walkState, from time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n := len(m.checkpoints); n > 0 {
+		from = m.checkpoints[n-1].at
+	}
+	if w.at.Sub(from) < checkpointEvery {
+		return
+	}
+	w.cursors = maps.Clone(w.cursors)
+	m.checkpoints = append(m.checkpoints, w)
+}
+
+// walkMemos holds the walk memo of each channel's schedule.
+type walkMemos struct {
+	mu        sync.Mutex
+	byChannel map[uint]*keyedWalkMemo
+}
+
+type keyedWalkMemo struct {
+	key  [sha256.Size]byte
+	memo *walkMemo
+}
+
+// share has s use the memo kept for its channel, replacing it when the schedule has changed.
+func (ms *walkMemos) share(s *schedule) {
+	key := s.fingerprint()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.byChannel == nil {
+		ms.byChannel = map[uint]*keyedWalkMemo{}
+	}
+	id := s.tl.channel.ID
+	if m, ok := ms.byChannel[id]; ok && m.key == key {
+		s.memo = m.memo
+		return
+	}
+	ms.byChannel[id] = &keyedWalkMemo{key: key, memo: s.memo}
+}
+
+// at returns what airs at t, which must not be before epoch. When nothing does, resume is when the
+// gap with nothing to play ends.
+func (s *schedule) at(t time.Time) (a Airing, ok bool, resume time.Time) {
+	s.seek(t)
+	w := &s.walk
+	for {
+		o, found := s.next(w.at)
+		if !found {
+			return Airing{}, false, t
+		}
+		if t.Before(o.start) {
+			a, ok, _ := s.fill(w.at, o.start, t, w.seq)
+			return a, ok, o.start
+		}
+		_, _, n := s.fill(w.at, o.start, o.start, w.seq)
+		w.seq += n
+		w.at = o.start
+
+		airings, cursor, padFrom := s.layout(o, w.cursors[o.block.block.ID], w.seq)
+		if t.Before(o.end) {
+			for _, a := range airings {
+				if t.Before(a.End) {
+					a.Offset = t.Sub(a.Start).Seconds()
+					return a, true, t
+				}
+			}
+			a, ok, _ := s.fill(padFrom, o.end, t, w.seq+int64(len(airings)))
+			return a, ok, o.end
+		}
+		_, _, n = s.fill(padFrom, o.end, o.end, w.seq+int64(len(airings)))
+		w.seq += int64(len(airings)) + n
+		w.cursors[o.block.block.ID] = cursor
+		w.at = o.end
+		s.memo.add(*w, s.tl.epoch)
+	}
+}
+
+// next returns the first block airing starting at or after p. Every block airs at least once a
+// week, so there is one unless the channel has no blocks.
+func (s *schedule) next(p time.Time) (occurrence, bool) {
+	y, m, d := p.In(s.loc).Date()
+	for i := range 8 {
+		var first occurrence
+		found := false
+		for _, b := range s.blocks {
+			o, ok := b.on(y, m, d+i, s.loc)
+			if ok && !o.start.Before(p) && (!found || o.start.Before(first.start)) {
+				first, found = o, true
+			}
+		}
+		if found {
+			return first, true
+		}
+	}
+	return occurrence{}, false
+}
+
+// on returns the block's airing starting on the given local date, if it airs that day.
+func (b *scheduledBlock) on(y int, m time.Month, d int, loc *time.Location) (occurrence, bool) {
+	if !b.rule.days[time.Date(y, m, d, 12, 0, 0, 0, loc).Weekday()] {
+		return occurrence{}, false
+	}
+	start := time.Date(y, m, d, 0, b.rule.start, 0, 0, loc)
+	end := time.Date(y, m, d, 0, b.rule.start+b.rule.duration(), 0, 0, loc)
+	if !end.After(start) {
+		return occurrence{}, false
+	}
+	return occurrence{block: b, start: start, end: end}, true
+}
+
+// layout returns the airings of o, starting at programme cursor of an in-order block and numbered
+// from seq, followed by filler up to the block's end. It also returns the cursor the block's next
+// airing starts at and when the airings end, which is before o.end when the block has no filler.
+func (s *schedule) layout(o occurrence, cursor int, seq int64) ([]Airing, int, time.Time) {
+	b := o.block
+	var out []Airing
+	add := func(item ChannelItem, start, end time.Time, filler bool) {
+		out = append(out, Airing{
+			ChannelID: s.tl.channel.ID,
+			Item:      item,
+			Start:     start,
+			End:       end,
+			Sequence:  seq + int64(len(out)),
+			BlockID:   b.block.ID,
+			Filler:    filler,
+		})
+	}
+
+	n := len(b.items)
+	var order []int
+	if b.block.Shuffle {
+		order = make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		r := rand.New(rand.NewPCG(uint64(b.block.ID), uint64(o.start.Unix())))
+		r.Shuffle(n, func(i, j int) { order[i], order[j] = order[j], order[i] })
+		cursor = 0
+	}
+
+	start := o.start
+	played := 0
+	for start.Before(o.end) {
+		idx := (cursor + played) % n
+		if order != nil {
+			idx = order[idx]
+		}
+		end := start.Add(b.lengths[idx])
+		if end.After(o.end) {
+			if played > 0 {
+				break
+			}
+			// The first programme always airs, cut at the block's end, so an in-order block cannot
+			// get stuck on a programme longer than itself.
+			end = o.end
+		}
+		add(b.items[idx], start, end, false)
+		start = end
+		played++
+	}
+	next := (cursor + played) % n
+
+	for j := 0; start.Before(o.end) && len(b.filler) > 0; j++ {
+		idx := j % len(b.filler)
+		end := start.Add(b.fillerLengths[idx])
+		if end.After(o.end) {
+			end = o.end
+		}
+		add(b.filler[idx], start, end, true)
+		start = end
+	}
+	return out, next, start
+}
+
+// fill lays the channel's playlist over [from, to), starting from the beginning of the programme
+// the playlist airs at from and cutting the last airing short at to. It returns the airing at t
+// when t falls in the range, numbered on from seq, and otherwise how many airings the range holds.
+func (s *schedule) fill(from, to, t time.Time, seq int64) (a Airing, ok bool, n int64) {
+	tl := s.tl
+	cycle, i, _, ok := tl.slotAt(from)
+	if !ok || !from.Before(to) {
+		return Airing{}, false, 0
+	}
+	size := int64(len(tl.slots))
+	pos := cycle*size + int64(i)
+	start := from
+	for start.Before(to) {
+		cycle, i := pos/size, pos%size
+		if tl.channel.Loop && i == 0 {
+			// Skip whole cycles rather than stepping through each of their slots.
+			limit := to
+			if t.Before(limit) {
+				limit = t
+			}
+			if k := int64(limit.Sub(start) / tl.total); k > 0 {
+				start = start.Add(time.Duration(k) * tl.total)
+				pos += k * size
+				n += k * tl.parts
+				continue
+			}
+		}
+		if !tl.channel.Loop && cycle != 0 {
+			break
+		}
+		sl := tl.slots[tl.order(cycle)[i]]
+		a, ok, m := tl.partAt(sl, start, t, to, seq+n)
+		if ok {
+			return a, true, 0
+		}
+		start = start.Add(sl.length)
+		pos++
+		n += m
+	}
+	return Airing{}, false, n
+}
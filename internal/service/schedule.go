@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// weekdays are the day names blocks are scheduled with, indexed by time.Weekday.
+var weekdays = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+const (
+	minutesPerDay  = 24 * 60
+	minutesPerWeek = 7 * minutesPerDay
+)
+
+// ScheduleBlock takes over a channel at a set time of day, playing its own content instead of the
+// channel's playlist.
+type ScheduleBlock struct {
+	ID        uint   `json:"id"`
+	ChannelID uint   `json:"channelId"`
+	Title     string `json:"title"`
+	// Days lists the days the block airs on as sun, mon, ... sat. Empty means every day.
+	Days []string `json:"days"`
+	// Start and End are times of day as HH:MM in the channel's timezone. An End at or before
+	// Start runs past midnight into the next day.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Shuffle plays the content in a fresh order every time the block airs. Otherwise each airing
+	// carries on in order from where the previous one stopped.
+	Shuffle bool `json:"shuffle"`
+	// ContentIDs is the content the block plays, and FillerIDs the content that pads the time
+	// left when the next programme would overrun the block.
+	ContentIDs []uint `json:"contentIds"`
+	FillerIDs  []uint `json:"fillerIds"`
+	// CollectionID has the block play a collection's content instead of ContentIDs.
+	CollectionID uint `json:"collectionId,omitempty"`
+	// Programmes and Filler hold the content behind ContentIDs and FillerIDs, in order, when the
+	// block is loaded for a timeline.
+	Programmes []Content `json:"-"`
+	Filler     []Content `json:"-"`
+}
+
+type ScheduleBlockRepo interface {
+	// ListByChannel returns the channel's blocks with their content loaded.
+	ListByChannel(ctx context.Context, channelID uint) ([]ScheduleBlock, error)
+	GetByID(ctx context.Context, channelID, id uint) (*ScheduleBlock, error)
+	Create(ctx context.Context, b *ScheduleBlock) error
+	Update(ctx context.Context, b *ScheduleBlock) error
+	Delete(ctx context.Context, channelID, id uint) error
+}
+
+// ScheduleService manages the schedule blocks programmed onto each channel.
+type ScheduleService struct {
+	channels    ChannelRepo
+	content     ContentRepo
+	blocks      ScheduleBlockRepo
+	collections CollectionRepo
+}
+
+type ScheduleOption func(*ScheduleService)
+
+// WithScheduleCollections lets blocks play a collection instead of hand-picked content.
+func WithScheduleCollections(collections CollectionRepo) ScheduleOption {
+	return func(s *ScheduleService) {
+		s.collections = collections
+	}
+}
+
+func NewScheduleService(channels ChannelRepo, content ContentRepo, blocks ScheduleBlockRepo, opts ...ScheduleOption) *ScheduleService {
+	s := &ScheduleService{channels: channels, content: content, blocks: blocks}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *ScheduleService) List(ctx context.Context, channelID uint) ([]ScheduleBlock, error) {
+	if _, err := s.channels.GetByID(ctx, channelID); err != nil {
+		return nil, fmt.Errorf("get channel by id: %w", err)
+	}
+	blocks, err := s.blocks.ListByChannel(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("list schedule blocks: %w", err)
+	}
+	return blocks, nil
+}
+
+func (s *ScheduleService) Get(ctx context.Context, channelID, id uint) (*ScheduleBlock, error) {
+	b, err := s.blocks.GetByID(ctx, channelID, id)
+	if err != nil {
+		return nil, fmt.Errorf("get schedule block by id: %w", err)
+	}
+	return b, nil
+}
+
+func (s *ScheduleService) Create(ctx context.Context, b *ScheduleBlock) error {
+	if err := s.validate(ctx, b); err != nil {
+		return err
+	}
+	if err := s.blocks.Create(ctx, b); err != nil {
+		return fmt.Errorf("create schedule block: %w", err)
+	}
+	return nil
+}
+
+func (s *ScheduleService) Update(ctx context.Context, b *ScheduleBlock) error {
+	if b == nil || b.ID == 0 {
+		return ErrValidation("id must be greater than zero")
+	}
+	if _, err := s.blocks.GetByID(ctx, b.ChannelID, b.ID); err != nil {
+		return fmt.Errorf("get schedule block by id: %w", err)
+	}
+	if err := s.validate(ctx, b); err != nil {
+		return err
+	}
+	if err := s.blocks.Update(ctx, b); err != nil {
+		return fmt.Errorf("update schedule block: %w", err)
+	}
+	return nil
+}
+
+func (s *ScheduleService) Delete(ctx context.Context, channelID, id uint) error {
+	if err := s.blocks.Delete(ctx, channelID, id); err != nil {
+		return fmt.Errorf("delete schedule block: %w", err)
+	}
+	return nil
+}
+
+// validate checks b, normalises its days and times, and checks that it does not overlap the
+// channel's other blocks and that its content exists and can be played on the channel.
+func (s *ScheduleService) validate(ctx context.Context, b *ScheduleBlock) error {
+	if b == nil {
+		return ErrValidation("block is required")
+	}
+	ch, err := s.channels.GetByID(ctx, b.ChannelID)
+	if err != nil {
+		return fmt.Errorf("get channel by id: %w", err)
+	}
+	b.Title = strings.TrimSpace(b.Title)
+	rule, err := parseBlockRule(b.Days, b.Start, b.End)
+	if err != nil {
+		return err
+	}
+	b.Days, b.Start, b.End = rule.normalized()
+	if b.CollectionID != 0 {
+		if len(b.ContentIDs) > 0 {
+			return ErrValidation("a block has either content ids or a collection id, not both")
+		}
+		if err := checkCollection(ctx, s.collections, b.CollectionID); err != nil {
+			return err
+		}
+	} else if len(b.ContentIDs) == 0 {
+		return ErrValidation("content ids must list at least one content")
+	}
+
+	others, err := s.blocks.ListByChannel(ctx, b.ChannelID)
+	if err != nil {
+		return fmt.Errorf("list schedule blocks: %w", err)
+	}
+	for _, other := range others {
+		if other.ID == b.ID {
+			continue
+		}
+		otherRule, err := parseBlockRule(other.Days, other.Start, other.End)
+		if err != nil {
+			return fmt.Errorf("schedule block %d: %w", other.ID, err)
+		}
+		if rule.overlaps(otherRule) {
+			return ErrValidation(fmt.Sprintf("block overlaps block %d", other.ID))
+		}
+	}
+
+	for _, id := range slices.Concat(b.ContentIDs, b.FillerIDs) {
+		c, err := s.content.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return ErrValidation(fmt.Sprintf("content %d does not exist", id))
+			}
+			return fmt.Errorf("get content by id: %w", err)
+		}
+		if err := checkPlays(ch, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockRule is when a block airs: on days, from start to end minutes past local midnight.
+type blockRule struct {
+	days       [7]bool
+	start, end int
+}
+
+func parseBlockRule(days []string, start, end string) (blockRule, error) {
+	var r blockRule
+	for _, d := range days {
+		i := slices.Index(weekdays[:], strings.ToLower(strings.TrimSpace(d)))
+		if i < 0 {
+			return r, ErrValidation(fmt.Sprintf("unknown day %q, use sun, mon, tue, wed, thu, fri or sat", d))
+		}
+		r.days[i] = true
+	}
+	if len(days) == 0 {
+		r.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	var err error
+	if r.start, err = parseTimeOfDay(start); err != nil {
+		return r, ErrValidation(fmt.Sprintf("start %v", err))
+	}
+	if r.end, err = parseTimeOfDay(end); err != nil {
+		return r, ErrValidation(fmt.Sprintf("end %v", err))
+	}
+	return r, nil
+}
+
+// parseTimeOfDay returns the minutes past midnight of an HH:MM time.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("must be a time of day as HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// duration returns how many minutes each airing lasts. A block ending at or before its start runs
+// past midnight, so one starting and ending at the same time lasts a whole day.
+func (r blockRule) duration() int {
+	d := r.end - r.start
+	if d <= 0 {
+		d += minutesPerDay
+	}
+	return d
+}
+
+// normalized returns the rule's days and times in their canonical form.
+func (r blockRule) normalized() ([]string, string, string) {
+	days := []string{}
+	for i, on := range r.days {
+		if on {
+			days = append(days, weekdays[i])
+		}
+	}
+	format := func(m int) string { return fmt.Sprintf("%02d:%02d", m/60, m%60) }
+	return days, format(r.start), format(r.end)
+}
+
+// overlaps reports whether two rules air at the same time of the week.
+func (r blockRule) overlaps(o blockRule) bool {
+	for d, on := range r.days {
+		if !on {
+			continue
+		}
+		for od, oon := range o.days {
+			if !oon {
+				continue
+			}
+			a := d*minutesPerDay + r.start
+			b := od*minutesPerDay + o.start
+			// Compare on a week that wraps, so Saturday night runs into Sunday morning.
+			diff := ((b-a)%minutesPerWeek + minutesPerWeek) % minutesPerWeek
+			if diff < r.duration() || minutesPerWeek-diff < o.duration() {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubScheduleBlockRepo struct {
+	blocks    []ScheduleBlock
+	listErr   error
+	created   *ScheduleBlock
+	updated   *ScheduleBlock
+	deleteErr error
+}
+
+func (s *stubScheduleBlockRepo) ListByChannel(context.Context, uint) ([]ScheduleBlock, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	return s.blocks, nil
+}
+
+func (s *stubScheduleBlockRepo) GetByID(_ context.Context, _ uint, id uint) (*ScheduleBlock, error) {
+	for _, b := range s.blocks {
+		if b.ID == id {
+			return &b, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *stubScheduleBlockRepo) Create(_ context.Context, b *ScheduleBlock) error {
+	b.ID = 100
+	s.created = b
+	return nil
+}
+
+func (s *stubScheduleBlockRepo) Update(_ context.Context, b *ScheduleBlock) error {
+	s.updated = b
+	return nil
+}
+
+func (s *stubScheduleBlockRepo) Delete(context.Context, uint, uint) error {
+	return s.deleteErr
+}
+
+func TestScheduleServiceCreateValidatesInput(t *testing.T) {
+	existing := ScheduleBlock{ID: 1, Days: []string{"mon"}, Start: "06:00", End: "09:00", ContentIDs: []uint{1}}
+	tests := []struct {
+		name    string
+		in      ScheduleBlock
+		content *stubRepo
+		wantErr string
+	}{
+		{name: "unknown day", in: ScheduleBlock{Days: []string{"someday"}, Start: "10:00", End: "11:00", ContentIDs: []uint{1}}, wantErr: "unknown day"},
+		{name: "bad start", in: ScheduleBlock{Start: "25:00", End: "11:00", ContentIDs: []uint{1}}, wantErr: "start must be a time of day"},
+		{name: "bad end", in: ScheduleBlock{Start: "10:00", End: "noon", ContentIDs: []uint{1}}, wantErr: "end must be a time of day"},
+		{name: "no content", in: ScheduleBlock{Start: "10:00", End: "11:00"}, wantErr: "content ids must list"},
+		{name: "overlap", in: ScheduleBlock{Days: []string{"mon"}, Start: "08:00", End: "10:00", ContentIDs: []uint{1}}, wantErr: "overlaps block 1"},
+		{
+			name:    "missing content",
+			in:      ScheduleBlock{Start: "10:00", End: "11:00", ContentIDs: []uint{1}, FillerIDs: []uint{9}},
+			content: &stubRepo{getByIDErr: ErrNotFound},
+			wantErr: "content 1 does not exist",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content := tc.content
+			if content == nil {
+				content = &stubRepo{}
+			}
+			blocks := &stubScheduleBlockRepo{blocks: []ScheduleBlock{existing}}
+			svc := NewScheduleService(&stubChannelRepo{}, content, blocks)
+			err := svc.Create(context.Background(), &tc.in)
+			var ve ValidationError
+			if !errors.As(err, &ve) || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected validation error containing %q, got %v", tc.wantErr, err)
+			}
+			if blocks.created != nil {
+				t.Fatal("expected nothing to be created")
+			}
+		})
+	}
+}
+
+func TestScheduleServiceCreateNormalizesRule(t *testing.T) {
+	blocks := &stubScheduleBlockRepo{}
+	svc := NewScheduleService(&stubChannelRepo{}, &stubRepo{}, blocks)
+	b := &ScheduleBlock{Title: "  Mornings ", Days: []string{"Fri", " mon"}, Start: "6:00", End: "09:30", ContentIDs: []uint{1}}
+
+	if err := svc.Create(context.Background(), b); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if blocks.created != b || b.ID != 100 {
+		t.Fatalf("expected block to be created, got %+v", blocks.created)
+	}
+	if b.Title != "Mornings" || !slices.Equal(b.Days, []string{"mon", "fri"}) || b.Start != "06:00" || b.End != "09:30" {
+		t.Fatalf("unexpected normalized block %+v", b)
+	}
+}
+
+func TestScheduleServiceUpdateIgnoresItself(t *testing.T) {
+	blocks := &stubScheduleBlockRepo{blocks: []ScheduleBlock{{ID: 1, Start: "06:00", End: "09:00", ContentIDs: []uint{1}}}}
+	svc := NewScheduleService(&stubChannelRepo{}, &stubRepo{}, blocks)
+
+	if err := svc.Update(context.Background(), &ScheduleBlock{ID: 1, Start: "07:00", End: "10:00", ContentIDs: []uint{1}}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if blocks.updated == nil || blocks.updated.Start != "07:00" {
+		t.Fatalf("expected block to be updated, got %+v", blocks.updated)
+	}
+	if err := svc.Update(context.Background(), &ScheduleBlock{ID: 2, Start: "07:00", End: "10:00", ContentIDs: []uint{1}}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown block, got %v", err)
+	}
+}
+
+func TestBlockRuleOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b ScheduleBlock
+		want bool
+	}{
+		{name: "same day overlapping", a: ScheduleBlock{Days: []string{"mon"}, Start: "06:00", End: "09:00"}, b: ScheduleBlock{Days: []string{"mon"}, Start: "08:00", End: "10:00"}, want: true},
+		{name: "back to back", a: ScheduleBlock{Days: []string{"mon"}, Start: "06:00", End: "09:00"}, b: ScheduleBlock{Days: []string{"mon"}, Start: "09:00", End: "10:00"}},
+		{name: "different days", a: ScheduleBlock{Days: []string{"mon"}, Start: "06:00", End: "09:00"}, b: ScheduleBlock{Days: []string{"tue"}, Start: "06:00", End: "09:00"}},
+		{name: "past midnight", a: ScheduleBlock{Days: []string{"mon"}, Start: "22:00", End: "02:00"}, b: ScheduleBlock{Days: []string{"tue"}, Start: "01:00", End: "03:00"}, want: true},
+		{name: "past the end of the week", a: ScheduleBlock{Days: []string{"sat"}, Start: "23:00", End: "01:00"}, b: ScheduleBlock{Days: []string{"sun"}, Start: "00:30", End: "01:00"}, want: true},
+		{name: "whole day", a: ScheduleBlock{Days: []string{"wed"}, Start: "05:00", End: "05:00"}, b: ScheduleBlock{Start: "04:00", End: "04:30"}, want: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := parseBlockRule(tc.a.Days, tc.a.Start, tc.a.End)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			b, err := parseBlockRule(tc.b.Days, tc.b.Start, tc.b.End)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := a.overlaps(b); got != tc.want {
+				t.Fatalf("a overlaps b: expected %v, got %v", tc.want, got)
+			}
+			if got := b.overlaps(a); got != tc.want {
+				t.Fatalf("b overlaps a: expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func testContent(id uint, minutes float64) Content {
+	return Content{ID: id, Length: minutes * 60}
+}
+
+// newScheduledTimeline returns a timeline for a channel with a playlist of two 10 minute items and
+// the given blocks. testEpoch is a Monday.
+func newScheduledTimeline(ch Channel, blocks ...ScheduleBlock) *TimelineService {
+	channels := &stubChannelRepo{getChannel: &ch}
+	items := &stubChannelItemRepo{items: testItems(600, 600)}
+	return NewTimelineService(channels, items, testEpoch, WithSchedule(&stubScheduleBlockRepo{blocks: blocks}))
+}
+
+var morningBlock = ScheduleBlock{
+	ID:         7,
+	Start:      "06:00",
+	End:        "07:00",
+	Programmes: []Content{testContent(10, 25), testContent(11, 25), testContent(12, 25)},
+	Filler:     []Content{testContent(20, 4)},
+}
+
+func TestTimelineServiceScheduleBlockTakesOver(t *testing.T) {
+	svc := newScheduledTimeline(Channel{ID: 1, Loop: true}, morningBlock)
+	at := func(day int, clock string) time.Time {
+		d, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatalf("parse %s: %v", clock, err)
+		}
+		return testEpoch.AddDate(0, 0, day).Add(time.Duration(d.Hour())*time.Hour + time.Duration(d.Minute())*time.Minute)
+	}
+
+	tests := []struct {
+		name        string
+		at          time.Time
+		wantContent uint
+		wantBlock   uint
+		wantFiller  bool
+		wantStart   time.Time
+		wantEnd     time.Time
+		wantSeq     int64
+	}{
+		{name: "playlist before", at: at(0, "05:59"), wantContent: 2, wantStart: at(0, "05:50"), wantEnd: at(0, "06:00"), wantSeq: 35},
+		{name: "first programme", at: at(0, "06:10"), wantContent: 10, wantBlock: 7, wantStart: at(0, "06:00"), wantEnd: at(0, "06:25"), wantSeq: 36},
+		{name: "second programme", at: at(0, "06:30"), wantContent: 11, wantBlock: 7, wantStart: at(0, "06:25"), wantEnd: at(0, "06:50"), wantSeq: 37},
+		{name: "filler", at: at(0, "06:55"), wantContent: 20, wantBlock: 7, wantFiller: true, wantStart: at(0, "06:54"), wantEnd: at(0, "06:58"), wantSeq: 39},
+		{name: "filler cut at the end", at: at(0, "06:59"), wantContent: 20, wantBlock: 7, wantFiller: true, wantStart: at(0, "06:58"), wantEnd: at(0, "07:00"), wantSeq: 40},
+		{name: "playlist after", at: at(0, "07:05"), wantContent: 1, wantStart: at(0, "07:00"), wantEnd: at(0, "07:10"), wantSeq: 41},
+		{name: "next day carries on in order", at: at(1, "06:05"), wantContent: 12, wantBlock: 7, wantStart: at(1, "06:00"), wantEnd: at(1, "06:25")},
+		{name: "before epoch", at: at(-1, "06:05"), wantContent: 1, wantStart: at(-1, "06:00"), wantEnd: at(-1, "06:10"), wantSeq: -108},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := svc.At(context.Background(), 1, tc.at)
+			if err != nil {
+				t.Fatalf("at: %v", err)
+			}
+			if a.Item.ContentID != tc.wantContent || a.BlockID != tc.wantBlock || a.Filler != tc.wantFiller {
+				t.Fatalf("expected content %d of block %d (filler %v), got %+v", tc.wantContent, tc.wantBlock, tc.wantFiller, a)
+			}
+			if !a.Start.Equal(tc.wantStart) || !a.End.Equal(tc.wantEnd) {
+				t.Fatalf("expected %s to %s, got %s to %s", tc.wantStart, tc.wantEnd, a.Start, a.End)
+			}
+			if tc.wantSeq != 0 && a.Sequence != tc.wantSeq {
+				t.Fatalf("expected sequence %d, got %d", tc.wantSeq, a.Sequence)
+			}
+			if want := tc.at.Sub(a.Start).Seconds(); a.Offset != want {
+				t.Fatalf("expected offset %v, got %v", want, a.Offset)
+			}
+		})
+	}
+}
+
+func TestTimelineServiceScheduleIsContiguous(t *testing.T) {
+	svc := newScheduledTimeline(Channel{ID: 1, Loop: true}, morningBlock)
+	from := testEpoch.Add(5 * time.Hour)
+	airings, err := svc.Schedule(context.Background(), 1, from, from.Add(50*time.Hour))
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	blockAirings := 0
+	for i, a := range airings {
+		if a.BlockID != 0 {
+			blockAirings++
+		}
+		if i == 0 {
+			continue
+		}
+		prev := airings[i-1]
+		if !a.Start.Equal(prev.End) || a.Sequence != prev.Sequence+1 {
+			t.Fatalf("airing %d does not follow the last: %+v after %+v", i, a, prev)
+		}
+	}
+	if blockAirings != 3*5 {
+		t.Fatalf("expected three block airings, got %d airings from blocks", blockAirings)
+	}
+
+	// Looking times up out of order must give the same airings as walking forward.
+	for _, i := range []int{len(airings) - 1, 0, len(airings) / 2} {
+		a, err := svc.At(context.Background(), 1, airings[i].Start)
+		if err != nil {
+			t.Fatalf("at: %v", err)
+		}
+		if a.Sequence != airings[i].Sequence || a.Item.ContentID != airings[i].Item.ContentID {
+			t.Fatalf("expected %+v, got %+v", airings[i], a)
+		}
+	}
+}
+
+func TestTimelineServiceScheduleBlockDays(t *testing.T) {
+	block := morningBlock
+	block.Days = []string{"tue"}
+	svc := newScheduledTimeline(Channel{ID: 1, Loop: true}, block)
+
+	for day, want := range map[int]uint{0: 0, 1: 7, 2: 0, 8: 7} {
+		a, err := svc.At(context.Background(), 1, testEpoch.AddDate(0, 0, day).Add(6*time.Hour+5*time.Minute))
+		if err != nil {
+			t.Fatalf("at: %v", err)
+		}
+		if a.BlockID != want {
+			t.Fatalf("day %d: expected block %d, got %d", day, want, a.BlockID)
+		}
+	}
+}
+
+func TestTimelineServiceScheduleUsesChannelTimezone(t *testing.T) {
+	svc := newScheduledTimeline(Channel{ID: 1, Loop: true, Timezone: "America/New_York"}, morningBlock)
+
+	// 06:10 in New York on January 1 is 11:10 UTC.
+	a, err := svc.At(context.Background(), 1, testEpoch.Add(11*time.Hour+10*time.Minute))
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if a.BlockID != 7 || !a.Start.Equal(testEpoch.Add(11*time.Hour)) {
+		t.Fatalf("expected the block to start at 06:00 New York time, got %+v", a)
+	}
+}
+
+func TestTimelineServiceScheduleCutsLongProgramme(t *testing.T) {
+	block := ScheduleBlock{ID: 3, Start: "06:00", End: "06:20", Programmes: []Content{testContent(10, 25), testContent(11, 5)}}
+	svc := newScheduledTimeline(Channel{ID: 1, Loop: true}, block)
+
+	a, err := svc.At(context.Background(), 1, testEpoch.Add(6*time.Hour+19*time.Minute))
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if a.Item.ContentID != 10 || !a.End.Equal(testEpoch.Add(6*time.Hour+20*time.Minute)) {
+		t.Fatalf("expected the programme to be cut at the block's end, got %+v", a)
+	}
+	a, err = svc.At(context.Background(), 1, testEpoch.AddDate(0, 0, 1).Add(6*time.Hour))
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if a.Item.ContentID != 11 {
+		t.Fatalf("expected the next airing to move on to the next programme, got %+v", a)
+	}
+}
+
+func TestTimelineServiceScheduleWithoutFillerPlaysPlaylist(t *testing.T) {
+	block := ScheduleBlock{ID: 3, Start: "06:00", End: "07:00", Programmes: []Content{testContent(10, 25)}}
+	svc := newScheduledTimeline(Channel{ID: 1, Loop: true}, block)
+
+	a, err := svc.At(context.Background(), 1, testEpoch.Add(6*time.Hour+55*time.Minute))
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if a.BlockID != 0 || !a.Start.Equal(testEpoch.Add(6*time.Hour+50*time.Minute)) || !a.End.Equal(testEpoch.Add(7*time.Hour)) {
+		t.Fatalf("expected the playlist to fill the block's last ten minutes, got %+v", a)
+	}
+}
+
+func TestTimelineServiceScheduleShuffleIsDeterministic(t *testing.T) {
+	block := morningBlock
+	block.Shuffle = true
+	block.Filler = nil
+	first := newScheduledTimeline(Channel{ID: 1, Loop: true}, block)
+	again := newScheduledTimeline(Channel{ID: 1, Loop: true}, block)
+
+	for _, at := range []time.Duration{6*time.Hour + time.Minute, 6*time.Hour + 30*time.Minute} {
+		a, err := first.At(context.Background(), 1, testEpoch.Add(at))
+		if err != nil {
+			t.Fatalf("at: %v", err)
+		}
+		b, err := again.At(context.Background(), 1, testEpoch.Add(at))
+		if err != nil {
+			t.Fatalf("at: %v", err)
+		}
+		if a.BlockID != 7 || a.Item.ContentID != b.Item.ContentID {
+			t.Fatalf("expected the same shuffled programme, got %+v and %+v", a, b)
+		}
+	}
+}
+
+func TestTimelineServiceScheduleWithEmptyPlaylist(t *testing.T) {
+	ch := Channel{ID: 1}
+	channels := &stubChannelRepo{getChannel: &ch}
+	svc := NewTimelineService(channels, &stubChannelItemRepo{}, testEpoch, WithSchedule(&stubScheduleBlockRepo{blocks: []ScheduleBlock{morningBlock}}))
+
+	if _, err := svc.At(context.Background(), 1, testEpoch.Add(5*time.Hour)); !errors.Is(err, ErrNothingScheduled) {
+		t.Fatalf("expected ErrNothingScheduled outside the block, got %v", err)
+	}
+	airings, err := svc.Schedule(context.Background(), 1, testEpoch, testEpoch.Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if len(airings) != 10 || !airings[0].Start.Equal(testEpoch.Add(6*time.Hour)) || airings[5].Sequence != 5 {
+		t.Fatalf("expected two block airings, got %+v", airings)
+	}
+}
+
+func TestTimelineServiceScheduleReusesWalkAcrossLoads(t *testing.T) {
+	ch := Channel{ID: 1, Loop: true}
+	blocks := &stubScheduleBlockRepo{blocks: []ScheduleBlock{morningBlock}}
+	newService := func() *TimelineService {
+		return NewTimelineService(&stubChannelRepo{getChannel: &ch}, &stubChannelItemRepo{items: testItems(600, 600)}, testEpoch, WithSchedule(blocks))
+	}
+	svc := newService()
+	later := testEpoch.AddDate(1, 0, 0).Add(6*time.Hour + 10*time.Minute)
+	if _, err := svc.At(context.Background(), 1, later); err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if n := len(svc.walks.byChannel[1].memo.checkpoints); n < 360 {
+		t.Fatalf("expected a checkpoint for about every day walked, got %d", n)
+	}
+
+	// Lookups that start from checkpoints, forwards and backwards, match a walk from epoch.
+	for _, at := range []time.Time{later.Add(time.Hour), later.Add(-30 * 24 * time.Hour), later.Add(26 * time.Hour), testEpoch.Add(6 * time.Hour)} {
+		got, err := svc.At(context.Background(), 1, at)
+		if err != nil {
+			t.Fatalf("at: %v", err)
+		}
+		want, err := newService().At(context.Background(), 1, at)
+		if err != nil {
+			t.Fatalf("at: %v", err)
+		}
+		if !sameAiring(*got, *want) {
+			t.Fatalf("at %s: expected %+v, got %+v", at, want, got)
+		}
+	}
+
+	// Changing the schedule starts a new walk.
+	edited := morningBlock
+	edited.Filler = nil
+	blocks.blocks = []ScheduleBlock{edited}
+	got, err := svc.At(context.Background(), 1, later)
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	want, err := newService().At(context.Background(), 1, later)
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if !sameAiring(*got, *want) {
+		t.Fatalf("after edit: expected %+v, got %+v", want, got)
+	}
+}
+
+func sameAiring(a, b Airing) bool {
+	return a.Item.ContentID == b.Item.ContentID && a.Start.Equal(b.Start) && a.End.Equal(b.End) &&
+		a.Offset == b.Offset && a.Sequence == b.Sequence && a.BlockID == b.BlockID && a.Filler == b.Filler
+}
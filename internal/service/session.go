@@ -0,0 +1,188 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTunersBusy is returned when every tuner is already in use by a stream session.
+var ErrTunersBusy = errors.New("all tuners are busy")
+
+// ErrSessionStopped is returned when a client asks to hold a session again soon after its
+// session was stopped.
+var ErrSessionStopped = errors.New("session was stopped")
+
+// Session is a client watching a channel's live stream.
+type Session struct {
+	ID        uint      `json:"id"`
+	ChannelID uint      `json:"channelId"`
+	Client    string    `json:"client"`
+	Started   time.Time `json:"started"`
+	BytesSent int64     `json:"bytesSent"`
+}
+
+// ActiveSession is a running session. It holds a tuner until End is called.
+type ActiveSession struct {
+	Session
+	bytes    atomic.Int64
+	cancel   context.CancelFunc
+	registry *SessionRegistry
+	// idle ends a held session once it goes unused, after idleFor. It is nil for sessions that
+	// are not held.
+	idle    *time.Timer
+	idleFor time.Duration
+}
+
+// Sent counts n more bytes sent to the client.
+func (s *ActiveSession) Sent(n int) {
+	s.bytes.Add(int64(n))
+}
+
+// End frees the session's tuner. It is safe to call more than once, and after the session was
+// stopped.
+func (s *ActiveSession) End() {
+	s.cancel()
+	s.registry.remove(s)
+}
+
+// SessionRegistry tracks live stream sessions and hands out a fixed number of tuners.
+type SessionRegistry struct {
+	mu       sync.Mutex
+	tuners   int
+	lastID   uint
+	sessions map[uint]*ActiveSession
+	held     map[heldSession]*ActiveSession
+	// stopped holds when the clients whose held sessions were stopped may hold them again.
+	stopped map[heldSession]time.Time
+	now     func() time.Time
+}
+
+// heldSession identifies the session held for a client watching a channel.
+type heldSession struct {
+	channelID uint
+	client    string
+}
+
+// NewSessionRegistry returns a registry allowing up to tuners sessions at once.
+func NewSessionRegistry(tuners int) *SessionRegistry {
+	return &SessionRegistry{
+		tuners:   tuners,
+		sessions: map[uint]*ActiveSession{},
+		held:     map[heldSession]*ActiveSession{},
+		stopped:  map[heldSession]time.Time{},
+		now:      time.Now,
+	}
+}
+
+// Start registers a session for client watching channelID. The returned context is derived from
+// ctx and is cancelled when the session is stopped, so the stream should run under it.
+func (r *SessionRegistry) Start(ctx context.Context, channelID uint, client string) (*ActiveSession, context.Context, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.start(ctx, channelID, client)
+}
+
+// Hold returns the session of client watching channelID, starting one when they have none, and
+// keeps it until idle passes without another Hold for them. It lets clients that fetch a stream in
+// many short requests, as HLS players do, hold one tuner from the first request to the last. A
+// client whose held session was stopped gets ErrSessionStopped until idle has passed, so its
+// player cannot take the tuner straight back.
+func (r *SessionRegistry) Hold(channelID uint, client string, idle time.Duration) (*ActiveSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := heldSession{channelID: channelID, client: client}
+	if until, ok := r.stopped[key]; ok {
+		if r.now().Before(until) {
+			return nil, ErrSessionStopped
+		}
+		delete(r.stopped, key)
+	}
+	// A session whose timer has already fired is ending, so it is replaced rather than kept.
+	if s, ok := r.held[key]; ok && s.idle.Stop() {
+		s.idle.Reset(idle)
+		return s, nil
+	}
+
+	s, _, err := r.start(context.Background(), channelID, client)
+	if err != nil {
+		return nil, err
+	}
+	s.idle, s.idleFor = time.AfterFunc(idle, s.End), idle
+	r.held[key] = s
+	return s, nil
+}
+
+// start registers a session. The caller holds r.mu.
+func (r *SessionRegistry) start(ctx context.Context, channelID uint, client string) (*ActiveSession, context.Context, error) {
+	if len(r.sessions) >= r.tuners {
+		return nil, nil, ErrTunersBusy
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.lastID++
+	s := &ActiveSession{
+		Session:  Session{ID: r.lastID, ChannelID: channelID, Client: client, Started: r.now()},
+		cancel:   cancel,
+		registry: r,
+	}
+	r.sessions[s.ID] = s
+	return s, ctx, nil
+}
+
+// List returns the running sessions in the order they started.
+func (r *SessionRegistry) List() []Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Session, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		snapshot := s.Session
+		snapshot.BytesSent = s.bytes.Load()
+		out = append(out, snapshot)
+	}
+	slices.SortFunc(out, func(a, b Session) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return out
+}
+
+// Stop ends session id, cancelling its stream and freeing its tuner. A held session's client is
+// refused by Hold for the session's idle time.
+func (r *SessionRegistry) Stop(id uint) error {
+	r.mu.Lock()
+	s, ok := r.sessions[id]
+	if ok && s.idle != nil {
+		now := r.now()
+		for key, until := range r.stopped {
+			if !now.Before(until) {
+				delete(r.stopped, key)
+			}
+		}
+		r.stopped[heldSession{channelID: s.ChannelID, client: s.Client}] = now.Add(s.idleFor)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	s.End()
+	return nil
+}
+
+func (r *SessionRegistry) remove(s *ActiveSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sessions[s.ID] == s {
+		delete(r.sessions, s.ID)
+	}
+	if s.idle != nil {
+		s.idle.Stop()
+		key := heldSession{channelID: s.ChannelID, client: s.Client}
+		if r.held[key] == s {
+			delete(r.held, key)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionRegistryEnforcesTunerCount(t *testing.T) {
+	r := NewSessionRegistry(2)
+
+	first, _, err := r.Start(context.Background(), 1, "192.0.2.1:5000")
+	if err != nil {
+		t.Fatalf("start first session: %v", err)
+	}
+	if _, _, err := r.Start(context.Background(), 2, "192.0.2.2:5000"); err != nil {
+		t.Fatalf("start second session: %v", err)
+	}
+	if _, _, err := r.Start(context.Background(), 3, "192.0.2.3:5000"); !errors.Is(err, ErrTunersBusy) {
+		t.Fatalf("expected ErrTunersBusy, got %v", err)
+	}
+
+	first.End()
+	first.End()
+	if _, _, err := r.Start(context.Background(), 3, "192.0.2.3:5000"); err != nil {
+		t.Fatalf("expected the ended session's tuner to be free, got %v", err)
+	}
+	if got := len(r.List()); got != 2 {
+		t.Fatalf("expected 2 sessions, got %d", got)
+	}
+}
+
+func TestSessionRegistryListReportsSessions(t *testing.T) {
+	started := time.Date(2025, time.June, 1, 20, 0, 0, 0, time.UTC)
+	r := NewSessionRegistry(3)
+	r.now = func() time.Time { return started }
+
+	a, _, err := r.Start(context.Background(), 7, "192.0.2.1:5000")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	b, _, err := r.Start(context.Background(), 4, "192.0.2.2:6000")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	a.Sent(188)
+	a.Sent(376)
+	b.Sent(188)
+
+	want := []Session{
+		{ID: 1, ChannelID: 7, Client: "192.0.2.1:5000", Started: started, BytesSent: 564},
+		{ID: 2, ChannelID: 4, Client: "192.0.2.2:6000", Started: started, BytesSent: 188},
+	}
+	got := r.List()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d sessions, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("session %d: got %+v want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSessionRegistryStopCancelsStream(t *testing.T) {
+	r := NewSessionRegistry(1)
+	_, ctx, err := r.Start(context.Background(), 1, "192.0.2.1:5000")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	if err := r.Stop(1); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expected the session context to be cancelled")
+	}
+	if len(r.List()) != 0 {
+		t.Fatal("expected the stopped session to be removed")
+	}
+	if err := r.Stop(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSessionRegistryHoldKeepsOneSessionPerClientAndChannel(t *testing.T) {
+	r := NewSessionRegistry(2)
+
+	first, err := r.Hold(1, "192.0.2.1", time.Hour)
+	if err != nil {
+		t.Fatalf("hold: %v", err)
+	}
+	again, err := r.Hold(1, "192.0.2.1", time.Hour)
+	if err != nil {
+		t.Fatalf("hold again: %v", err)
+	}
+	if again != first {
+		t.Fatal("expected the client's session to be reused")
+	}
+	if _, err := r.Hold(2, "192.0.2.1", time.Hour); err != nil {
+		t.Fatalf("hold another channel: %v", err)
+	}
+	if _, err := r.Hold(1, "192.0.2.2", time.Hour); !errors.Is(err, ErrTunersBusy) {
+		t.Fatalf("expected ErrTunersBusy, got %v", err)
+	}
+
+	if err := r.Stop(first.ID); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if _, err := r.Hold(1, "192.0.2.1", time.Hour); !errors.Is(err, ErrSessionStopped) {
+		t.Fatalf("expected ErrSessionStopped right after stop, got %v", err)
+	}
+	r.now = func() time.Time { return time.Now().Add(time.Hour) }
+	replaced, err := r.Hold(1, "192.0.2.1", time.Hour)
+	if err != nil {
+		t.Fatalf("hold once the idle time passed: %v", err)
+	}
+	if replaced == first {
+		t.Fatal("expected a stopped session to be replaced")
+	}
+}
+
+func TestSessionRegistryHoldEndsIdleSessions(t *testing.T) {
+	r := NewSessionRegistry(1)
+	if _, err := r.Hold(1, "192.0.2.1", 10*time.Millisecond); err != nil {
+		t.Fatalf("hold: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(r.List()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the idle session to end")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := r.Hold(1, "192.0.2.2", time.Hour); err != nil {
+		t.Fatalf("expected the idle session's tuner to be free, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// airDateFormat is the layout of Episode.AirDate.
+const airDateFormat = "2006-01-02"
+
+// Episode places a piece of content in a show. An episode is numbered within its season, or
+// dated: dated episodes have an AirDate, and usually the year as their season and no number.
+type Episode struct {
+	// ShowID is the show the episode belongs to. It is filled in when content is loaded and
+	// ignored when saving, where Show names the show instead.
+	ShowID  uint   `json:"showId"`
+	Show    string `json:"show"`
+	Season  int    `json:"season"`
+	Episode int    `json:"episode"`
+	Title   string `json:"title,omitempty"`
+	// AirDate is when a dated episode first aired, as YYYY-MM-DD.
+	AirDate string `json:"airDate,omitempty"`
+}
+
+// Show is a series that content belongs to as episodes. Shows are made by saving content with an
+// Episode and cannot be edited on their own.
+type Show struct {
+	ID       uint   `json:"id"`
+	Title    string `json:"title"`
+	Episodes int    `json:"episodes"`
+}
+
+type ShowRepo interface {
+	List(ctx context.Context) ([]Show, error)
+	GetByID(ctx context.Context, id uint) (*Show, error)
+	// Episodes returns the show's content in episode order: by season, then episode number, then
+	// air date.
+	Episodes(ctx context.Context, showID uint) ([]Content, error)
+}
+
+// ShowService lists the shows in the library and their episodes.
+type ShowService struct {
+	repo ShowRepo
+}
+
+func NewShowService(repo ShowRepo) *ShowService {
+	return &ShowService{repo: repo}
+}
+
+func (s *ShowService) List(ctx context.Context) ([]Show, error) {
+	shows, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list shows: %w", err)
+	}
+	return shows, nil
+}
+
+func (s *ShowService) Get(ctx context.Context, id uint) (*Show, error) {
+	show, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get show by id: %w", err)
+	}
+	return show, nil
+}
+
+func (s *ShowService) Episodes(ctx context.Context, id uint) ([]Content, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	episodes, err := s.repo.Episodes(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("list show episodes: %w", err)
+	}
+	return episodes, nil
+}
+
+func validateEpisode(e *Episode) error {
+	e.Show = strings.TrimSpace(e.Show)
+	e.Title = strings.TrimSpace(e.Title)
+	e.AirDate = strings.TrimSpace(e.AirDate)
+	if e.Show == "" {
+		return ErrValidation("episode show is required")
+	}
+	if e.Season < 0 || e.Episode < 0 {
+		return ErrValidation("episode season and number must be non-negative")
+	}
+	if e.AirDate != "" {
+		if _, err := time.Parse(airDateFormat, e.AirDate); err != nil {
+			return ErrValidation(fmt.Sprintf("episode air date must be YYYY-MM-DD, got %q", e.AirDate))
+		}
+	}
+	if e.Episode == 0 && e.AirDate == "" {
+		return ErrValidation("episode needs a number or an air date")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type stubShowRepo struct {
+	shows    []Show
+	episodes map[uint][]Content
+}
+
+func (s *stubShowRepo) List(context.Context) ([]Show, error) {
+	return s.shows, nil
+}
+
+func (s *stubShowRepo) GetByID(_ context.Context, id uint) (*Show, error) {
+	for _, show := range s.shows {
+		if show.ID == id {
+			return &show, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *stubShowRepo) Episodes(_ context.Context, showID uint) ([]Content, error) {
+	return s.episodes[showID], nil
+}
+
+func TestShowServiceEpisodes(t *testing.T) {
+	repo := &stubShowRepo{
+		shows:    []Show{{ID: 1, Title: "Cheers", Episodes: 2}},
+		episodes: map[uint][]Content{1: {testContent(1, 30), testContent(2, 30)}},
+	}
+	svc := NewShowService(repo)
+
+	episodes, err := svc.Episodes(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("episodes: %v", err)
+	}
+	if len(episodes) != 2 {
+		t.Fatalf("expected 2 episodes, got %+v", episodes)
+	}
+	if _, err := svc.Episodes(context.Background(), 2); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown show, got %v", err)
+	}
+}
+
+func TestContentServiceCreateTrimsEpisode(t *testing.T) {
+	c := &Content{Title: "ok", Path: "/tmp/f.ts", Episode: &Episode{Show: " Nightly News ", Season: 2024, AirDate: " 2024-01-15 "}}
+	if err := NewContentService(&stubRepo{}).Create(context.Background(), c); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if c.Episode.Show != "Nightly News" || c.Episode.AirDate != "2024-01-15" {
+		t.Fatalf("expected a trimmed episode, got %+v", c.Episode)
+	}
+}
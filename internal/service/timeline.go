@@ -0,0 +1,425 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrNothingScheduled is returned when a channel has nothing airing at the requested time, either
+// because its playlist is empty or because a non-looping playlist has finished.
+var ErrNothingScheduled = errors.New("nothing scheduled")
+
+// Airing is a single playlist item on a channel's timeline.
+type Airing struct {
+	ChannelID uint        `json:"channelId"`
+	Item      ChannelItem `json:"item"`
+	Start     time.Time   `json:"start"`
+	End       time.Time   `json:"end"`
+	// Offset is how far into the item playback is at the requested time, in seconds.
+	Offset float64 `json:"offset"`
+	// Sequence numbers the channel's airings in order, counting from the first airing at the
+	// playlist's origin.
+	Sequence int64 `json:"sequence"`
+	// BlockID is the schedule block the airing belongs to, or zero for the channel's playlist.
+	BlockID uint `json:"blockId,omitempty"`
+	// Filler marks content from a break or padding a block to its end.
+	Filler bool `json:"filler,omitempty"`
+}
+
+// TimelineService works out what each channel is playing at a given time, as if every channel had
+// played its playlist continuously since epoch.
+type TimelineService struct {
+	channels    ChannelRepo
+	items       ChannelItemRepo
+	blocks      ScheduleBlockRepo
+	pools       FillerPoolRepo
+	collections CollectionRepo
+	cursors     CursorRepo
+	epoch       time.Time
+	now         func() time.Time
+	// walks keeps each channel's schedule walk between loads.
+	walks walkMemos
+}
+
+type TimelineOption func(*TimelineService)
+
+// WithSchedule lets channels' schedule blocks take over from their playlists.
+func WithSchedule(blocks ScheduleBlockRepo) TimelineOption {
+	return func(s *TimelineService) {
+		s.blocks = blocks
+	}
+}
+
+// WithFiller has channels play breaks from their filler pools after each programme.
+func WithFiller(pools FillerPoolRepo) TimelineOption {
+	return func(s *TimelineService) {
+		s.pools = pools
+	}
+}
+
+// WithCollections has playlist items and schedule blocks that reference a collection play its
+// content as it is when the timeline is loaded.
+func WithCollections(collections CollectionRepo) TimelineOption {
+	return func(s *TimelineService) {
+		s.collections = collections
+	}
+}
+
+func NewTimelineService(channels ChannelRepo, items ChannelItemRepo, epoch time.Time, opts ...TimelineOption) *TimelineService {
+	s := &TimelineService{channels: channels, items: items, epoch: epoch, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Epoch is the instant every channel is treated as having started playing.
+func (s *TimelineService) Epoch() time.Time {
+	return s.epoch
+}
+
+func (s *TimelineService) Now(ctx context.Context, channelID uint) (*Airing, error) {
+	return s.At(ctx, channelID, s.now())
+}
+
+func (s *TimelineService) At(ctx context.Context, channelID uint, t time.Time) (*Airing, error) {
+	tl, err := s.load(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := tl.at(t)
+	if !ok {
+		return nil, ErrNothingScheduled
+	}
+	return &a, nil
+}
+
+// Schedule returns the airings that overlap [from, to) in order. The first airing may have started
+// before from. A channel with nothing to play yields an empty schedule rather than an error.
+func (s *TimelineService) Schedule(ctx context.Context, channelID uint, from, to time.Time) ([]Airing, error) {
+	tl, err := s.load(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return tl.between(from, to), nil
+}
+
+// Programmes returns the programmes that overlap [from, to) in order, as a guide shows them: the
+// filler after a programme is folded into it, so a programme runs until the next one starts. The
+// first programme may have started before from, and the last may end after to.
+func (s *TimelineService) Programmes(ctx context.Context, channelID uint, from, to time.Time) ([]Airing, error) {
+	tl, err := s.load(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	airings := tl.between(from, to)
+	// Find the programme the filler at from belongs to.
+	for len(airings) > 0 && airings[0].Filler {
+		a, ok := tl.at(airings[0].Start.Add(-time.Nanosecond))
+		if !ok || !a.End.Equal(airings[0].Start) {
+			break
+		}
+		airings = append([]Airing{a}, airings...)
+	}
+	var out []Airing
+	for _, a := range airings {
+		if a.Filler && len(out) > 0 {
+			out[len(out)-1].End = a.End
+			continue
+		}
+		out = append(out, a)
+	}
+	// Run the last programme on to the end of its break, which can be past to.
+	for len(out) > 0 {
+		last := &out[len(out)-1]
+		a, ok := tl.at(last.End)
+		if !ok || !a.Filler || !a.Start.Equal(last.End) {
+			break
+		}
+		last.End = a.End
+	}
+	return out, nil
+}
+
+func (s *TimelineService) load(ctx context.Context, channelID uint) (*timeline, error) {
+	ch, err := s.channels.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("get channel by id: %w", err)
+	}
+	items, err := s.items.ListByChannel(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("list channel items: %w", err)
+	}
+	if items, err = s.expand(ctx, items); err != nil {
+		return nil, err
+	}
+	var pools []FillerPool
+	if s.pools != nil {
+		if pools, err = s.pools.ListByChannel(ctx, channelID); err != nil {
+			return nil, fmt.Errorf("list filler pools: %w", err)
+		}
+	}
+	tl := newTimeline(*ch, items, pools, s.epoch)
+	if s.cursors != nil && usesCursor(*ch) {
+		c, err := s.cursors.Get(ctx, channelID)
+		switch {
+		case err == nil:
+			tl.anchor(*c)
+		case !errors.Is(err, ErrNotFound):
+			return nil, fmt.Errorf("get cursor: %w", err)
+		}
+	}
+	if s.blocks == nil {
+		return tl, nil
+	}
+	blocks, err := s.blocks.ListByChannel(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("list schedule blocks: %w", err)
+	}
+	for i, b := range blocks {
+		if b.CollectionID == 0 || s.collections == nil {
+			continue
+		}
+		if blocks[i].Programmes, err = s.members(ctx, b.CollectionID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tl.schedule(blocks); err != nil {
+		return nil, fmt.Errorf("channel %d: %w", channelID, err)
+	}
+	if tl.sched != nil {
+		s.walks.share(tl.sched)
+	}
+	return tl, nil
+}
+
+// expand replaces playlist items that reference a collection with one item per piece of content
+// in it. The expanded items keep the ID of the item they came from.
+func (s *TimelineService) expand(ctx context.Context, items []ChannelItem) ([]ChannelItem, error) {
+	if s.collections == nil {
+		return items, nil
+	}
+	out := make([]ChannelItem, 0, len(items))
+	for _, it := range items {
+		if it.CollectionID == 0 {
+			out = append(out, it)
+			continue
+		}
+		members, err := s.members(ctx, it.CollectionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range members {
+			item := it
+			item.ContentID = c.ID
+			item.Content = &c
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+// members returns a collection's content. A collection deleted since it was referenced has none.
+func (s *TimelineService) members(ctx context.Context, id uint) ([]Content, error) {
+	c, err := s.collections.GetByID(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get collection by id: %w", err)
+	}
+	members, err := s.collections.Members(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("list collection %d content: %w", id, err)
+	}
+	return members, nil
+}
+
+// timeline lays a channel's playlist end to end from its origin, which is epoch unless a cursor
+// anchors it elsewhere. Each programme takes up a slot with the break after it. One pass over the
+// playlist is a cycle; looping channels repeat cycles in both directions and shuffled channels use
+// a fresh, deterministic order for every cycle. From epoch on, a channel's schedule blocks take
+// over at their times of day.
+type timeline struct {
+	channel Channel
+	slots   []slot
+	total   time.Duration
+	// parts counts the airings in a cycle.
+	parts  int64
+	epoch  time.Time
+	origin time.Time
+	cursor *Cursor
+	sched  *schedule
+
+	// orderCycle and orderIdx cache the order of the cycle looked up last.
+	orderCycle int64
+	orderIdx   []int
+}
+
+// slot is a programme followed by its break.
+type slot struct {
+	parts  []part
+	length time.Duration
+}
+
+// part is one airing within a slot.
+type part struct {
+	item   ChannelItem
+	length time.Duration
+	filler bool
+}
+
+func newTimeline(ch Channel, items []ChannelItem, pools []FillerPool, epoch time.Time) *timeline {
+	tl := &timeline{channel: ch, epoch: epoch, origin: epoch}
+	breaks := newBreaker(ch, pools)
+	for _, it := range items {
+		// Items without a known length cannot be placed on the timeline, and items the channel
+		// cannot play, such as those of a collection or left from before its profile was removed,
+		// are left off it rather than skipped while streaming.
+		if it.Content == nil || it.Content.Length <= 0 || !ch.Plays(it.Content) {
+			continue
+		}
+		d := secondsToDuration(it.Content.Length)
+		s := slot{parts: []part{{item: it, length: d}}, length: d}
+		for _, p := range breaks.after(len(tl.slots), d) {
+			s.parts = append(s.parts, p)
+			s.length += p.length
+		}
+		tl.slots = append(tl.slots, s)
+		tl.total += s.length
+		tl.parts += int64(len(s.parts))
+	}
+	return tl
+}
+
+func (tl *timeline) at(t time.Time) (Airing, bool) {
+	a, ok, _ := tl.lookup(t)
+	return a, ok
+}
+
+// lookup returns what airs at t. When nothing does, resume is when something next might, or t if
+// nothing ever will.
+func (tl *timeline) lookup(t time.Time) (a Airing, ok bool, resume time.Time) {
+	if tl.sched != nil && !t.Before(tl.epoch) {
+		return tl.sched.at(t)
+	}
+	a, ok = tl.playlistAt(t)
+	return a, ok, t
+}
+
+// playlistAt returns what the channel's playlist airs at t, ignoring schedule blocks.
+func (tl *timeline) playlistAt(t time.Time) (Airing, bool) {
+	cycle, pos, start, ok := tl.slotAt(t)
+	if !ok {
+		return Airing{}, false
+	}
+	seq := cycle * tl.parts
+	order := tl.order(cycle)
+	for _, i := range order[:pos] {
+		seq += int64(len(tl.slots[i].parts))
+	}
+	s := tl.slots[order[pos]]
+	a, ok, _ := tl.partAt(s, start, t, start.Add(s.length), seq)
+	return a, ok
+}
+
+// slotAt returns the cycle and position in it of the slot the playlist airs at t, and when the
+// slot starts.
+func (tl *timeline) slotAt(t time.Time) (cycle int64, pos int, start time.Time, ok bool) {
+	if tl.total <= 0 {
+		return 0, 0, time.Time{}, false
+	}
+	elapsed := t.Sub(tl.origin)
+	cycle = int64(elapsed / tl.total)
+	if elapsed < 0 && elapsed%tl.total != 0 {
+		cycle--
+	}
+	if !tl.channel.Loop && cycle != 0 {
+		return 0, 0, time.Time{}, false
+	}
+	start = tl.origin.Add(time.Duration(cycle) * tl.total)
+	for pos, i := range tl.order(cycle) {
+		end := start.Add(tl.slots[i].length)
+		if t.Before(end) {
+			return cycle, pos, start, true
+		}
+		start = end
+	}
+	return 0, 0, time.Time{}, false
+}
+
+// partAt returns the airing of slot s, which starts at start and is numbered from seq, that is on
+// at t, with parts cut short at to. When none is, it returns how many airings start before to.
+func (tl *timeline) partAt(s slot, start, t, to time.Time, seq int64) (a Airing, ok bool, n int64) {
+	for _, p := range s.parts {
+		if !start.Before(to) {
+			break
+		}
+		end := start.Add(p.length)
+		if end.After(to) {
+			end = to
+		}
+		if !t.Before(start) && t.Before(end) {
+			return Airing{
+				ChannelID: tl.channel.ID,
+				Item:      p.item,
+				Start:     start,
+				End:       end,
+				Offset:    t.Sub(start).Seconds(),
+				Sequence:  seq + n,
+				Filler:    p.filler,
+			}, true, 0
+		}
+		start = end
+		n++
+	}
+	return Airing{}, false, n
+}
+
+func (tl *timeline) between(from, to time.Time) []Airing {
+	var out []Airing
+	t := from
+	if !tl.channel.Loop && t.Before(tl.epoch) {
+		t = tl.epoch
+	}
+	for t.Before(to) {
+		a, ok, resume := tl.lookup(t)
+		if !ok {
+			// A schedule can leave gaps with nothing to play before its next block.
+			if !resume.After(t) {
+				break
+			}
+			t = resume
+			continue
+		}
+		out = append(out, a)
+		t = a.End
+	}
+	return out
+}
+
+// order returns the playlist indexes in the order they air during cycle. The result must not be
+// modified.
+func (tl *timeline) order(cycle int64) []int {
+	if tl.orderIdx != nil && tl.orderCycle == cycle {
+		return tl.orderIdx
+	}
+	idx := make([]int, len(tl.slots))
+	for i := range idx {
+		idx[i] = i
+	}
+	if tl.channel.Shuffle {
+		r := rand.New(rand.NewPCG(uint64(tl.channel.ID), uint64(cycle)))
+		r.Shuffle(len(idx), func(i, j int) { idx[i], idx[j] = idx[j], idx[i] })
+	}
+	tl.orderCycle, tl.orderIdx = cycle, idx
+	return idx
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func testItems(lengths ...float64) []ChannelItem {
+	items := make([]ChannelItem, len(lengths))
+	for i, l := range lengths {
+		id := uint(i + 1)
+		items[i] = ChannelItem{ID: id, ContentID: id, Position: i, Content: &Content{ID: id, Length: l}}
+	}
+	return items
+}
+
+func newTestTimeline(ch Channel, items []ChannelItem) *TimelineService {
+	channels := &stubChannelRepo{getChannel: &ch}
+	return NewTimelineService(channels, &stubChannelItemRepo{items: items}, testEpoch)
+}
+
+func TestTimelineServiceAtWalksPlaylist(t *testing.T) {
+	svc := newTestTimeline(Channel{ID: 1, Loop: true}, testItems(60, 30, 90))
+
+	tests := []struct {
+		name       string
+		at         time.Duration
+		wantItem   uint
+		wantStart  time.Duration
+		wantOffset float64
+		wantSeq    int64
+	}{
+		{name: "epoch", at: 0, wantItem: 1, wantStart: 0, wantOffset: 0, wantSeq: 0},
+		{name: "inside first", at: 59 * time.Second, wantItem: 1, wantStart: 0, wantOffset: 59, wantSeq: 0},
+		{name: "item boundary", at: 60 * time.Second, wantItem: 2, wantStart: 60 * time.Second, wantOffset: 0, wantSeq: 1},
+		{name: "last item", at: 100 * time.Second, wantItem: 3, wantStart: 90 * time.Second, wantOffset: 10, wantSeq: 2},
+		{name: "second cycle", at: 185 * time.Second, wantItem: 1, wantStart: 180 * time.Second, wantOffset: 5, wantSeq: 3},
+		{name: "before epoch", at: -10 * time.Second, wantItem: 3, wantStart: -90 * time.Second, wantOffset: 80, wantSeq: -1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := svc.At(context.Background(), 1, testEpoch.Add(tc.at))
+			if err != nil {
+				t.Fatalf("at: %v", err)
+			}
+			if a.Item.ID != tc.wantItem {
+				t.Fatalf("expected item %d, got %d", tc.wantItem, a.Item.ID)
+			}
+			if !a.Start.Equal(testEpoch.Add(tc.wantStart)) {
+				t.Fatalf("expected start %s, got %s", testEpoch.Add(tc.wantStart), a.Start)
+			}
+			if a.Offset != tc.wantOffset {
+				t.Fatalf("expected offset %v, got %v", tc.wantOffset, a.Offset)
+			}
+			if a.Sequence != tc.wantSeq {
+				t.Fatalf("expected sequence %d, got %d", tc.wantSeq, a.Sequence)
+			}
+			if got := a.End.Sub(a.Start).Seconds(); got != a.Item.Content.Length {
+				t.Fatalf("expected airing to span item length %v, got %v", a.Item.Content.Length, got)
+			}
+		})
+	}
+}
+
+func TestTimelineServiceWithoutLoopPlaysOnce(t *testing.T) {
+	svc := newTestTimeline(Channel{ID: 1}, testItems(60, 60))
+
+	if _, err := svc.At(context.Background(), 1, testEpoch.Add(90*time.Second)); err != nil {
+		t.Fatalf("expected item during first pass, got %v", err)
+	}
+	for _, at := range []time.Duration{120 * time.Second, -time.Second} {
+		if _, err := svc.At(context.Background(), 1, testEpoch.Add(at)); !errors.Is(err, ErrNothingScheduled) {
+			t.Fatalf("expected ErrNothingScheduled at %s, got %v", at, err)
+		}
+	}
+}
+
+func TestTimelineServiceSkipsItemsWithoutLength(t *testing.T) {
+	svc := newTestTimeline(Channel{ID: 1, Loop: true}, testItems(0, 45))
+
+	a, err := svc.At(context.Background(), 1, testEpoch)
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if a.Item.ID != 2 {
+		t.Fatalf("expected item without length to be skipped, got item %d", a.Item.ID)
+	}
+
+	empty := newTestTimeline(Channel{ID: 1, Loop: true}, testItems(0))
+	if _, err := empty.At(context.Background(), 1, testEpoch); !errors.Is(err, ErrNothingScheduled) {
+		t.Fatalf("expected ErrNothingScheduled, got %v", err)
+	}
+}
+
+func TestTimelineServiceLeavesOutContentTheChannelCannotPlay(t *testing.T) {
+	items := testItems(30, 45, 60)
+	items[0].Content.Container = "mp4"
+	items[1].Content.Container = "mpegts"
+
+	a, err := newTestTimeline(Channel{ID: 1, Loop: true}, items).At(context.Background(), 1, testEpoch)
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if a.Item.ID != 2 {
+		t.Fatalf("expected mp4 content to be left out, got item %d", a.Item.ID)
+	}
+
+	a, err = newTestTimeline(Channel{ID: 1, Loop: true, Profile: "720p"}, items).At(context.Background(), 1, testEpoch)
+	if err != nil {
+		t.Fatalf("at: %v", err)
+	}
+	if a.Item.ID != 1 {
+		t.Fatalf("expected a channel with a profile to play mp4 content, got item %d", a.Item.ID)
+	}
+}
+
+func TestTimelineServiceShuffleIsDeterministicPerCycle(t *testing.T) {
+	items := testItems(10, 10, 10, 10, 10, 10, 10, 10)
+	ch := Channel{ID: 3, Loop: true, Shuffle: true}
+
+	cycle := func(svc *TimelineService, n int) []uint {
+		var ids []uint
+		for i := range len(items) {
+			at := testEpoch.Add(time.Duration(n*len(items)+i) * 10 * time.Second)
+			a, err := svc.At(context.Background(), ch.ID, at)
+			if err != nil {
+				t.Fatalf("at: %v", err)
+			}
+			ids = append(ids, a.Item.ID)
+		}
+		return ids
+	}
+
+	first := cycle(newTestTimeline(ch, items), 0)
+	again := cycle(newTestTimeline(ch, items), 0)
+	seen := make(map[uint]bool)
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("expected the same order for the same cycle, got %v and %v", first, again)
+		}
+		seen[first[i]] = true
+	}
+	if len(seen) != len(items) {
+		t.Fatalf("expected every item once per cycle, got %v", first)
+	}
+
+	differs := false
+	for n := 1; n < 5 && !differs; n++ {
+		next := cycle(newTestTimeline(ch, items), n)
+		for i := range next {
+			if next[i] != first[i] {
+				differs = true
+			}
+		}
+	}
+	if !differs {
+		t.Fatalf("expected later cycles to be shuffled differently")
+	}
+}
+
+func TestTimelineServiceNowUsesClock(t *testing.T) {
+	svc := newTestTimeline(Channel{ID: 1, Loop: true}, testItems(60, 60))
+	svc.now = func() time.Time { return testEpoch.Add(75 * time.Second) }
+
+	a, err := svc.Now(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("now: %v", err)
+	}
+	if a.Item.ID != 2 || a.Offset != 15 {
+		t.Fatalf("unexpected airing: %+v", a)
+	}
+}
+
+func TestTimelineServiceWrapsChannelLookupError(t *testing.T) {
+	channels := &stubChannelRepo{getErr: ErrNotFound}
+	svc := NewTimelineService(channels, &stubChannelItemRepo{}, testEpoch)
+
+	if _, err := svc.At(context.Background(), 9, testEpoch); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTimelineServiceScheduleCoversWindow(t *testing.T) {
+	svc := newTestTimeline(Channel{ID: 1, Loop: true}, testItems(60, 30))
+
+	got, err := svc.Schedule(context.Background(), 1, testEpoch.Add(45*time.Second), testEpoch.Add(150*time.Second))
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	wantIDs := []uint{1, 2, 1}
+	if len(got) != len(wantIDs) {
+		t.Fatalf("expected %d airings, got %d", len(wantIDs), len(got))
+	}
+	for i, a := range got {
+		if a.Item.ID != wantIDs[i] {
+			t.Fatalf("airing %d: expected item %d, got %d", i, wantIDs[i], a.Item.ID)
+		}
+		if i > 0 && !a.Start.Equal(got[i-1].End) {
+			t.Fatalf("airing %d does not start where the previous one ended", i)
+		}
+	}
+	if !got[0].Start.Equal(testEpoch) {
+		t.Fatalf("expected first airing to start at the epoch, got %s", got[0].Start)
+	}
+}
+
+func TestTimelineServiceScheduleWithoutLoopStopsAfterOnePass(t *testing.T) {
+	svc := newTestTimeline(Channel{ID: 1}, testItems(60, 60))
+
+	got, err := svc.Schedule(context.Background(), 1, testEpoch.Add(-time.Hour), testEpoch.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if len(got) != 2 || !got[0].Start.Equal(testEpoch) {
+		t.Fatalf("expected a single pass starting at the epoch, got %+v", got)
+	}
+
+	empty := newTestTimeline(Channel{ID: 1, Loop: true}, nil)
+	got, err = empty.Schedule(context.Background(), 1, testEpoch, testEpoch.Add(time.Hour))
+	if err != nil || len(got) != 0 {
+		t.Fatalf("expected empty schedule, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/iamseth/tiny-headend/internal/probe"
+)
+
+// Track kinds.
+const (
+	TrackAudio    = probe.TrackAudio
+	TrackSubtitle = probe.TrackSubtitle
+)
+
+// Track is an audio or subtitle track of content, inside its media file or in a sidecar file next
+// to it. Tracks are read from the file by the prober.
+type Track struct {
+	Kind string `json:"kind"`
+	// Index counts the media file's tracks of the same kind from zero. It is zero for sidecar
+	// files.
+	Index int    `json:"index"`
+	Codec string `json:"codec"`
+	// Language is a language tag such as en or pt-BR, or empty when the file does not give one.
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Default  bool   `json:"default,omitempty"`
+	Forced   bool   `json:"forced,omitempty"`
+	// Path is the sidecar file holding the track, or empty for a track inside the media file.
+	Path string `json:"path,omitempty"`
+}
+
+// textSubtitleCodecs are the subtitle codecs that can be served as WebVTT.
+var textSubtitleCodecs = map[string]bool{"subrip": true, "webvtt": true, "ass": true}
+
+// IsText reports whether t is a subtitle sidecar file in a text format.
+func (t Track) IsText() bool {
+	return t.Kind == TrackSubtitle && t.Path != "" && textSubtitleCodecs[t.Codec]
+}
+
+// languages maps ISO 639-1 codes to their ISO 639-2 bibliographic and terminology codes, which
+// containers use, and to their English names.
+var languages = []struct {
+	code, bibliographic, terminology, name string
+}{
+	{"ar", "ara", "ara", "Arabic"},
+	{"cs", "cze", "ces", "Czech"},
+	{"da", "dan", "dan", "Danish"},
+	{"de", "ger", "deu", "German"},
+	{"el", "gre", "ell", "Greek"},
+	{"en", "eng", "eng", "English"},
+	{"es", "spa", "spa", "Spanish"},
+	{"fi", "fin", "fin", "Finnish"},
+	{"fr", "fre", "fra", "French"},
+	{"he", "heb", "heb", "Hebrew"},
+	{"hi", "hin", "hin", "Hindi"},
+	{"hu", "hun", "hun", "Hungarian"},
+	{"it", "ita", "ita", "Italian"},
+	{"ja", "jpn", "jpn", "Japanese"},
+	{"ko", "kor", "kor", "Korean"},
+	{"nl", "dut", "nld", "Dutch"},
+	{"no", "nor", "nor", "Norwegian"},
+	{"pl", "pol", "pol", "Polish"},
+	{"pt", "por", "por", "Portuguese"},
+	{"ru", "rus", "rus", "Russian"},
+	{"sv", "swe", "swe", "Swedish"},
+	{"th", "tha", "tha", "Thai"},
+	{"tr", "tur", "tur", "Turkish"},
+	{"uk", "ukr", "ukr", "Ukrainian"},
+	{"zh", "chi", "zho", "Chinese"},
+}
+
+// languagePattern matches a primary language subtag and optional further subtags.
+var languagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{1,8})*$`)
+
+// CanonicalLanguage rewrites a language tag the way tracks and channels store it: the primary
+// subtag lower case and, for common languages, as its two-letter code, so eng and en-US become en
+// and en-US. The undetermined tag und becomes empty.
+func CanonicalLanguage(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	primary, rest, _ := strings.Cut(tag, "-")
+	primary = strings.ToLower(primary)
+	if primary == "und" || primary == "" {
+		return ""
+	}
+	for _, l := range languages {
+		if primary == l.bibliographic || primary == l.terminology {
+			primary = l.code
+			break
+		}
+	}
+	if rest == "" {
+		return primary
+	}
+	return primary + "-" + rest
+}
+
+// LanguageName names a language tag in English, falling back to the tag itself.
+func LanguageName(tag string) string {
+	primary, _, _ := strings.Cut(CanonicalLanguage(tag), "-")
+	for _, l := range languages {
+		if primary == l.code {
+			return l.name
+		}
+	}
+	return tag
+}
+
+// SameLanguage reports whether two language tags name the same language, ignoring regions and
+// scripts.
+func SameLanguage(a, b string) bool {
+	a, _, _ = strings.Cut(CanonicalLanguage(a), "-")
+	b, _, _ = strings.Cut(CanonicalLanguage(b), "-")
+	return a != "" && a == b
+}
+
+// PreferredTrack picks the track of kind to play for a preferred language: the first in that
+// language, otherwise the one the file marks as default, otherwise the first. It reports false
+// when content has no tracks of kind.
+func PreferredTrack(tracks []Track, kind, language string) (Track, bool) {
+	var first, flagged *Track
+	for i := range tracks {
+		t := &tracks[i]
+		if t.Kind != kind {
+			continue
+		}
+		if language != "" && SameLanguage(t.Language, language) {
+			return *t, true
+		}
+		if first == nil {
+			first = t
+		}
+		if flagged == nil && t.Default {
+			flagged = t
+		}
+	}
+	switch {
+	case flagged != nil:
+		return *flagged, true
+	case first != nil:
+		return *first, true
+	}
+	return Track{}, false
+}
+
+// TracksFromProbe converts probed tracks, canonicalizing their languages.
+func TracksFromProbe(tracks []probe.Track) []Track {
+	if len(tracks) == 0 {
+		return nil
+	}
+	out := make([]Track, len(tracks))
+	for i, t := range tracks {
+		out[i] = Track{
+			Kind:     t.Kind,
+			Index:    t.Index,
+			Codec:    t.Codec,
+			Language: CanonicalLanguage(t.Language),
+			Title:    t.Title,
+			Default:  t.Default,
+			Forced:   t.Forced,
+			Path:     t.Path,
+		}
+	}
+	return out
+}
+
+// validateLanguage checks and canonicalizes a language preference. Empty means no preference.
+func validateLanguage(field string, tag *string) error {
+	*tag = strings.TrimSpace(*tag)
+	if *tag == "" {
+		return nil
+	}
+	if !languagePattern.MatchString(*tag) {
+		return ErrValidation(fmt.Sprintf("%s %q is not a language tag", field, *tag))
+	}
+	*tag = CanonicalLanguage(*tag)
+	return nil
+}
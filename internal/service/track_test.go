@@ -0,0 +1,51 @@
+package service
+
+import "testing"
+
+func TestCanonicalLanguage(t *testing.T) {
+	tests := map[string]string{
+		"eng":    "en",
+		"EN":     "en",
+		"ger":    "de",
+		"deu":    "de",
+		"en-US":  "en-US",
+		"pt_BR":  "pt-BR",
+		"und":    "",
+		"":       "",
+		"tlh":    "tlh",
+		" fre ":  "fr",
+		"zho-TW": "zh-TW",
+	}
+	for in, want := range tests {
+		if got := CanonicalLanguage(in); got != want {
+			t.Errorf("CanonicalLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPreferredTrack(t *testing.T) {
+	tracks := []Track{
+		{Kind: TrackSubtitle, Index: 0, Language: "en"},
+		{Kind: TrackAudio, Index: 0, Language: "en"},
+		{Kind: TrackAudio, Index: 1, Language: "fr-CA", Default: true},
+		{Kind: TrackAudio, Index: 2, Language: "de"},
+	}
+	tests := []struct {
+		language  string
+		wantIndex int
+	}{
+		{language: "de", wantIndex: 2},
+		{language: "fra", wantIndex: 1},
+		{language: "ja", wantIndex: 1},
+		{language: "", wantIndex: 1},
+	}
+	for _, tc := range tests {
+		got, ok := PreferredTrack(tracks, TrackAudio, tc.language)
+		if !ok || got.Kind != TrackAudio || got.Index != tc.wantIndex {
+			t.Errorf("PreferredTrack(%q) = %+v, want audio track %d", tc.language, got, tc.wantIndex)
+		}
+	}
+	if _, ok := PreferredTrack(tracks[:1], TrackAudio, "en"); ok {
+		t.Fatal("expected no audio track among subtitles")
+	}
+}
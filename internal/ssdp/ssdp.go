@@ -0,0 +1,296 @@
+// Package ssdp announces the emulated tuner on the LAN with SSDP so UPnP clients can find it
+// without the address being typed in.
+package ssdp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	groupAddr  = "239.255.255.250:1900"
+	maxAge     = 1800
+	serverName = "Linux/1.0 UPnP/1.0 tiny-headend/1.0"
+	maxDelay   = time.Second
+
+	// replyWorkers is how many searches are answered at once, each after its random delay.
+	replyWorkers = 4
+	// maxPendingSearches is how many searches can wait for a reply worker. Searches beyond it are
+	// dropped, so a flood of M-SEARCH packets cannot pile up goroutines or memory.
+	maxPendingSearches = 64
+
+	// DeviceType is the UPnP device type advertised for the tuner.
+	DeviceType = "urn:schemas-upnp-org:device:MediaServer:1"
+	rootDevice = "upnp:rootdevice"
+)
+
+// Config describes how the responder advertises the HTTP server.
+type Config struct {
+	// DeviceID is the HDHomeRun device ID the UDN is derived from.
+	DeviceID string
+	// BaseURL is the server's public URL. When empty, LOCATION is built from the local address
+	// that faces each client and the port of HTTPAddr.
+	BaseURL string
+	// HTTPAddr is the address the HTTP server listens on.
+	HTTPAddr string
+	// NotifyInterval is how often ssdp:alive announcements are multicast.
+	NotifyInterval time.Duration
+}
+
+// searchRequest is an M-SEARCH waiting to be answered.
+type searchRequest struct {
+	from net.Addr
+	st   string
+	mx   int
+}
+
+// Responder answers M-SEARCH queries and sends NOTIFY announcements.
+type Responder struct {
+	cfg   Config
+	udn   string
+	group *net.UDPAddr
+	// delay picks how long to wait before answering a search with the given MX.
+	delay func(mx int) time.Duration
+}
+
+func New(cfg Config) *Responder {
+	group, _ := net.ResolveUDPAddr("udp4", groupAddr)
+	return &Responder{cfg: cfg, udn: UDN(cfg.DeviceID), group: group, delay: randomDelay}
+}
+
+// UDN returns the stable UPnP unique device name for a device ID.
+func UDN(deviceID string) string {
+	sum := sha1.Sum([]byte("tiny-headend:" + deviceID))
+	sum[6] = (sum[6] & 0x0f) | 0x50 // version 5
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Run joins the SSDP multicast group and serves until ctx is done.
+func (r *Responder) Run(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, r.group)
+	if err != nil {
+		return fmt.Errorf("listen for ssdp: %w", err)
+	}
+	return r.Serve(ctx, conn)
+}
+
+// Serve answers searches arriving on conn and announces the device until ctx is done, then sends
+// ssdp:byebye and closes conn.
+func (r *Responder) Serve(ctx context.Context, conn net.PacketConn) error {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.announce(ctx, conn)
+	}()
+
+	go func() {
+		<-ctx.Done()
+		_ = conn.SetReadDeadline(time.Now())
+	}()
+
+	searches := make(chan searchRequest, maxPendingSearches)
+	for range replyWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range searches {
+				r.reply(ctx, conn, s.from, s.st, s.mx)
+			}
+		}()
+	}
+
+	buf := make([]byte, 2048)
+	var err error
+	for {
+		n, from, readErr := conn.ReadFrom(buf)
+		if readErr != nil {
+			if ctx.Err() == nil {
+				err = fmt.Errorf("read ssdp: %w", readErr)
+			}
+			break
+		}
+		st, mx, ok := parseSearch(buf[:n])
+		if !ok {
+			continue
+		}
+		select {
+		case searches <- searchRequest{from: from, st: st, mx: mx}:
+		default:
+			slog.Debug("drop ssdp search, too many pending", "from", from.String())
+		}
+	}
+
+	close(searches)
+	wg.Wait()
+	r.notify(conn, "ssdp:byebye", r.location(nil))
+	if closeErr := conn.Close(); closeErr != nil && err == nil && !errors.Is(closeErr, net.ErrClosed) {
+		err = fmt.Errorf("close ssdp: %w", closeErr)
+	}
+	return err
+}
+
+func (r *Responder) announce(ctx context.Context, conn net.PacketConn) {
+	r.notify(conn, "ssdp:alive", r.location(nil))
+	ticker := time.NewTicker(r.cfg.NotifyInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.notify(conn, "ssdp:alive", r.location(nil))
+		}
+	}
+}
+
+func (r *Responder) reply(ctx context.Context, conn net.PacketConn, to net.Addr, st string, mx int) {
+	timer := time.NewTimer(r.delay(mx))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+
+	location := r.location(to)
+	for _, target := range r.matching(st) {
+		if _, err := conn.WriteTo(r.searchResponse(target, location), to); err != nil {
+			slog.Error("send ssdp search response", "to", to.String(), "error", err)
+			return
+		}
+	}
+}
+
+func (r *Responder) notify(conn net.PacketConn, nts, location string) {
+	for _, target := range r.targets() {
+		if _, err := conn.WriteTo(r.notifyMessage(target, nts, location), r.group); err != nil {
+			slog.Error("send ssdp notify", "nts", nts, "error", err)
+			return
+		}
+	}
+}
+
+// targets lists every notification type the device advertises.
+func (r *Responder) targets() []string {
+	return []string{rootDevice, r.udn, DeviceType}
+}
+
+// matching returns the advertised targets a search for st should be answered with.
+func (r *Responder) matching(st string) []string {
+	if st == "ssdp:all" {
+		return r.targets()
+	}
+	for _, t := range r.targets() {
+		if strings.EqualFold(t, st) {
+			return []string{t}
+		}
+	}
+	return nil
+}
+
+func (r *Responder) usn(target string) string {
+	if target == r.udn {
+		return r.udn
+	}
+	return r.udn + "::" + target
+}
+
+func (r *Responder) searchResponse(st, location string) []byte {
+	var b bytes.Buffer
+	b.WriteString("HTTP/1.1 200 OK\r\n")
+	fmt.Fprintf(&b, "CACHE-CONTROL: max-age=%d\r\n", maxAge)
+	b.WriteString("EXT:\r\n")
+	fmt.Fprintf(&b, "LOCATION: %s\r\n", location)
+	fmt.Fprintf(&b, "SERVER: %s\r\n", serverName)
+	fmt.Fprintf(&b, "ST: %s\r\n", st)
+	fmt.Fprintf(&b, "USN: %s\r\n\r\n", r.usn(st))
+	return b.Bytes()
+}
+
+func (r *Responder) notifyMessage(nt, nts, location string) []byte {
+	var b bytes.Buffer
+	b.WriteString("NOTIFY * HTTP/1.1\r\n")
+	fmt.Fprintf(&b, "HOST: %s\r\n", groupAddr)
+	fmt.Fprintf(&b, "CACHE-CONTROL: max-age=%d\r\n", maxAge)
+	fmt.Fprintf(&b, "LOCATION: %s\r\n", location)
+	fmt.Fprintf(&b, "NT: %s\r\n", nt)
+	fmt.Fprintf(&b, "NTS: %s\r\n", nts)
+	fmt.Fprintf(&b, "SERVER: %s\r\n", serverName)
+	fmt.Fprintf(&b, "USN: %s\r\n\r\n", r.usn(nt))
+	return b.Bytes()
+}
+
+// location returns the device description URL as seen from peer. A nil peer means the
+// multicast group.
+func (r *Responder) location(peer net.Addr) string {
+	if r.cfg.BaseURL != "" {
+		return r.cfg.BaseURL + "/device.xml"
+	}
+	host, port, err := net.SplitHostPort(r.cfg.HTTPAddr)
+	if err != nil {
+		host, port = "", "80"
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = localIPFacing(peer, r.group)
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/device.xml"
+}
+
+// localIPFacing returns the local address the kernel would use to reach peer (or fallback when
+// peer is nil). No packets are sent.
+func localIPFacing(peer net.Addr, fallback *net.UDPAddr) string {
+	target := fallback.String()
+	if peer != nil {
+		target = peer.String()
+	}
+	c, err := net.Dial("udp4", target)
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer func() { _ = c.Close() }()
+	return c.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// parseSearch extracts the search target and MX from an M-SEARCH request.
+func parseSearch(data []byte) (string, int, bool) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil || req.Method != "M-SEARCH" {
+		return "", 0, false
+	}
+	if strings.Trim(req.Header.Get("MAN"), `"`) != "ssdp:discover" {
+		return "", 0, false
+	}
+	st := strings.TrimSpace(req.Header.Get("ST"))
+	if st == "" {
+		return "", 0, false
+	}
+	mx, err := strconv.Atoi(strings.TrimSpace(req.Header.Get("MX")))
+	if err != nil || mx < 0 {
+		mx = 1
+	}
+	return st, mx, true
+}
+
+// randomDelay spreads responses over the MX window as the spec asks, capped so clients with a
+// large MX are not kept waiting.
+func randomDelay(mx int) time.Duration {
+	window := min(time.Duration(mx)*time.Second, maxDelay)
+	if window <= 0 {
+		return 0
+	}
+	return rand.N(window)
+}
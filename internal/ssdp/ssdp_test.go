@@ -0,0 +1,241 @@
+package ssdp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testDeviceID = "ABCDEF01"
+
+func newTestResponder(t *testing.T) (*Responder, net.PacketConn) {
+	t.Helper()
+	group, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen group: %v", err)
+	}
+	t.Cleanup(func() { _ = group.Close() })
+
+	r := New(Config{DeviceID: testDeviceID, BaseURL: "http://tv.example.com", NotifyInterval: time.Hour})
+	r.group = group.LocalAddr().(*net.UDPAddr)
+	r.delay = func(int) time.Duration { return 0 }
+	return r, group
+}
+
+func startServe(t *testing.T, r *Responder) (net.Addr, context.CancelFunc, <-chan error) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Serve(ctx, conn) }()
+	t.Cleanup(cancel)
+	return conn.LocalAddr(), cancel, done
+}
+
+func readMessage(t *testing.T, conn net.PacketConn) (string, http.Header) {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("set deadline: %v", err)
+	}
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	tp := bufio.NewReader(bytes.NewReader(buf[:n]))
+	line, err := tp.ReadString('\n')
+	if err != nil {
+		t.Fatalf("read start line: %v", err)
+	}
+	header := http.Header{}
+	for {
+		l, err := tp.ReadString('\n')
+		if err != nil || strings.TrimSpace(l) == "" {
+			break
+		}
+		k, v, _ := strings.Cut(l, ":")
+		header.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return strings.TrimSpace(line), header
+}
+
+func search(st string) []byte {
+	return []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: " +
+		st + "\r\n\r\n")
+}
+
+func TestUDNIsStableAndWellFormed(t *testing.T) {
+	got := UDN(testDeviceID)
+	if got != UDN(testDeviceID) {
+		t.Fatal("expected UDN to be stable")
+	}
+	if got == UDN("10A1B2C3") {
+		t.Fatal("expected different devices to get different UDNs")
+	}
+	if len(got) != len("uuid:")+36 || !strings.HasPrefix(got, "uuid:") || got[5+14] != '5' {
+		t.Fatalf("unexpected UDN %q", got)
+	}
+}
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		wantST string
+		wantMX int
+		wantOK bool
+	}{
+		{name: "valid", data: string(search("ssdp:all")), wantST: "ssdp:all", wantMX: 1, wantOK: true},
+		{name: "missing mx", data: "M-SEARCH * HTTP/1.1\r\nMAN: \"ssdp:discover\"\r\nST: upnp:rootdevice\r\n\r\n",
+			wantST: "upnp:rootdevice", wantMX: 1, wantOK: true},
+		{name: "notify", data: "NOTIFY * HTTP/1.1\r\nNT: upnp:rootdevice\r\n\r\n"},
+		{name: "wrong man", data: "M-SEARCH * HTTP/1.1\r\nMAN: \"ssdp:other\"\r\nST: ssdp:all\r\n\r\n"},
+		{name: "missing st", data: "M-SEARCH * HTTP/1.1\r\nMAN: \"ssdp:discover\"\r\n\r\n"},
+		{name: "garbage", data: "hello"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			st, mx, ok := parseSearch([]byte(tc.data))
+			if ok != tc.wantOK || st != tc.wantST || mx != tc.wantMX {
+				t.Fatalf("got (%q, %d, %v), want (%q, %d, %v)", st, mx, ok, tc.wantST, tc.wantMX, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestMatching(t *testing.T) {
+	r := New(Config{DeviceID: testDeviceID})
+
+	if got := r.matching("ssdp:all"); len(got) != 3 {
+		t.Fatalf("expected all targets, got %v", got)
+	}
+	if got := r.matching(DeviceType); len(got) != 1 || got[0] != DeviceType {
+		t.Fatalf("expected device type, got %v", got)
+	}
+	if got := r.matching(UDN(testDeviceID)); len(got) != 1 || r.usn(got[0]) != UDN(testDeviceID) {
+		t.Fatalf("expected bare UDN, got %v", got)
+	}
+	if got := r.matching("urn:schemas-upnp-org:device:Printer:1"); len(got) != 0 {
+		t.Fatalf("expected no match, got %v", got)
+	}
+}
+
+func TestLocationUsesConfiguredHTTPAddr(t *testing.T) {
+	r := New(Config{DeviceID: testDeviceID, HTTPAddr: "192.168.1.5:9090"})
+	if got := r.location(nil); got != "http://192.168.1.5:9090/device.xml" {
+		t.Fatalf("unexpected location %q", got)
+	}
+
+	r = New(Config{DeviceID: testDeviceID, HTTPAddr: ":8080"})
+	peer := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1900}
+	if got := r.location(peer); got != "http://127.0.0.1:8080/device.xml" {
+		t.Fatalf("unexpected location %q", got)
+	}
+}
+
+func TestServeAnswersSearch(t *testing.T) {
+	r, _ := newTestResponder(t)
+	addr, _, _ := startServe(t, r)
+
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen client: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if _, err := client.WriteTo(search(DeviceType), addr); err != nil {
+		t.Fatalf("send search: %v", err)
+	}
+
+	line, header := readMessage(t, client)
+	if line != "HTTP/1.1 200 OK" {
+		t.Fatalf("unexpected status line %q", line)
+	}
+	if header.Get("ST") != DeviceType {
+		t.Fatalf("unexpected ST %q", header.Get("ST"))
+	}
+	if header.Get("USN") != UDN(testDeviceID)+"::"+DeviceType {
+		t.Fatalf("unexpected USN %q", header.Get("USN"))
+	}
+	if header.Get("LOCATION") != "http://tv.example.com/device.xml" {
+		t.Fatalf("unexpected LOCATION %q", header.Get("LOCATION"))
+	}
+}
+
+func TestServeBoundsConcurrentReplies(t *testing.T) {
+	r, _ := newTestResponder(t)
+	var waiting atomic.Int32
+	r.delay = func(int) time.Duration {
+		waiting.Add(1)
+		return time.Hour
+	}
+	addr, cancel, done := startServe(t, r)
+
+	client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen client: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	for range 4 * maxPendingSearches {
+		if _, err := client.WriteTo(search(DeviceType), addr); err != nil {
+			t.Fatalf("send search: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for waiting.Load() < replyWorkers && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := waiting.Load(); n != replyWorkers {
+		t.Fatalf("expected %d searches answered at once, got %d", replyWorkers, n)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve did not stop")
+	}
+}
+
+func TestServeAnnouncesAndSaysByeOnShutdown(t *testing.T) {
+	r, group := newTestResponder(t)
+	_, cancel, done := startServe(t, r)
+
+	for _, nt := range r.targets() {
+		line, header := readMessage(t, group)
+		if line != "NOTIFY * HTTP/1.1" || header.Get("NTS") != "ssdp:alive" || header.Get("NT") != nt {
+			t.Fatalf("unexpected announcement %q %v", line, header)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve did not stop")
+	}
+
+	for range r.targets() {
+		_, header := readMessage(t, group)
+		if header.Get("NTS") != "ssdp:byebye" {
+			t.Fatalf("expected byebye, got %v", header)
+		}
+	}
+}
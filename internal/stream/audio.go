@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"github.com/iamseth/tiny-headend/internal/probe"
+)
+
+// audioFilter keeps one audio track of a transport stream. Its PMTs are rewritten to list only
+// that track's stream, and the packets of the other audio streams are dropped, so players that
+// cannot switch audio tracks in a single stream play the one a channel prefers.
+type audioFilter struct {
+	packetSource
+	// track is the index of the audio track to keep, counted as the probe counts them.
+	track   int
+	pmtPIDs map[uint16]bool
+	dropped map[uint16]bool
+}
+
+func newAudioFilter(src packetSource, track int) *audioFilter {
+	return &audioFilter{packetSource: src, track: track, pmtPIDs: map[uint16]bool{}, dropped: map[uint16]bool{}}
+}
+
+func (f *audioFilter) next() ([]byte, error) {
+	for {
+		p, err := f.packetSource.next()
+		if err != nil {
+			return nil, err
+		}
+		pid := packetPID(p)
+		if f.dropped[pid] {
+			continue
+		}
+		if packetPUSI(p) {
+			switch section := psiSection(p[payloadOffset(p):]); {
+			case section == nil:
+			case pid == patPID && section[0] == 0x00:
+				for _, pmt := range patPMTPIDs(section) {
+					f.pmtPIDs[pmt] = true
+				}
+			case f.pmtPIDs[pid] && section[0] == 0x02:
+				f.filterPMT(p)
+			}
+		}
+		return p, nil
+	}
+}
+
+// filterPMT removes the audio streams other than the kept track from the PMT section starting in
+// packet p, and records their PIDs to drop. A PMT without the kept track is left as it is.
+func (f *audioFilter) filterPMT(p []byte) {
+	payload := p[payloadOffset(p):]
+	section := psiSection(payload)
+	if len(section) < 16 {
+		return
+	}
+	infoLen := int(section[10]&0x0F)<<8 | int(section[11])
+	if 12+infoLen > len(section)-4 {
+		return
+	}
+	head := section[:12+infoLen]
+	streams := section[12+infoLen : len(section)-4]
+
+	var kept []byte
+	dropped := map[uint16]bool{}
+	found := false
+	audio := 0
+	for i := 0; i+5 <= len(streams); {
+		pid := uint16(streams[i+1]&0x1F)<<8 | uint16(streams[i+2])
+		esInfoLen := int(streams[i+3]&0x0F)<<8 | int(streams[i+4])
+		end := min(i+5+esInfoLen, len(streams))
+		entry := streams[i:end]
+		i = end
+
+		if probe.TSAudioStream(entry[0], entry[5:]) {
+			keep := audio == f.track
+			audio++
+			if !keep {
+				dropped[pid] = true
+				continue
+			}
+			found = true
+		}
+		kept = append(kept, entry...)
+	}
+	if !found {
+		return
+	}
+
+	out := append(append(make([]byte, 0, len(section)), head...), kept...)
+	out = append(out, 0, 0, 0, 0)
+	length := len(out) - 3
+	out[1] = out[1]&0xF0 | byte(length>>8)&0x0F
+	out[2] = byte(length)
+	writeSectionCRC(out)
+	// The shorter section is followed by stuffing to the end of the packet.
+	start := 1 + int(payload[0])
+	n := copy(payload[start:], out)
+	for i := start + n; i < len(payload); i++ {
+		payload[i] = 0xFF
+	}
+	f.dropped = dropped
+}
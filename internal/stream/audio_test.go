@@ -0,0 +1,178 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const (
+	testAACPID      = 0x101
+	testAC3PID      = 0x102
+	testSubtitlePID = 0x103
+)
+
+// multiAudioPMT is a PMT listing video, English AAC, French AC-3 carried as private data, and DVB
+// subtitles.
+func multiAudioPMT() []byte {
+	return psiPacket(testPMTPID, 0x02, []byte{
+		0xE0 | testVideoPID>>8, testVideoPID & 0xFF, 0xF0, 0x00,
+		0x1B, 0xE0 | testVideoPID>>8, testVideoPID & 0xFF, 0xF0, 0x00,
+		0x0F, 0xE0 | testAACPID>>8, testAACPID & 0xFF, 0xF0, 0x06, 0x0A, 0x04, 'e', 'n', 'g', 0x00,
+		0x06, 0xE0 | testAC3PID>>8, testAC3PID & 0xFF, 0xF0, 0x08, 0x6A, 0x00, 0x0A, 0x04, 'f', 'r', 'a', 0x00,
+		0x06, 0xE0 | testSubtitlePID>>8, testSubtitlePID & 0xFF, 0xF0, 0x05, 0x59, 0x03, 'f', 'r', 'a',
+	})
+}
+
+// writeMultiAudioTSFile writes a transport stream with the streams of multiAudioPMT, one packet of
+// each per frame. It returns the path and the stream's length in seconds.
+func writeMultiAudioTSFile(t *testing.T, frames int) (string, float64) {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.Write(psiPacket(patPID, 0x00, []byte{0x00, 0x01, 0xE0 | testPMTPID>>8, testPMTPID & 0xFF}))
+	buf.Write(multiAudioPMT())
+	for i := range frames {
+		pcr := int64(i) * frameTicks
+		buf.Write(tsPacketBytes(testVideoPID, true, pcr, pesHeader(pcr+ptsDelay, byte(i))))
+		for _, pid := range []uint16{testAACPID, testAC3PID, testSubtitlePID} {
+			buf.Write(tsPacketBytes(pid, true, -1, []byte{0x00, 0x00, 0x01, 0xC0, 0x00, 0x00}))
+		}
+	}
+	path := filepath.Join(t.TempDir(), "film.ts")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path, float64(int64(frames)*frameTicks) / clockRate
+}
+
+type packetList struct {
+	packets [][]byte
+}
+
+func (l *packetList) next() ([]byte, error) {
+	if len(l.packets) == 0 {
+		return nil, io.EOF
+	}
+	p := l.packets[0]
+	l.packets = l.packets[1:]
+	return p, nil
+}
+
+func (l *packetList) Close() error { return nil }
+
+// pmtStreamPIDs returns the elementary stream PIDs a PMT packet lists, failing the test when its
+// CRC does not match.
+func pmtStreamPIDs(t *testing.T, p []byte) []uint16 {
+	t.Helper()
+	section := psiSection(p[payloadOffset(p):])
+	if section == nil {
+		t.Fatal("expected a PMT section")
+	}
+	n := len(section)
+	if crc := crc32MPEG(section[:n-4]); crc != uint32(section[n-4])<<24|uint32(section[n-3])<<16|uint32(section[n-2])<<8|uint32(section[n-1]) {
+		t.Fatal("PMT CRC does not match")
+	}
+	infoLen := int(section[10]&0x0F)<<8 | int(section[11])
+	streams := section[12+infoLen : n-4]
+	var pids []uint16
+	for i := 0; i+5 <= len(streams); i += 5 + (int(streams[i+3]&0x0F)<<8 | int(streams[i+4])) {
+		pids = append(pids, uint16(streams[i+1]&0x1F)<<8|uint16(streams[i+2]))
+	}
+	return pids
+}
+
+func TestAudioFilterKeepsOneAudioTrack(t *testing.T) {
+	pat := psiPacket(patPID, 0x00, []byte{0x00, 0x01, 0xE0 | testPMTPID>>8, testPMTPID & 0xFF})
+	src := &packetList{packets: [][]byte{
+		pat,
+		multiAudioPMT(),
+		tsPacketBytes(testVideoPID, true, 0, pesHeader(ptsDelay, 0)),
+		tsPacketBytes(testAACPID, true, -1, nil),
+		tsPacketBytes(testAC3PID, true, -1, nil),
+		tsPacketBytes(testSubtitlePID, true, -1, nil),
+	}}
+
+	f := newAudioFilter(src, 1)
+	var pids []uint16
+	for {
+		p, err := f.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if len(p) != packetSize {
+			t.Fatalf("expected %d-byte packets, got %d", packetSize, len(p))
+		}
+		pid := packetPID(p)
+		pids = append(pids, pid)
+		if pid == testPMTPID {
+			if got, want := pmtStreamPIDs(t, p), []uint16{testVideoPID, testAC3PID, testSubtitlePID}; !slices.Equal(got, want) {
+				t.Fatalf("expected the PMT to list %v, got %v", want, got)
+			}
+		}
+	}
+	if want := []uint16{patPID, testPMTPID, testVideoPID, testAC3PID, testSubtitlePID}; !slices.Equal(pids, want) {
+		t.Fatalf("expected packets %v, got %v", want, pids)
+	}
+}
+
+func TestAudioFilterKeepsPMTWithoutTrack(t *testing.T) {
+	pmt := multiAudioPMT()
+	src := &packetList{packets: [][]byte{
+		psiPacket(patPID, 0x00, []byte{0x00, 0x01, 0xE0 | testPMTPID>>8, testPMTPID & 0xFF}),
+		bytes.Clone(pmt),
+		tsPacketBytes(testAACPID, true, -1, nil),
+	}}
+
+	f := newAudioFilter(src, 5)
+	var n int
+	for {
+		p, err := f.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if packetPID(p) == testPMTPID && !bytes.Equal(p, pmt) {
+			t.Fatal("expected a PMT without the track to be left as it is")
+		}
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("expected every packet to be kept, got %d", n)
+	}
+}
+
+func TestSegmenterWriteSegmentKeepsPreferredAudio(t *testing.T) {
+	path, length := writeMultiAudioTSFile(t, 50)
+	it := trackedItem(path, length,
+		service.Track{Kind: service.TrackAudio, Index: 0, Codec: "aac", Language: "en", Default: true},
+		service.Track{Kind: service.TrackAudio, Index: 1, Codec: "ac3", Language: "fr"},
+	)
+	channels := stubChannelRepo{channel: service.Channel{ID: 1, Title: "Films", Loop: true, AudioLanguage: "fr"}}
+	timeline := service.NewTimelineService(channels, stubChannelItemRepo{items: []service.ChannelItem{it}}, testEpoch)
+	g := NewSegmenter(timeline, time.Second, 2, WithChannels(service.NewChannelService(channels)))
+
+	var buf bytes.Buffer
+	if err := g.WriteSegment(context.Background(), 1, 0, &buf); err != nil {
+		t.Fatalf("segment: %v", err)
+	}
+	seen := map[uint16]bool{}
+	for _, p := range decodeOutput(t, buf.Bytes()) {
+		seen[p.pid] = true
+	}
+	if seen[testAACPID] || !seen[testAC3PID] || !seen[testVideoPID] {
+		t.Fatalf("expected only the French audio with the video, got PIDs %v", seen)
+	}
+}
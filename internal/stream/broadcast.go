@@ -0,0 +1,241 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+const (
+	// feedBufferPackets is how many of the most recent packets a feed keeps for its viewers, about
+	// 6 MB. Viewers that fall further behind skip ahead.
+	feedBufferPackets = 1 << 15
+	// readBatchPackets is the most packets a viewer takes from the feed per write.
+	readBatchPackets = 64
+)
+
+// errFeedEnded is the end of a feed whose producer stopped without an error.
+var errFeedEnded = errors.New("feed ended")
+
+// Broadcaster shares one producer per channel between everyone watching it. The first viewer of a
+// channel starts a Streamer writing into a ring buffer, later viewers read the same buffer from the
+// latest point where a player can start decoding, and the producer stops when the last viewer
+// leaves. A viewer that cannot keep up skips ahead instead of holding the producer back.
+type Broadcaster struct {
+	streamer *Streamer
+	capacity int
+
+	mu    sync.Mutex
+	feeds map[uint]*feed
+}
+
+func NewBroadcaster(streamer *Streamer) *Broadcaster {
+	return &Broadcaster{streamer: streamer, capacity: feedBufferPackets, feeds: map[uint]*feed{}}
+}
+
+// Stream writes the channel to w like Streamer.Stream, sharing the producer with any other viewers
+// of the channel. Nothing is written when the producer fails before its first packet.
+func (b *Broadcaster) Stream(ctx context.Context, channelID uint, w io.Writer) error {
+	f, cur := b.join(ctx, channelID)
+	defer b.leave(f)
+
+	buf := make([]byte, readBatchPackets*packetSize)
+	for {
+		n, next, err := f.read(ctx, cur, buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, errFeedEnded) {
+				return nil
+			}
+			return err
+		}
+		cur = next
+		if _, err := w.Write(buf[:n]); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// join adds a viewer to the channel's feed, starting a producer when there is none, and returns
+// the position the viewer starts reading from.
+func (b *Broadcaster) join(ctx context.Context, channelID uint) (*feed, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, ok := b.feeds[channelID]
+	if !ok || f.ended() {
+		// The producer outlives the request that started it, so it only takes its values.
+		pctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = newFeed(channelID, b.capacity, cancel)
+		b.feeds[channelID] = f
+		go func() {
+			f.finish(b.streamer.Stream(pctx, channelID, f))
+		}()
+	}
+	f.viewers++
+	return f, f.joinPoint()
+}
+
+// leave removes a viewer, stopping the producer after the last one.
+func (b *Broadcaster) leave(f *feed) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if f.viewers--; f.viewers > 0 {
+		return
+	}
+	f.cancel()
+	if b.feeds[f.channelID] == f {
+		delete(b.feeds, f.channelID)
+	}
+}
+
+// feed is a ring buffer of a channel's packets written by one producer and read by many viewers.
+// Positions count packets from the start of the feed.
+type feed struct {
+	channelID uint
+	cancel    context.CancelFunc
+	// viewers is guarded by the Broadcaster's mutex.
+	viewers int
+
+	mu      sync.Mutex
+	ring    []byte
+	size    int64
+	end     int64
+	partial []byte
+	// lastPAT is the position of the latest PAT, and keyJoin that of the latest PAT followed by a
+	// random access point. Both are -1 until seen.
+	lastPAT   int64
+	keyJoin   int64
+	done      bool
+	err       error
+	available chan struct{}
+}
+
+func newFeed(channelID uint, capacity int, cancel context.CancelFunc) *feed {
+	return &feed{
+		channelID: channelID,
+		cancel:    cancel,
+		ring:      make([]byte, capacity*packetSize),
+		size:      int64(capacity),
+		lastPAT:   -1,
+		keyJoin:   -1,
+		available: make(chan struct{}),
+	}
+}
+
+// Write appends the producer's packets, overwriting the oldest, and wakes waiting viewers.
+func (f *feed) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := len(p)
+	if len(f.partial) > 0 {
+		need := packetSize - len(f.partial)
+		if len(p) < need {
+			f.partial = append(f.partial, p...)
+			return n, nil
+		}
+		f.partial = append(f.partial, p[:need]...)
+		f.appendPacket(f.partial)
+		f.partial = f.partial[:0]
+		p = p[need:]
+	}
+	for ; len(p) >= packetSize; p = p[packetSize:] {
+		f.appendPacket(p[:packetSize])
+	}
+	f.partial = append(f.partial, p...)
+
+	close(f.available)
+	f.available = make(chan struct{})
+	return n, nil
+}
+
+func (f *feed) appendPacket(p []byte) {
+	if packetPID(p) == patPID && packetPUSI(p) {
+		f.lastPAT = f.end
+	}
+	if packetRandomAccess(p) && f.lastPAT > f.keyJoin {
+		f.keyJoin = f.lastPAT
+	}
+	copy(f.ring[f.end%f.size*packetSize:], p)
+	f.end++
+}
+
+// finish records that the producer stopped, with err if it failed.
+func (f *feed) finish(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.done, f.err = true, err
+	close(f.available)
+	f.available = make(chan struct{})
+}
+
+func (f *feed) ended() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.done
+}
+
+// joinPoint returns where a viewer joining now starts: the latest PAT followed by a keyframe, or
+// failing that the latest PAT, or else the live edge.
+func (f *feed) joinPoint() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.joinPointLocked()
+}
+
+func (f *feed) joinPointLocked() int64 {
+	start := max(f.end-f.size, 0)
+	switch {
+	case f.keyJoin >= start:
+		return f.keyJoin
+	case f.lastPAT >= start:
+		return f.lastPAT
+	default:
+		return f.end
+	}
+}
+
+// read copies packets from position cur into buf, waiting for the producer when there are none
+// yet, and returns how many bytes were copied and the position to read from next. A position the
+// ring has already overwritten skips ahead to the join point. Once the producer has stopped and
+// every packet has been read, it returns the producer's error or errFeedEnded.
+func (f *feed) read(ctx context.Context, cur int64, buf []byte) (int, int64, error) {
+	for {
+		f.mu.Lock()
+		if start := f.end - f.size; cur < start {
+			next := f.joinPointLocked()
+			slog.Warn("slow viewer skipped ahead", "channel_id", f.channelID, "packets", next-cur)
+			cur = next
+		}
+		if cur < f.end {
+			n := 0
+			for ; cur < f.end && n+packetSize <= len(buf); cur++ {
+				off := cur % f.size * packetSize
+				n += copy(buf[n:], f.ring[off:off+packetSize])
+			}
+			f.mu.Unlock()
+			return n, cur, nil
+		}
+		if f.done {
+			err := f.err
+			f.mu.Unlock()
+			if err == nil {
+				err = errFeedEnded
+			}
+			return 0, cur, err
+		}
+		available := f.available
+		f.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return 0, cur, ctx.Err()
+		case <-available:
+		}
+	}
+}
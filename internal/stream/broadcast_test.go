@@ -0,0 +1,233 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/transcode/transcodetest"
+)
+
+// keyframePacket returns a video packet whose adaptation field marks a random access point.
+func keyframePacket() []byte {
+	p := tsPacketBytes(testVideoPID, true, 0, nil)
+	p[5] |= 0x40
+	return p
+}
+
+func patPacket() []byte {
+	return psiPacket(patPID, 0x00, []byte{0x00, 0x01, 0xE0 | testPMTPID>>8, testPMTPID & 0xFF})
+}
+
+func videoPacket() []byte {
+	return tsPacketBytes(testVideoPID, true, -1, nil)
+}
+
+func writePackets(t *testing.T, f *feed, packets ...[]byte) {
+	t.Helper()
+	for _, p := range packets {
+		if _, err := f.Write(p); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func TestFeedJoinPoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		packets [][]byte
+		want    int64
+	}{
+		{name: "empty", want: 0},
+		{name: "no pat", packets: [][]byte{videoPacket(), videoPacket()}, want: 2},
+		{
+			name:    "latest pat without keyframes",
+			packets: [][]byte{patPacket(), videoPacket(), patPacket(), videoPacket()},
+			want:    2,
+		},
+		{
+			name:    "pat before the latest keyframe",
+			packets: [][]byte{patPacket(), keyframePacket(), videoPacket(), patPacket(), videoPacket()},
+			want:    0,
+		},
+		{
+			name: "keyframe overwritten",
+			packets: [][]byte{
+				patPacket(), keyframePacket(), videoPacket(), videoPacket(),
+				patPacket(), videoPacket(), videoPacket(), videoPacket(), videoPacket(),
+			},
+			want: 4,
+		},
+		{
+			name: "everything overwritten",
+			packets: [][]byte{
+				patPacket(), keyframePacket(), videoPacket(), videoPacket(), videoPacket(),
+				videoPacket(), videoPacket(), videoPacket(), videoPacket(), videoPacket(),
+			},
+			want: 10,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFeed(1, 8, func() {})
+			writePackets(t, f, tc.packets...)
+			if got := f.joinPoint(); got != tc.want {
+				t.Fatalf("expected join point %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFeedWriteCarriesPartialPackets(t *testing.T) {
+	f := newFeed(1, 8, func() {})
+	stream := append(patPacket(), videoPacket()...)
+	writePackets(t, f, stream[:100], stream[100:200], stream[200:])
+
+	buf := make([]byte, 4*packetSize)
+	n, next, err := f.read(context.Background(), 0, buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if next != 2 || string(buf[:n]) != string(stream) {
+		t.Fatalf("expected both packets back intact, got %d bytes up to %d", n, next)
+	}
+}
+
+func TestFeedReadSkipsOverwrittenPackets(t *testing.T) {
+	f := newFeed(1, 4, func() {})
+	writePackets(t, f, videoPacket(), videoPacket(), videoPacket(), patPacket(), videoPacket(), videoPacket())
+
+	buf := make([]byte, 8*packetSize)
+	n, next, err := f.read(context.Background(), 1, buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if n != 3*packetSize || next != 6 || packetPID(buf) != patPID {
+		t.Fatalf("expected to skip to the pat at 3, got %d bytes up to %d", n, next)
+	}
+}
+
+func TestFeedReadWaitsForProducer(t *testing.T) {
+	f := newFeed(1, 4, func() {})
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := f.read(context.Background(), 0, make([]byte, packetSize))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("read returned %v before the producer wrote", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	writePackets(t, f, patPacket())
+	if err := <-done; err != nil {
+		t.Fatalf("read: %v", err)
+	}
+}
+
+func TestFeedReadReturnsProducerResult(t *testing.T) {
+	want := errors.New("no programme")
+	f := newFeed(1, 4, func() {})
+	writePackets(t, f, patPacket())
+	f.finish(want)
+
+	buf := make([]byte, 4*packetSize)
+	if n, next, err := f.read(context.Background(), 0, buf); err != nil || n != packetSize {
+		t.Fatalf("expected buffered packets before the error, got %d bytes, %v", n, err)
+	} else if _, _, err := f.read(context.Background(), next, buf); !errors.Is(err, want) {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+
+	f = newFeed(1, 4, func() {})
+	f.finish(nil)
+	if _, _, err := f.read(context.Background(), 0, buf); !errors.Is(err, errFeedEnded) {
+		t.Fatalf("expected errFeedEnded, got %v", err)
+	}
+}
+
+// viewer is a client of a Broadcaster reading through a pipe.
+type viewer struct {
+	r      *io.PipeReader
+	cancel context.CancelFunc
+	done   chan error
+}
+
+func watch(b *Broadcaster, channelID uint) *viewer {
+	ctx, cancel := context.WithCancel(context.Background())
+	r, w := io.Pipe()
+	v := &viewer{r: r, cancel: cancel, done: make(chan error, 1)}
+	go func() {
+		v.done <- b.Stream(ctx, channelID, w)
+	}()
+	return v
+}
+
+func (v *viewer) firstPacket(t *testing.T) []byte {
+	t.Helper()
+	p := make([]byte, packetSize)
+	if _, err := io.ReadFull(v.r, p); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return p
+}
+
+func (v *viewer) stop(t *testing.T) {
+	t.Helper()
+	v.cancel()
+	_ = v.r.Close()
+	select {
+	case err := <-v.done:
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("viewer did not stop")
+	}
+}
+
+func TestBroadcasterSharesProducer(t *testing.T) {
+	fake := &transcodetest.Fake{Length: time.Minute}
+	timeline, opt := transcodedTimeline("720p", fake, item(1, "/media/film.mkv", 60))
+	s := New(timeline, opt)
+	s.now = func() time.Time { return testEpoch }
+	// The clock never moves, so the producer stops at its lead and waits there.
+	s.sleep = func(ctx context.Context, _ time.Duration) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	b := NewBroadcaster(s)
+
+	first := watch(b, 1)
+	first.firstPacket(t)
+	second := watch(b, 1)
+	if p := second.firstPacket(t); packetPID(p) != patPID {
+		t.Fatalf("expected the late viewer to start at a pat, got pid %#x", packetPID(p))
+	}
+	if calls := fake.Calls(); len(calls) != 1 {
+		t.Fatalf("expected one shared producer, got %d transcodes", len(calls))
+	}
+
+	b.mu.Lock()
+	f := b.feeds[1]
+	b.mu.Unlock()
+	first.stop(t)
+	if f.ended() {
+		t.Fatal("producer stopped while a viewer was still watching")
+	}
+	second.stop(t)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !f.ended() {
+		if time.Now().After(deadline) {
+			t.Fatal("producer did not stop after the last viewer left")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(b.feeds) != 0 {
+		t.Fatalf("expected no feeds left, got %d", len(b.feeds))
+	}
+}
@@ -0,0 +1,179 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+// Segment is one HLS media segment of a channel.
+type Segment struct {
+	Sequence int64
+	Start    time.Time
+	Duration time.Duration
+	// Discontinuity marks a segment that starts a different programme from the segment before it.
+	Discontinuity bool
+	airing        service.Airing
+}
+
+// MediaPlaylist is the sliding window of segments a live HLS playlist lists.
+type MediaPlaylist struct {
+	// TargetDuration is the longest a segment can be, in whole seconds.
+	TargetDuration        int
+	MediaSequence         int64
+	DiscontinuitySequence int64
+	Segments              []Segment
+}
+
+// Segmenter presents channel timelines as live HLS. Segments sit on a grid of fixed-length slots
+// counted from the timeline epoch, so their sequence numbers follow the wall clock. Where a
+// programme starts inside a slot, that slot's segment starts with the programme instead, so no
+// segment spans two programmes and segment lengths range up to twice the slot length.
+type Segmenter struct {
+	timeline *service.TimelineService
+	opener   opener
+	duration time.Duration
+	window   int
+}
+
+// NewSegmenter returns a segmenter cutting duration-long slots and listing window segments per
+// playlist.
+func NewSegmenter(timeline *service.TimelineService, duration time.Duration, window int, opts ...Option) *Segmenter {
+	return &Segmenter{timeline: timeline, opener: newOpener(opts), duration: duration, window: window}
+}
+
+// Playlist returns the window of segments ending with the one airing at now. The window stops
+// short at a gap with nothing airing.
+func (g *Segmenter) Playlist(ctx context.Context, channelID uint, now time.Time) (*MediaPlaylist, error) {
+	last := g.slot(now)
+	segments, err := g.segments(ctx, channelID, last-int64(g.window)+1, last)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, service.ErrNothingScheduled
+	}
+	// Sequence numbers must run on without a break within a playlist, so a gap in the schedule
+	// starts the window after it.
+	for i := len(segments) - 1; i > 0; i-- {
+		if segments[i].Sequence != segments[i-1].Sequence+1 {
+			segments = segments[i:]
+			break
+		}
+	}
+
+	// Each programme adds one discontinuity, so the first segment's count is the number of
+	// programmes before its own. Its tag, if any, belongs to segments that have left the window.
+	segments[0].Discontinuity = false
+	return &MediaPlaylist{
+		TargetDuration:        int(math.Ceil((2 * g.duration).Seconds())),
+		MediaSequence:         segments[0].Sequence,
+		DiscontinuitySequence: segments[0].airing.Sequence,
+		Segments:              segments,
+	}, nil
+}
+
+// WriteSegment writes the transport stream packets of segment seq to w. The programme's own
+// timestamps are kept, since playlists mark every programme change as a discontinuity.
+func (g *Segmenter) WriteSegment(ctx context.Context, channelID uint, seq int64, w io.Writer) error {
+	segments, err := g.segments(ctx, channelID, seq, seq)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return service.ErrNothingScheduled
+	}
+	seg := segments[0]
+	a := seg.airing
+
+	from := seg.Start.Sub(a.Start).Seconds()
+	to := airingEnd(a)
+	if end := seg.Start.Add(seg.Duration); end.Before(a.End) {
+		to = end.Sub(a.Start).Seconds()
+	}
+	src, err := g.opener.open(ctx, a, from, to)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	for {
+		pkt, err := src.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read media file: %w", err)
+		}
+		if _, err := w.Write(pkt); err != nil {
+			return fmt.Errorf("write segment: %w", err)
+		}
+	}
+}
+
+// segments returns the segments in slots first to last that have something airing.
+func (g *Segmenter) segments(ctx context.Context, channelID uint, first, last int64) ([]Segment, error) {
+	// Boundaries depend on programmes starting in the slot before first and the slot after last.
+	airings, err := g.timeline.Schedule(ctx, channelID, g.slotStart(first-1), g.slotStart(last+1).Add(time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Segment
+	for k := first; k <= last; k++ {
+		slot := g.slotStart(k)
+		a, ok := airingAt(airings, slot)
+		if !ok {
+			continue
+		}
+		start := slot
+		if a.Start.After(g.slotStart(k - 1)) {
+			start = a.Start
+		}
+		end := g.slotStart(k + 1)
+		if next, ok := airingAt(airings, end); ok && next.Start.After(slot) {
+			end = next.Start
+		}
+		if a.End.Before(end) {
+			end = a.End
+		}
+		out = append(out, Segment{
+			Sequence:      k,
+			Start:         start,
+			Duration:      end.Sub(start),
+			Discontinuity: start.Equal(a.Start),
+			airing:        a,
+		})
+	}
+	return out, nil
+}
+
+// slot returns the slot t falls in.
+func (g *Segmenter) slot(t time.Time) int64 {
+	elapsed := t.Sub(g.timeline.Epoch())
+	k := int64(elapsed / g.duration)
+	if elapsed < 0 && elapsed%g.duration != 0 {
+		k--
+	}
+	return k
+}
+
+func (g *Segmenter) slotStart(k int64) time.Time {
+	return g.timeline.Epoch().Add(time.Duration(k) * g.duration)
+}
+
+func airingAt(airings []service.Airing, t time.Time) (service.Airing, bool) {
+	for _, a := range airings {
+		if !t.Before(a.Start) && t.Before(a.End) {
+			return a, true
+		}
+	}
+	return service.Airing{}, false
+}
@@ -0,0 +1,152 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func newTestSegmenter(loop bool, duration time.Duration, window int, items ...service.ChannelItem) *Segmenter {
+	channels := stubChannelRepo{channel: service.Channel{ID: 1, Title: "News", Loop: loop}}
+	timeline := service.NewTimelineService(channels, stubChannelItemRepo{items: items}, testEpoch)
+	return NewSegmenter(timeline, duration, window)
+}
+
+func TestSegmenterPlaylistFollowsProgrammeBoundaries(t *testing.T) {
+	// Programmes air at 0-20s, 20-35s, 35-55s and 55-70s.
+	g := newTestSegmenter(true, 6*time.Second, 4, item(1, "a.ts", 20), item(2, "b.ts", 15))
+
+	pl, err := g.Playlist(context.Background(), 1, testEpoch.Add(50*time.Second))
+	if err != nil {
+		t.Fatalf("playlist: %v", err)
+	}
+
+	if pl.TargetDuration != 12 || pl.MediaSequence != 5 || pl.DiscontinuitySequence != 1 {
+		t.Fatalf("unexpected playlist header: %+v", pl)
+	}
+	want := []struct {
+		start, duration time.Duration
+		discontinuity   bool
+	}{
+		{start: 30 * time.Second, duration: 5 * time.Second},
+		{start: 35 * time.Second, duration: 7 * time.Second, discontinuity: true},
+		{start: 42 * time.Second, duration: 6 * time.Second},
+		{start: 48 * time.Second, duration: 6 * time.Second},
+	}
+	if len(pl.Segments) != len(want) {
+		t.Fatalf("expected %d segments, got %d", len(want), len(pl.Segments))
+	}
+	for i, w := range want {
+		s := pl.Segments[i]
+		if s.Sequence != int64(5+i) || !s.Start.Equal(testEpoch.Add(w.start)) || s.Duration != w.duration ||
+			s.Discontinuity != w.discontinuity {
+			t.Fatalf("segment %d: unexpected %+v", i, s)
+		}
+	}
+}
+
+func TestSegmenterPlaylistSlidesWithWallClock(t *testing.T) {
+	g := newTestSegmenter(true, 6*time.Second, 4, item(1, "a.ts", 20), item(2, "b.ts", 15))
+
+	before, err := g.Playlist(context.Background(), 1, testEpoch.Add(50*time.Second))
+	if err != nil {
+		t.Fatalf("playlist: %v", err)
+	}
+	after, err := g.Playlist(context.Background(), 1, testEpoch.Add(62*time.Second))
+	if err != nil {
+		t.Fatalf("playlist: %v", err)
+	}
+
+	if after.MediaSequence != before.MediaSequence+2 {
+		t.Fatalf("expected media sequence to advance by 2, got %d then %d", before.MediaSequence, after.MediaSequence)
+	}
+	// The segment that kept its place in the window must not change.
+	if after.Segments[0].Start != before.Segments[2].Start || after.Segments[0].Duration != before.Segments[2].Duration {
+		t.Fatalf("segment %d changed between playlists", after.MediaSequence)
+	}
+}
+
+func TestSegmenterPlaylistWithNothingAiring(t *testing.T) {
+	g := newTestSegmenter(false, 6*time.Second, 4, item(1, "a.ts", 20))
+
+	_, err := g.Playlist(context.Background(), 1, testEpoch.Add(time.Hour))
+	if !errors.Is(err, service.ErrNothingScheduled) {
+		t.Fatalf("expected ErrNothingScheduled, got %v", err)
+	}
+}
+
+type stubScheduleBlockRepo struct {
+	blocks []service.ScheduleBlock
+}
+
+func (s stubScheduleBlockRepo) ListByChannel(context.Context, uint) ([]service.ScheduleBlock, error) {
+	return s.blocks, nil
+}
+
+func (s stubScheduleBlockRepo) GetByID(context.Context, uint, uint) (*service.ScheduleBlock, error) {
+	return nil, service.ErrNotFound
+}
+
+func (s stubScheduleBlockRepo) Create(context.Context, *service.ScheduleBlock) error { return nil }
+func (s stubScheduleBlockRepo) Update(context.Context, *service.ScheduleBlock) error { return nil }
+func (s stubScheduleBlockRepo) Delete(context.Context, uint, uint) error             { return nil }
+
+func TestSegmenterPlaylistStartsAfterAGap(t *testing.T) {
+	// Two one-minute blocks each air a 25s programme twice, leaving nothing on from 50s to 60s.
+	blocks := stubScheduleBlockRepo{blocks: []service.ScheduleBlock{
+		{ID: 1, Start: "00:00", End: "00:01", Programmes: []service.Content{{ID: 1, Path: "a.ts", Length: 25}}},
+		{ID: 2, Start: "00:01", End: "00:02", Programmes: []service.Content{{ID: 2, Path: "b.ts", Length: 25}}},
+	}}
+	channels := stubChannelRepo{channel: service.Channel{ID: 1, Title: "News"}}
+	timeline := service.NewTimelineService(channels, stubChannelItemRepo{}, testEpoch, service.WithSchedule(blocks))
+	g := NewSegmenter(timeline, 6*time.Second, 4)
+
+	pl, err := g.Playlist(context.Background(), 1, testEpoch.Add(62*time.Second))
+	if err != nil {
+		t.Fatalf("playlist: %v", err)
+	}
+	if pl.MediaSequence != 10 || len(pl.Segments) != 1 || pl.Segments[0].Sequence != 10 {
+		t.Fatalf("expected the window to start after the gap at segment 10, got %+v", pl)
+	}
+}
+
+func TestSegmenterWriteSegmentCutsOnPCR(t *testing.T) {
+	path, length := writeTSFile(t, "show.ts", 0, 100)
+	g := newTestSegmenter(true, time.Second, 4, item(1, path, length))
+
+	tests := []struct {
+		seq                   int64
+		firstFrame, lastFrame int
+	}{
+		{seq: 0, firstFrame: 0, lastFrame: 24},
+		{seq: 1, firstFrame: 25, lastFrame: 49},
+		{seq: 3, firstFrame: 75, lastFrame: 99},
+		// The next pass of the looping playlist.
+		{seq: 4, firstFrame: 0, lastFrame: 24},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		if err := g.WriteSegment(context.Background(), 1, tc.seq, &buf); err != nil {
+			t.Fatalf("segment %d: %v", tc.seq, err)
+		}
+
+		packets := decodeOutput(t, buf.Bytes())
+		if len(packets) < 2 || packets[0].pid != patPID || packets[1].pid != testPMTPID {
+			t.Fatalf("segment %d: expected to open with PAT and PMT", tc.seq)
+		}
+		var frames []int
+		for _, p := range packets {
+			if p.frame >= 0 {
+				frames = append(frames, p.frame)
+			}
+		}
+		if len(frames) == 0 || frames[0] != tc.firstFrame || frames[len(frames)-1] != tc.lastFrame ||
+			len(frames) != tc.lastFrame-tc.firstFrame+1 {
+			t.Fatalf("segment %d: expected frames %d-%d, got %v", tc.seq, tc.firstFrame, tc.lastFrame, frames)
+		}
+	}
+}
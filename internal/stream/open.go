@@ -0,0 +1,234 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/transcode"
+)
+
+// packetSource yields the 188-byte packets of one programme.
+type packetSource interface {
+	// next returns the next packet. The slice is only valid until the following call.
+	next() ([]byte, error)
+	Close() error
+}
+
+// Option configures a Streamer or Segmenter.
+type Option func(*opener)
+
+// WithChannels looks up the channel of each programme, so that remuxed programmes keep only the
+// audio track the channel prefers. Without it, they keep every audio track of their file.
+func WithChannels(channels *service.ChannelService) Option {
+	return func(o *opener) {
+		o.channels = channels
+	}
+}
+
+// WithTranscoder plays channels that have a transcode profile through t instead of remuxing
+// their files. profiles maps profile names to their settings.
+func WithTranscoder(channels *service.ChannelService, t transcode.Transcoder, profiles map[string]transcode.Profile) Option {
+	return func(o *opener) {
+		o.channels = channels
+		o.transcoder = t
+		o.profiles = profiles
+	}
+}
+
+// opener opens the programmes of a channel for playback.
+type opener struct {
+	channels   *service.ChannelService
+	transcoder transcode.Transcoder
+	profiles   map[string]transcode.Profile
+}
+
+func newOpener(opts []Option) opener {
+	var o opener
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// open returns the packets of an airing from one offset, in seconds, up to another. A to of +Inf
+// plays to the end of the file.
+func (o *opener) open(ctx context.Context, a service.Airing, from, to float64) (packetSource, error) {
+	path := a.Item.Content.Path
+	profile, ok, err := o.profile(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		track, filter, err := o.audioTrack(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		src, err := openRange(path, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if !filter {
+			return src, nil
+		}
+		return newAudioFilter(src, track), nil
+	}
+	rc, err := o.transcoder.Transcode(ctx, path, time.Duration(from*float64(time.Second)), profile)
+	if err != nil {
+		return nil, fmt.Errorf("transcode %s: %w", path, err)
+	}
+	return newPipeSource(rc, to-from), nil
+}
+
+// profile returns the transcode profile an airing plays through, set to keep the audio track the
+// channel prefers, or false when the airing's file is remuxed as it is.
+func (o *opener) profile(ctx context.Context, a service.Airing) (transcode.Profile, bool, error) {
+	if o.transcoder == nil {
+		return transcode.Profile{}, false, nil
+	}
+	ch, err := o.channels.Get(ctx, a.ChannelID)
+	if err != nil {
+		return transcode.Profile{}, false, err
+	}
+	if ch.Profile == "" {
+		return transcode.Profile{}, false, nil
+	}
+	profile, ok := o.profiles[ch.Profile]
+	if !ok {
+		return transcode.Profile{}, false, fmt.Errorf("unknown transcode profile %q", ch.Profile)
+	}
+	if t, ok := service.PreferredTrack(a.Item.Content.Tracks, service.TrackAudio, ch.AudioLanguage); ok {
+		profile.AudioTrack = t.Index
+	}
+	return profile, true, nil
+}
+
+// audioTrack returns the index of the audio track the airing's channel prefers, or false when
+// the channel is not known or the content lists no audio tracks.
+func (o *opener) audioTrack(ctx context.Context, a service.Airing) (int, bool, error) {
+	if o.channels == nil {
+		return 0, false, nil
+	}
+	ch, err := o.channels.Get(ctx, a.ChannelID)
+	if err != nil {
+		return 0, false, err
+	}
+	t, ok := service.PreferredTrack(a.Item.Content.Tracks, service.TrackAudio, ch.AudioLanguage)
+	return t.Index, ok, nil
+}
+
+// clockStart returns the timestamp, in clock ticks, at which the airing's content starts in the
+// packets open returns: the file's first PCR when it is remuxed, and zero for a transcode, whose
+// timestamps count from the start of the file.
+func (o *opener) clockStart(ctx context.Context, a service.Airing) (int64, error) {
+	if _, ok, err := o.profile(ctx, a); err != nil || ok {
+		return 0, err
+	}
+	src, err := openSource(a.Item.Content.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	pcr, _, err := src.pcrFrom(0)
+	return pcr, err
+}
+
+// airingEnd returns the offset, in seconds, the airing stops playing at: +Inf when it runs to the
+// end of its content, and its length when a schedule cut it short.
+func airingEnd(a service.Airing) float64 {
+	if length := a.End.Sub(a.Start); length < secondsToDuration(a.Item.Content.Length) {
+		return length.Seconds()
+	}
+	return math.Inf(1)
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+// pipeSource reads the packets of a transcode as they are produced.
+type pipeSource struct {
+	rc  io.ReadCloser
+	r   *bufio.Reader
+	buf []byte
+	// limit is how many clock ticks past the first PCR to read, or -1 to read to the end.
+	limit   int64
+	first   int64
+	started bool
+}
+
+func newPipeSource(rc io.ReadCloser, seconds float64) *pipeSource {
+	s := &pipeSource{
+		rc:    rc,
+		r:     bufio.NewReaderSize(rc, scanPackets*packetSize),
+		buf:   make([]byte, packetSize),
+		limit: -1,
+	}
+	if !math.IsInf(seconds, 1) {
+		s.limit = int64(seconds * clockRate)
+	}
+	return s
+}
+
+// next returns the next packet, ending before the first PCR that reaches the limit.
+func (s *pipeSource) next() ([]byte, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(s.r, s.buf); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	if o := pcrOffset(s.buf); o >= 0 && s.limit >= 0 {
+		pcr := readPCR(s.buf[o:])
+		if !s.started {
+			s.first, s.started = pcr, true
+		} else if wrapTimestamp(pcr-s.first) >= s.limit {
+			return nil, io.EOF
+		}
+	}
+	return s.buf, nil
+}
+
+// sync finds the start of the next packet. Output that has lost sync is skipped up to the next
+// sync byte followed by another a packet later, so a stray byte in the transcoder's output costs
+// at most the packet it lands in rather than every packet after it.
+func (s *pipeSource) sync() error {
+	for {
+		head, err := s.r.Peek(1)
+		if len(head) == 0 {
+			return err
+		}
+		if head[0] == syncByte {
+			return nil
+		}
+		head, err = s.r.Peek(2 * packetSize)
+		if len(head) == 0 {
+			return err
+		}
+		// Near the end of the output a sync byte cannot be confirmed, and is trusted.
+		skip := min(len(head), packetSize)
+		for i := 1; i < skip; i++ {
+			if head[i] == syncByte && (i+packetSize >= len(head) || head[i+packetSize] == syncByte) {
+				skip = i
+				break
+			}
+		}
+		if _, err := s.r.Discard(skip); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *pipeSource) Close() error {
+	return s.rc.Close()
+}
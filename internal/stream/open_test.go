@@ -0,0 +1,198 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/transcode"
+	"github.com/iamseth/tiny-headend/internal/transcode/transcodetest"
+)
+
+var testProfiles = map[string]transcode.Profile{
+	"720p": {Name: "720p", Width: 1280, Height: 720, VideoCodec: "libx264", AudioCodec: "aac"},
+}
+
+// transcodedTimeline returns a timeline whose channel plays through profile, and an option
+// transcoding with fake.
+func transcodedTimeline(profile string, fake *transcodetest.Fake, items ...service.ChannelItem) (*service.TimelineService, Option) {
+	channels := stubChannelRepo{channel: service.Channel{ID: 1, Title: "Films", Profile: profile}}
+	timeline := service.NewTimelineService(channels, stubChannelItemRepo{items: items}, testEpoch)
+	channelSvc := service.NewChannelService(channels, service.WithProfiles("720p"))
+	return timeline, WithTranscoder(channelSvc, fake, testProfiles)
+}
+
+// frameRange returns the first and last frame numbers in the output and how many there are.
+func frameRange(packets []outputPacket) (first, last, n int) {
+	first, last = -1, -1
+	for _, p := range packets {
+		if p.frame < 0 || p.pid != transcodetest.VideoPID {
+			continue
+		}
+		if first < 0 {
+			first = p.frame
+		}
+		last = p.frame
+		n++
+	}
+	return first, last, n
+}
+
+func TestStreamPlaysThroughTranscoder(t *testing.T) {
+	fake := &transcodetest.Fake{Length: 10 * time.Second}
+	timeline, opt := transcodedTimeline("720p", fake, item(1, "/media/film.mkv", 10))
+	s := New(timeline, opt)
+	s.now = func() time.Time { return testEpoch.Add(3 * time.Second) }
+	s.sleep = func(context.Context, time.Duration) error { return nil }
+
+	var buf bytes.Buffer
+	if err := s.Stream(context.Background(), 1, &buf); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Input != "/media/film.mkv" || calls[0].Offset != 3*time.Second ||
+		calls[0].Profile != testProfiles["720p"] {
+		t.Fatalf("unexpected transcode calls %+v", calls)
+	}
+	packets := decodeOutput(t, buf.Bytes())
+	if first, last, n := frameRange(packets); first != 75 || last != 249 || n != 175 {
+		t.Fatalf("expected frames 75-249, got %d frames from %d to %d", n, first, last)
+	}
+	lastPCR := int64(-1)
+	for i, p := range packets {
+		if p.pcr >= 0 {
+			if p.pcr <= lastPCR {
+				t.Fatalf("packet %d: pcr %d does not follow %d", i, p.pcr, lastPCR)
+			}
+			lastPCR = p.pcr
+		}
+	}
+}
+
+func TestTranscodeKeepsPreferredAudioTrack(t *testing.T) {
+	fake := &transcodetest.Fake{Length: 10 * time.Second}
+	channels := stubChannelRepo{channel: service.Channel{ID: 1, Title: "Films", Profile: "720p", AudioLanguage: "fra"}}
+	it := item(1, "/media/film.mkv", 10)
+	it.Content.Tracks = []service.Track{
+		{Kind: service.TrackAudio, Index: 0, Language: "en", Default: true},
+		{Kind: service.TrackAudio, Index: 1, Language: "fr"},
+	}
+	timeline := service.NewTimelineService(channels, stubChannelItemRepo{items: []service.ChannelItem{it}}, testEpoch)
+	channelSvc := service.NewChannelService(channels, service.WithProfiles("720p"))
+	g := NewSegmenter(timeline, time.Second, 4, WithTranscoder(channelSvc, fake, testProfiles))
+
+	var buf bytes.Buffer
+	if err := g.WriteSegment(context.Background(), 1, 0, &buf); err != nil {
+		t.Fatalf("segment: %v", err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Profile.AudioTrack != 1 {
+		t.Fatalf("expected the French audio track to be kept, got %+v", calls)
+	}
+}
+
+func TestStreamSkipsProgrammesWithUnknownProfile(t *testing.T) {
+	fake := &transcodetest.Fake{Length: 10 * time.Second}
+	timeline, opt := transcodedTimeline("4k", fake, item(1, "/media/film.mkv", 10))
+	s := New(timeline, opt)
+	s.now = func() time.Time { return testEpoch }
+
+	var buf bytes.Buffer
+	if err := s.Stream(context.Background(), 1, &buf); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(fake.Calls()) != 0 || buf.Len() != 0 {
+		t.Fatalf("expected nothing to be transcoded or written")
+	}
+}
+
+func TestStreamRemuxesChannelsWithoutProfile(t *testing.T) {
+	path, length := writeTSFile(t, "show.ts", 0, 50)
+	fake := &transcodetest.Fake{}
+	timeline, opt := transcodedTimeline("", fake, item(1, path, length))
+	s := New(timeline, opt)
+	s.now = func() time.Time { return testEpoch }
+	s.sleep = func(context.Context, time.Duration) error { return nil }
+
+	var buf bytes.Buffer
+	if err := s.Stream(context.Background(), 1, &buf); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if len(fake.Calls()) != 0 {
+		t.Fatalf("expected the file to be remuxed, got transcode calls %+v", fake.Calls())
+	}
+	if _, _, n := frameRange(decodeOutput(t, buf.Bytes())); n != 50 {
+		t.Fatalf("expected 50 frames, got %d", n)
+	}
+}
+
+func TestSegmenterWriteSegmentThroughTranscoder(t *testing.T) {
+	fake := &transcodetest.Fake{Length: 10 * time.Second}
+	timeline, opt := transcodedTimeline("720p", fake, item(1, "/media/film.mkv", 10))
+	g := NewSegmenter(timeline, time.Second, 4, opt)
+
+	var buf bytes.Buffer
+	if err := g.WriteSegment(context.Background(), 1, 2, &buf); err != nil {
+		t.Fatalf("segment: %v", err)
+	}
+
+	if calls := fake.Calls(); len(calls) != 1 || calls[0].Offset != 2*time.Second {
+		t.Fatalf("unexpected transcode calls %+v", calls)
+	}
+	packets := decodeOutput(t, buf.Bytes())
+	if packets[0].pid != patPID || packets[1].pid != transcodetest.PMTPID {
+		t.Fatal("expected the segment to open with PAT and PMT")
+	}
+	if first, last, n := frameRange(packets); first != 50 || last != 74 || n != 25 {
+		t.Fatalf("expected frames 50-74, got %d frames from %d to %d", n, first, last)
+	}
+}
+
+func TestAiringEndStopsCutAirings(t *testing.T) {
+	item := service.ChannelItem{Content: &service.Content{Length: 60}}
+	whole := service.Airing{Item: item, Start: testEpoch, End: testEpoch.Add(time.Minute)}
+	if got := airingEnd(whole); !math.IsInf(got, 1) {
+		t.Fatalf("expected a whole airing to play to the end of the file, got %v", got)
+	}
+	cut := service.Airing{Item: item, Start: testEpoch, End: testEpoch.Add(40 * time.Second)}
+	if got := airingEnd(cut); got != 40 {
+		t.Fatalf("expected a cut airing to stop at 40s, got %v", got)
+	}
+}
+
+func TestPipeSourceResyncsAfterStrayBytes(t *testing.T) {
+	var in bytes.Buffer
+	for i := range 4 {
+		if i == 2 {
+			// A stray byte, and junk that looks like a sync byte but is not followed by one.
+			in.Write([]byte{0x00, syncByte, 0x01})
+		}
+		in.Write(tsPacketBytes(testVideoPID, true, -1, []byte{byte(i)}))
+	}
+	src := newPipeSource(io.NopCloser(&in), math.Inf(1))
+
+	var got []byte
+	for {
+		p, err := src.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if p[0] != syncByte || packetPID(p) != testVideoPID {
+			t.Fatalf("expected an aligned video packet, got % x", p[:4])
+		}
+		got = append(got, p[payloadOffset(p)])
+	}
+	if !bytes.Equal(got, []byte{0, 1, 2, 3}) {
+		t.Fatalf("expected every packet to survive the stray bytes, got %v", got)
+	}
+}
@@ -0,0 +1,137 @@
+package stream
+
+const (
+	// initialClock is where output timestamps start, leaving room below for streams whose first
+	// PTS comes slightly before their first PCR.
+	initialClock = clockRate
+	// spliceGap separates the last timestamp of one source from the first of the next.
+	spliceGap = clockRate / 25
+)
+
+// remuxer splices transport streams into one continuous stream. Each source's timestamps are
+// shifted to carry on from where the previous source ended, continuity counters run on without
+// gaps, and PAT/PMT versions change at every splice so decoders pick up the new program.
+type remuxer struct {
+	cc      map[uint16]byte
+	version byte
+	next    int64
+	high    int64
+
+	// Per-source state, reset by begin.
+	pmtPIDs map[uint16]bool
+	synced  map[uint16]bool
+	first   int64
+	started bool
+}
+
+func newRemuxer() *remuxer {
+	return &remuxer{cc: map[uint16]byte{}, next: initialClock, high: initialClock - spliceGap}
+}
+
+// begin prepares for the next source.
+func (m *remuxer) begin() {
+	m.version = (m.version + 1) & 0x1F
+	m.next = m.high + spliceGap
+	m.pmtPIDs = map[uint16]bool{}
+	m.synced = map[uint16]bool{}
+	m.started = false
+}
+
+// rewrite adjusts the 188-byte packet p in place for the output stream. It reports whether p
+// should be written and, when p carries a PCR, the PCR as an unwrapped output clock value.
+//
+// Until a source's first PCR only PAT and PMT packets are kept. After that, each PID is held back
+// until it starts a new PES packet or section, so a source entered part way through never
+// emits a fragment.
+func (m *remuxer) rewrite(p []byte) (keep bool, pcr int64, hasPCR bool) {
+	pid := packetPID(p)
+	if pid == nullPID {
+		return false, 0, false
+	}
+	psi := pid == patPID || m.pmtPIDs[pid]
+
+	if off := pcrOffset(p); off >= 0 {
+		src := readPCR(p[off:])
+		if !m.started {
+			m.first = src
+			m.started = true
+		}
+		pcr = m.shift(src)
+		writePCR(p[off:], wrapTimestamp(pcr))
+		hasPCR = true
+	}
+	if !m.started && !psi {
+		return false, 0, false
+	}
+	if !m.synced[pid] {
+		if !packetPUSI(p) {
+			return false, 0, false
+		}
+		m.synced[pid] = true
+	}
+
+	payload := p[payloadOffset(p):]
+	if packetPUSI(p) {
+		if psi {
+			m.rewriteSection(pid, payload)
+		} else {
+			m.rewritePES(payload)
+		}
+	}
+
+	cc := m.cc[pid]
+	if packetHasPayload(p) {
+		cc = (cc + 1) & 0x0F
+		m.cc[pid] = cc
+	}
+	p[3] = p[3]&0xF0 | cc
+	return true, pcr, hasPCR
+}
+
+func (m *remuxer) rewriteSection(pid uint16, payload []byte) {
+	section := psiSection(payload)
+	if section == nil {
+		return
+	}
+	switch {
+	case pid == patPID && section[0] == 0x00:
+		for _, pmt := range patPMTPIDs(section) {
+			m.pmtPIDs[pmt] = true
+		}
+	case section[0] != 0x02:
+		return
+	}
+	setSectionVersion(section, m.version)
+}
+
+func (m *remuxer) rewritePES(payload []byte) {
+	ptsOff, dtsOff := pesTimestampOffsets(payload)
+	for _, off := range []int{ptsOff, dtsOff} {
+		if off < 0 {
+			continue
+		}
+		writeTimestamp(payload[off:], wrapTimestamp(m.shift(readTimestamp(payload[off:]))))
+	}
+}
+
+// shift maps a source timestamp onto the unwrapped output clock.
+func (m *remuxer) shift(ts int64) int64 {
+	delta := (ts - m.first) % timestampWrap
+	if delta < 0 {
+		delta += timestampWrap
+	}
+	if delta > timestampWrap/2 {
+		delta -= timestampWrap
+	}
+	out := m.next + delta
+	m.high = max(m.high, out)
+	return out
+}
+
+func wrapTimestamp(ts int64) int64 {
+	ts %= timestampWrap
+	if ts < 0 {
+		ts += timestampWrap
+	}
+	return ts
+}
@@ -0,0 +1,159 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const (
+	// defaultBandwidth is the bitrate, in bits per second, advertised for programmes whose bitrate
+	// is unknown.
+	defaultBandwidth = 5_000_000
+	// undeterminedLanguage addresses subtitles whose language is not known.
+	undeterminedLanguage = "und"
+)
+
+// Rendition is an audio or subtitle track a master playlist offers.
+type Rendition struct {
+	Name string
+	// Language is the track's language tag, or empty when it is not known.
+	Language string
+	Default  bool
+	Forced   bool
+}
+
+// Tag is the language the rendition's subtitle playlist is requested by.
+func (r Rendition) Tag() string {
+	if r.Language == "" {
+		return undeterminedLanguage
+	}
+	return r.Language
+}
+
+// MasterPlaylist describes a channel's stream and its alternative renditions.
+type MasterPlaylist struct {
+	// Bandwidth is the stream's peak bitrate in bits per second.
+	Bandwidth int64
+	// Audio is the audio track carried in the stream itself: the one the channel prefers, as the
+	// others are left out of its segments. It is empty when the programme lists no audio tracks.
+	Audio []Rendition
+	// Subtitles lists the languages that subtitle playlists are offered in, each cut from text
+	// sidecar files.
+	Subtitles []Rendition
+}
+
+// Master describes the renditions of the programme airing on ch at now. Players read the master
+// playlist once, so later programmes are offered the same subtitle languages, and segments of
+// programmes without subtitles in a language are empty.
+func (g *Segmenter) Master(ctx context.Context, ch service.Channel, now time.Time) (*MasterPlaylist, error) {
+	a, err := g.timeline.At(ctx, ch.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	profile, transcoded, err := g.opener.profile(ctx, *a)
+	if err != nil {
+		return nil, err
+	}
+	content := a.Item.Content
+	m := &MasterPlaylist{Bandwidth: content.Bitrate}
+	if transcoded && profile.VideoBitrate > 0 && profile.AudioBitrate > 0 {
+		m.Bandwidth = int64(profile.VideoBitrate+profile.AudioBitrate) * 1000
+	}
+	if m.Bandwidth <= 0 {
+		m.Bandwidth = defaultBandwidth
+	}
+	if t, ok := service.PreferredTrack(content.Tracks, service.TrackAudio, ch.AudioLanguage); ok {
+		m.Audio = []Rendition{{Name: trackName(t), Language: t.Language, Default: true}}
+	}
+
+	subtitleNames := map[string]int{}
+	for _, t := range subtitleTracks(content.Tracks) {
+		m.Subtitles = append(m.Subtitles, Rendition{
+			Name:     uniqueName(subtitleNames, trackName(t)),
+			Language: t.Language,
+			Default:  ch.SubtitleLanguage != "" && service.SameLanguage(t.Language, ch.SubtitleLanguage),
+			Forced:   t.Forced,
+		})
+	}
+	return m, nil
+}
+
+// WriteSubtitles writes segment seq of the channel's subtitles in language tag as WebVTT. The
+// segment is empty when the programme has no subtitles in that language.
+func (g *Segmenter) WriteSubtitles(ctx context.Context, channelID uint, tag string, seq int64, w io.Writer) error {
+	segments, err := g.segments(ctx, channelID, seq, seq)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return service.ErrNothingScheduled
+	}
+	seg := segments[0]
+	a := seg.airing
+
+	var cues []cue
+	var clock int64
+	for _, t := range subtitleTracks(a.Item.Content.Tracks) {
+		if (Rendition{Language: t.Language}).Tag() != tag {
+			continue
+		}
+		if cues, err = readCues(t); err != nil {
+			return err
+		}
+		if clock, err = g.opener.clockStart(ctx, a); err != nil {
+			return err
+		}
+		break
+	}
+	from := seg.Start.Sub(a.Start)
+	return writeWebVTT(w, cues, from, from+seg.Duration, clock)
+}
+
+// subtitleTracks returns the text sidecar subtitles of content, one per language. A full track
+// is preferred over one with only forced subtitles.
+func subtitleTracks(tracks []service.Track) []service.Track {
+	var out []service.Track
+	byTag := map[string]int{}
+	for _, t := range tracks {
+		if !t.IsText() {
+			continue
+		}
+		tag := Rendition{Language: t.Language}.Tag()
+		i, ok := byTag[tag]
+		switch {
+		case !ok:
+			byTag[tag] = len(out)
+			out = append(out, t)
+		case out[i].Forced && !t.Forced:
+			out[i] = t
+		}
+	}
+	return out
+}
+
+// trackName labels a track by its title, or otherwise its language.
+func trackName(t service.Track) string {
+	switch {
+	case t.Title != "":
+		return t.Title
+	case t.Language != "":
+		return service.LanguageName(t.Language)
+	case t.Kind == service.TrackAudio:
+		return "Audio " + strconv.Itoa(t.Index+1)
+	}
+	return "Subtitles"
+}
+
+// uniqueName numbers repeats of a name, since renditions must be told apart by name.
+func uniqueName(seen map[string]int, name string) string {
+	seen[name]++
+	if n := seen[name]; n > 1 {
+		return fmt.Sprintf("%s (%d)", name, n)
+	}
+	return name
+}
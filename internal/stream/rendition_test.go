@@ -0,0 +1,109 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+	"github.com/iamseth/tiny-headend/internal/transcode"
+	"github.com/iamseth/tiny-headend/internal/transcode/transcodetest"
+)
+
+func trackedItem(path string, length float64, tracks ...service.Track) service.ChannelItem {
+	it := item(1, path, length)
+	it.Content.Tracks = tracks
+	return it
+}
+
+func TestSegmenterMaster(t *testing.T) {
+	tracks := []service.Track{
+		{Kind: service.TrackAudio, Index: 0, Language: "en", Default: true},
+		{Kind: service.TrackAudio, Index: 1, Language: "de"},
+		{Kind: service.TrackAudio, Index: 2, Language: "de"},
+		{Kind: service.TrackSubtitle, Index: 0, Codec: "hdmv_pgs_subtitle", Language: "fr"},
+		{Kind: service.TrackSubtitle, Codec: "subrip", Language: "de", Forced: true, Path: "/media/film.de.forced.srt"},
+		{Kind: service.TrackSubtitle, Codec: "subrip", Language: "de", Path: "/media/film.de.srt"},
+	}
+	ch := service.Channel{ID: 1, AudioLanguage: "de", SubtitleLanguage: "de"}
+
+	t.Run("remuxed", func(t *testing.T) {
+		g := newTestSegmenter(true, 6*time.Second, 3, trackedItem("/media/film.ts", 60, tracks...))
+
+		m, err := g.Master(context.Background(), ch, testEpoch)
+		if err != nil {
+			t.Fatalf("master: %v", err)
+		}
+		// Only the preferred track is offered, as the others are left out of the segments.
+		wantAudio := []Rendition{{Name: "German", Language: "de", Default: true}}
+		wantSubtitles := []Rendition{{Name: "German", Language: "de", Default: true}}
+		if m.Bandwidth != defaultBandwidth || !slices.Equal(m.Audio, wantAudio) || !slices.Equal(m.Subtitles, wantSubtitles) {
+			t.Fatalf("unexpected master %+v", m)
+		}
+	})
+
+	t.Run("transcoded", func(t *testing.T) {
+		fake := &transcodetest.Fake{Length: time.Minute}
+		channels := stubChannelRepo{channel: service.Channel{ID: 1, Profile: "720p", AudioLanguage: "de"}}
+		timeline := service.NewTimelineService(channels, stubChannelItemRepo{items: []service.ChannelItem{
+			trackedItem("/media/film.mkv", 60, tracks...),
+		}}, testEpoch)
+		profiles := map[string]transcode.Profile{"720p": {Name: "720p", VideoBitrate: 3000, AudioBitrate: 128}}
+		channelSvc := service.NewChannelService(channels, service.WithProfiles("720p"))
+		g := NewSegmenter(timeline, 6*time.Second, 3, WithTranscoder(channelSvc, fake, profiles))
+
+		m, err := g.Master(context.Background(), ch, testEpoch)
+		if err != nil {
+			t.Fatalf("master: %v", err)
+		}
+		wantAudio := []Rendition{{Name: "German", Language: "de", Default: true}}
+		if m.Bandwidth != 3_128_000 || !slices.Equal(m.Audio, wantAudio) {
+			t.Fatalf("unexpected master %+v", m)
+		}
+	})
+}
+
+func TestSegmenterWriteSubtitles(t *testing.T) {
+	path, length := writeTSFile(t, "film.ts", 900000, 100)
+	subs := writeSubtitleFile(t, "film.en.srt",
+		"1\n00:00:00,500 --> 00:00:01,200\nFirst\n\n2\n00:00:02,100 --> 00:00:02,500\nSecond\n")
+	g := newTestSegmenter(true, time.Second, 4, trackedItem(path, length,
+		service.Track{Kind: service.TrackSubtitle, Codec: "subrip", Language: "en", Path: subs}))
+
+	tests := []struct {
+		tag  string
+		seq  int64
+		want []string
+	}{
+		{tag: "en", seq: 0, want: []string{"First"}},
+		// A cue running past the end of a segment is repeated in the next.
+		{tag: "en", seq: 1, want: []string{"First"}},
+		{tag: "en", seq: 2, want: []string{"Second"}},
+		{tag: "en", seq: 3, want: nil},
+		{tag: "fr", seq: 0, want: nil},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		if err := g.WriteSubtitles(context.Background(), 1, tc.tag, tc.seq, &buf); err != nil {
+			t.Fatalf("%s segment %d: %v", tc.tag, tc.seq, err)
+		}
+		out := buf.String()
+		if !strings.HasPrefix(out, "WEBVTT\n") {
+			t.Fatalf("%s segment %d: expected a WebVTT header, got:\n%s", tc.tag, tc.seq, out)
+		}
+		if tc.tag == "en" && !strings.Contains(out, "X-TIMESTAMP-MAP=MPEGTS:900000,") {
+			t.Fatalf("%s segment %d: expected timestamps mapped to the first PCR, got:\n%s", tc.tag, tc.seq, out)
+		}
+		var texts []string
+		for _, block := range strings.Split(out, "\n\n")[1:] {
+			lines := strings.Split(strings.TrimSpace(block), "\n")
+			texts = append(texts, lines[len(lines)-1])
+		}
+		if !slices.Equal(texts, tc.want) {
+			t.Fatalf("%s segment %d: expected cues %v, got %v", tc.tag, tc.seq, tc.want, texts)
+		}
+	}
+}
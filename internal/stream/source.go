@@ -0,0 +1,242 @@
+package stream
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// errUnsupported is returned for content that is not an MPEG transport stream.
+var errUnsupported = errors.New("not an mpeg transport stream")
+
+const (
+	// headPSIWindow bounds how far into a file the PAT and PMT are looked for before seeking.
+	headPSIWindow = 1 << 20
+	// scanPackets is how many packets are read at a time while looking for a PCR.
+	scanPackets = 64
+)
+
+// source reads the 188-byte packets of a transport stream file. M2TS timecode prefixes are
+// stripped.
+type source struct {
+	f          *os.File
+	size       int64
+	packetSize int
+	r          io.Reader
+	buf        []byte
+	// head holds the PAT and PMT packets from the start of the file, replayed before the packets
+	// after a seek.
+	head [][]byte
+}
+
+// openSource opens the transport stream at path, positioned at its start.
+func openSource(path string) (*source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open media file: %w", err)
+	}
+	s, err := newSource(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// openRange opens the transport stream at path positioned to play from one offset, in seconds,
+// up to another. A to of +Inf plays to the end of the file.
+func openRange(path string, from, to float64) (*source, error) {
+	s, err := openSource(path)
+	if err != nil {
+		return nil, err
+	}
+	start, err := s.locate(from)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	end := s.size
+	if !math.IsInf(to, 1) {
+		if end, err = s.locate(to); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+	}
+	if err := s.seek(start, end); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSource(f *os.File) (*source, error) {
+	st, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat media file: %w", err)
+	}
+	s := &source{f: f}
+	if s.packetSize, err = detectPacketSize(f); err != nil {
+		return nil, err
+	}
+	s.size = st.Size() / int64(s.packetSize) * int64(s.packetSize)
+	s.buf = make([]byte, s.packetSize)
+	s.r = bufio.NewReaderSize(io.NewSectionReader(f, 0, s.size), scanPackets*s.packetSize)
+	return s, nil
+}
+
+// seek positions the source to read the packets in [from, to). A source entered part way
+// through first replays the PAT and PMT from the start of the file.
+func (s *source) seek(from, to int64) error {
+	s.head = nil
+	if from > 0 {
+		if err := s.readHead(); err != nil {
+			return err
+		}
+	}
+	s.r = bufio.NewReaderSize(io.NewSectionReader(s.f, from, max(to-from, 0)), scanPackets*s.packetSize)
+	return nil
+}
+
+// locate returns the position just after the last packet whose PCR is less than offset seconds
+// past the file's first PCR. Files without a PCR are located at their start.
+func (s *source) locate(offset float64) (int64, error) {
+	if offset <= 0 {
+		return 0, nil
+	}
+	first, ok, err := s.pcrFrom(0)
+	if err != nil || !ok {
+		return 0, err
+	}
+	target := int64(offset * clockRate)
+
+	// Binary search for the first packet from which the next PCR reaches target.
+	lo, hi := int64(0), s.size/int64(s.packetSize)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		pcr, ok, err := s.pcrFrom(mid)
+		if err != nil {
+			return 0, err
+		}
+		if !ok || wrapTimestamp(pcr-first) >= target {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo * int64(s.packetSize), nil
+}
+
+// pcrFrom returns the first PCR carried at or after packet index i.
+func (s *source) pcrFrom(i int64) (int64, bool, error) {
+	chunk := make([]byte, scanPackets*s.packetSize)
+	for off := i * int64(s.packetSize); off < s.size; off += int64(len(chunk)) {
+		n, err := s.f.ReadAt(chunk, off)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, false, fmt.Errorf("read media file: %w", err)
+		}
+		for p := 0; p+s.packetSize <= n; p += s.packetSize {
+			pkt := chunk[p+s.packetSize-packetSize : p+s.packetSize]
+			if pkt[0] != syncByte {
+				continue
+			}
+			if o := pcrOffset(pkt); o >= 0 {
+				return readPCR(pkt[o:]), true, nil
+			}
+		}
+		if n < len(chunk) {
+			break
+		}
+	}
+	return 0, false, nil
+}
+
+// readHead collects the PAT and the PMTs it points at from the start of the file.
+func (s *source) readHead() error {
+	r := bufio.NewReader(io.NewSectionReader(s.f, 0, min(s.size, headPSIWindow)))
+	pmts := map[uint16]bool{}
+	for {
+		p, err := s.readFrom(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !packetPUSI(p) {
+			continue
+		}
+		pid := packetPID(p)
+		switch {
+		case pid == patPID && len(pmts) == 0:
+			section := psiSection(p[payloadOffset(p):])
+			if section == nil {
+				continue
+			}
+			for _, pmt := range patPMTPIDs(section) {
+				pmts[pmt] = true
+			}
+			s.head = append(s.head, append([]byte(nil), p...))
+		case pmts[pid]:
+			s.head = append(s.head, append([]byte(nil), p...))
+			delete(pmts, pid)
+			if len(pmts) == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *source) next() ([]byte, error) {
+	if len(s.head) > 0 {
+		p := s.head[0]
+		s.head = s.head[1:]
+		return p, nil
+	}
+	return s.readFrom(s.r)
+}
+
+func (s *source) readFrom(r io.Reader) ([]byte, error) {
+	for {
+		if _, err := io.ReadFull(r, s.buf); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		p := s.buf[s.packetSize-packetSize:]
+		if p[0] == syncByte {
+			return p, nil
+		}
+	}
+}
+
+func (s *source) Close() error {
+	return s.f.Close()
+}
+
+// detectPacketSize requires three consecutive packets to start with a sync byte.
+func detectPacketSize(r io.ReaderAt) (int, error) {
+	for _, ps := range []int{packetSize, m2tsPacketSize} {
+		buf := make([]byte, ps*3)
+		if _, err := r.ReadAt(buf, 0); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return 0, fmt.Errorf("read media header: %w", err)
+		}
+		ok := true
+		for i := ps - packetSize; i < len(buf); i += ps {
+			if buf[i] != syncByte {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return ps, nil
+		}
+	}
+	return 0, errUnsupported
+}
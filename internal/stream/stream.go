@@ -0,0 +1,149 @@
+// Package stream plays channels as continuous MPEG transport streams. Each programme's file is
+// remuxed in pure Go and spliced onto the previous one with its timestamps and continuity
+// counters rewritten, so players see one uninterrupted stream. Channels with a transcode profile
+// are run through a transcoder first.
+package stream
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const (
+	// maxLead is how far ahead of real time output may run, so players can fill their buffers
+	// when they tune in.
+	maxLead = 2 * time.Second
+	// maxSkips is how many programmes in a row may fail to open before the stream gives up.
+	maxSkips = 8
+	// writeBufferPackets is how many packets are buffered before they are written out.
+	writeBufferPackets = 64
+)
+
+// Streamer plays channel timelines as MPEG transport streams.
+type Streamer struct {
+	timeline *service.TimelineService
+	opener   opener
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+func New(timeline *service.TimelineService, opts ...Option) *Streamer {
+	return &Streamer{timeline: timeline, opener: newOpener(opts), now: time.Now, sleep: sleepContext}
+}
+
+// Stream writes what the channel is airing to w, starting part way into the current programme and
+// continuing through the following ones, paced to real time. It returns nil when ctx is done or a
+// non-looping channel runs out of programming, and writes nothing when it fails before the first
+// programme starts.
+func (s *Streamer) Stream(ctx context.Context, channelID uint, w io.Writer) error {
+	out := bufio.NewWriterSize(w, writeBufferPackets*packetSize)
+	m := newRemuxer()
+	p := &pacer{now: s.now}
+	t := s.now()
+	played := false
+	skips := 0
+	for {
+		a, err := s.timeline.At(ctx, channelID, t)
+		if err != nil {
+			if played && errors.Is(err, service.ErrNothingScheduled) {
+				return out.Flush()
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		src, err := s.opener.open(ctx, *a, a.Offset, airingEnd(*a))
+		if err != nil {
+			slog.Warn("skip programme", "channel_id", channelID, "content_id", a.Item.ContentID, "error", err)
+			if skips++; skips >= maxSkips {
+				return fmt.Errorf("channel %d: %d programmes in a row could not be played", channelID, skips)
+			}
+			t = a.End
+			continue
+		}
+		skips = 0
+		played = true
+
+		err = s.play(ctx, a.Item.Content.Path, src, m, p, out)
+		if closeErr := src.Close(); closeErr != nil {
+			slog.Error("close media file", "path", a.Item.Content.Path, "error", closeErr)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		t = a.End
+	}
+}
+
+// play copies one source to out. Read errors end the source early rather than the stream.
+func (s *Streamer) play(ctx context.Context, path string, src packetSource, m *remuxer, p *pacer, out *bufio.Writer) error {
+	m.begin()
+	for {
+		pkt, err := src.next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Error("read media file", "path", path, "error", err)
+			}
+			return nil
+		}
+		keep, pcr, hasPCR := m.rewrite(pkt)
+		if !keep {
+			continue
+		}
+		if hasPCR {
+			if d := p.delay(pcr); d > 0 {
+				if err := out.Flush(); err != nil {
+					return fmt.Errorf("write stream: %w", err)
+				}
+				if err := s.sleep(ctx, d); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := out.Write(pkt); err != nil {
+			return fmt.Errorf("write stream: %w", err)
+		}
+	}
+}
+
+// pacer holds output to real time using the PCRs written.
+type pacer struct {
+	now     func() time.Time
+	start   time.Time
+	base    int64
+	started bool
+}
+
+// delay returns how long to wait before writing a packet carrying the output PCR pcr.
+func (p *pacer) delay(pcr int64) time.Duration {
+	now := p.now()
+	if !p.started {
+		p.start, p.base, p.started = now, pcr, true
+		return 0
+	}
+	due := p.start.Add(time.Duration(pcr-p.base) * time.Second / clockRate)
+	return due.Sub(now) - maxLead
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,315 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+const (
+	testPMTPID   = 0x1000
+	testVideoPID = 0x100
+	frameTicks   = clockRate / 25
+	ptsDelay     = clockRate / 10
+)
+
+var testEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type stubChannelRepo struct {
+	channel service.Channel
+}
+
+func (s stubChannelRepo) Create(context.Context, *service.Channel) error { return nil }
+
+func (s stubChannelRepo) GetByID(_ context.Context, id uint) (*service.Channel, error) {
+	if id != s.channel.ID {
+		return nil, service.ErrNotFound
+	}
+	return &s.channel, nil
+}
+
+func (s stubChannelRepo) List(context.Context, int, int) ([]service.Channel, error) { return nil, nil }
+func (s stubChannelRepo) Update(context.Context, *service.Channel) error            { return nil }
+func (s stubChannelRepo) Delete(context.Context, uint) error                        { return nil }
+
+type stubChannelItemRepo struct {
+	items []service.ChannelItem
+}
+
+func (s stubChannelItemRepo) ListByChannel(context.Context, uint) ([]service.ChannelItem, error) {
+	return s.items, nil
+}
+
+func (s stubChannelItemRepo) Append(context.Context, *service.ChannelItem) error { return nil }
+func (s stubChannelItemRepo) Delete(context.Context, uint, uint) error           { return nil }
+func (s stubChannelItemRepo) Reorder(context.Context, uint, []uint) error        { return nil }
+
+func tsPacketBytes(pid uint16, pusi bool, pcr int64, payload []byte) []byte {
+	p := []byte{syncByte, byte(pid>>8) & 0x1F, byte(pid), 0x10}
+	if pusi {
+		p[1] |= 0x40
+	}
+	stuffing := packetSize - 4 - len(payload)
+	if pcr >= 0 || stuffing > 0 {
+		af := []byte{0x00}
+		if pcr >= 0 {
+			af = []byte{0x10, 0, 0, 0, 0, 0x7E, 0}
+			writePCR(af[1:], pcr)
+		}
+		for len(af)+1 < stuffing {
+			af = append(af, 0xFF)
+		}
+		p[3] = 0x30
+		p = append(p, byte(len(af)))
+		p = append(p, af...)
+	}
+	p = append(p, payload...)
+	return p[:packetSize]
+}
+
+func psiPacket(pid uint16, tableID byte, body []byte) []byte {
+	length := len(body) + 5 + 4
+	section := append([]byte{tableID, 0xB0 | byte(length>>8), byte(length), 0x00, 0x01, 0xC1, 0x00, 0x00}, body...)
+	crc := crc32MPEG(section)
+	section = append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+	return tsPacketBytes(pid, true, -1, append([]byte{0x00}, section...))
+}
+
+// pesHeader starts a video PES packet with a PTS, followed by the frame number as its data.
+func pesHeader(pts int64, frame byte) []byte {
+	pes := []byte{0x00, 0x00, 0x01, 0xE0, 0x00, 0x00, 0x80, 0x80, 0x05, 0x21, 0, 0, 0, 0, frame}
+	writeTimestamp(pes[9:], pts)
+	return pes
+}
+
+// writeTSFile writes a transport stream of frames video frames, one packet each, whose clock
+// starts at firstPCR. It returns the path and the stream's length in seconds.
+func writeTSFile(t *testing.T, name string, firstPCR int64, frames int) (string, float64) {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.Write(psiPacket(patPID, 0x00, []byte{0x00, 0x01, 0xE0 | testPMTPID>>8, testPMTPID & 0xFF}))
+	buf.Write(psiPacket(testPMTPID, 0x02, []byte{
+		0xE0 | testVideoPID>>8, testVideoPID & 0xFF, 0xF0, 0x00,
+		0x1B, 0xE0 | testVideoPID>>8, testVideoPID & 0xFF, 0xF0, 0x00,
+	}))
+	for i := range frames {
+		pcr := wrapTimestamp(firstPCR + int64(i)*frameTicks)
+		buf.Write(tsPacketBytes(testVideoPID, true, pcr, pesHeader(wrapTimestamp(pcr+ptsDelay), byte(i))))
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path, float64(int64(frames)*frameTicks) / clockRate
+}
+
+func newTestStreamer(now time.Time, items ...service.ChannelItem) (*Streamer, *time.Duration) {
+	channels := stubChannelRepo{channel: service.Channel{ID: 1, Title: "News"}}
+	s := New(service.NewTimelineService(channels, stubChannelItemRepo{items: items}, testEpoch))
+	slept := new(time.Duration)
+	s.now = func() time.Time { return now.Add(*slept) }
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		*slept += d
+		return nil
+	}
+	return s, slept
+}
+
+func item(id uint, path string, length float64) service.ChannelItem {
+	return service.ChannelItem{ID: id, ContentID: id, Content: &service.Content{ID: id, Path: path, Length: length}}
+}
+
+// outputPacket is a decoded packet of the output stream.
+type outputPacket struct {
+	pid     uint16
+	cc      byte
+	pcr     int64
+	pts     int64
+	frame   int
+	version int
+}
+
+func decodeOutput(t *testing.T, b []byte) []outputPacket {
+	t.Helper()
+	if len(b)%packetSize != 0 {
+		t.Fatalf("output is %d bytes, not a whole number of packets", len(b))
+	}
+	var out []outputPacket
+	for off := 0; off < len(b); off += packetSize {
+		p := b[off : off+packetSize]
+		if p[0] != syncByte {
+			t.Fatalf("packet at %d has no sync byte", off)
+		}
+		op := outputPacket{pid: packetPID(p), cc: p[3] & 0x0F, pcr: -1, pts: -1, frame: -1, version: -1}
+		if o := pcrOffset(p); o >= 0 {
+			op.pcr = readPCR(p[o:])
+		}
+		payload := p[payloadOffset(p):]
+		if op.pid == patPID || op.pid == testPMTPID {
+			section := psiSection(payload)
+			if section == nil || crc32MPEG(section) != 0 {
+				t.Fatalf("pid %#x carries a section with a bad crc", op.pid)
+			}
+			op.version = int(section[5]>>1) & 0x1F
+		} else if pts, _ := pesTimestampOffsets(payload); pts >= 0 {
+			op.pts = readTimestamp(payload[pts:])
+			op.frame = int(payload[14])
+		}
+		out = append(out, op)
+	}
+	return out
+}
+
+func TestStreamSplicesProgrammesContinuously(t *testing.T) {
+	first, firstLen := writeTSFile(t, "first.ts", 500000, 100)
+	// The second file's clock wraps part way through.
+	second, secondLen := writeTSFile(t, "second.ts", timestampWrap-50*frameTicks, 100)
+	s, slept := newTestStreamer(testEpoch, item(1, first, firstLen), item(2, second, secondLen))
+
+	var buf bytes.Buffer
+	if err := s.Stream(context.Background(), 1, &buf); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+
+	packets := decodeOutput(t, buf.Bytes())
+	cc := map[uint16]byte{}
+	lastPCR, lastPTS := int64(-1), int64(-1)
+	versions := map[int]bool{}
+	frames := 0
+	for i, p := range packets {
+		if prev, ok := cc[p.pid]; ok && p.cc != (prev+1)&0x0F {
+			t.Fatalf("packet %d: pid %#x continuity counter %d follows %d", i, p.pid, p.cc, prev)
+		}
+		cc[p.pid] = p.cc
+		if p.pcr >= 0 {
+			if p.pcr <= lastPCR {
+				t.Fatalf("packet %d: pcr %d does not follow %d", i, p.pcr, lastPCR)
+			}
+			lastPCR = p.pcr
+		}
+		if p.pts >= 0 {
+			if p.pts <= lastPTS {
+				t.Fatalf("packet %d: pts %d does not follow %d", i, p.pts, lastPTS)
+			}
+			lastPTS = p.pts
+			frames++
+		}
+		if p.pid == patPID {
+			versions[p.version] = true
+		}
+	}
+	if frames != 200 {
+		t.Fatalf("expected 200 frames, got %d", frames)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected a new PAT version per programme, got %v", versions)
+	}
+	// Two 4s programmes less the lead allowed ahead of real time.
+	if *slept < 5*time.Second || *slept > 7*time.Second {
+		t.Fatalf("expected output paced to real time, slept %v", *slept)
+	}
+}
+
+func TestStreamStartsPartWayThroughCurrentProgramme(t *testing.T) {
+	path, length := writeTSFile(t, "show.ts", 0, 100)
+	s, _ := newTestStreamer(testEpoch.Add(2*time.Second), item(1, path, length))
+
+	var buf bytes.Buffer
+	if err := s.Stream(context.Background(), 1, &buf); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+
+	packets := decodeOutput(t, buf.Bytes())
+	if len(packets) < 3 || packets[0].pid != patPID || packets[1].pid != testPMTPID {
+		t.Fatalf("expected the stream to open with PAT and PMT, got %+v", packets[:min(len(packets), 3)])
+	}
+	first := -1
+	for _, p := range packets {
+		if p.frame >= 0 {
+			first = p.frame
+			break
+		}
+	}
+	if first < 40 || first > 60 {
+		t.Fatalf("expected to start half way through the programme, started at frame %d", first)
+	}
+}
+
+func TestStreamSkipsUnplayableProgrammes(t *testing.T) {
+	notTS := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(notTS, bytes.Repeat([]byte("not video "), 100), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	path, length := writeTSFile(t, "show.ts", 0, 10)
+	s, _ := newTestStreamer(testEpoch,
+		item(1, filepath.Join(t.TempDir(), "missing.ts"), 60),
+		item(2, notTS, 60),
+		item(3, path, length),
+	)
+
+	var buf bytes.Buffer
+	if err := s.Stream(context.Background(), 1, &buf); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	frames := 0
+	for _, p := range decodeOutput(t, buf.Bytes()) {
+		if p.frame >= 0 {
+			frames++
+		}
+	}
+	if frames != 10 {
+		t.Fatalf("expected 10 frames from the playable programme, got %d", frames)
+	}
+}
+
+func TestStreamWritesNothingWhenNoProgrammeCanPlay(t *testing.T) {
+	s, _ := newTestStreamer(testEpoch, item(1, filepath.Join(t.TempDir(), "missing.ts"), 60))
+
+	var buf bytes.Buffer
+	err := s.Stream(context.Background(), 1, &buf)
+	if !errors.Is(err, service.ErrNothingScheduled) {
+		t.Fatalf("expected ErrNothingScheduled, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output, got %d bytes", buf.Len())
+	}
+
+	if err := s.Stream(context.Background(), 2, &buf); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	for _, ts := range []int64{0, 1, 90000, timestampWrap - 1} {
+		b := []byte{0x31, 0, 0, 0, 0}
+		writeTimestamp(b, ts)
+		if got := readTimestamp(b); got != ts {
+			t.Fatalf("timestamp %d round-tripped to %d", ts, got)
+		}
+		if b[0]&0xF0 != 0x30 {
+			t.Fatalf("timestamp prefix lost: %#x", b[0])
+		}
+
+		pcr := []byte{0, 0, 0, 0, 0x7F, 0xAB}
+		writePCR(pcr, ts)
+		if got := readPCR(pcr); got != ts {
+			t.Fatalf("pcr %d round-tripped to %d", ts, got)
+		}
+		if pcr[4]&0x7F != 0x7F || pcr[5] != 0xAB {
+			t.Fatal("pcr extension lost")
+		}
+	}
+}
+
+func TestCRC32MPEG(t *testing.T) {
+	if got := crc32MPEG([]byte("123456789")); got != 0x0376E6E7 {
+		t.Fatalf("unexpected crc %#x", got)
+	}
+}
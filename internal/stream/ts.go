@@ -0,0 +1,178 @@
+package stream
+
+const (
+	syncByte       = 0x47
+	packetSize     = 188
+	m2tsPacketSize = 192
+	clockRate      = 90000
+	timestampWrap  = 1 << 33
+	patPID         = 0x0000
+	nullPID        = 0x1FFF
+)
+
+func packetPID(p []byte) uint16 {
+	return uint16(p[1]&0x1F)<<8 | uint16(p[2])
+}
+
+func packetPUSI(p []byte) bool {
+	return p[1]&0x40 != 0
+}
+
+func packetHasPayload(p []byte) bool {
+	return p[3]&0x10 != 0
+}
+
+// payloadOffset returns where the payload of p starts, or len(p) if it has none.
+func payloadOffset(p []byte) int {
+	if !packetHasPayload(p) {
+		return len(p)
+	}
+	if p[3]&0x20 == 0 {
+		return 4
+	}
+	return min(5+int(p[4]), len(p))
+}
+
+// pcrOffset returns where the PCR of p starts, or -1 if p does not carry one.
+func pcrOffset(p []byte) int {
+	if p[3]&0x20 == 0 || p[4] < 7 || p[5]&0x10 == 0 {
+		return -1
+	}
+	return 6
+}
+
+// packetRandomAccess reports whether the adaptation field of p marks a random access point, such
+// as the start of a keyframe.
+func packetRandomAccess(p []byte) bool {
+	return p[3]&0x20 != 0 && p[4] > 0 && p[5]&0x40 != 0
+}
+
+// readPCR returns the 90 kHz base of the PCR at b. The 27 MHz extension is ignored.
+func readPCR(b []byte) int64 {
+	return int64(b[0])<<25 | int64(b[1])<<17 | int64(b[2])<<9 | int64(b[3])<<1 | int64(b[4])>>7
+}
+
+// writePCR replaces the 90 kHz base of the PCR at b, keeping its extension.
+func writePCR(b []byte, base int64) {
+	b[0] = byte(base >> 25)
+	b[1] = byte(base >> 17)
+	b[2] = byte(base >> 9)
+	b[3] = byte(base >> 1)
+	b[4] = byte(base&1)<<7 | b[4]&0x7F
+}
+
+// readTimestamp decodes a 33-bit PES PTS or DTS.
+func readTimestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 | int64(b[1])<<22 | int64(b[2]>>1)<<15 | int64(b[3])<<7 | int64(b[4]>>1)
+}
+
+// writeTimestamp encodes a 33-bit PES PTS or DTS at b, keeping its 4-bit prefix.
+func writeTimestamp(b []byte, ts int64) {
+	b[0] = b[0]&0xF0 | byte(ts>>29)&0x0E | 0x01
+	b[1] = byte(ts >> 22)
+	b[2] = byte(ts>>14)&0xFE | 0x01
+	b[3] = byte(ts >> 7)
+	b[4] = byte(ts<<1) | 0x01
+}
+
+// pesTimestampOffsets returns where the PTS and DTS of a PES header start in payload. Either is -1
+// when absent.
+func pesTimestampOffsets(payload []byte) (int, int) {
+	if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return -1, -1
+	}
+	switch payload[3] {
+	// Streams whose PES packets have no optional header.
+	case 0xBC, 0xBE, 0xBF, 0xF0, 0xF1, 0xF2, 0xF8, 0xFF:
+		return -1, -1
+	}
+	pts, dts := -1, -1
+	switch payload[7] >> 6 {
+	case 0x2:
+		pts = 9
+	case 0x3:
+		pts, dts = 9, 14
+	}
+	if dts >= 0 && len(payload) < dts+5 {
+		dts = -1
+	}
+	if pts >= 0 && len(payload) < pts+5 {
+		pts = -1
+	}
+	return pts, dts
+}
+
+// psiSection returns the complete PSI section starting in payload, or nil when it does not fit in
+// a single packet.
+func psiSection(payload []byte) []byte {
+	if len(payload) < 1 || 1+int(payload[0]) >= len(payload) {
+		return nil
+	}
+	section := payload[1+int(payload[0]):]
+	if len(section) < 3 {
+		return nil
+	}
+	length := int(section[1]&0x0F)<<8 | int(section[2])
+	if length < 9 || 3+length > len(section) {
+		return nil
+	}
+	return section[:3+length]
+}
+
+// patPMTPIDs returns the PMT PIDs listed in a PAT section.
+func patPMTPIDs(section []byte) []uint16 {
+	if len(section) < 12 || section[0] != 0x00 {
+		return nil
+	}
+	var pids []uint16
+	programs := section[8 : len(section)-4]
+	for i := 0; i+4 <= len(programs); i += 4 {
+		if programs[i] == 0 && programs[i+1] == 0 {
+			// Program 0 points at the network information table.
+			continue
+		}
+		pids = append(pids, uint16(programs[i+2]&0x1F)<<8|uint16(programs[i+3]))
+	}
+	return pids
+}
+
+// setSectionVersion rewrites the version number of a PSI section and updates its CRC.
+func setSectionVersion(section []byte, version byte) {
+	section[5] = section[5]&0xC1 | version<<1&0x3E
+	writeSectionCRC(section)
+}
+
+// writeSectionCRC replaces the CRC that ends a PSI section.
+func writeSectionCRC(section []byte) {
+	crc := crc32MPEG(section[:len(section)-4])
+	n := len(section)
+	section[n-4] = byte(crc >> 24)
+	section[n-3] = byte(crc >> 16)
+	section[n-2] = byte(crc >> 8)
+	section[n-1] = byte(crc)
+}
+
+var crcTable = func() [256]uint32 {
+	var t [256]uint32
+	for i := range t {
+		c := uint32(i) << 24
+		for range 8 {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+// crc32MPEG is the CRC-32/MPEG-2 checksum PSI sections end with.
+func crc32MPEG(b []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, v := range b {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^v]
+	}
+	return crc
+}
@@ -0,0 +1,164 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+// assOverridePattern matches the style override blocks in ASS dialogue, such as {\i1}.
+var assOverridePattern = regexp.MustCompile(`\{[^}]*\}`)
+
+// cue is one timed subtitle. Times are offsets into the content.
+type cue struct {
+	start, end time.Duration
+	text       string
+}
+
+// readCues reads the cues of a text subtitle sidecar file.
+func readCues(t service.Track) ([]cue, error) {
+	data, err := os.ReadFile(t.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read subtitle file: %w", err)
+	}
+	text := strings.TrimPrefix(string(data), "\uFEFF")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if t.Codec == "ass" {
+		return parseASS(text), nil
+	}
+	return parseCueBlocks(text), nil
+}
+
+// parseCueBlocks reads SubRip and WebVTT files, whose cues are blocks of an optional identifier, a
+// timing line and text, separated by blank lines. Blocks without a timing line, such as a WebVTT
+// header or note, are skipped.
+func parseCueBlocks(text string) []cue {
+	var cues []cue
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		for i, line := range lines {
+			if !strings.Contains(line, "-->") {
+				continue
+			}
+			fields := strings.Fields(line)
+			if len(fields) < 3 || fields[1] != "-->" {
+				break
+			}
+			start, ok1 := parseCueTime(fields[0])
+			end, ok2 := parseCueTime(fields[2])
+			if ok1 && ok2 && end > start {
+				cues = append(cues, cue{start: start, end: end, text: strings.Join(lines[i+1:], "\n")})
+			}
+			break
+		}
+	}
+	return cues
+}
+
+// parseASS reads the dialogue events of an ASS or SSA file, dropping style overrides.
+func parseASS(text string) []cue {
+	format := []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+	var cues []cue
+	for _, line := range strings.Split(text, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Format":
+			fields := strings.Split(value, ",")
+			for i, f := range fields {
+				fields[i] = strings.ToLower(strings.TrimSpace(f))
+			}
+			format = fields
+		case "Dialogue":
+			fields := strings.SplitN(value, ",", len(format))
+			if len(fields) < len(format) {
+				continue
+			}
+			var c cue
+			var okStart, okEnd bool
+			for i, name := range format {
+				switch name {
+				case "start":
+					c.start, okStart = parseCueTime(strings.TrimSpace(fields[i]))
+				case "end":
+					c.end, okEnd = parseCueTime(strings.TrimSpace(fields[i]))
+				case "text":
+					c.text = assText(fields[i])
+				}
+			}
+			if okStart && okEnd && c.end > c.start && c.text != "" {
+				cues = append(cues, c)
+			}
+		}
+	}
+	return cues
+}
+
+func assText(s string) string {
+	s = assOverridePattern.ReplaceAllString(s, "")
+	s = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(s)
+	return strings.TrimSpace(s)
+}
+
+// parseCueTime reads h:mm:ss.fff, mm:ss.fff and the SubRip form h:mm:ss,fff.
+func parseCueTime(s string) (time.Duration, bool) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	secs, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	d := time.Duration(math.Round(secs*1000)) * time.Millisecond
+	for i, unit := range []time.Duration{time.Minute, time.Hour}[:len(parts)-1] {
+		n, err := strconv.Atoi(parts[len(parts)-2-i])
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, true
+}
+
+// writeWebVTT writes the cues overlapping [from, to) as a WebVTT segment. Cue times stay offsets
+// into the content, and the timestamp map ties the content's start to clock, its timestamp in the
+// segment's media.
+func writeWebVTT(w io.Writer, cues []cue, from, to time.Duration, clock int64) error {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	fmt.Fprintf(&b, "X-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000\n", clock)
+	for _, c := range cues {
+		if c.end <= from || c.start >= to {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s --> %s\n%s\n", vttTime(c.start), vttTime(c.end), vttText(c.text))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// vttText keeps cue text from ending the cue early or reading as a timing line.
+func vttText(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, strings.ReplaceAll(line, "-->", "->"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func vttTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/service"
+)
+
+func writeSubtitleFile(t *testing.T, name, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path
+}
+
+func TestReadCues(t *testing.T) {
+	want := []cue{
+		{start: 1500 * time.Millisecond, end: 3 * time.Second, text: "Hello"},
+		{start: time.Hour + 2*time.Second, end: time.Hour + 4250*time.Millisecond, text: "Two\nlines"},
+	}
+	tests := []struct {
+		name, codec, text string
+	}{
+		{
+			name:  "subrip",
+			codec: "subrip",
+			text: "\uFEFF1\r\n00:00:01,500 --> 00:00:03,000\r\nHello\r\n\r\n" +
+				"2\r\n01:00:02,000 --> 01:00:04,250\r\nTwo\r\nlines\r\n",
+		},
+		{
+			name:  "webvtt",
+			codec: "webvtt",
+			text: "WEBVTT\n\nNOTE a comment\n\n00:01.500 --> 00:03.000 align:start\nHello\n\n" +
+				"intro\n01:00:02.000 --> 01:00:04.250\nTwo\nlines\n",
+		},
+		{
+			name:  "ass",
+			codec: "ass",
+			text: "[Script Info]\nTitle: Test\n\n[Events]\n" +
+				"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+				"Dialogue: 0,0:00:01.50,0:00:03.00,Default,,0,0,0,,{\\i1}Hello{\\i0}\n" +
+				"Comment: 0,0:00:02.00,0:00:03.00,Default,,0,0,0,,skipped\n" +
+				"Dialogue: 0,1:00:02.00,1:00:04.25,Default,,0,0,0,,Two\\Nlines\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeSubtitleFile(t, "film.sub", tc.text)
+			cues, err := readCues(service.Track{Kind: service.TrackSubtitle, Codec: tc.codec, Path: path})
+			if err != nil {
+				t.Fatalf("read cues: %v", err)
+			}
+			if !slices.Equal(cues, want) {
+				t.Fatalf("expected %+v, got %+v", want, cues)
+			}
+		})
+	}
+}
+
+func TestWriteWebVTTKeepsOverlappingCues(t *testing.T) {
+	cues := []cue{
+		{start: time.Second, end: 2 * time.Second, text: "before"},
+		{start: 5 * time.Second, end: 7 * time.Second, text: "spans --> start"},
+		{start: 8 * time.Second, end: 9 * time.Second, text: "inside"},
+		{start: 12 * time.Second, end: 13 * time.Second, text: "after"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeWebVTT(&buf, cues, 6*time.Second, 12*time.Second, 900000); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	want := "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\n" +
+		"\n00:00:05.000 --> 00:00:07.000\nspans -> start\n" +
+		"\n00:00:08.000 --> 00:00:09.000\ninside\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected segment:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,166 @@
+// Package transcode converts media files into MPEG transport streams in broadcast-safe codecs, for
+// content the stream package cannot remux as it is.
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxStderr bounds how much of ffmpeg's error output is kept for error messages.
+const maxStderr = 4 << 10
+
+// Profile is a named set of output settings.
+type Profile struct {
+	Name string
+	// Width and Height scale the video. Zero keeps the source resolution.
+	Width  int
+	Height int
+	// VideoCodec and AudioCodec name ffmpeg encoders, such as libx264 and aac, or copy.
+	VideoCodec string
+	AudioCodec string
+	// VideoBitrate and AudioBitrate are in kbit/s. Zero leaves the encoder's default.
+	VideoBitrate int
+	AudioBitrate int
+	// AudioTrack picks which of the input's audio tracks is kept, counting from zero. It is set per
+	// programme rather than configured.
+	AudioTrack int
+}
+
+// Transcoder converts the media file at input into an MPEG transport stream, starting offset into
+// the file. Output timestamps count from the start of the file rather than from offset, so streams
+// opened at different offsets line up. Closing the reader stops the transcode.
+type Transcoder interface {
+	Transcode(ctx context.Context, input string, offset time.Duration, profile Profile) (io.ReadCloser, error)
+}
+
+// FFmpeg transcodes by running an ffmpeg binary.
+type FFmpeg struct {
+	path string
+}
+
+// NewFFmpeg returns a transcoder running the ffmpeg binary at path, which is looked up in PATH
+// when it has no directory.
+func NewFFmpeg(path string) *FFmpeg {
+	return &FFmpeg{path: path}
+}
+
+func (f *FFmpeg) Transcode(ctx context.Context, input string, offset time.Duration, profile Profile) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, f.path, ffmpegArgs(input, offset, profile)...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg stdout: %w", err)
+	}
+	stderr := &limitedBuffer{max: maxStderr}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start ffmpeg: %w", err)
+	}
+	return &process{cmd: cmd, stdout: stdout, stderr: stderr}, nil
+}
+
+// ffmpegArgs builds the ffmpeg command line. Seeking before -i is fast and lands on the keyframe
+// at or before offset.
+func ffmpegArgs(input string, offset time.Duration, p Profile) []string {
+	seconds := strconv.FormatFloat(offset.Seconds(), 'f', 3, 64)
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin"}
+	if offset > 0 {
+		args = append(args, "-ss", seconds)
+	}
+	args = append(args, "-i", input, "-map", "0:v:0?", "-map", fmt.Sprintf("0:a:%d?", p.AudioTrack), "-c:v", p.VideoCodec)
+	if p.VideoCodec != "copy" {
+		if p.Width > 0 && p.Height > 0 {
+			args = append(args, "-vf", fmt.Sprintf("scale=%d:%d", p.Width, p.Height))
+		}
+		if p.VideoBitrate > 0 {
+			args = append(args, "-b:v", fmt.Sprintf("%dk", p.VideoBitrate))
+		}
+	}
+	args = append(args, "-c:a", p.AudioCodec)
+	if p.AudioCodec != "copy" && p.AudioBitrate > 0 {
+		args = append(args, "-b:a", fmt.Sprintf("%dk", p.AudioBitrate))
+	}
+	if offset > 0 {
+		args = append(args, "-output_ts_offset", seconds)
+	}
+	return append(args, "-f", "mpegts", "pipe:1")
+}
+
+// process is a running ffmpeg whose output is being read.
+type process struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr *limitedBuffer
+	once   sync.Once
+	err    error
+}
+
+// Read returns ffmpeg's output. When ffmpeg fails, the error it reported replaces io.EOF.
+func (p *process) Read(b []byte) (int, error) {
+	n, err := p.stdout.Read(b)
+	if errors.Is(err, io.EOF) {
+		if waitErr := p.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close stops ffmpeg if it is still running.
+func (p *process) Close() error {
+	if p.cmd.ProcessState == nil {
+		_ = p.cmd.Process.Kill()
+	}
+	_ = p.stdout.Close()
+	if err := p.wait(); err != nil && !killed(err) {
+		return err
+	}
+	return nil
+}
+
+func (p *process) wait() error {
+	p.once.Do(func() {
+		if err := p.cmd.Wait(); err != nil {
+			if msg := bytes.TrimSpace(p.stderr.Bytes()); len(msg) > 0 {
+				err = fmt.Errorf("%w: %s", err, msg)
+			}
+			p.err = fmt.Errorf("ffmpeg: %w", err)
+		}
+	})
+	return p.err
+}
+
+// killed reports whether err is from a process that was stopped by a signal.
+func killed(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == -1
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest.
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
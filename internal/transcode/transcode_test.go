@@ -0,0 +1,140 @@
+package transcode
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFFmpegArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		offset  time.Duration
+		profile Profile
+		want    []string
+	}{
+		{
+			name:   "scaled from the start",
+			offset: 0,
+			profile: Profile{
+				Width: 1280, Height: 720, VideoCodec: "libx264", VideoBitrate: 3000, AudioCodec: "aac", AudioBitrate: 128,
+			},
+			want: []string{
+				"-hide_banner", "-loglevel", "error", "-nostdin",
+				"-i", "in.mkv", "-map", "0:v:0?", "-map", "0:a:0?",
+				"-c:v", "libx264", "-vf", "scale=1280:720", "-b:v", "3000k", "-c:a", "aac", "-b:a", "128k",
+				"-f", "mpegts", "pipe:1",
+			},
+		},
+		{
+			name:    "copy part way in",
+			offset:  90500 * time.Millisecond,
+			profile: Profile{Width: 1280, Height: 720, VideoCodec: "copy", VideoBitrate: 3000, AudioCodec: "aac"},
+			want: []string{
+				"-hide_banner", "-loglevel", "error", "-nostdin", "-ss", "90.500",
+				"-i", "in.mkv", "-map", "0:v:0?", "-map", "0:a:0?",
+				"-c:v", "copy", "-c:a", "aac", "-output_ts_offset", "90.500",
+				"-f", "mpegts", "pipe:1",
+			},
+		},
+		{
+			name:    "second audio track",
+			offset:  0,
+			profile: Profile{VideoCodec: "copy", AudioCodec: "copy", AudioTrack: 1},
+			want: []string{
+				"-hide_banner", "-loglevel", "error", "-nostdin",
+				"-i", "in.mkv", "-map", "0:v:0?", "-map", "0:a:1?",
+				"-c:v", "copy", "-c:a", "copy",
+				"-f", "mpegts", "pipe:1",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ffmpegArgs("in.mkv", tc.offset, tc.profile)
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("unexpected args:\ngot  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// writeScript writes an executable shell script standing in for ffmpeg.
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o700); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	return path
+}
+
+func TestFFmpegTranscodeReadsOutput(t *testing.T) {
+	f := NewFFmpeg(writeScript(t, "printf 'transcoded'\n"))
+
+	rc, err := f.Transcode(context.Background(), "in.mkv", 0, Profile{VideoCodec: "copy", AudioCodec: "copy"})
+	if err != nil {
+		t.Fatalf("transcode: %v", err)
+	}
+	out, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(out) != "transcoded" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+}
+
+func TestFFmpegTranscodeReportsFailure(t *testing.T) {
+	f := NewFFmpeg(writeScript(t, "echo 'in.mkv: No such file or directory' >&2\nexit 1\n"))
+
+	rc, err := f.Transcode(context.Background(), "in.mkv", 0, Profile{VideoCodec: "copy", AudioCodec: "copy"})
+	if err != nil {
+		t.Fatalf("transcode: %v", err)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	_, err = io.ReadAll(rc)
+	if err == nil || !strings.Contains(err.Error(), "No such file or directory") {
+		t.Fatalf("expected ffmpeg's error output, got %v", err)
+	}
+}
+
+func TestFFmpegTranscodeCloseStopsProcess(t *testing.T) {
+	f := NewFFmpeg(writeScript(t, "exec sleep 30\n"))
+
+	rc, err := f.Transcode(context.Background(), "in.mkv", 0, Profile{VideoCodec: "copy", AudioCodec: "copy"})
+	if err != nil {
+		t.Fatalf("transcode: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- rc.Close()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("close did not stop ffmpeg")
+	}
+}
+
+func TestFFmpegTranscodeMissingBinary(t *testing.T) {
+	f := NewFFmpeg(filepath.Join(t.TempDir(), "ffmpeg"))
+
+	if _, err := f.Transcode(context.Background(), "in.mkv", 0, Profile{}); err == nil {
+		t.Fatal("expected error for a missing binary")
+	}
+}
@@ -0,0 +1,167 @@
+// Package transcodetest provides a fake transcoder for tests of packages that stream transcoded
+// channels.
+package transcodetest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/transcode"
+)
+
+const (
+	// PMTPID and VideoPID are the PIDs of the fake's PMT and video stream.
+	PMTPID   = 0x1000
+	VideoPID = 0x100
+	// FrameRate is how many video frames the fake emits per second of input.
+	FrameRate = 25
+
+	packetSize = 188
+	clockRate  = 90000
+	// fakePTSDelay is how far each frame's PTS runs ahead of the PCR of its packet.
+	fakePTSDelay = clockRate / 10
+)
+
+// Call records the arguments of one Transcode call on a Fake.
+type Call struct {
+	Input   string
+	Offset  time.Duration
+	Profile transcode.Profile
+}
+
+// Fake is a transcode.Transcoder that emits a synthetic transport stream instead of running ffmpeg. The
+// stream has a PAT and PMT once a second and one packet per video frame, each carrying a PCR and a
+// PES header whose PTS follows the PCR. The low byte of the frame number, counted from the start
+// of the input, follows the PES header.
+type Fake struct {
+	// Length is how long the synthetic input is. Zero makes the output endless.
+	Length time.Duration
+	// Err, when set, is returned by every call.
+	Err error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (f *Fake) Transcode(_ context.Context, input string, offset time.Duration, profile transcode.Profile) (io.ReadCloser, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Input: input, Offset: offset, Profile: profile})
+	f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	first := int64(offset * FrameRate / time.Second)
+	r := &fakeReader{start: first, frame: first, end: -1, cc: map[uint16]byte{}}
+	if f.Length > 0 {
+		r.end = int64(f.Length * FrameRate / time.Second)
+	}
+	return r, nil
+}
+
+// Calls returns the calls made so far.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// fakeReader generates the fake stream a packet at a time.
+type fakeReader struct {
+	start   int64
+	frame   int64
+	end     int64
+	pending []byte
+	cc      map[uint16]byte
+	closed  bool
+}
+
+func (r *fakeReader) Read(b []byte) (int, error) {
+	if r.closed {
+		return 0, errors.New("read from closed transcode")
+	}
+	n := 0
+	for n < len(b) {
+		if len(r.pending) == 0 && !r.fill() {
+			if n == 0 {
+				return 0, io.EOF
+			}
+			break
+		}
+		c := copy(b[n:], r.pending)
+		r.pending = r.pending[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (r *fakeReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+// fill queues the packets for the next frame, preceded by the PAT and PMT every second.
+func (r *fakeReader) fill() bool {
+	if r.end >= 0 && r.frame >= r.end {
+		return false
+	}
+	if r.frame == r.start || r.frame%FrameRate == 0 {
+		r.pending = append(r.pending, r.packet(0, fakePSI(0x00, []byte{0x00, 0x01, 0xE0 | PMTPID>>8, PMTPID & 0xFF}), -1)...)
+		r.pending = append(r.pending, r.packet(PMTPID, fakePSI(0x02, []byte{
+			0xE0 | VideoPID>>8, VideoPID & 0xFF, 0xF0, 0x00,
+			0x1B, 0xE0 | VideoPID>>8, VideoPID & 0xFF, 0xF0, 0x00,
+		}), -1)...)
+	}
+
+	pcr := r.frame * clockRate / FrameRate
+	pes := []byte{0x00, 0x00, 0x01, 0xE0, 0x00, 0x00, 0x80, 0x80, 0x05, 0, 0, 0, 0, 0, byte(r.frame)}
+	pts := pcr + fakePTSDelay
+	pes[9] = 0x21 | byte(pts>>29)&0x0E
+	pes[10] = byte(pts >> 22)
+	pes[11] = byte(pts>>14) | 0x01
+	pes[12] = byte(pts >> 7)
+	pes[13] = byte(pts<<1) | 0x01
+	r.pending = append(r.pending, r.packet(VideoPID, pes, pcr)...)
+	r.frame++
+	return true
+}
+
+// packet builds one packet starting a payload, padded with adaptation field stuffing. A pcr of -1
+// leaves the PCR out.
+func (r *fakeReader) packet(pid uint16, payload []byte, pcr int64) []byte {
+	p := []byte{0x47, 0x40 | byte(pid>>8)&0x1F, byte(pid), 0x30 | r.cc[pid]}
+	r.cc[pid] = (r.cc[pid] + 1) & 0x0F
+
+	af := []byte{0x00}
+	if pcr >= 0 {
+		af = []byte{0x10, byte(pcr >> 25), byte(pcr >> 17), byte(pcr >> 9), byte(pcr >> 1), byte(pcr<<7) | 0x7E, 0}
+	}
+	for len(af)+1 < packetSize-4-len(payload) {
+		af = append(af, 0xFF)
+	}
+	p = append(p, byte(len(af)))
+	p = append(p, af...)
+	return append(p, payload...)
+}
+
+// fakePSI returns a pointer field and a section with the given table ID and body.
+func fakePSI(tableID byte, body []byte) []byte {
+	length := len(body) + 5 + 4
+	section := append([]byte{tableID, 0xB0 | byte(length>>8), byte(length), 0x00, 0x01, 0xC1, 0x00, 0x00}, body...)
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range section {
+		crc ^= uint32(b) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	section = append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+	return append([]byte{0x00}, section...)
+}
@@ -0,0 +1,66 @@
+package transcodetest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/iamseth/tiny-headend/internal/transcode"
+)
+
+func TestFakeEmitsFramesFromOffset(t *testing.T) {
+	f := &Fake{Length: 4 * time.Second}
+	profile := transcode.Profile{Name: "720p", VideoCodec: "libx264", AudioCodec: "aac"}
+
+	rc, err := f.Transcode(context.Background(), "in.mkv", 2*time.Second, profile)
+	if err != nil {
+		t.Fatalf("transcode: %v", err)
+	}
+	out, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(out)%packetSize != 0 {
+		t.Fatalf("output is %d bytes, not a whole number of packets", len(out))
+	}
+
+	var frames []int
+	var psi int
+	for off := 0; off < len(out); off += packetSize {
+		p := out[off : off+packetSize]
+		if p[0] != 0x47 {
+			t.Fatalf("packet at %d has no sync byte", off)
+		}
+		switch pid := uint16(p[1]&0x1F)<<8 | uint16(p[2]); pid {
+		case 0, PMTPID:
+			psi++
+		case VideoPID:
+			frames = append(frames, int(p[len(p)-1]))
+		default:
+			t.Fatalf("unexpected pid %#x", pid)
+		}
+	}
+	if len(frames) != 2*FrameRate || frames[0] != 2*FrameRate || frames[len(frames)-1] != 4*FrameRate-1 {
+		t.Fatalf("expected frames 50-99, got %d frames from %d", len(frames), frames[0])
+	}
+	// One PAT and PMT at the start and one pair a second after.
+	if psi != 4 {
+		t.Fatalf("expected 4 psi packets, got %d", psi)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 1 || calls[0].Input != "in.mkv" || calls[0].Offset != 2*time.Second || calls[0].Profile != profile {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+}
+
+func TestFakeReturnsErr(t *testing.T) {
+	want := errors.New("no encoder")
+	f := &Fake{Err: want}
+
+	if _, err := f.Transcode(context.Background(), "in.mkv", 0, transcode.Profile{}); !errors.Is(err, want) {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+}
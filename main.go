@@ -1,6 +1,11 @@
 package main
 
-import "github.com/iamseth/tiny-headend/cmd"
+import (
+	// Embed the timezone database so channel timezones resolve on hosts without one.
+	_ "time/tzdata"
+
+	"github.com/iamseth/tiny-headend/cmd"
+)
 
 func main() {
 	cmd.Execute()